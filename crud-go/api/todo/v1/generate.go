@@ -0,0 +1,5 @@
+// Package todov1 holds the messages and the gRPC service of the todo
+// API, generated from todo.proto.
+package todov1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative todo.proto
@@ -2,20 +2,26 @@ package main
 
 import (
 	"crud/internal/config"
-	"crud/internal/store"
-	"crud/internal/transport"
 	"fmt"
 	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
+
+	// The scratch image has no zoneinfo, and due dates carry a timezone.
+	_ "time/tzdata"
 )
 
+const usage = `usage:
+  crud [serve]                    start the HTTP server
+  crud migrate up                 apply all pending migrations
+  crud migrate down [N]           roll back N migrations (default 1)
+  crud migrate to N               migrate up or down to version N
+  crud migrate status             print the schema version`
+
 func main() {
 	appConfig, err := config.ParseConfig()
 	if err != nil {
 		slog.Error("Failed init app configuration", "error", err.Error())
-		return
+		os.Exit(1)
 	}
 
 	logLevel := new(slog.LevelVar)
@@ -37,26 +43,23 @@ func main() {
 
 	logger.Info("Logger configured")
 
-	store := store.NewPostgresStore(appConfig.Store)
-
-	if err := store.Open(); err != nil {
-		logger.Error("Error opening database connection", "error", err.Error())
-		return
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
 
-	errChan := make(chan error)
-
-	// Starting HTTP server
-	go func() {
-		httpServer := transport.NewHttpServer(logger, store, appConfig.Http, appConfig.Service)
-		errChan <- httpServer.Start()
-	}()
-
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-		errChan <- fmt.Errorf("%s", <-c)
-	}()
+	switch command {
+	case "serve":
+		err = serve(logger, appConfig)
+	case "migrate":
+		err = migrate(logger, appConfig, args)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
 
-	logger.Warn("Server closed", "error", (<-errChan).Error())
+	if err != nil {
+		logger.Error("Command failed", "command", command, "error", err.Error())
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crud/internal/config"
+	"crud/internal/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+func migrate(logger *slog.Logger, appConfig *config.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate needs a subcommand")
+	}
+
+	store, err := store.New(appConfig.Store)
+	if err != nil {
+		return fmt.Errorf("create store: %w", err)
+	}
+
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open database connection: %w", err)
+	}
+	defer store.Close()
+
+	migrator := store.Migrator()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("bad number of steps %q", args[1])
+			}
+		}
+		err = migrator.Down(steps)
+	case "to":
+		if len(args) < 2 {
+			return errors.New("migrate to needs a version")
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("bad version %q", args[1])
+		}
+		err = migrator.To(uint(version))
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", args[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Schema version",
+		"current", status.Current,
+		"latest", status.Latest,
+		"dirty", status.Dirty,
+		"pending", status.Pending,
+	)
+
+	return nil
+}
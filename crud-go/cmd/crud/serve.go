@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crud/internal/config"
+	"crud/internal/service"
+	"crud/internal/store"
+	"crud/internal/transport"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+func serve(logger *slog.Logger, appConfig *config.AppConfig) error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	driverStore, err := store.New(appConfig.Store)
+	if err != nil {
+		return fmt.Errorf("create store: %w", err)
+	}
+	store := store.Instrument(driverStore, appConfig.Store.Driver, registry)
+
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("open database connection: %w", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.Error("Failed close database connection", "error", err.Error())
+		}
+	}()
+
+	if appConfig.AutoMigrate {
+		logger.Info("Applying migrations")
+
+		if err := store.Migrator().Up(); err != nil {
+			return fmt.Errorf("auto migrate: %w", err)
+		}
+	}
+
+	// The HTTP and the gRPC API share the service.
+	todos := service.NewTodoService(logger, store, appConfig.Service)
+
+	httpServer := transport.NewHttpServer(logger, store, todos, appConfig.Http, registry)
+
+	var grpcServer *transport.GrpcServer
+	if appConfig.Grpc.BindAddress != "" {
+		grpcServer = transport.NewGrpcServer(logger, todos, appConfig.Grpc, registry)
+		grpcServer.ShareRateLimits(httpServer)
+	}
+
+	if appConfig.JwtSecret != "" {
+		httpServer.RequireAuth([]byte(appConfig.JwtSecret))
+		if grpcServer != nil {
+			grpcServer.RequireAuth([]byte(appConfig.JwtSecret))
+		}
+	} else {
+		logger.Warn("No JWT secret set, requests are not authenticated and todos are shared by everyone")
+	}
+
+	notifier, err := service.NewNotifier(logger, appConfig.Service)
+	if err != nil {
+		return fmt.Errorf("create reminder notifier: %w", err)
+	}
+
+	// The purge, the webhook deliveries and the reminders stop before
+	// the store is closed.
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	background.Add(3)
+	go func() {
+		defer background.Done()
+		service.NewPurger(logger, store, appConfig.Service).Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		service.NewDispatcher(logger, store, appConfig.Service).Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		service.NewReminderScheduler(logger, store, appConfig.Service, notifier).Run(backgroundCtx)
+	}()
+	defer func() {
+		stopBackground()
+		background.Wait()
+	}()
+
+	errChan := make(chan error, 1)
+
+	// Starting HTTP server
+	go func() {
+		errChan <- httpServer.Start()
+	}()
+
+	grpcErrChan := make(chan error, 1)
+	if grpcServer != nil {
+		go func() {
+			grpcErrChan <- grpcServer.Start()
+		}()
+	}
+
+	// The admin server stays up while the API drains, so the drain can
+	// be watched.
+	var adminServer *transport.AdminServer
+	adminErrChan := make(chan error, 1)
+	if appConfig.Http.AdminBindAddress != "" {
+		adminServer = transport.NewAdminServer(logger, appConfig.Http, registry)
+		go func() {
+			adminErrChan <- adminServer.Start()
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("http server: %w", err)
+	case err := <-grpcErrChan:
+		return fmt.Errorf("grpc server: %w", err)
+	case err := <-adminErrChan:
+		return fmt.Errorf("admin server: %w", err)
+	case sig := <-signals:
+		logger.Warn("Shutting down", "signal", sig.String())
+	}
+
+	// A second signal kills the process without waiting for the drain.
+	signal.Stop(signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), appConfig.Http.ShutdownDelay+appConfig.Http.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Error("Requests did not drain in time", "error", err.Error())
+	}
+
+	if err := <-errChan; err != nil {
+		logger.Error("HTTP server failed", "error", err.Error())
+	}
+
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			logger.Error("gRPC calls did not drain in time", "error", err.Error())
+		}
+		if err := <-grpcErrChan; err != nil {
+			logger.Error("gRPC server failed", "error", err.Error())
+		}
+	}
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Error("Admin server did not drain in time", "error", err.Error())
+		}
+		if err := <-adminErrChan; err != nil {
+			logger.Error("Admin server failed", "error", err.Error())
+		}
+	}
+
+	logger.Warn("Server closed")
+
+	return nil
+}
@@ -15,15 +15,24 @@ type AppConfig struct {
 	Service  *service.Config
 	Store    *store.Config
 	Http     *transport.HttpConfig
+	Grpc     *transport.GrpcConfig
 	LogLevel string
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool
+	// JwtSecret is the secret the auth service signs its tokens with.
+	// Requests are not authenticated without one.
+	JwtSecret string
 }
 
 func NewAppConfig() *AppConfig {
 	return &AppConfig{
-		Service:  service.NewConfig(),
-		Store:    store.NewConfig(),
-		Http:     transport.NewHttpConfig(),
-		LogLevel: "debug",
+		Service:     service.NewConfig(),
+		Store:       store.NewConfig(),
+		Http:        transport.NewHttpConfig(),
+		Grpc:        transport.NewGrpcConfig(),
+		LogLevel:    "debug",
+		AutoMigrate: false,
+		JwtSecret:   "",
 	}
 }
 
@@ -31,7 +40,10 @@ func createDefaultConfig() error {
 	viper.SetDefault("Service", service.NewConfig())
 	viper.SetDefault("Store", store.NewConfig())
 	viper.SetDefault("Http", transport.NewHttpConfig())
+	viper.SetDefault("Grpc", transport.NewGrpcConfig())
 	viper.SetDefault("LogLevel", "debug")
+	viper.SetDefault("AutoMigrate", false)
+	viper.SetDefault("JwtSecret", "")
 
 	viper.SetConfigType("yaml")
 
@@ -48,9 +60,32 @@ func importConfig() error {
 	viper.AddConfigPath("./config")
 	viper.SetConfigName("config.yaml")
 
+	viper.BindEnv("Store.Driver", "STORE_DRIVER")
 	viper.BindEnv("Store.DatabaseUrl", "DATABASE_URL")
 	viper.BindEnv("Http.BindAddress", "HTTP_BIND_ADDRESS")
+	viper.BindEnv("Http.AdminBindAddress", "HTTP_ADMIN_BIND_ADDRESS")
+	viper.BindEnv("Http.RequestTimeout", "HTTP_REQUEST_TIMEOUT")
+	viper.BindEnv("Http.ReadRateLimit", "HTTP_READ_RATE_LIMIT")
+	viper.BindEnv("Http.WriteRateLimit", "HTTP_WRITE_RATE_LIMIT")
+	viper.BindEnv("Http.TrustedProxies", "HTTP_TRUSTED_PROXIES")
+	viper.BindEnv("Http.MaxBodySize", "HTTP_MAX_BODY_SIZE")
+	viper.BindEnv("Http.HealthCheckTimeout", "HTTP_HEALTH_CHECK_TIMEOUT")
+	viper.BindEnv("Http.ShutdownDelay", "HTTP_SHUTDOWN_DELAY")
+	viper.BindEnv("Http.ShutdownGracePeriod", "HTTP_SHUTDOWN_GRACE_PERIOD")
+	viper.BindEnv("Grpc.BindAddress", "GRPC_BIND_ADDRESS")
+	viper.BindEnv("Grpc.RequestTimeout", "GRPC_REQUEST_TIMEOUT")
+	viper.BindEnv("Grpc.MaxMessageSize", "GRPC_MAX_MESSAGE_SIZE")
+	viper.BindEnv("Service.TrashRetention", "TRASH_RETENTION")
+	viper.BindEnv("Service.ReminderPollInterval", "REMINDER_POLL_INTERVAL")
+	viper.BindEnv("Service.ReminderNotifier", "REMINDER_NOTIFIER")
+	viper.BindEnv("Service.ReminderWebhookURL", "REMINDER_WEBHOOK_URL")
+	viper.BindEnv("Service.ReminderWebhookSecret", "REMINDER_WEBHOOK_SECRET")
+	viper.BindEnv("Service.ReminderSMTPAddress", "REMINDER_SMTP_ADDRESS")
+	viper.BindEnv("Service.ReminderSMTPUsername", "REMINDER_SMTP_USERNAME")
+	viper.BindEnv("Service.ReminderSMTPPassword", "REMINDER_SMTP_PASSWORD")
 	viper.BindEnv("LogLevel", "LOG_LEVEL")
+	viper.BindEnv("AutoMigrate", "AUTO_MIGRATE")
+	viper.BindEnv("JwtSecret", "JWT_SECRET")
 
 	if err := viper.ReadInConfig(); err != nil {
 		slog.Warn("Config file does not exist, creating default config...")
@@ -72,12 +107,13 @@ func ParseConfig() (*AppConfig, error) {
 		return nil, err
 	}
 
-	var c AppConfig
-	err := viper.Unmarshal(&c)
+	// Settings missing from the file keep their defaults.
+	c := NewAppConfig()
+	err := viper.Unmarshal(c)
 	if err != nil {
 		slog.Error("Failed decode loaded config file", "error", err.Error())
 		return nil, err
 	}
 
-	return &c, nil
+	return c, nil
 }
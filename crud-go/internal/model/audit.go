@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditToggle  AuditAction = "toggle"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+)
+
+// AuditEntry records one change of a todo: its state before and after,
+// who made the change and in which request. Before is nil for creates.
+type AuditEntry struct {
+	ID        int64       `json:"id"`
+	TodoID    ID          `json:"todoId"`
+	Action    AuditAction `json:"action"`
+	Before    *Todo       `json:"before,omitempty"`
+	After     *Todo       `json:"after,omitempty"`
+	Actor     string      `json:"actor"`
+	RequestID string      `json:"requestId,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// UserID is the owner of the todo changed.
+func (e AuditEntry) UserID() UserID {
+	if e.After != nil {
+		return e.After.UserID
+	}
+	if e.Before != nil {
+		return e.Before.UserID
+	}
+	return 0
+}
+
+// AuditFilter selects audit entries, oldest first. Cursor is an opaque
+// value taken from a previous AuditPage.
+type AuditFilter struct {
+	TodoID        *ID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// AfterID skips the entries up to and including this id.
+	AfterID int64
+
+	Cursor string
+	Limit  int
+}
+
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
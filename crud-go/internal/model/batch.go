@@ -0,0 +1,29 @@
+package model
+
+type BatchOpType string
+
+const (
+	BatchCreate BatchOpType = "create"
+	BatchUpdate BatchOpType = "update"
+	BatchToggle BatchOpType = "toggle"
+	BatchDelete BatchOpType = "delete"
+)
+
+// BatchOp is one operation of a batch. Todo is used by creates, Patch
+// by updates, Cascade and IfVersion by toggles and deletes and ID by all
+// but creates.
+type BatchOp struct {
+	Type      BatchOpType
+	ID        ID
+	Todo      Todo
+	Patch     TodoPatch
+	Cascade   bool
+	IfVersion *int64
+}
+
+// BatchResult is the outcome of one BatchOp: the todo it returned or
+// the error it failed with.
+type BatchResult struct {
+	Todo *Todo
+	Err  error
+}
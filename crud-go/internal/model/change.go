@@ -0,0 +1,78 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change is an event of the change feed. Its ID is that of the audit
+// entry it comes from, so a client can resume after the last one it saw.
+type Change struct {
+	ID        int64       `json:"id"`
+	Type      ChangeType  `json:"type"`
+	Action    AuditAction `json:"action"`
+	Todo      Todo        `json:"todo"`
+	Actor     string      `json:"actor"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// ChangeOf makes the change event of an audit entry. A restored todo
+// appears again, so it is reported as created.
+func ChangeOf(entry AuditEntry) Change {
+	change := Change{
+		ID:        entry.ID,
+		Type:      ChangeUpdated,
+		Action:    entry.Action,
+		Actor:     entry.Actor,
+		CreatedAt: entry.CreatedAt,
+	}
+
+	switch entry.Action {
+	case AuditCreate, AuditRestore:
+		change.Type = ChangeCreated
+	case AuditDelete:
+		change.Type = ChangeDeleted
+	}
+
+	if entry.After != nil {
+		change.Todo = *entry.After
+	} else if entry.Before != nil {
+		change.Todo = *entry.Before
+	}
+
+	return change
+}
+
+// ChangeFilter selects the changes of a feed. Empty fields match
+// everything.
+type ChangeFilter struct {
+	TodoIDs []ID
+	Types   []ChangeType
+	// Tags the todo has to have all of.
+	Tags []string
+	// AfterID replays the changes since this one before the live ones.
+	AfterID int64
+}
+
+func (f ChangeFilter) Match(change Change) bool {
+	if len(f.TodoIDs) > 0 && !slices.Contains(f.TodoIDs, change.Todo.ID) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, change.Type) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(change.Todo.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
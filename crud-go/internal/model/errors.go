@@ -0,0 +1,32 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors shared by every layer. Stores and services wrap them, the
+// transport maps them onto status codes with errors.Is.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrConflict rejects a change the current state of a resource
+	// doesn't allow.
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is matched by every *VersionConflictError.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// VersionConflictError rejects a write that expected another version of
+// the todo than the current one.
+type VersionConflictError struct {
+	Current Todo
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: todo %d is at version %d", ErrPreconditionFailed, e.Current.ID, e.Current.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrPreconditionFailed
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+type TodoSortField string
+
+const (
+	SortByID        TodoSortField = "id"
+	SortByCreatedAt TodoSortField = "created_at"
+	SortByUpdatedAt TodoSortField = "updated_at"
+	SortByDueAt     TodoSortField = "due_at"
+	SortByPriority  TodoSortField = "priority"
+)
+
+// TodoFilter describes which todos to list and in what order.
+// Cursor is an opaque value taken from a previous TodoPage.
+// Todos without a due date sort after all others in ascending order.
+type TodoFilter struct {
+	ListID *ID
+	// SeriesID lists the occurrences of a series: its first todo and the
+	// ones created after it.
+	SeriesID      *ID
+	Complete      *bool
+	TitleContains string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	DueAfter      *time.Time
+	DueBefore     *time.Time
+	MinPriority   *Priority
+	// Tags a todo must all have.
+	Tags []string
+	// Trashed lists the todos in the trash instead of the live ones.
+	Trashed bool
+
+	SortBy TodoSortField
+	Order  SortOrder
+
+	Cursor string
+	Limit  int
+}
+
+type TodoPage struct {
+	Todos      []Todo `json:"todos"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// List is a named collection of todos. Every todo is in exactly one
+// list, which its subtasks share.
+type List struct {
+	ID     ID     `json:"id"`
+	UserID UserID `json:"userId,omitempty"`
+	Name   string `json:"name"`
+	// ArchivedAt is set while the list is archived. Archived lists keep
+	// their todos but take no new ones.
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	// Counts are of the live todos of the list at any depth.
+	Counts ListCounts `json:"counts"`
+}
+
+type ListCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+}
+
+// ListPatch changes the fields it has set.
+type ListPatch struct {
+	Name     *string
+	Archived *bool
+}
+
+// ListFilter with Archived lists the archived lists instead of the
+// others.
+type ListFilter struct {
+	Archived bool
+}
+
+// DeleteListOptions with Cascade delete the todos of the list along
+// with it. Otherwise a list with todos is not deleted.
+type DeleteListOptions struct {
+	Cascade bool
+}
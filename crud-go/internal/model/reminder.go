@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Reminder is the notice that a todo is due within Offset. There is one
+// reminder per todo, offset and due date, so moving the due date brings
+// a new one.
+type Reminder struct {
+	ID     int64         `json:"id"`
+	TodoID ID            `json:"todoId"`
+	Offset time.Duration `json:"-"`
+	DueAt  time.Time     `json:"dueAt"`
+	// SentAt is set once the reminder is sent; a reminder that failed has
+	// its Error instead.
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// Todo is the todo the reminder was claimed for.
+	Todo Todo `json:"todo"`
+}
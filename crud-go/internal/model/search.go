@@ -0,0 +1,25 @@
+package model
+
+// SearchFilter finds the todos, outside of the trash, whose title or
+// description have words beginning with every word of Query. The best
+// matches come first.
+type SearchFilter struct {
+	Query string
+
+	Cursor string
+	Limit  int
+}
+
+type SearchResult struct {
+	Todo Todo `json:"todo"`
+	// Rank orders the results; it only compares within one search.
+	Rank float64 `json:"rank"`
+	// Snippet is the best matching part of the title and description,
+	// HTML escaped, with the matching words in <mark> tags.
+	Snippet string `json:"snippet"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
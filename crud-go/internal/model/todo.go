@@ -1,12 +1,153 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type ID uint64
 
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < 0 || int(p) >= len(priorityNames) {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(priorityNames) {
+		return nil, fmt.Errorf("invalid priority %d", int(p))
+	}
+	return []byte(priorityNames[p]), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	for i, name := range priorityNames {
+		if name == string(text) {
+			*p = Priority(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown priority %q", text)
+}
+
 type Todo struct {
-	ID        ID        `json:"id"`
-	Title     string    `json:"title"`
-	Complete  bool      `json:"complete"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID ID `json:"id"`
+	// UserID owns the todo. Subtasks belong to the owner of their parent.
+	UserID UserID `json:"userId,omitempty"`
+	// ListID is the list the todo is in, along with its subtasks.
+	ListID ID `json:"listId"`
+	// ParentID is the todo this one is a subtask of.
+	ParentID    *ID      `json:"parentId,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Complete    bool     `json:"complete"`
+	Priority    Priority `json:"priority"`
+	// DueAt is shown in DueTimezone, an IANA zone name, when one is set.
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	DueTimezone string     `json:"dueTimezone,omitempty"`
+	// Recurrence is an RFC 5545 RRULE, e.g. FREQ=WEEKLY;BYDAY=MO, whose
+	// occurrences start at DueAt and follow the wall clock of
+	// DueTimezone. Completing the todo creates the next occurrence.
+	Recurrence string `json:"recurrence,omitempty"`
+	// SeriesID is the first todo of the series an occurrence was created
+	// for.
+	SeriesID    *ID        `json:"seriesId,omitempty"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// DeletedAt is set while the todo is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Version goes up by one with every change of the todo.
+	Version int64 `json:"version"`
+}
+
+// TodoPatch lists the fields of a todo to change. Nil fields are left
+// as they are.
+type TodoPatch struct {
+	// IfVersion makes the update fail with a *VersionConflictError
+	// unless the todo is at this version.
+	IfVersion *int64
+	// ListID moves the todo and its subtasks to another list. A todo
+	// whose parent stays behind moves to the top level, unless ParentID
+	// names a new parent in the list.
+	ListID      *ID
+	ParentID    Nullable[ID]
+	Title       *string
+	Description *string
+	Complete    *bool
+	Priority    *Priority
+	DueAt       Nullable[time.Time]
+	DueTimezone *string
+	// Recurrence set to "" stops the todo from recurring.
+	Recurrence *string
+	Tags       *[]string
+	// Series applies the patch to the open occurrences of the series of
+	// the todo as well, not to the todo alone. IfVersion, ListID,
+	// ParentID, Complete and DueAt only ever apply to the todo itself.
+	Series bool
+}
+
+// ToggleOptions with Cascade set all descendants of the todo to its new
+// state. IfVersion works as in TodoPatch.
+type ToggleOptions struct {
+	Cascade   bool
+	IfVersion *int64
+}
+
+// DeleteOptions with Cascade delete all descendants of the todo, which
+// otherwise move up to its parent. IfVersion works as in TodoPatch.
+type DeleteOptions struct {
+	Cascade   bool
+	IfVersion *int64
+}
+
+// Progress counts the subtasks below a todo at any depth.
+type Progress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// TodoTree is a todo with all of its subtasks.
+type TodoTree struct {
+	Todo
+	Progress Progress   `json:"progress"`
+	Children []TodoTree `json:"children"`
+}
+
+// Nullable tells a field left out of a JSON patch (Set is false) apart
+// from one explicitly set to null (Set is true, Value is nil).
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func NullableOf[T any](value *T) Nullable[T] {
+	return Nullable[T]{Set: true, Value: value}
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	n.Value = new(T)
+	return json.Unmarshal(data, n.Value)
 }
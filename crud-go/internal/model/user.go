@@ -0,0 +1,22 @@
+package model
+
+import "context"
+
+// UserID is the id of a user of the auth service. Zero is no user: the
+// owner of the todos made while authentication is off.
+type UserID uint64
+
+type userKey struct{}
+
+// WithUser makes ctx act for user, which keeps the repositories to the
+// todos user owns.
+func WithUser(ctx context.Context, user UserID) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user ctx acts for. A context acting for no user,
+// like the one of the purge, isn't kept to anyone's todos.
+func UserFrom(ctx context.Context) (UserID, bool) {
+	user, ok := ctx.Value(userKey{}).(UserID)
+	return user, ok
+}
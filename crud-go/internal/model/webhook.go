@@ -0,0 +1,88 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Webhook is a subscription of an URL to the changes of todos. Secret
+// signs the deliveries and is never sent back.
+type Webhook struct {
+	ID ID `json:"id"`
+	// UserID owns the webhook, which gets the changes of their todos.
+	UserID UserID `json:"userId,omitempty"`
+	URL    string `json:"url"`
+	// Events are the change types delivered; none means all of them.
+	Events    []ChangeType `json:"events"`
+	Secret    string       `json:"-"`
+	Active    bool         `json:"active"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// Subscribed tells whether changes of type t are delivered to w.
+func (w Webhook) Subscribed(t ChangeType) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, t)
+}
+
+// WebhookPatch changes the fields it has set.
+type WebhookPatch struct {
+	URL    *string
+	Events *[]ChangeType
+	Secret *string
+	Active *bool
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is a change sent, or still to be sent, to a webhook,
+// along with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID        int64      `json:"id"`
+	WebhookID ID         `json:"webhookId"`
+	EventID   int64      `json:"eventId"`
+	Event     ChangeType `json:"event"`
+	// Payload is the request body, kept as sent so it can be sent again.
+	Payload       string         `json:"payload"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt *time.Time     `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt *time.Time     `json:"lastAttemptAt,omitempty"`
+	// ResponseStatus is the HTTP status of the last attempt, if any.
+	ResponseStatus *int   `json:"responseStatus,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	// RedeliveryOf is the delivery this one was manually repeated from.
+	RedeliveryOf *int64    `json:"redeliveryOf,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// DeliveryAttempt is the outcome of sending a delivery once.
+type DeliveryAttempt struct {
+	At             time.Time
+	ResponseStatus *int
+	Error          string
+	// Status is what the delivery is now; a pending one is tried again
+	// at NextAttemptAt.
+	Status        DeliveryStatus
+	NextAttemptAt *time.Time
+}
+
+// DeliveryFilter selects the deliveries of a webhook, newest first.
+type DeliveryFilter struct {
+	WebhookID ID
+	Status    *DeliveryStatus
+
+	Cursor string
+	Limit  int
+}
+
+type DeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
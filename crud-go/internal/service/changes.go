@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"fmt"
+)
+
+// maxReplay is how many changes a resumed stream may have missed. One
+// resuming from further back is turned away, so that a client can't make
+// the server hold the whole audit log; it has to list the todos again
+// and watch from then.
+const maxReplay = 10 * maxListLimit
+
+// WatchChanges implements ITodoService.
+func (t *TodoService) WatchChanges(ctx context.Context, filter model.ChangeFilter) (<-chan model.Change, error) {
+	if len(filter.Tags) > 0 {
+		filter.Tags = normalizeTags(filter.Tags)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Subscribe before replaying, so that nothing committed in between is
+	// missed. What shows up in both is sent once.
+	live := t.store.Changes().Subscribe(ctx)
+
+	var replay []model.AuditEntry
+	if filter.AfterID > 0 {
+		var err error
+		if replay, err = t.auditSince(ctx, filter.AfterID); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	changes := make(chan model.Change)
+
+	// The feed carries everyone's changes; the replay is already kept
+	// to the user's.
+	user, scoped := model.UserFrom(ctx)
+
+	go func() {
+		defer cancel()
+		defer close(changes)
+
+		send := func(entry model.AuditEntry) bool {
+			if scoped && entry.UserID() != user {
+				return true
+			}
+
+			change := model.ChangeOf(entry)
+			if !filter.Match(change) {
+				return true
+			}
+
+			select {
+			case changes <- change:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		replayed := make(map[int64]bool, len(replay))
+		for _, entry := range replay {
+			replayed[entry.ID] = true
+			if !send(entry) {
+				return
+			}
+		}
+
+		for entry := range live {
+			if replayed[entry.ID] {
+				continue
+			}
+			if !send(entry) {
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// auditSince returns every audit entry after afterID, unless there are
+// more than maxReplay of them.
+func (t *TodoService) auditSince(ctx context.Context, afterID int64) ([]model.AuditEntry, error) {
+	filter := model.AuditFilter{AfterID: afterID, Limit: maxListLimit}
+
+	var entries []model.AuditEntry
+	for {
+		page, err := t.store.Audit().ListAudit(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page.Entries...)
+		if len(entries) > maxReplay {
+			return nil, fmt.Errorf("%w: more than %d changes since %d, list the todos again and watch from now", model.ErrConflict, maxReplay, afterID)
+		}
+		if page.NextCursor == "" {
+			return entries, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	mock_store "crud/internal/store/mocks"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_WatchChanges(t *testing.T) {
+	todo := func(id model.ID, tags ...string) *model.Todo { return &model.Todo{ID: id, Tags: tags} }
+
+	entries := []model.AuditEntry{
+		{ID: 1, TodoID: 1, Action: model.AuditCreate, After: todo(1, "work")},
+		{ID: 2, TodoID: 2, Action: model.AuditCreate, After: todo(2)},
+		{ID: 3, TodoID: 1, Action: model.AuditToggle, Before: todo(1, "work"), After: todo(1, "work")},
+		{ID: 4, TodoID: 1, Action: model.AuditDelete, Before: todo(1, "work"), After: todo(1, "work")},
+		{ID: 5, TodoID: 1, Action: model.AuditRestore, Before: todo(1, "work"), After: todo(1, "work")},
+	}
+	owned := []model.AuditEntry{
+		{ID: 6, TodoID: 3, Action: model.AuditCreate, After: &model.Todo{ID: 3, UserID: 7}},
+		{ID: 7, TodoID: 4, Action: model.AuditCreate, After: &model.Todo{ID: 4, UserID: 8}},
+		{ID: 8, TodoID: 3, Action: model.AuditDelete, Before: &model.Todo{ID: 3, UserID: 7}},
+	}
+
+	testTable := []struct {
+		name          string
+		filter        model.ChangeFilter
+		user          model.UserID
+		replay        []model.AuditEntry
+		live          []model.AuditEntry
+		expectedIDs   []int64
+		expectedTypes []model.ChangeType
+	}{
+		{
+			name:          "Live",
+			live:          entries,
+			expectedIDs:   []int64{1, 2, 3, 4, 5},
+			expectedTypes: []model.ChangeType{model.ChangeCreated, model.ChangeCreated, model.ChangeUpdated, model.ChangeDeleted, model.ChangeCreated},
+		},
+		{
+			name:          "Filtered",
+			filter:        model.ChangeFilter{Types: []model.ChangeType{model.ChangeCreated, model.ChangeDeleted}, Tags: []string{" Work"}},
+			live:          entries,
+			expectedIDs:   []int64{1, 4, 5},
+			expectedTypes: []model.ChangeType{model.ChangeCreated, model.ChangeDeleted, model.ChangeCreated},
+		},
+		{
+			name:          "Resumed",
+			filter:        model.ChangeFilter{TodoIDs: []model.ID{1}, AfterID: 2},
+			replay:        entries[2:4],
+			live:          entries[3:],
+			expectedIDs:   []int64{3, 4, 5},
+			expectedTypes: []model.ChangeType{model.ChangeUpdated, model.ChangeDeleted, model.ChangeCreated},
+		},
+		{
+			name:          "Owned",
+			user:          7,
+			live:          owned,
+			expectedIDs:   []int64{6, 8},
+			expectedTypes: []model.ChangeType{model.ChangeCreated, model.ChangeDeleted},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			live := make(chan model.AuditEntry, len(tt.live))
+			for _, entry := range tt.live {
+				live <- entry
+			}
+			close(live)
+
+			feed := mock_store.NewMockChangeFeed(ctrl)
+			feed.EXPECT().Subscribe(gomock.Any()).Return(live)
+
+			audit := mock_store.NewMockAuditRepository(ctrl)
+			if tt.filter.AfterID > 0 {
+				audit.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{AfterID: tt.filter.AfterID, Limit: maxListLimit}).
+					Return(model.AuditPage{Entries: tt.replay}, nil)
+			}
+
+			s := mock_store.NewMockStore(ctrl)
+			s.EXPECT().Changes().Return(feed)
+			s.EXPECT().Audit().Return(audit).AnyTimes()
+
+			service := &TodoService{store: s}
+
+			ctx := context.Background()
+			if tt.user != 0 {
+				ctx = model.WithUser(ctx, tt.user)
+			}
+
+			changes, err := service.WatchChanges(ctx, tt.filter)
+			assert.NoError(t, err)
+
+			var ids []int64
+			var types []model.ChangeType
+			for change := range changes {
+				ids = append(ids, change.ID)
+				types = append(types, change.Type)
+			}
+
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.expectedTypes, types)
+		})
+	}
+}
+
+func TestService_WatchChangesReplayError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	failure := errors.New("failure")
+
+	audit := mock_store.NewMockAuditRepository(ctrl)
+	audit.EXPECT().ListAudit(gomock.Any(), gomock.Any()).Return(model.AuditPage{}, failure)
+
+	feed := mock_store.NewMockChangeFeed(ctrl)
+	feed.EXPECT().Subscribe(gomock.Any()).Return(make(chan model.AuditEntry))
+
+	s := mock_store.NewMockStore(ctrl)
+	s.EXPECT().Changes().Return(feed)
+	s.EXPECT().Audit().Return(audit)
+
+	service := &TodoService{store: s}
+
+	changes, err := service.WatchChanges(context.Background(), model.ChangeFilter{AfterID: 1})
+	assert.ErrorIs(t, err, failure)
+	assert.Nil(t, changes)
+}
+
+func TestService_WatchChangesReplayTooFar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := model.AuditPage{Entries: make([]model.AuditEntry, maxListLimit), NextCursor: "next"}
+
+	audit := mock_store.NewMockAuditRepository(ctrl)
+	audit.EXPECT().ListAudit(gomock.Any(), gomock.Any()).Return(page, nil).Times(maxReplay/maxListLimit + 1)
+
+	feed := mock_store.NewMockChangeFeed(ctrl)
+	feed.EXPECT().Subscribe(gomock.Any()).Return(make(chan model.AuditEntry))
+
+	s := mock_store.NewMockStore(ctrl)
+	s.EXPECT().Changes().Return(feed)
+	s.EXPECT().Audit().Return(audit).AnyTimes()
+
+	service := &TodoService{store: s}
+
+	changes, err := service.WatchChanges(context.Background(), model.ChangeFilter{AfterID: 1})
+	assert.ErrorIs(t, err, model.ErrConflict, "the audit log is not read further than maxReplay")
+	assert.Nil(t, changes)
+}
@@ -1,8 +1,68 @@
 package service
 
+import "time"
+
 type Config struct {
+	// TrashRetention is how long deleted todos stay in the trash before
+	// they are purged. Zero keeps them forever.
+	TrashRetention time.Duration
+	// PurgeInterval is how often the trash is checked for todos past
+	// their retention.
+	PurgeInterval time.Duration
+
+	// WebhookTimeout bounds a single delivery attempt.
+	WebhookTimeout time.Duration
+	// WebhookMaxAttempts is how often a delivery is tried before it is
+	// given up.
+	WebhookMaxAttempts int
+	// WebhookRetryDelay is the wait before the first retry, which doubles
+	// with every further one up to WebhookMaxRetryDelay.
+	WebhookRetryDelay    time.Duration
+	WebhookMaxRetryDelay time.Duration
+	// WebhookPollInterval is how often due deliveries are looked for when
+	// no todo changes. Zero disables deliveries.
+	WebhookPollInterval time.Duration
+
+	// ReminderOffsets are how long before their due date open todos are
+	// reminded of, once for every offset.
+	ReminderOffsets []time.Duration
+	// ReminderPollInterval is how often due reminders are looked for.
+	// Zero disables reminders.
+	ReminderPollInterval time.Duration
+	// ReminderMaxDelay is how late a reminder is still sent, as for a
+	// todo created after its reminder was due or while no instance ran.
+	ReminderMaxDelay time.Duration
+	// ReminderTimeout bounds sending a single reminder.
+	ReminderTimeout time.Duration
+	// ReminderNotifier sends the reminders: "log", "webhook" or "smtp".
+	ReminderNotifier string
+	// ReminderWebhookURL is posted the reminders of the webhook notifier,
+	// signed with ReminderWebhookSecret like webhook deliveries.
+	ReminderWebhookURL    string
+	ReminderWebhookSecret string
+	// ReminderSMTPAddress is the host:port of the mail server the smtp
+	// notifier sends the reminders through, from ReminderSMTPFrom to
+	// ReminderSMTPTo. It logs in if ReminderSMTPUsername is set.
+	ReminderSMTPAddress  string
+	ReminderSMTPUsername string
+	ReminderSMTPPassword string
+	ReminderSMTPFrom     string
+	ReminderSMTPTo       []string
 }
 
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		TrashRetention:       30 * 24 * time.Hour,
+		PurgeInterval:        time.Hour,
+		WebhookTimeout:       10 * time.Second,
+		WebhookMaxAttempts:   8,
+		WebhookRetryDelay:    30 * time.Second,
+		WebhookMaxRetryDelay: time.Hour,
+		WebhookPollInterval:  5 * time.Second,
+		ReminderOffsets:      []time.Duration{time.Hour},
+		ReminderPollInterval: time.Minute,
+		ReminderMaxDelay:     time.Hour,
+		ReminderTimeout:      10 * time.Second,
+		ReminderNotifier:     "log",
+	}
 }
@@ -0,0 +1,23 @@
+package service
+
+import "context"
+
+// RequestInfo says who made a request. It is recorded with every change
+// the request makes.
+type RequestInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the RequestInfo of ctx, or the zero value if it
+// has none.
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dispatchBatch is how many deliveries are claimed and sent at once.
+const dispatchBatch = 20
+
+// Dispatcher sends the queued webhook deliveries. Several instances can
+// run against one store, each delivery is claimed by one of them.
+type Dispatcher struct {
+	config       *Config
+	logger       *slog.Logger
+	store        store.Store
+	webhooksRepo store.WebhookRepository
+	client       *http.Client
+	now          func() time.Time
+}
+
+func NewDispatcher(logger *slog.Logger, store store.Store, config *Config) *Dispatcher {
+	return &Dispatcher{
+		config:       config,
+		logger:       logger,
+		store:        store,
+		webhooksRepo: store.Webhooks(),
+		client: &http.Client{
+			// A redirect is answered like any other non-2xx status.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Run sends due deliveries every WebhookPollInterval, and right away
+// when todos change, until ctx is done. Deliveries in flight when ctx is
+// done are left to be retried once their claim runs out.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d.config.WebhookPollInterval <= 0 {
+		d.logger.Info("Webhook deliveries disabled")
+		return
+	}
+
+	ticker := time.NewTicker(d.config.WebhookPollInterval)
+	defer ticker.Stop()
+
+	changes := d.store.Changes().Subscribe(ctx)
+
+	for {
+		// A full batch suggests more are due.
+		for {
+			sent, err := d.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				d.logger.Error("Failed dispatch webhook deliveries", "error", err.Error())
+			}
+			if err != nil || sent < dispatchBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if changes == nil {
+				changes = d.store.Changes().Subscribe(ctx)
+			}
+		case _, ok := <-changes:
+			if !ok {
+				// Polling goes on until the next subscription.
+				changes = nil
+			}
+		}
+	}
+}
+
+// Dispatch claims the due deliveries, sends them and records the
+// outcomes. It returns how many deliveries it claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.now().UTC()
+
+	// The claim outlasts the attempt, so nobody else sends it meanwhile.
+	deliveries, err := d.webhooksRepo.ClaimDeliveries(ctx, now, now.Add(2*d.config.WebhookTimeout), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	webhooks := make(map[model.ID]model.Webhook)
+	var wg sync.WaitGroup
+
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = d.webhooksRepo.GetWebhook(ctx, delivery.WebhookID)
+			if errors.Is(err, model.ErrNotFound) {
+				// Its deliveries went along with it.
+				continue
+			}
+			if err != nil {
+				wg.Wait()
+				return len(deliveries), err
+			}
+			webhooks[webhook.ID] = webhook
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, webhook, delivery)
+		}()
+	}
+
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) {
+	attempt := d.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// Cut short by the shutdown rather than the receiver.
+		return
+	}
+
+	logger := d.logger.With("webhook_id", webhook.ID, "delivery_id", delivery.ID, "attempt", delivery.Attempts+1)
+	switch attempt.Status {
+	case model.DeliverySucceeded:
+		logger.Debug("Delivered webhook")
+	case model.DeliveryPending:
+		logger.Warn("Webhook delivery failed, retrying", "error", attempt.Error, "next_attempt_at", *attempt.NextAttemptAt)
+	case model.DeliveryFailed:
+		logger.Error("Webhook delivery failed, giving up", "error", attempt.Error)
+	}
+
+	if _, err := d.webhooksRepo.RecordAttempt(ctx, delivery.ID, attempt); err != nil && !errors.Is(err, model.ErrNotFound) {
+		logger.Error("Failed record webhook delivery attempt", "error", err.Error())
+	}
+}
+
+// send posts the payload of delivery to webhook and tells what comes of
+// it: success on a 2xx answer, otherwise a retry after the backoff,
+// unless it was the last attempt.
+func (d *Dispatcher) send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) model.DeliveryAttempt {
+	now := d.now().UTC()
+	attempt := model.DeliveryAttempt{At: now, Status: model.DeliverySucceeded}
+
+	status, err := d.post(ctx, webhook, delivery, now)
+	if status != 0 {
+		attempt.ResponseStatus = &status
+	}
+	if err == nil {
+		return attempt
+	}
+
+	attempt.Error = err.Error()
+
+	if delivery.Attempts+1 >= d.config.WebhookMaxAttempts {
+		attempt.Status = model.DeliveryFailed
+		return attempt
+	}
+
+	next := now.Add(d.backoff(delivery.Attempts + 1))
+	attempt.Status = model.DeliveryPending
+	attempt.NextAttemptAt = &next
+
+	return attempt
+}
+
+// maxResponseRead is how much of an answer is read, so that the
+// connection can be reused.
+const maxResponseRead = 64 << 10
+
+func (d *Dispatcher) post(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crud-webhooks")
+	req.Header.Set("X-Webhook-Id", strconv.FormatUint(uint64(webhook.ID), 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.Event))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", WebhookSignature(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait before retry n, counting from one.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.config.WebhookRetryDelay
+	for i := 1; i < n && delay < d.config.WebhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.config.WebhookMaxRetryDelay)
+}
+
+// WebhookSignature is the X-Webhook-Signature of a delivery: the
+// HMAC-SHA256, keyed with the webhook secret, of the X-Webhook-Timestamp
+// value, a dot and the body. Receivers compute it to check that the
+// delivery is genuine, and the timestamp to reject replays.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedDelivery struct {
+	header http.Header
+	body   string
+}
+
+// receiver is a webhook endpoint answering with statuses in turn, and
+// with the last one once they run out.
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []receivedDelivery
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries = append(r.deliveries, receivedDelivery{header: req.Header.Clone(), body: string(body)})
+
+	status := r.statuses[0]
+	if len(r.statuses) > 1 {
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []receivedDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedDelivery(nil), r.deliveries...)
+}
+
+func newDispatchTest(t *testing.T, statuses ...int) (store.Store, *Dispatcher, *receiver, *model.Webhook, *time.Time) {
+	t.Helper()
+
+	s := store.NewMemoryStore(nil)
+	require.NoError(t, s.Open())
+	t.Cleanup(func() { s.Close() })
+
+	recv := &receiver{statuses: statuses}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	webhook, err := s.Webhooks().CreateWebhook(context.Background(), model.Webhook{
+		URL:    server.URL,
+		Secret: "0123456789abcdef",
+		Active: true,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	config := &Config{
+		WebhookTimeout:       time.Second,
+		WebhookMaxAttempts:   3,
+		WebhookRetryDelay:    time.Minute,
+		WebhookMaxRetryDelay: time.Hour,
+	}
+	dispatcher := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), s, config)
+	dispatcher.now = func() time.Time { return now }
+
+	return s, dispatcher, recv, &webhook, &now
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	s, dispatcher, recv, webhook, now := newDispatchTest(t, http.StatusInternalServerError, http.StatusNoContent)
+
+	list, err := s.Lists().CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+	todo, err := NewTodoService(slog.New(slog.NewTextHandler(io.Discard, nil)), s, NewConfig()).CreateTodo(ctx, model.Todo{ListID: list.ID, Title: "Title"})
+	require.NoError(t, err)
+
+	sent, err := dispatcher.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	received := recv.received()
+	require.Len(t, received, 1)
+
+	header := received[0].header
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, strconv.FormatUint(uint64(webhook.ID), 10), header.Get("X-Webhook-Id"))
+	assert.Equal(t, "created", header.Get("X-Webhook-Event"))
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), header.Get("X-Webhook-Timestamp"))
+	assert.Equal(t, WebhookSignature(webhook.Secret, now.Unix(), []byte(received[0].body)), header.Get("X-Webhook-Signature"))
+	assert.Contains(t, received[0].body, `"title":"`+todo.Title+`"`)
+
+	page, err := s.Webhooks().ListDeliveries(ctx, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	delivery := page.Deliveries[0]
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, *delivery.ResponseStatus)
+	assert.NotEmpty(t, delivery.LastError)
+	assert.Equal(t, now.Add(time.Minute), *delivery.NextAttemptAt)
+
+	// Nothing is due before the backoff is over.
+	sent, err = dispatcher.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	*now = now.Add(time.Minute)
+	sent, err = dispatcher.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	received = recv.received()
+	require.Len(t, received, 2)
+	assert.Equal(t, received[0].body, received[1].body, "retries send the same payload")
+	assert.Equal(t, received[0].header.Get("X-Webhook-Delivery"), received[1].header.Get("X-Webhook-Delivery"))
+
+	page, err = s.Webhooks().ListDeliveries(ctx, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	assert.Equal(t, model.DeliverySucceeded, page.Deliveries[0].Status)
+	assert.Equal(t, 2, page.Deliveries[0].Attempts)
+	assert.Nil(t, page.Deliveries[0].NextAttemptAt)
+
+	webhooks := &WebhookService{webhooksRepo: s.Webhooks(), now: func() time.Time { return *now }}
+	redelivered, err := webhooks.Redeliver(ctx, webhook.ID, delivery.ID)
+	require.NoError(t, err)
+
+	sent, err = dispatcher.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	received = recv.received()
+	require.Len(t, received, 3)
+	assert.Equal(t, received[0].body, received[2].body)
+	assert.Equal(t, strconv.FormatInt(redelivered.ID, 10), received[2].header.Get("X-Webhook-Delivery"))
+}
+
+func TestDispatcher_GiveUp(t *testing.T) {
+	ctx := context.Background()
+	s, dispatcher, recv, webhook, now := newDispatchTest(t, http.StatusBadGateway)
+
+	_, err := s.Webhooks().EnqueueDeliveries(ctx, 0, model.WebhookDelivery{EventID: 1, Event: model.ChangeCreated, Payload: "{}", NextAttemptAt: now})
+	require.NoError(t, err)
+
+	for _, wait := range []time.Duration{0, time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		*now = now.Add(wait)
+		_, err := dispatcher.Dispatch(ctx)
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, recv.received(), 3, "no attempts after the last one")
+
+	page, err := s.Webhooks().ListDeliveries(ctx, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	assert.Equal(t, model.DeliveryFailed, page.Deliveries[0].Status)
+	assert.Equal(t, 3, page.Deliveries[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, *page.Deliveries[0].ResponseStatus)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	dispatcher := &Dispatcher{config: &Config{WebhookRetryDelay: 30 * time.Second, WebhookMaxRetryDelay: 5 * time.Minute}}
+
+	testTable := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{retry: 1, expected: 30 * time.Second},
+		{retry: 2, expected: time.Minute},
+		{retry: 4, expected: 4 * time.Minute},
+		{retry: 5, expected: 5 * time.Minute},
+		{retry: 60, expected: 5 * time.Minute},
+	}
+
+	for _, tt := range testTable {
+		t.Run(strconv.Itoa(tt.retry), func(t *testing.T) {
+			assert.Equal(t, tt.expected, dispatcher.backoff(tt.retry))
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '1700000000.{"id":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11",
+		WebhookSignature("secret", 1_700_000_000, []byte(`{"id":1}`)),
+	)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"errors"
+	"fmt"
+)
+
+// exportPageSize is the number of todos an export reads at a time.
+const exportPageSize = 500
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ExportTodos implements ITodoService.
+func (t *TodoService) ExportTodos(ctx context.Context, filter model.TodoFilter, each func(model.Todo) error) error {
+	filter.Trashed = false
+	if filter.SortBy == "" {
+		filter.SortBy = model.SortByID
+	}
+	if filter.Order == "" {
+		filter.Order = model.SortAsc
+	}
+	if len(filter.Tags) > 0 {
+		filter.Tags = normalizeTags(filter.Tags)
+	}
+	filter.Cursor, filter.Limit = "", exportPageSize
+
+	for {
+		page, err := t.todosRepo.ListTodos(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range page.Todos {
+			if err := each(todo); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// ImportTodos implements ITodoService.
+func (t *TodoService) ImportTodos(ctx context.Context, todos []model.Todo, dryRun bool) ([]model.Todo, error) {
+	created := make([]model.Todo, 0, len(todos))
+
+	err := t.store.InTx(ctx, func(tx store.Tx) error {
+		// ids maps the ids todos had where they were exported from to
+		// the ones they got here.
+		ids := make(map[model.ID]model.ID, len(todos))
+
+		for i, todo := range todos {
+			// A parent left out of the import, as by a filtered export,
+			// is dropped rather than taken for whatever todo has its id
+			// here.
+			if todo.ParentID != nil {
+				if id, ok := ids[*todo.ParentID]; ok {
+					todo.ParentID = &id
+				} else {
+					todo.ParentID = nil
+				}
+			}
+
+			source := todo.ID
+			todo.ID = 0
+
+			c, err := createTodo(ctx, tx, todo)
+			if err != nil {
+				return fmt.Errorf("todo %d: %w", i, err)
+			}
+
+			if source != 0 {
+				ids[source] = c.ID
+			}
+			created = append(created, *c)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !(dryRun && errors.Is(err, errDryRun)) {
+		return nil, err
+	}
+
+	return created, nil
+}
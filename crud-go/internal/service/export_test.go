@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	mock_store "crud/internal/store/mocks"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_ExportTodos(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_store.NewMockTodoRepository(ctrl)
+	service := &TodoService{todosRepo: repo}
+
+	filter := model.TodoFilter{Tags: []string{"work"}, SortBy: model.SortByID, Order: model.SortAsc, Limit: exportPageSize}
+	next := filter
+	next.Cursor = "next"
+
+	gomock.InOrder(
+		repo.EXPECT().ListTodos(gomock.Any(), filter).Return(model.TodoPage{Todos: []model.Todo{{ID: 1}, {ID: 2}}, NextCursor: "next"}, nil),
+		repo.EXPECT().ListTodos(gomock.Any(), next).Return(model.TodoPage{Todos: []model.Todo{{ID: 3}}}, nil),
+	)
+
+	var ids []model.ID
+	err := service.ExportTodos(context.Background(), model.TodoFilter{Tags: []string{" Work"}, Cursor: "ignored", Limit: 5, Trashed: true}, func(todo model.Todo) error {
+		ids = append(ids, todo.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ID{1, 2, 3}, ids)
+
+	// A failing write stops the export.
+	failure := errors.New("broken pipe")
+	repo.EXPECT().ListTodos(gomock.Any(), filter).Return(model.TodoPage{Todos: []model.Todo{{ID: 1}, {ID: 2}}, NextCursor: "next"}, nil)
+
+	calls := 0
+	err = service.ExportTodos(context.Background(), model.TodoFilter{Tags: []string{"work"}}, func(todo model.Todo) error {
+		calls++
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, calls)
+}
+
+func TestService_ImportTodos(t *testing.T) {
+	parentID := model.ID(1)
+	existingID := model.ID(7)
+	createdParentID := model.ID(11)
+
+	todos := []model.Todo{
+		{ID: 1, Title: "Parent"},
+		{ID: 2, ParentID: &parentID, Title: "Child"},
+		{ParentID: &existingID, Title: "Elsewhere"},
+	}
+
+	testTable := []struct {
+		name          string
+		dryRun        bool
+		failAt        int
+		expectedError error
+	}{
+		{name: "Import", failAt: -1},
+		{name: "Dry run", dryRun: true, failAt: -1},
+		{name: "Failure", failAt: 2, expectedError: errors.New("failure")},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockTodoRepository(ctrl)
+
+			expected := []model.Todo{
+				{ID: 11, Title: "Parent", Tags: []string{}},
+				{ID: 12, ParentID: &createdParentID, Title: "Child", Tags: []string{}},
+				// Not a todo of the import, however one here has its id.
+				{ID: 13, Title: "Elsewhere", Tags: []string{}},
+			}
+
+			var calls []*gomock.Call
+			for i, todo := range expected {
+				in := todo
+				in.ID = 0
+
+				call := repo.EXPECT().CreateTodo(gomock.Any(), in)
+				if i == tt.failAt {
+					calls = append(calls, call.Return(model.Todo{}, tt.expectedError))
+					break
+				}
+				calls = append(calls, call.Return(todo, nil))
+			}
+			gomock.InOrder(calls...)
+
+			// Rolling back is up to the store.
+			var committed bool
+			s := mock_store.NewMockStore(ctrl)
+			s.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(tx store.Tx) error) error {
+				tx := mock_store.NewMockTx(ctrl)
+				tx.EXPECT().Todos().Return(repo).AnyTimes()
+				tx.EXPECT().Lists().Return(anyLists(ctrl)).AnyTimes()
+				tx.EXPECT().Audit().Return(anyAudit(ctrl)).AnyTimes()
+				tx.EXPECT().Webhooks().Return(anyWebhooks(ctrl)).AnyTimes()
+
+				err := fn(tx)
+				committed = err == nil
+				return err
+			})
+
+			service := &TodoService{store: s, todosRepo: repo}
+
+			created, err := service.ImportTodos(context.Background(), todos, tt.dryRun)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.ErrorContains(t, err, "todo 2")
+				assert.Nil(t, created)
+				assert.False(t, committed)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, created)
+			assert.Equal(t, !tt.dryRun, committed)
+		})
+	}
+}
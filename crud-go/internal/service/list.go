@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+//go:generate mockgen -source=list.go -destination=mocks/list.go
+
+type IListService interface {
+	GetList(ctx context.Context, id model.ID) (*model.List, error)
+	ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error)
+	CreateList(ctx context.Context, list model.List) (*model.List, error)
+	UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (*model.List, error)
+	// DeleteList deletes a list. With opts.Cascade its todos are deleted
+	// first, as if one by one, otherwise a list with todos is kept and
+	// store.ErrListNotEmpty returned.
+	DeleteList(ctx context.Context, id model.ID, opts model.DeleteListOptions) (*model.List, error)
+}
+
+var _ IListService = &ListService{}
+
+type ListService struct {
+	logger    *slog.Logger
+	store     store.Store
+	listsRepo store.ListRepository
+}
+
+func NewListService(logger *slog.Logger, store store.Store) IListService {
+	return &ListService{
+		logger:    logger,
+		store:     store,
+		listsRepo: store.Lists(),
+	}
+}
+
+// GetList implements IListService.
+func (l *ListService) GetList(ctx context.Context, id model.ID) (*model.List, error) {
+	list, err := l.listsRepo.GetList(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ListLists implements IListService.
+func (l *ListService) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	return l.listsRepo.ListLists(ctx, filter)
+}
+
+// CreateList implements IListService.
+func (l *ListService) CreateList(ctx context.Context, list model.List) (*model.List, error) {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return nil, errListName
+	}
+
+	created, err := l.listsRepo.CreateList(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateList implements IListService.
+func (l *ListService) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (*model.List, error) {
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if name == "" {
+			return nil, errListName
+		}
+		patch.Name = &name
+	}
+
+	updated, err := l.listsRepo.UpdateList(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteList implements IListService.
+func (l *ListService) DeleteList(ctx context.Context, id model.ID, opts model.DeleteListOptions) (*model.List, error) {
+	var list model.List
+	err := l.store.InTx(ctx, func(tx store.Tx) error {
+		var err error
+		list, err = tx.Lists().GetList(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if opts.Cascade {
+			if err := deleteListTodos(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		return tx.Lists().DeleteList(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// deleteListTodos deletes the top level todos of the list with their
+// subtasks, each with its own audit entry and deliveries.
+func deleteListTodos(ctx context.Context, tx store.Tx, id model.ID) error {
+	filter := model.TodoFilter{ListID: &id, SortBy: model.SortByID, Order: model.SortAsc, Limit: maxListLimit}
+
+	var top []model.ID
+	for {
+		page, err := tx.Todos().ListTodos(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range page.Todos {
+			if todo.ParentID == nil {
+				top = append(top, todo.ID)
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	for _, todo := range top {
+		if _, err := deleteTodo(ctx, tx, todo, model.DeleteOptions{Cascade: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errListName = fmt.Errorf("%w: list name is empty", model.ErrInvalidArgument)
+
+// checkList makes sure todos can go to the list id: it has to be one of
+// the user's lists that isn't archived.
+func checkList(ctx context.Context, tx store.Tx, id model.ID) error {
+	list, err := tx.Lists().GetList(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return store.ErrListNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if list.ArchivedAt != nil {
+		return store.ErrListArchived
+	}
+	return nil
+}
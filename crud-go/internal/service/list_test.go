@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newListTest(t *testing.T) (store.Store, IListService, ITodoService) {
+	t.Helper()
+
+	s := store.NewMemoryStore(nil)
+	require.NoError(t, s.Open())
+	t.Cleanup(func() { s.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return s, NewListService(logger, s), NewTodoService(logger, s, NewConfig())
+}
+
+func TestListService_CreateList(t *testing.T) {
+	ctx := context.Background()
+	_, lists, _ := newListTest(t)
+
+	list, err := lists.CreateList(ctx, model.List{Name: "  Work "})
+	require.NoError(t, err)
+	assert.Equal(t, "Work", list.Name)
+
+	_, err = lists.CreateList(ctx, model.List{Name: " "})
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+
+	blank := ""
+	_, err = lists.UpdateList(ctx, list.ID, model.ListPatch{Name: &blank})
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+}
+
+func TestListService_CheckList(t *testing.T) {
+	ctx := context.Background()
+	_, lists, todos := newListTest(t)
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+	archive, err := lists.CreateList(ctx, model.List{Name: "Archive"})
+	require.NoError(t, err)
+	archived := true
+	_, err = lists.UpdateList(ctx, archive.ID, model.ListPatch{Archived: &archived})
+	require.NoError(t, err)
+
+	todo, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Todo"})
+	require.NoError(t, err)
+
+	_, err = todos.CreateTodo(ctx, model.Todo{ListID: 99, Title: "Todo"})
+	assert.ErrorIs(t, err, store.ErrListNotFound)
+	_, err = todos.CreateTodo(ctx, model.Todo{ListID: archive.ID, Title: "Todo"})
+	assert.ErrorIs(t, err, store.ErrListArchived)
+	_, err = todos.UpdateTodo(ctx, todo.ID, model.TodoPatch{ListID: &archive.ID})
+	assert.ErrorIs(t, err, store.ErrListArchived)
+
+	// Lists of other users don't exist for the user.
+	_, err = todos.CreateTodo(model.WithUser(ctx, 1), model.Todo{ListID: inbox.ID, Title: "Todo"})
+	assert.ErrorIs(t, err, store.ErrListNotFound)
+}
+
+func TestListService_DeleteList(t *testing.T) {
+	ctx := context.Background()
+	s, lists, todos := newListTest(t)
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+	work, err := lists.CreateList(ctx, model.List{Name: "Work"})
+	require.NoError(t, err)
+
+	parent, err := todos.CreateTodo(ctx, model.Todo{ListID: work.ID, Title: "Parent"})
+	require.NoError(t, err)
+	child, err := todos.CreateTodo(ctx, model.Todo{ListID: work.ID, Title: "Child", ParentID: &parent.ID})
+	require.NoError(t, err)
+	other, err := todos.CreateTodo(ctx, model.Todo{ListID: work.ID, Title: "Other"})
+	require.NoError(t, err)
+	kept, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Kept"})
+	require.NoError(t, err)
+
+	_, err = lists.DeleteList(ctx, work.ID, model.DeleteListOptions{})
+	assert.ErrorIs(t, err, store.ErrListNotEmpty)
+	_, err = lists.GetList(ctx, work.ID)
+	require.NoError(t, err, "a list with todos is kept")
+
+	deleted, err := lists.DeleteList(ctx, work.ID, model.DeleteListOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.Equal(t, "Work", deleted.Name)
+	assert.Equal(t, model.ListCounts{Total: 3}, deleted.Counts)
+
+	_, err = lists.GetList(ctx, work.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	got, err := todos.GetTodos(ctx, []model.ID{parent.ID, child.ID, other.ID, kept.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, kept.ID, got[0].ID)
+
+	// Every todo deleted with the list is in the audit trail.
+	page, err := s.Audit().ListAudit(ctx, model.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	var deletes []model.ID
+	for _, entry := range page.Entries {
+		if entry.Action == model.AuditDelete {
+			deletes = append(deletes, entry.TodoID)
+		}
+	}
+	assert.ElementsMatch(t, []model.ID{parent.ID, child.ID, other.ID}, deletes)
+}
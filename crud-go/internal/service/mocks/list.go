@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: list.go
+
+// Package mock_service is a generated GoMock package.
+package mock_service
+
+import (
+	context "context"
+	model "crud/internal/model"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIListService is a mock of IListService interface.
+type MockIListService struct {
+	ctrl     *gomock.Controller
+	recorder *MockIListServiceMockRecorder
+}
+
+// MockIListServiceMockRecorder is the mock recorder for MockIListService.
+type MockIListServiceMockRecorder struct {
+	mock *MockIListService
+}
+
+// NewMockIListService creates a new mock instance.
+func NewMockIListService(ctrl *gomock.Controller) *MockIListService {
+	mock := &MockIListService{ctrl: ctrl}
+	mock.recorder = &MockIListServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIListService) EXPECT() *MockIListServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateList mocks base method.
+func (m *MockIListService) CreateList(ctx context.Context, list model.List) (*model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateList", ctx, list)
+	ret0, _ := ret[0].(*model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateList indicates an expected call of CreateList.
+func (mr *MockIListServiceMockRecorder) CreateList(ctx, list interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateList", reflect.TypeOf((*MockIListService)(nil).CreateList), ctx, list)
+}
+
+// DeleteList mocks base method.
+func (m *MockIListService) DeleteList(ctx context.Context, id model.ID, opts model.DeleteListOptions) (*model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteList", ctx, id, opts)
+	ret0, _ := ret[0].(*model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteList indicates an expected call of DeleteList.
+func (mr *MockIListServiceMockRecorder) DeleteList(ctx, id, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteList", reflect.TypeOf((*MockIListService)(nil).DeleteList), ctx, id, opts)
+}
+
+// GetList mocks base method.
+func (m *MockIListService) GetList(ctx context.Context, id model.ID) (*model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetList", ctx, id)
+	ret0, _ := ret[0].(*model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetList indicates an expected call of GetList.
+func (mr *MockIListServiceMockRecorder) GetList(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockIListService)(nil).GetList), ctx, id)
+}
+
+// ListLists mocks base method.
+func (m *MockIListService) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLists", ctx, filter)
+	ret0, _ := ret[0].([]model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLists indicates an expected call of ListLists.
+func (mr *MockIListServiceMockRecorder) ListLists(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLists", reflect.TypeOf((*MockIListService)(nil).ListLists), ctx, filter)
+}
+
+// UpdateList mocks base method.
+func (m *MockIListService) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (*model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateList", ctx, id, patch)
+	ret0, _ := ret[0].(*model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateList indicates an expected call of UpdateList.
+func (mr *MockIListServiceMockRecorder) UpdateList(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateList", reflect.TypeOf((*MockIListService)(nil).UpdateList), ctx, id, patch)
+}
@@ -5,6 +5,7 @@
 package mock_service
 
 import (
+	context "context"
 	model "crud/internal/model"
 	reflect "reflect"
 
@@ -34,77 +35,241 @@ func (m *MockITodoService) EXPECT() *MockITodoServiceMockRecorder {
 	return m.recorder
 }
 
+// Batch mocks base method.
+func (m *MockITodoService) Batch(ctx context.Context, ops []model.BatchOp, atomic bool) ([]model.BatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", ctx, ops, atomic)
+	ret0, _ := ret[0].([]model.BatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Batch indicates an expected call of Batch.
+func (mr *MockITodoServiceMockRecorder) Batch(ctx, ops, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockITodoService)(nil).Batch), ctx, ops, atomic)
+}
+
 // CreateTodo mocks base method.
-func (m *MockITodoService) CreateTodo(title string) (*model.Todo, error) {
+func (m *MockITodoService) CreateTodo(ctx context.Context, todo model.Todo) (*model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTodo", title)
+	ret := m.ctrl.Call(m, "CreateTodo", ctx, todo)
 	ret0, _ := ret[0].(*model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateTodo indicates an expected call of CreateTodo.
-func (mr *MockITodoServiceMockRecorder) CreateTodo(title interface{}) *gomock.Call {
+func (mr *MockITodoServiceMockRecorder) CreateTodo(ctx, todo interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTodo", reflect.TypeOf((*MockITodoService)(nil).CreateTodo), title)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTodo", reflect.TypeOf((*MockITodoService)(nil).CreateTodo), ctx, todo)
 }
 
 // DeleteTodo mocks base method.
-func (m *MockITodoService) DeleteTodo(id model.ID) (*model.Todo, error) {
+func (m *MockITodoService) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (*model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTodo", id)
+	ret := m.ctrl.Call(m, "DeleteTodo", ctx, id, opts)
 	ret0, _ := ret[0].(*model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteTodo indicates an expected call of DeleteTodo.
-func (mr *MockITodoServiceMockRecorder) DeleteTodo(id interface{}) *gomock.Call {
+func (mr *MockITodoServiceMockRecorder) DeleteTodo(ctx, id, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTodo", reflect.TypeOf((*MockITodoService)(nil).DeleteTodo), ctx, id, opts)
+}
+
+// ExportTodos mocks base method.
+func (m *MockITodoService) ExportTodos(ctx context.Context, filter model.TodoFilter, each func(model.Todo) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportTodos", ctx, filter, each)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportTodos indicates an expected call of ExportTodos.
+func (mr *MockITodoServiceMockRecorder) ExportTodos(ctx, filter, each interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportTodos", reflect.TypeOf((*MockITodoService)(nil).ExportTodos), ctx, filter, each)
+}
+
+// GetTodo mocks base method.
+func (m *MockITodoService) GetTodo(ctx context.Context, id model.ID) (*model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTodo", ctx, id)
+	ret0, _ := ret[0].(*model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTodo indicates an expected call of GetTodo.
+func (mr *MockITodoServiceMockRecorder) GetTodo(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTodo", reflect.TypeOf((*MockITodoService)(nil).DeleteTodo), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodo", reflect.TypeOf((*MockITodoService)(nil).GetTodo), ctx, id)
+}
+
+// GetTodoTree mocks base method.
+func (m *MockITodoService) GetTodoTree(ctx context.Context, id model.ID) (*model.TodoTree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTodoTree", ctx, id)
+	ret0, _ := ret[0].(*model.TodoTree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTodoTree indicates an expected call of GetTodoTree.
+func (mr *MockITodoServiceMockRecorder) GetTodoTree(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodoTree", reflect.TypeOf((*MockITodoService)(nil).GetTodoTree), ctx, id)
 }
 
 // GetTodos mocks base method.
-func (m *MockITodoService) GetTodos(ids []model.ID) ([]model.Todo, error) {
+func (m *MockITodoService) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTodos", ids)
+	ret := m.ctrl.Call(m, "GetTodos", ctx, ids)
 	ret0, _ := ret[0].([]model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTodos indicates an expected call of GetTodos.
-func (mr *MockITodoServiceMockRecorder) GetTodos(ids interface{}) *gomock.Call {
+func (mr *MockITodoServiceMockRecorder) GetTodos(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodos", reflect.TypeOf((*MockITodoService)(nil).GetTodos), ctx, ids)
+}
+
+// ImportTodos mocks base method.
+func (m *MockITodoService) ImportTodos(ctx context.Context, todos []model.Todo, dryRun bool) ([]model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportTodos", ctx, todos, dryRun)
+	ret0, _ := ret[0].([]model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportTodos indicates an expected call of ImportTodos.
+func (mr *MockITodoServiceMockRecorder) ImportTodos(ctx, todos, dryRun interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodos", reflect.TypeOf((*MockITodoService)(nil).GetTodos), ids)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportTodos", reflect.TypeOf((*MockITodoService)(nil).ImportTodos), ctx, todos, dryRun)
+}
+
+// ListAudit mocks base method.
+func (m *MockITodoService) ListAudit(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAudit", ctx, filter)
+	ret0, _ := ret[0].(*model.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAudit indicates an expected call of ListAudit.
+func (mr *MockITodoServiceMockRecorder) ListAudit(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAudit", reflect.TypeOf((*MockITodoService)(nil).ListAudit), ctx, filter)
+}
+
+// ListTodos mocks base method.
+func (m *MockITodoService) ListTodos(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTodos", ctx, filter)
+	ret0, _ := ret[0].(*model.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTodos indicates an expected call of ListTodos.
+func (mr *MockITodoServiceMockRecorder) ListTodos(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTodos", reflect.TypeOf((*MockITodoService)(nil).ListTodos), ctx, filter)
+}
+
+// ListTrash mocks base method.
+func (m *MockITodoService) ListTrash(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrash", ctx, filter)
+	ret0, _ := ret[0].(*model.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrash indicates an expected call of ListTrash.
+func (mr *MockITodoServiceMockRecorder) ListTrash(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrash", reflect.TypeOf((*MockITodoService)(nil).ListTrash), ctx, filter)
+}
+
+// RestoreTodo mocks base method.
+func (m *MockITodoService) RestoreTodo(ctx context.Context, id model.ID) (*model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTodo", ctx, id)
+	ret0, _ := ret[0].(*model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreTodo indicates an expected call of RestoreTodo.
+func (mr *MockITodoServiceMockRecorder) RestoreTodo(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTodo", reflect.TypeOf((*MockITodoService)(nil).RestoreTodo), ctx, id)
+}
+
+// SearchTodos mocks base method.
+func (m *MockITodoService) SearchTodos(ctx context.Context, filter model.SearchFilter) (*model.SearchPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchTodos", ctx, filter)
+	ret0, _ := ret[0].(*model.SearchPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchTodos indicates an expected call of SearchTodos.
+func (mr *MockITodoServiceMockRecorder) SearchTodos(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTodos", reflect.TypeOf((*MockITodoService)(nil).SearchTodos), ctx, filter)
 }
 
 // ToggleTodo mocks base method.
-func (m *MockITodoService) ToggleTodo(id model.ID) (*model.Todo, error) {
+func (m *MockITodoService) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (*model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ToggleTodo", id)
+	ret := m.ctrl.Call(m, "ToggleTodo", ctx, id, opts)
 	ret0, _ := ret[0].(*model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ToggleTodo indicates an expected call of ToggleTodo.
-func (mr *MockITodoServiceMockRecorder) ToggleTodo(id interface{}) *gomock.Call {
+func (mr *MockITodoServiceMockRecorder) ToggleTodo(ctx, id, opts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ToggleTodo", reflect.TypeOf((*MockITodoService)(nil).ToggleTodo), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ToggleTodo", reflect.TypeOf((*MockITodoService)(nil).ToggleTodo), ctx, id, opts)
 }
 
 // UpdateTodo mocks base method.
-func (m *MockITodoService) UpdateTodo(id model.ID, title *string, complete *bool) (*model.Todo, error) {
+func (m *MockITodoService) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (*model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTodo", id, title, complete)
+	ret := m.ctrl.Call(m, "UpdateTodo", ctx, id, patch)
 	ret0, _ := ret[0].(*model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateTodo indicates an expected call of UpdateTodo.
-func (mr *MockITodoServiceMockRecorder) UpdateTodo(id, title, complete interface{}) *gomock.Call {
+func (mr *MockITodoServiceMockRecorder) UpdateTodo(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTodo", reflect.TypeOf((*MockITodoService)(nil).UpdateTodo), ctx, id, patch)
+}
+
+// WatchChanges mocks base method.
+func (m *MockITodoService) WatchChanges(ctx context.Context, filter model.ChangeFilter) (<-chan model.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchChanges", ctx, filter)
+	ret0, _ := ret[0].(<-chan model.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchChanges indicates an expected call of WatchChanges.
+func (mr *MockITodoServiceMockRecorder) WatchChanges(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTodo", reflect.TypeOf((*MockITodoService)(nil).UpdateTodo), id, title, complete)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchChanges", reflect.TypeOf((*MockITodoService)(nil).WatchChanges), ctx, filter)
 }
@@ -0,0 +1,141 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package mock_service is a generated GoMock package.
+package mock_service
+
+import (
+	context "context"
+	model "crud/internal/model"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIWebhookService is a mock of IWebhookService interface.
+type MockIWebhookService struct {
+	ctrl     *gomock.Controller
+	recorder *MockIWebhookServiceMockRecorder
+}
+
+// MockIWebhookServiceMockRecorder is the mock recorder for MockIWebhookService.
+type MockIWebhookServiceMockRecorder struct {
+	mock *MockIWebhookService
+}
+
+// NewMockIWebhookService creates a new mock instance.
+func NewMockIWebhookService(ctrl *gomock.Controller) *MockIWebhookService {
+	mock := &MockIWebhookService{ctrl: ctrl}
+	mock.recorder = &MockIWebhookServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIWebhookService) EXPECT() *MockIWebhookServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateWebhook mocks base method.
+func (m *MockIWebhookService) CreateWebhook(ctx context.Context, webhook model.Webhook) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockIWebhookServiceMockRecorder) CreateWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockIWebhookService)(nil).CreateWebhook), ctx, webhook)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockIWebhookService) DeleteWebhook(ctx context.Context, id model.ID) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, id)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockIWebhookServiceMockRecorder) DeleteWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockIWebhookService)(nil).DeleteWebhook), ctx, id)
+}
+
+// GetWebhook mocks base method.
+func (m *MockIWebhookService) GetWebhook(ctx context.Context, id model.ID) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", ctx, id)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockIWebhookServiceMockRecorder) GetWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockIWebhookService)(nil).GetWebhook), ctx, id)
+}
+
+// ListDeliveries mocks base method.
+func (m *MockIWebhookService) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (*model.DeliveryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeliveries", ctx, filter)
+	ret0, _ := ret[0].(*model.DeliveryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeliveries indicates an expected call of ListDeliveries.
+func (mr *MockIWebhookServiceMockRecorder) ListDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeliveries", reflect.TypeOf((*MockIWebhookService)(nil).ListDeliveries), ctx, filter)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockIWebhookService) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", ctx)
+	ret0, _ := ret[0].([]model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockIWebhookServiceMockRecorder) ListWebhooks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockIWebhookService)(nil).ListWebhooks), ctx)
+}
+
+// Redeliver mocks base method.
+func (m *MockIWebhookService) Redeliver(ctx context.Context, webhookID model.ID, id int64) (*model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, webhookID, id)
+	ret0, _ := ret[0].(*model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockIWebhookServiceMockRecorder) Redeliver(ctx, webhookID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockIWebhookService)(nil).Redeliver), ctx, webhookID, id)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockIWebhookService) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", ctx, id, patch)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockIWebhookServiceMockRecorder) UpdateWebhook(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockIWebhookService)(nil).UpdateWebhook), ctx, id, patch)
+}
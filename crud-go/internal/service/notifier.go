@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crud/internal/model"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier sends reminders. Notify is called once for every reminder,
+// and a reminder it fails to send isn't tried again.
+type Notifier interface {
+	Notify(ctx context.Context, reminder model.Reminder) error
+}
+
+// NewNotifier returns the notifier Config.ReminderNotifier names.
+func NewNotifier(logger *slog.Logger, config *Config) (Notifier, error) {
+	switch config.ReminderNotifier {
+	case "", "log":
+		return NewLogNotifier(logger), nil
+	case "webhook":
+		if config.ReminderWebhookURL == "" {
+			return nil, errors.New("webhook notifier: no URL")
+		}
+		return NewWebhookNotifier(config.ReminderWebhookURL, config.ReminderWebhookSecret), nil
+	case "smtp":
+		if config.ReminderSMTPAddress == "" || config.ReminderSMTPFrom == "" || len(config.ReminderSMTPTo) == 0 {
+			return nil, errors.New("smtp notifier: address, sender and recipients are required")
+		}
+		return NewSMTPNotifier(config), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", config.ReminderNotifier)
+	}
+}
+
+// LogNotifier writes reminders to the log, for local runs.
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	n.logger.Info("Todo due",
+		"todo_id", reminder.TodoID,
+		"title", reminder.Todo.Title,
+		"due_at", dueIn(reminder),
+		"offset", reminder.Offset.String(),
+	)
+	return nil
+}
+
+// ReminderEvent is the body the webhook notifier posts.
+type ReminderEvent struct {
+	Event      string     `json:"event"`
+	ReminderID int64      `json:"reminderId"`
+	Offset     string     `json:"offset"`
+	DueAt      time.Time  `json:"dueAt"`
+	Todo       model.Todo `json:"todo"`
+}
+
+// ReminderEventType is the event of the reminders the webhook notifier
+// posts, also sent as X-Webhook-Event.
+const ReminderEventType = "todo.reminder"
+
+// WebhookNotifier posts reminders to an URL with the headers and the
+// signature of webhook deliveries. Anything but a 2xx answer fails.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	body, err := json.Marshal(ReminderEvent{
+		Event:      ReminderEventType,
+		ReminderID: reminder.ID,
+		Offset:     reminder.Offset.String(),
+		DueAt:      dueIn(reminder),
+		Todo:       reminder.Todo,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := n.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crud-webhooks")
+	req.Header.Set("X-Webhook-Event", ReminderEventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(reminder.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", WebhookSignature(n.secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier mails reminders. It uses STARTTLS whenever the server
+// offers it and logs in only then, unless the server is on localhost.
+type SMTPNotifier struct {
+	address string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	now     func() time.Time
+}
+
+func NewSMTPNotifier(config *Config) *SMTPNotifier {
+	n := &SMTPNotifier{
+		address: config.ReminderSMTPAddress,
+		from:    config.ReminderSMTPFrom,
+		to:      config.ReminderSMTPTo,
+		now:     time.Now,
+	}
+
+	if config.ReminderSMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(config.ReminderSMTPAddress)
+		n.auth = smtp.PlainAuth("", config.ReminderSMTPUsername, config.ReminderSMTPPassword, host)
+	}
+
+	return n
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	host, _, err := net.SplitHostPort(n.address)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if err := client.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(reminder)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message is the mail of reminder. Header values are encoded, so a
+// title can't add headers of its own.
+func (n *SMTPNotifier) message(reminder model.Reminder) []byte {
+	due := dueIn(reminder)
+	subject := fmt.Sprintf("Reminder: %s is due %s", reminder.Todo.Title, due.Format("Mon, 02 Jan 15:04 MST"))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", n.now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "%s is due %s.\r\n", reminder.Todo.Title, due.Format("Monday, 02 January 2006 at 15:04 MST"))
+	if reminder.Todo.Description != "" {
+		b.WriteString("\r\n")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(reminder.Todo.Description, "\r\n", "\n"), "\n", "\r\n"))
+		b.WriteString("\r\n")
+	}
+
+	return []byte(b.String())
+}
+
+// dueIn is the due date of reminder in the timezone of its todo.
+func dueIn(reminder model.Reminder) time.Time {
+	if location, err := time.LoadLocation(reminder.Todo.DueTimezone); err == nil {
+		return reminder.DueAt.In(location)
+	}
+	return reminder.DueAt
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crud/internal/model"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReminder() model.Reminder {
+	due := time.Date(2030, time.March, 10, 12, 30, 0, 0, time.UTC)
+	return model.Reminder{
+		ID:     7,
+		TodoID: 3,
+		Offset: time.Hour,
+		DueAt:  due,
+		Todo: model.Todo{
+			ID:          3,
+			Title:       "Pay rent",
+			Description: "Before noon\nor else",
+			DueAt:       &due,
+			DueTimezone: "Europe/Berlin",
+		},
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "default", config: Config{}},
+		{name: "log", config: Config{ReminderNotifier: "log"}},
+		{name: "webhook", config: Config{ReminderNotifier: "webhook", ReminderWebhookURL: "http://localhost/hook"}},
+		{name: "webhook without URL", config: Config{ReminderNotifier: "webhook"}, wantErr: true},
+		{name: "smtp", config: Config{ReminderNotifier: "smtp", ReminderSMTPAddress: "localhost:25", ReminderSMTPFrom: "todos@example.com", ReminderSMTPTo: []string{"me@example.com"}}},
+		{name: "smtp without recipients", config: Config{ReminderNotifier: "smtp", ReminderSMTPAddress: "localhost:25", ReminderSMTPFrom: "todos@example.com"}, wantErr: true},
+		{name: "unknown", config: Config{ReminderNotifier: "pager"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, err := NewNotifier(nil, &tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, notifier)
+		})
+	}
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	recv := &receiver{statuses: []int{http.StatusNoContent, http.StatusInternalServerError}}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	now := time.Date(2030, time.March, 10, 11, 30, 0, 0, time.UTC)
+	notifier := NewWebhookNotifier(server.URL, "0123456789abcdef")
+	notifier.now = func() time.Time { return now }
+
+	reminder := newReminder()
+	require.NoError(t, notifier.Notify(context.Background(), reminder))
+	assert.Error(t, notifier.Notify(context.Background(), reminder), "a 5xx answer fails")
+
+	deliveries := recv.received()
+	require.Len(t, deliveries, 2)
+
+	delivery := deliveries[0]
+	assert.Equal(t, ReminderEventType, delivery.header.Get("X-Webhook-Event"))
+	assert.Equal(t, "7", delivery.header.Get("X-Webhook-Delivery"))
+	assert.Equal(t, WebhookSignature("0123456789abcdef", now.Unix(), []byte(delivery.body)), delivery.header.Get("X-Webhook-Signature"))
+
+	var event ReminderEvent
+	require.NoError(t, json.Unmarshal([]byte(delivery.body), &event))
+	assert.Equal(t, ReminderEventType, event.Event)
+	assert.Equal(t, int64(7), event.ReminderID)
+	assert.Equal(t, "1h0m0s", event.Offset)
+	assert.True(t, reminder.DueAt.Equal(event.DueAt))
+	assert.Contains(t, delivery.body, `"dueAt":"2030-03-10T13:30:00+01:00"`, "the due date is in the todo's timezone")
+	assert.Equal(t, "Pay rent", event.Todo.Title)
+}
+
+type receivedMail struct {
+	from string
+	to   []string
+	data []byte
+}
+
+// newSMTPServer stands in for a mail server: it accepts one mail per
+// connection, without TLS or authentication, and hands it to mails.
+func newSMTPServer(t *testing.T) (string, <-chan receivedMail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan receivedMail, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			serveSMTP(conn, mails)
+		}
+	}()
+
+	return listener.Addr().String(), mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- receivedMail) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	var mail receivedMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL":
+			mail.from = arg
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, arg)
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			mail.data, err = text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mails <- mail
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier_Notify(t *testing.T) {
+	address, mails := newSMTPServer(t)
+
+	notifier := NewSMTPNotifier(&Config{
+		ReminderSMTPAddress: address,
+		ReminderSMTPFrom:    "todos@example.com",
+		ReminderSMTPTo:      []string{"me@example.com", "you@example.com"},
+	})
+	notifier.now = func() time.Time { return time.Date(2030, time.March, 10, 11, 30, 0, 0, time.UTC) }
+
+	reminder := newReminder()
+	reminder.Todo.Title = "Pay rent\r\nBcc: everyone@example.com"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, notifier.Notify(ctx, reminder))
+
+	var received receivedMail
+	select {
+	case received = <-mails:
+	case <-ctx.Done():
+		t.Fatal("no mail received")
+	}
+
+	assert.Equal(t, "FROM:<todos@example.com>", received.from)
+	assert.Equal(t, []string{"TO:<me@example.com>", "TO:<you@example.com>"}, received.to)
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(received.data))))
+	require.NoError(t, err)
+
+	assert.Empty(t, msg.Header.Get("Bcc"), "a title can't add headers")
+	assert.Equal(t, "me@example.com, you@example.com", msg.Header.Get("To"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Reminder: Pay rent\r\nBcc: everyone@example.com is due Sun, 10 Mar 13:30 CET", subject)
+
+	var body strings.Builder
+	_, err = bufio.NewReader(msg.Body).WriteTo(&body)
+	require.NoError(t, err)
+	assert.Contains(t, body.String(), "is due Sunday, 10 March 2030 at 13:30 CET.\n\nBefore noon\nor else\n")
+}
+
+func TestSMTPNotifier_Notify_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	notifier := NewSMTPNotifier(&Config{
+		ReminderSMTPAddress: address,
+		ReminderSMTPFrom:    "todos@example.com",
+		ReminderSMTPTo:      []string{"me@example.com"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Error(t, notifier.Notify(ctx, newReminder()))
+}
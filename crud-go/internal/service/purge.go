@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"crud/internal/store"
+	"log/slog"
+	"time"
+)
+
+// Purger permanently removes todos that have been in the trash for
+// longer than Config.TrashRetention.
+type Purger struct {
+	config    *Config
+	logger    *slog.Logger
+	todosRepo store.TodoRepository
+	now       func() time.Time
+}
+
+func NewPurger(logger *slog.Logger, store store.Store, config *Config) *Purger {
+	return &Purger{
+		config:    config,
+		logger:    logger,
+		todosRepo: store.Todos(),
+		now:       time.Now,
+	}
+}
+
+// Run purges the trash every PurgeInterval until ctx is done. It does
+// nothing if the retention is zero.
+func (p *Purger) Run(ctx context.Context) {
+	if p.config.TrashRetention <= 0 || p.config.PurgeInterval <= 0 {
+		p.logger.Info("Trash purge disabled")
+		return
+	}
+
+	ticker := time.NewTicker(p.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+			p.logger.Error("Failed purge trash", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes the todos trashed before the retention period and
+// returns how many there were.
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	purged, err := p.todosRepo.PurgeTodos(ctx, p.now().UTC().Add(-p.config.TrashRetention))
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		p.logger.Info("Purged trash", "todos", purged)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	mock_store "crud/internal/store/mocks"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurger_Purge(t *testing.T) {
+	now := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	failure := errors.New("connection refused")
+
+	testTable := []struct {
+		name           string
+		purged         int64
+		err            error
+		expectedOutput int64
+	}{
+		{name: "Purged", purged: 3, expectedOutput: 3},
+		{name: "Nothing to purge"},
+		{name: "Error", err: failure},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockTodoRepository(ctrl)
+			repo.EXPECT().PurgeTodos(gomock.Any(), now.Add(-24*time.Hour)).Return(tt.purged, tt.err)
+
+			purger := &Purger{
+				config:    &Config{TrashRetention: 24 * time.Hour, PurgeInterval: time.Hour},
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+				todosRepo: repo,
+				now:       func() time.Time { return now },
+			}
+
+			output, err := purger.Purge(context.Background())
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expectedOutput, output)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var errRecurrenceDue = fmt.Errorf("%w: a recurring todo needs a due date", model.ErrInvalidArgument)
+
+// normalizeRecurrence checks an RRULE, with or without its "RRULE:"
+// prefix, and returns it in the form it is stored in. The start of the
+// series is the due date of the todo, so the rule can't set DTSTART.
+func normalizeRecurrence(rule string) (string, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return "", nil
+	}
+
+	opt, err := rrule.StrToROption(rule)
+	if err == nil && strings.Contains(rule, "\n") {
+		err = fmt.Errorf("more than one line")
+	}
+	if err == nil && !opt.Dtstart.IsZero() {
+		err = fmt.Errorf("DTSTART is taken from the due date")
+	}
+	if err == nil {
+		_, err = rrule.NewRRule(*opt)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: recurrence %q: %v", model.ErrInvalidArgument, rule, err)
+	}
+
+	return opt.RRuleString(), nil
+}
+
+// nextOccurrence returns the due date and the rule of the occurrence
+// that follows todo, or false if the series ends with it. The rule is
+// evaluated on the wall clock of the due timezone, so a todo due at
+// 09:00 stays due at 09:00 across DST changes. A COUNT counts down with
+// every occurrence.
+func nextOccurrence(todo model.Todo) (time.Time, string, bool, error) {
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return time.Time{}, "", false, nil
+	}
+
+	loc, err := time.LoadLocation(todo.DueTimezone)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+
+	opt, err := rrule.StrToROption(todo.Recurrence)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+	if opt.Count == 1 {
+		return time.Time{}, "", false, nil
+	}
+
+	due := todo.DueAt.In(loc)
+	opt.Dtstart = due
+	rule, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+
+	next := rule.After(due, false)
+	if next.IsZero() {
+		return time.Time{}, "", false, nil
+	}
+
+	if opt.Count > 1 {
+		opt.Count--
+	}
+	opt.Dtstart = time.Time{}
+	return next.UTC(), opt.RRuleString(), true, nil
+}
+
+// recur creates the occurrence that follows the completed todo, unless
+// the series has ended or one has already been created, as it has when
+// the todo is reopened and completed again.
+func recur(ctx context.Context, tx store.Tx, todo model.Todo) error {
+	due, rule, ok, err := nextOccurrence(todo)
+	if err != nil || !ok {
+		return err
+	}
+
+	series := todo.ID
+	if todo.SeriesID != nil {
+		series = *todo.SeriesID
+	}
+
+	open := false
+	page, err := tx.Todos().ListTodos(ctx, model.TodoFilter{SeriesID: &series, Complete: &open, DueAfter: &due, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(page.Todos) > 0 {
+		return nil
+	}
+
+	_, err = createTodo(ctx, tx, model.Todo{
+		UserID:      todo.UserID,
+		ListID:      todo.ListID,
+		ParentID:    todo.ParentID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Priority:    todo.Priority,
+		DueAt:       &due,
+		DueTimezone: todo.DueTimezone,
+		Recurrence:  rule,
+		SeriesID:    &series,
+		Tags:        todo.Tags,
+	})
+	return err
+}
+
+// updateSeries applies the parts of patch that describe a series to the
+// other open occurrences of the series of todo. Completed occurrences
+// are left as they were done.
+func updateSeries(ctx context.Context, tx store.Tx, todo model.Todo, patch model.TodoPatch) error {
+	series := todo.ID
+	if todo.SeriesID != nil {
+		series = *todo.SeriesID
+	}
+
+	open := false
+	filter := model.TodoFilter{SeriesID: &series, Complete: &open, SortBy: model.SortByID, Order: model.SortAsc, Limit: maxListLimit}
+
+	var ids []model.ID
+	for {
+		page, err := tx.Todos().ListTodos(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, occurrence := range page.Todos {
+			if occurrence.ID != todo.ID {
+				ids = append(ids, occurrence.ID)
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	each := model.TodoPatch{
+		Title:       patch.Title,
+		Description: patch.Description,
+		Priority:    patch.Priority,
+		DueTimezone: patch.DueTimezone,
+		Recurrence:  patch.Recurrence,
+		Tags:        patch.Tags,
+	}
+	for _, id := range ids {
+		if _, err := updateTodo(ctx, tx, id, each); err != nil {
+			return err
+		}
+	}
+	return nil
+}
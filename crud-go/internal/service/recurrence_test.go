@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeRecurrence(t *testing.T) {
+	testTable := []struct {
+		rule     string
+		expected string
+		wantErr  bool
+	}{
+		{rule: "", expected: ""},
+		{rule: "FREQ=WEEKLY;BYDAY=MO", expected: "FREQ=WEEKLY;BYDAY=MO"},
+		{rule: " RRULE:FREQ=DAILY;INTERVAL=2 ", expected: "FREQ=DAILY;INTERVAL=2"},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", expected: "FREQ=MONTHLY;COUNT=3;BYMONTHDAY=31"},
+		{rule: "BYDAY=MO", wantErr: true},
+		{rule: "FREQ=HOURLY;BYHOUR=25", wantErr: true},
+		{rule: "FREQ=DAILY;DTSTART=20300310T120000Z", wantErr: true},
+		{rule: "DTSTART:20300310T120000Z\nRRULE:FREQ=DAILY", wantErr: true},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := normalizeRecurrence(tt.rule)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, model.ErrInvalidArgument)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rule)
+		})
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	at := func(s string) *time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return &ts
+	}
+
+	testTable := []struct {
+		name     string
+		todo     model.Todo
+		expected *time.Time
+		rule     string
+	}{
+		{
+			name:     "Weekly",
+			todo:     model.Todo{DueAt: at("2030-03-10T12:00:00Z"), Recurrence: "FREQ=WEEKLY"},
+			expected: at("2030-03-17T12:00:00Z"),
+			rule:     "FREQ=WEEKLY",
+		},
+		{
+			name:     "Keeps the wall clock into summer time",
+			todo:     model.Todo{DueAt: at("2026-03-28T08:00:00Z"), DueTimezone: "Europe/Berlin", Recurrence: "FREQ=DAILY"},
+			expected: at("2026-03-29T07:00:00Z"),
+			rule:     "FREQ=DAILY",
+		},
+		{
+			name:     "Keeps the wall clock out of summer time",
+			todo:     model.Todo{DueAt: at("2026-10-31T13:30:00Z"), DueTimezone: "America/New_York", Recurrence: "FREQ=WEEKLY"},
+			expected: at("2026-11-07T14:30:00Z"),
+			rule:     "FREQ=WEEKLY",
+		},
+		{
+			name:     "Weekdays of the timezone",
+			todo:     model.Todo{DueAt: at("2030-03-11T23:30:00Z"), DueTimezone: "Asia/Tokyo", Recurrence: "FREQ=WEEKLY;BYDAY=TU,FR"},
+			expected: at("2030-03-14T23:30:00Z"),
+			rule:     "FREQ=WEEKLY;BYDAY=TU,FR",
+		},
+		{
+			name:     "Skips months without the day",
+			todo:     model.Todo{DueAt: at("2030-01-31T09:00:00Z"), Recurrence: "FREQ=MONTHLY;BYMONTHDAY=31"},
+			expected: at("2030-03-31T09:00:00Z"),
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=31",
+		},
+		{
+			name:     "Counts down",
+			todo:     model.Todo{DueAt: at("2030-03-10T12:00:00Z"), Recurrence: "FREQ=DAILY;COUNT=3"},
+			expected: at("2030-03-11T12:00:00Z"),
+			rule:     "FREQ=DAILY;COUNT=2",
+		},
+		{
+			name: "Last of a count",
+			todo: model.Todo{DueAt: at("2030-03-10T12:00:00Z"), Recurrence: "FREQ=DAILY;COUNT=1"},
+		},
+		{
+			name: "Past until",
+			todo: model.Todo{DueAt: at("2030-03-10T12:00:00Z"), Recurrence: "FREQ=DAILY;UNTIL=20300310T235959Z"},
+		},
+		{
+			name: "Not recurring",
+			todo: model.Todo{DueAt: at("2030-03-10T12:00:00Z")},
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			next, rule, ok, err := nextOccurrence(tt.todo)
+			require.NoError(t, err)
+			if tt.expected == nil {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, *tt.expected, next)
+			assert.Equal(t, tt.rule, rule)
+		})
+	}
+}
+
+func TestTodoService_Recurrence(t *testing.T) {
+	ctx := context.Background()
+	_, lists, todos := newListTest(t)
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+
+	_, err = todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Chore", Recurrence: "FREQ=DAILY"})
+	assert.ErrorIs(t, err, model.ErrInvalidArgument, "a recurring todo needs a due date")
+
+	dueAt := time.Date(2026, time.March, 28, 8, 0, 0, 0, time.UTC)
+	first, err := todos.CreateTodo(ctx, model.Todo{
+		ListID:      inbox.ID,
+		Title:       "Chore",
+		DueAt:       &dueAt,
+		DueTimezone: "Europe/Berlin",
+		Recurrence:  "RRULE:FREQ=DAILY",
+		Tags:        []string{"home"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=DAILY", first.Recurrence)
+
+	_, err = todos.UpdateTodo(ctx, first.ID, model.TodoPatch{DueAt: model.NullableOf[time.Time](nil)})
+	assert.ErrorIs(t, err, model.ErrInvalidArgument, "the due date of a recurring todo stays")
+
+	_, err = todos.ToggleTodo(ctx, first.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+
+	open := false
+	series := func() []model.Todo {
+		page, err := todos.ListTodos(ctx, model.TodoFilter{SeriesID: &first.ID, Complete: &open, SortBy: model.SortByID, Order: model.SortAsc})
+		require.NoError(t, err)
+		return page.Todos
+	}
+
+	occurrences := series()
+	require.Len(t, occurrences, 1)
+	second := occurrences[0]
+	assert.Equal(t, "Chore", second.Title)
+	assert.Equal(t, []string{"home"}, second.Tags)
+	assert.Equal(t, "FREQ=DAILY", second.Recurrence)
+	require.NotNil(t, second.SeriesID)
+	assert.Equal(t, first.ID, *second.SeriesID)
+	require.NotNil(t, second.DueAt)
+	assert.True(t, time.Date(2026, time.March, 29, 9, 0, 0, 0, time.FixedZone("CEST", 2*60*60)).Equal(*second.DueAt),
+		"due at 09:00 in Berlin after the clocks went forward")
+
+	// Completing the todo again doesn't repeat its occurrence.
+	_, err = todos.ToggleTodo(ctx, first.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+	_, err = todos.ToggleTodo(ctx, first.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+	assert.Len(t, series(), 1)
+
+	complete := true
+	_, err = todos.UpdateTodo(ctx, second.ID, model.TodoPatch{Complete: &complete})
+	require.NoError(t, err)
+
+	occurrences = series()
+	require.Len(t, occurrences, 1)
+	third := occurrences[0]
+	require.NotNil(t, third.SeriesID)
+	assert.Equal(t, first.ID, *third.SeriesID, "occurrences link to the first todo of the series")
+	require.NotNil(t, third.DueAt)
+	assert.True(t, time.Date(2026, time.March, 30, 7, 0, 0, 0, time.UTC).Equal(*third.DueAt))
+
+	// A todo that stopped recurring ends the series.
+	stop := ""
+	_, err = todos.UpdateTodo(ctx, third.ID, model.TodoPatch{Recurrence: &stop, Complete: &complete})
+	require.NoError(t, err)
+	assert.Empty(t, series())
+}
+
+func TestTodoService_UpdateSeries(t *testing.T) {
+	ctx := context.Background()
+	_, lists, todos := newListTest(t)
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+
+	dueAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	first, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Review", DueAt: &dueAt, Recurrence: "FREQ=WEEKLY"})
+	require.NoError(t, err)
+	_, err = todos.ToggleTodo(ctx, first.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+
+	page, err := todos.ListTodos(ctx, model.TodoFilter{SeriesID: &first.ID, SortBy: model.SortByID, Order: model.SortAsc})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 2)
+	second := page.Todos[1]
+
+	// Some other occurrence, as left open by an earlier rule.
+	third, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Review", DueAt: &dueAt, Recurrence: "FREQ=WEEKLY", SeriesID: &first.ID})
+	require.NoError(t, err)
+
+	title := "Weekly review"
+	updated, err := todos.UpdateTodo(ctx, second.ID, model.TodoPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+
+	got, err := todos.GetTodos(ctx, []model.ID{first.ID, third.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Review", got[0].Title)
+	assert.Equal(t, "Review", got[1].Title, "only the occurrence is changed")
+
+	title, rule := "Monthly review", "FREQ=MONTHLY"
+	later := dueAt.AddDate(0, 0, 14)
+	_, err = todos.UpdateTodo(ctx, second.ID, model.TodoPatch{Title: &title, Recurrence: &rule, DueAt: model.NullableOf(&later), Series: true})
+	require.NoError(t, err)
+
+	got, err = todos.GetTodos(ctx, []model.ID{first.ID, second.ID, third.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, "Review", got[0].Title, "completed occurrences are kept as they were")
+	assert.Equal(t, "FREQ=WEEKLY", got[0].Recurrence)
+	for _, todo := range got[1:] {
+		assert.Equal(t, title, todo.Title)
+		assert.Equal(t, rule, todo.Recurrence)
+	}
+	assert.True(t, later.Equal(*got[1].DueAt))
+	assert.True(t, dueAt.Equal(*got[2].DueAt), "due dates are only changed for the todo")
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	// reminderLease is held by the instance that sends the reminders.
+	reminderLease = "reminders"
+	// reminderBatch is how many reminders are claimed at once.
+	reminderBatch = 20
+)
+
+// ReminderScheduler reminds of todos due within the ReminderOffsets.
+// Several instances can run against one store: the one holding the
+// reminder lease sends them, and every reminder is claimed once before
+// it is sent, so none is sent twice.
+type ReminderScheduler struct {
+	config        *Config
+	logger        *slog.Logger
+	remindersRepo store.ReminderRepository
+	leasesRepo    store.LeaseRepository
+	notifier      Notifier
+	// holder names the instance to the lease.
+	holder string
+	now    func() time.Time
+}
+
+func NewReminderScheduler(logger *slog.Logger, store store.Store, config *Config, notifier Notifier) *ReminderScheduler {
+	return &ReminderScheduler{
+		config:        config,
+		logger:        logger,
+		remindersRepo: store.Reminders(),
+		leasesRepo:    store.Leases(),
+		notifier:      notifier,
+		holder:        leaseHolder(),
+		now:           time.Now,
+	}
+}
+
+// leaseHolder is the host name and process id, which tell operators who
+// holds a lease, and a random part, which sets apart processes that
+// share both.
+func leaseHolder() string {
+	host, _ := os.Hostname()
+
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Run sends due reminders every ReminderPollInterval until ctx is done,
+// then gives up the lease so another instance takes over right away.
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	if s.config.ReminderPollInterval <= 0 || len(s.config.ReminderOffsets) == 0 {
+		s.logger.Info("Reminders disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.config.ReminderPollInterval)
+	defer ticker.Stop()
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.config.ReminderTimeout)
+		defer cancel()
+
+		if err := s.leasesRepo.ReleaseLease(ctx, reminderLease, s.holder); err != nil {
+			s.logger.Error("Failed release reminder lease", "error", err.Error())
+		}
+	}()
+
+	for {
+		if _, err := s.Remind(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed send reminders", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Remind sends the reminders due now, if this instance holds the lease,
+// and returns how many it claimed. Reminders due more than
+// ReminderMaxDelay ago are left out.
+func (s *ReminderScheduler) Remind(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+
+	// The lease outlasts the next poll, so it only passes on when its
+	// holder stops renewing it.
+	held, err := s.leasesRepo.AcquireLease(ctx, reminderLease, s.holder, now, now.Add(2*s.config.ReminderPollInterval))
+	if err != nil || !held {
+		return 0, err
+	}
+
+	from := now.Add(-s.config.ReminderMaxDelay)
+
+	var claimed int
+	for _, offset := range s.config.ReminderOffsets {
+		for {
+			reminders, err := s.remindersRepo.ClaimReminders(ctx, offset, from, now, reminderBatch)
+			if err != nil {
+				return claimed, err
+			}
+			claimed += len(reminders)
+
+			for _, reminder := range reminders {
+				s.send(ctx, reminder)
+			}
+
+			// A full batch suggests more are due.
+			if len(reminders) < reminderBatch {
+				break
+			}
+		}
+	}
+
+	return claimed, nil
+}
+
+// send notifies of reminder and records the outcome, even when ctx is
+// done meanwhile, as the reminder is claimed either way.
+func (s *ReminderScheduler) send(ctx context.Context, reminder model.Reminder) {
+	notifyCtx, cancel := context.WithTimeout(ctx, s.config.ReminderTimeout)
+	err := s.notifier.Notify(notifyCtx, reminder)
+	cancel()
+
+	logger := s.logger.With("reminder_id", reminder.ID, "todo_id", reminder.TodoID, "offset", reminder.Offset.String())
+
+	var sendErr string
+	if err != nil {
+		sendErr = err.Error()
+		logger.Error("Failed send reminder", "error", sendErr)
+	} else {
+		logger.Debug("Sent reminder")
+	}
+
+	if err := s.remindersRepo.RecordReminder(context.WithoutCancel(ctx), reminder.ID, s.now().UTC(), sendErr); err != nil {
+		logger.Error("Failed record reminder", "error", err.Error())
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier keeps the reminders it is given and fails those of
+// the todos in fail.
+type recordingNotifier struct {
+	mu        sync.Mutex
+	fail      map[model.ID]bool
+	reminders []model.Reminder
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, reminder model.Reminder) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reminders = append(n.reminders, reminder)
+	if n.fail[reminder.TodoID] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (n *recordingNotifier) notified() []model.ID {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ids := make([]model.ID, len(n.reminders))
+	for i, reminder := range n.reminders {
+		ids[i] = reminder.TodoID
+	}
+	return ids
+}
+
+func newReminderScheduler(s store.Store, notifier Notifier, now *time.Time) *ReminderScheduler {
+	config := &Config{
+		ReminderOffsets:      []time.Duration{time.Hour, 0},
+		ReminderPollInterval: time.Minute,
+		ReminderMaxDelay:     time.Hour,
+		ReminderTimeout:      time.Second,
+	}
+
+	scheduler := NewReminderScheduler(slog.New(slog.NewTextHandler(io.Discard, nil)), s, config, notifier)
+	scheduler.now = func() time.Time { return *now }
+	return scheduler
+}
+
+func TestReminderScheduler_Remind(t *testing.T) {
+	ctx := context.Background()
+	_, lists, todos := newListTest(t)
+	s := todos.(*TodoService).store
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+
+	now := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	create := func(title string, due time.Time) *model.Todo {
+		t.Helper()
+		todo, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: title, DueAt: &due})
+		require.NoError(t, err)
+		return todo
+	}
+
+	soon := create("Soon", now.Add(30*time.Minute))
+	failing := create("Failing", now.Add(45*time.Minute))
+	create("Later", now.Add(3*time.Hour))
+	create("Long overdue", now.Add(-2*time.Hour))
+
+	notifier := &recordingNotifier{fail: map[model.ID]bool{failing.ID: true}}
+	scheduler := newReminderScheduler(s, notifier, &now)
+	other := newReminderScheduler(s, notifier, &now)
+
+	claimed, err := scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, []model.ID{soon.ID, failing.ID}, notifier.notified())
+
+	claimed, err = other.Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed, "another instance waits while the lease is held")
+
+	claimed, err = scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed, "reminders are sent once, failed ones too")
+
+	// The due dates come; the lease has run out meanwhile, so the other
+	// instance takes over.
+	now = now.Add(50 * time.Minute)
+	claimed, err = other.Remind(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, []model.ID{soon.ID, failing.ID, soon.ID, failing.ID}, notifier.notified()[:4])
+
+	claimed, err = scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+}
+
+func TestReminderScheduler_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := store.NewMemoryStore(nil)
+	require.NoError(t, s.Open())
+	t.Cleanup(func() { s.Close() })
+
+	now := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	scheduler := newReminderScheduler(s, &recordingNotifier{}, &now)
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		held, err := s.Leases().AcquireLease(context.Background(), reminderLease, "other", now, now.Add(time.Minute))
+		require.NoError(t, err)
+		return !held
+	}, time.Second, 10*time.Millisecond, "the lease is taken")
+
+	cancel()
+	<-done
+
+	held, err := s.Leases().AcquireLease(context.Background(), reminderLease, "other", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, held, "the lease is given up on the way out")
+}
@@ -1,63 +1,522 @@
 package service
 
 import (
+	"context"
 	"crud/internal/model"
 	"crud/internal/store"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/service.go
 
 type ITodoService interface {
-	GetTodos(ids []model.ID) ([]model.Todo, error)
-	CreateTodo(title string) (*model.Todo, error)
-	ToggleTodo(id model.ID) (*model.Todo, error)
-	UpdateTodo(id model.ID, title *string, complete *bool) (*model.Todo, error)
-	DeleteTodo(id model.ID) (*model.Todo, error)
+	GetTodo(ctx context.Context, id model.ID) (*model.Todo, error)
+	GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error)
+	GetTodoTree(ctx context.Context, id model.ID) (*model.TodoTree, error)
+	ListTodos(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error)
+	// SearchTodos finds todos by the words of their title and
+	// description, best matches first.
+	SearchTodos(ctx context.Context, filter model.SearchFilter) (*model.SearchPage, error)
+	// ExportTodos calls each with every live todo matching filter, in
+	// pages of its own, so that exports of any size can be streamed. The
+	// cursor and limit of filter are ignored; todos come in id order
+	// unless filter sorts them otherwise.
+	ExportTodos(ctx context.Context, filter model.TodoFilter, each func(model.Todo) error) error
+	// ImportTodos creates todos in one transaction, in order, and returns
+	// them. A ParentID naming the ID of an earlier todo of the import is
+	// taken to mean the todo created for it; any other one is cleared.
+	// With dryRun nothing is committed.
+	ImportTodos(ctx context.Context, todos []model.Todo, dryRun bool) ([]model.Todo, error)
+	// ListTrash lists deleted todos that haven't been purged yet.
+	ListTrash(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error)
+	CreateTodo(ctx context.Context, todo model.Todo) (*model.Todo, error)
+	ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (*model.Todo, error)
+	UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (*model.Todo, error)
+	DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (*model.Todo, error)
+	RestoreTodo(ctx context.Context, id model.ID) (*model.Todo, error)
+	// Batch runs ops in one transaction. If atomic, the first failing
+	// operation rolls all of them back and its error is returned.
+	// Otherwise a failing operation is only rolled back itself and its
+	// error is reported in its result.
+	Batch(ctx context.Context, ops []model.BatchOp, atomic bool) ([]model.BatchResult, error)
+	// ListAudit lists the recorded changes of todos, oldest first.
+	ListAudit(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error)
+	// WatchChanges streams the changes matching filter, first those since
+	// filter.AfterID, if set, and then the live ones. The channel is
+	// closed when ctx is done or the feed breaks off, after which the
+	// client can resume from the last change it got.
+	WatchChanges(ctx context.Context, filter model.ChangeFilter) (<-chan model.Change, error)
 }
 
 var _ ITodoService = &TodoService{}
 
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
 type TodoService struct {
 	config    *Config
 	logger    *slog.Logger
+	store     store.Store
 	todosRepo store.TodoRepository
 }
 
-func NewTodoService(logger *slog.Logger, todosRepo store.TodoRepository, config *Config) ITodoService {
+func NewTodoService(logger *slog.Logger, store store.Store, config *Config) ITodoService {
 	return &TodoService{
 		config:    config,
 		logger:    logger,
-		todosRepo: todosRepo,
+		store:     store,
+		todosRepo: store.Todos(),
+	}
+}
+
+// inTx runs a single change in a transaction of its own, so that it is
+// committed together with its audit entry.
+func (t *TodoService) inTx(ctx context.Context, change func(tx store.Tx) (*model.Todo, error)) (*model.Todo, error) {
+	var todo *model.Todo
+	err := t.store.InTx(ctx, func(tx store.Tx) error {
+		var err error
+		todo, err = change(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return todo, nil
 }
 
 // CreateTodo implements ITodoService.
-func (t *TodoService) CreateTodo(title string) (*model.Todo, error) {
-	todo, err := t.todosRepo.CreateTodo(title)
-	return &todo, err
+func (t *TodoService) CreateTodo(ctx context.Context, todo model.Todo) (*model.Todo, error) {
+	return t.inTx(ctx, func(tx store.Tx) (*model.Todo, error) {
+		return createTodo(ctx, tx, todo)
+	})
+}
+
+func createTodo(ctx context.Context, tx store.Tx, todo model.Todo) (*model.Todo, error) {
+	if err := checkTimezone(todo.DueTimezone); err != nil {
+		return nil, err
+	}
+
+	if err := checkList(ctx, tx, todo.ListID); err != nil {
+		return nil, err
+	}
+
+	recurrence, err := normalizeRecurrence(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	if recurrence != "" && todo.DueAt == nil {
+		return nil, errRecurrenceDue
+	}
+	todo.Recurrence = recurrence
+
+	todo.Tags = normalizeTags(todo.Tags)
+
+	todo, err = tx.Todos().CreateTodo(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, audit(ctx, tx, model.AuditCreate, nil, &todo)
 }
 
 // DeleteTodo implements ITodoService.
-func (t *TodoService) DeleteTodo(id model.ID) (*model.Todo, error) {
-	todo, err := t.todosRepo.DeleteTodo(id)
-	return &todo, err
+func (t *TodoService) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (*model.Todo, error) {
+	return t.inTx(ctx, func(tx store.Tx) (*model.Todo, error) {
+		return deleteTodo(ctx, tx, id, opts)
+	})
+}
+
+func deleteTodo(ctx context.Context, tx store.Tx, id model.ID, opts model.DeleteOptions) (*model.Todo, error) {
+	// The subtasks go along or move up to the parent.
+	return change(ctx, tx, id, model.AuditDelete, true, func(repo store.TodoRepository) (model.Todo, error) {
+		return repo.DeleteTodo(ctx, id, opts)
+	})
+}
+
+// GetTodo implements ITodoService.
+func (t *TodoService) GetTodo(ctx context.Context, id model.ID) (*model.Todo, error) {
+	todos, err := t.todosRepo.GetTodos(ctx, []model.ID{id})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(todos) == 0 {
+		return nil, model.ErrNotFound
+	}
+
+	return &todos[0], nil
 }
 
 // GetTodos implements ITodoService.
-func (t *TodoService) GetTodos(ids []model.ID) ([]model.Todo, error) {
-	todos, err := t.todosRepo.GetTodos(ids)
+func (t *TodoService) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
+	todos, err := t.todosRepo.GetTodos(ctx, ids)
 	return todos, err
 }
 
+// GetTodoTree implements ITodoService.
+func (t *TodoService) GetTodoTree(ctx context.Context, id model.ID) (*model.TodoTree, error) {
+	todos, err := t.todosRepo.GetSubtree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(todos) == 0 {
+		return nil, model.ErrNotFound
+	}
+
+	tree := buildTree(id, todos)
+	return &tree, nil
+}
+
+// buildTree arranges the subtree of root, as returned by the repository,
+// and rolls the progress of every todo up from its descendants.
+func buildTree(root model.ID, todos []model.Todo) model.TodoTree {
+	var rootTodo model.Todo
+	children := make(map[model.ID][]model.Todo)
+
+	for _, todo := range todos {
+		if todo.ID == root {
+			rootTodo = todo
+			continue
+		}
+		children[*todo.ParentID] = append(children[*todo.ParentID], todo)
+	}
+
+	var build func(todo model.Todo) model.TodoTree
+	build = func(todo model.Todo) model.TodoTree {
+		tree := model.TodoTree{
+			Todo:     todo,
+			Children: make([]model.TodoTree, 0, len(children[todo.ID])),
+		}
+
+		for _, child := range children[todo.ID] {
+			subtree := build(child)
+
+			tree.Progress.Total += subtree.Progress.Total + 1
+			tree.Progress.Completed += subtree.Progress.Completed
+			if child.Complete {
+				tree.Progress.Completed++
+			}
+
+			tree.Children = append(tree.Children, subtree)
+		}
+
+		return tree
+	}
+
+	return build(rootTodo)
+}
+
+// ListTodos implements ITodoService.
+func (t *TodoService) ListTodos(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+	filter.Trashed = false
+	return t.listTodos(ctx, filter)
+}
+
+// SearchTodos implements ITodoService.
+func (t *TodoService) SearchTodos(ctx context.Context, filter model.SearchFilter) (*model.SearchPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	page, err := t.todosRepo.SearchTodos(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ListTrash implements ITodoService.
+func (t *TodoService) ListTrash(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+	filter.Trashed = true
+	return t.listTodos(ctx, filter)
+}
+
+func (t *TodoService) listTodos(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+	if filter.SortBy == "" {
+		filter.SortBy = model.SortByCreatedAt
+	}
+	if filter.Order == "" {
+		filter.Order = model.SortDesc
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if len(filter.Tags) > 0 {
+		filter.Tags = normalizeTags(filter.Tags)
+	}
+
+	page, err := t.todosRepo.ListTodos(ctx, filter)
+	return &page, err
+}
+
+// RestoreTodo implements ITodoService.
+func (t *TodoService) RestoreTodo(ctx context.Context, id model.ID) (*model.Todo, error) {
+	return t.inTx(ctx, func(tx store.Tx) (*model.Todo, error) {
+		return change(ctx, tx, id, model.AuditRestore, true, func(repo store.TodoRepository) (model.Todo, error) {
+			return repo.RestoreTodo(ctx, id)
+		})
+	})
+}
+
 // ToggleTodo implements ITodoService.
-func (t *TodoService) ToggleTodo(id model.ID) (*model.Todo, error) {
-	todo, err := t.todosRepo.ToggleTodo(id)
-	return &todo, err
+func (t *TodoService) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (*model.Todo, error) {
+	return t.inTx(ctx, func(tx store.Tx) (*model.Todo, error) {
+		return toggleTodo(ctx, tx, id, opts)
+	})
+}
+
+func toggleTodo(ctx context.Context, tx store.Tx, id model.ID, opts model.ToggleOptions) (*model.Todo, error) {
+	return change(ctx, tx, id, model.AuditToggle, opts.Cascade, func(repo store.TodoRepository) (model.Todo, error) {
+		return repo.ToggleTodo(ctx, id, opts)
+	})
 }
 
 // UpdateTodo implements ITodoService.
-func (t *TodoService) UpdateTodo(id model.ID, title *string, complete *bool) (*model.Todo, error) {
-	todo, err := t.todosRepo.UpdateTodo(id, title, complete)
-	return &todo, err
+func (t *TodoService) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (*model.Todo, error) {
+	return t.inTx(ctx, func(tx store.Tx) (*model.Todo, error) {
+		return updateTodo(ctx, tx, id, patch)
+	})
+}
+
+func updateTodo(ctx context.Context, tx store.Tx, id model.ID, patch model.TodoPatch) (*model.Todo, error) {
+	if patch.DueTimezone != nil {
+		if err := checkTimezone(*patch.DueTimezone); err != nil {
+			return nil, err
+		}
+	}
+
+	if patch.Tags != nil {
+		tags := normalizeTags(*patch.Tags)
+		patch.Tags = &tags
+	}
+
+	if patch.ListID != nil {
+		if err := checkList(ctx, tx, *patch.ListID); err != nil {
+			return nil, err
+		}
+	}
+
+	if patch.Recurrence != nil {
+		recurrence, err := normalizeRecurrence(*patch.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		patch.Recurrence = &recurrence
+	}
+
+	// The subtree moves along to another list.
+	todo, err := change(ctx, tx, id, model.AuditUpdate, patch.ListID != nil, func(repo store.TodoRepository) (model.Todo, error) {
+		todo, err := repo.UpdateTodo(ctx, id, patch)
+		if err == nil && todo.Recurrence != "" && todo.DueAt == nil {
+			return model.Todo{}, errRecurrenceDue
+		}
+		return todo, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Series {
+		if err := updateSeries(ctx, tx, *todo, patch); err != nil {
+			return nil, err
+		}
+	}
+	return todo, nil
+}
+
+// change applies write to the todo id and records it, along with every
+// descendant it changes if it cascades. The todos are locked first, so
+// that their state before the write is the one the write applies to.
+// Completing a recurring todo creates its next occurrence.
+func change(ctx context.Context, tx store.Tx, id model.ID, action model.AuditAction, cascades bool, write func(repo store.TodoRepository) (model.Todo, error)) (*model.Todo, error) {
+	before, err := tx.Todos().LockTodo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var descendants []model.Todo
+	if cascades {
+		if descendants, err = tx.Todos().LockDescendants(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := write(tx.Todos())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := audit(ctx, tx, action, &before, &after); err != nil {
+		return nil, err
+	}
+	if err := auditDescendants(ctx, tx, id, action, descendants); err != nil {
+		return nil, err
+	}
+
+	if !before.Complete && after.Complete && after.Recurrence != "" {
+		if err := recur(ctx, tx, after); err != nil {
+			return nil, err
+		}
+	}
+	return &after, nil
+}
+
+// auditDescendants records the descendants of id whose version a write
+// with action bumped, from their state before it.
+func auditDescendants(ctx context.Context, tx store.Tx, id model.ID, action model.AuditAction, before []model.Todo) error {
+	if len(before) == 0 {
+		return nil
+	}
+
+	descendants, err := tx.Todos().LockDescendants(ctx, id)
+	if err != nil {
+		return err
+	}
+	current := make(map[model.ID]model.Todo, len(descendants))
+	for _, todo := range descendants {
+		current[todo.ID] = todo
+	}
+
+	for i := range before {
+		after, ok := current[before[i].ID]
+		if !ok {
+			// Deleting a todo alone hands its subtasks to its parent.
+			if after, err = tx.Todos().LockTodo(ctx, before[i].ID); err != nil {
+				return err
+			}
+		}
+		if after.Version == before[i].Version {
+			continue
+		}
+
+		// A subtask that stays in or out of the trash is only updated.
+		descendantAction := action
+		if (before[i].DeletedAt == nil) == (after.DeletedAt == nil) && (action == model.AuditDelete || action == model.AuditRestore) {
+			descendantAction = model.AuditUpdate
+		}
+
+		if err := audit(ctx, tx, descendantAction, &before[i], &after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func audit(ctx context.Context, tx store.Tx, action model.AuditAction, before, after *model.Todo) error {
+	info := RequestInfoFrom(ctx)
+	entry, err := tx.Audit().AppendAudit(ctx, model.AuditEntry{
+		TodoID:    after.ID,
+		Action:    action,
+		Before:    before,
+		After:     after,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return enqueueDeliveries(ctx, tx, entry)
+}
+
+// ListAudit implements ITodoService.
+func (t *TodoService) ListAudit(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	page, err := t.store.Audit().ListAudit(ctx, filter)
+	return &page, err
+}
+
+// Batch implements ITodoService.
+func (t *TodoService) Batch(ctx context.Context, ops []model.BatchOp, atomic bool) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ops))
+
+	err := t.store.InTx(ctx, func(tx store.Tx) error {
+		for i, op := range ops {
+			var todo *model.Todo
+			apply := func() error {
+				var err error
+				todo, err = applyOp(ctx, tx, op)
+				return err
+			}
+
+			if atomic {
+				if err := apply(); err != nil {
+					return fmt.Errorf("operation %d: %w", i, err)
+				}
+			} else if err := tx.Savepoint(ctx, apply); err != nil {
+				// Nothing can be committed once the context is done.
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+
+				results[i] = model.BatchResult{Err: err}
+				continue
+			}
+
+			results[i] = model.BatchResult{Todo: todo}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func applyOp(ctx context.Context, tx store.Tx, op model.BatchOp) (*model.Todo, error) {
+	switch op.Type {
+	case model.BatchCreate:
+		return createTodo(ctx, tx, op.Todo)
+	case model.BatchUpdate:
+		return updateTodo(ctx, tx, op.ID, op.Patch)
+	case model.BatchToggle:
+		return toggleTodo(ctx, tx, op.ID, model.ToggleOptions{Cascade: op.Cascade, IfVersion: op.IfVersion})
+	case model.BatchDelete:
+		return deleteTodo(ctx, tx, op.ID, model.DeleteOptions{Cascade: op.Cascade, IfVersion: op.IfVersion})
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", model.ErrInvalidArgument, op.Type)
+	}
+}
+
+// normalizeTags trims and lowercases tags and drops empty and repeated
+// ones, so tag filters match regardless of how a tag was typed.
+func normalizeTags(tags []string) []string {
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(ret, tag) {
+			continue
+		}
+		ret = append(ret, tag)
+	}
+	return ret
+}
+
+func checkTimezone(name string) error {
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", model.ErrInvalidArgument, name)
+	}
+	return nil
 }
@@ -1,17 +1,70 @@
 package service
 
 import (
+	"context"
 	"crud/internal/model"
+	"crud/internal/store"
 	mock_store "crud/internal/store/mocks"
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Тестирую моки)))0
 
+// newTxService returns a service whose transactions run on repo and
+// record into audit. Every todo can be locked and has no subtasks.
+func newTxService(ctrl *gomock.Controller, repo *mock_store.MockTodoRepository, audit *mock_store.MockAuditRepository) *TodoService {
+	repo.EXPECT().LockTodo(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id model.ID) (model.Todo, error) { return model.Todo{ID: id}, nil },
+	).AnyTimes()
+	repo.EXPECT().LockDescendants(gomock.Any(), gomock.Any()).AnyTimes()
+
+	tx := mock_store.NewMockTx(ctrl)
+	tx.EXPECT().Todos().Return(repo).AnyTimes()
+	tx.EXPECT().Lists().Return(anyLists(ctrl)).AnyTimes()
+	tx.EXPECT().Audit().Return(audit).AnyTimes()
+	tx.EXPECT().Webhooks().Return(anyWebhooks(ctrl)).AnyTimes()
+	tx.EXPECT().Savepoint(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func() error) error { return fn() },
+	).AnyTimes()
+
+	s := mock_store.NewMockStore(ctrl)
+	s.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func(tx store.Tx) error) error { return fn(tx) },
+	).AnyTimes()
+
+	return &TodoService{store: s, todosRepo: repo}
+}
+
+// anyAudit lets a test ignore the audit entries it causes.
+func anyAudit(ctrl *gomock.Controller) *mock_store.MockAuditRepository {
+	audit := mock_store.NewMockAuditRepository(ctrl)
+	audit.EXPECT().AppendAudit(gomock.Any(), gomock.Any()).AnyTimes()
+	return audit
+}
+
+// anyLists lets todos go to any list.
+func anyLists(ctrl *gomock.Controller) *mock_store.MockListRepository {
+	lists := mock_store.NewMockListRepository(ctrl)
+	lists.EXPECT().GetList(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id model.ID) (model.List, error) { return model.List{ID: id}, nil },
+	).AnyTimes()
+	return lists
+}
+
+// anyWebhooks lets a test ignore the deliveries its changes queue.
+func anyWebhooks(ctrl *gomock.Controller) *mock_store.MockWebhookRepository {
+	webhooks := mock_store.NewMockWebhookRepository(ctrl)
+	webhooks.EXPECT().EnqueueDeliveries(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	return webhooks
+}
+
 func TestService_GetTodos(t *testing.T) {
 	type mockBehavior func(s *mock_store.MockTodoRepository, ids []model.ID)
 
@@ -58,7 +111,7 @@ func TestService_GetTodos(t *testing.T) {
 			name: "Correct case 1",
 			args: []model.ID{1},
 			mockBehavior: func(s *mock_store.MockTodoRepository, ids []model.ID) {
-				s.EXPECT().GetTodos(ids).Return(testBase[0:1], nil)
+				s.EXPECT().GetTodos(gomock.Any(), ids).Return(testBase[0:1], nil)
 			},
 			expectedOutput: testBase[0:1],
 		},
@@ -66,7 +119,7 @@ func TestService_GetTodos(t *testing.T) {
 			name: "Correct case 2",
 			args: []model.ID{1, 5},
 			mockBehavior: func(s *mock_store.MockTodoRepository, ids []model.ID) {
-				s.EXPECT().GetTodos(ids).Return([]model.Todo{testBase[0], testBase[4]}, nil)
+				s.EXPECT().GetTodos(gomock.Any(), ids).Return([]model.Todo{testBase[0], testBase[4]}, nil)
 			},
 			expectedOutput: []model.Todo{testBase[0], testBase[4]},
 		},
@@ -74,7 +127,7 @@ func TestService_GetTodos(t *testing.T) {
 			name: "Not found 1",
 			args: []model.ID{1000},
 			mockBehavior: func(s *mock_store.MockTodoRepository, ids []model.ID) {
-				s.EXPECT().GetTodos(ids).Return([]model.Todo{}, nil)
+				s.EXPECT().GetTodos(gomock.Any(), ids).Return([]model.Todo{}, nil)
 			},
 			expectedOutput: []model.Todo{},
 		},
@@ -90,32 +143,169 @@ func TestService_GetTodos(t *testing.T) {
 
 			service := &TodoService{todosRepo: repo}
 
-			output, err := service.GetTodos(tt.args)
+			output, err := service.GetTodos(context.Background(), tt.args)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutput, output)
+		})
+	}
+}
+
+func TestService_GetTodo(t *testing.T) {
+	type mockBehavior func(s *mock_store.MockTodoRepository, id model.ID)
+
+	exampleTodoInBase := model.Todo{ID: 1, Title: "Title 1", Complete: false, CreatedAt: time.Now()}
+
+	testTable := []struct {
+		name           string
+		args           model.ID
+		mockBehavior   mockBehavior
+		expectedOutput *model.Todo
+		expectedError  error
+	}{
+		{
+			name: "Found",
+			args: 1,
+			mockBehavior: func(s *mock_store.MockTodoRepository, id model.ID) {
+				s.EXPECT().GetTodos(gomock.Any(), []model.ID{id}).Return([]model.Todo{exampleTodoInBase}, nil)
+			},
+			expectedOutput: &exampleTodoInBase,
+		},
+		{
+			name: "Not found",
+			args: 1000,
+			mockBehavior: func(s *mock_store.MockTodoRepository, id model.ID) {
+				s.EXPECT().GetTodos(gomock.Any(), []model.ID{id}).Return([]model.Todo{}, nil)
+			},
+			expectedError: model.ErrNotFound,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockTodoRepository(ctrl)
+			tt.mockBehavior(repo, tt.args)
+
+			service := &TodoService{todosRepo: repo}
+
+			output, err := service.GetTodo(context.Background(), tt.args)
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.Equal(t, tt.expectedOutput, output)
+		})
+	}
+}
+
+func TestService_ListTodos(t *testing.T) {
+	type mockBehavior func(s *mock_store.MockTodoRepository, filter model.TodoFilter)
+
+	page := model.TodoPage{
+		Todos:      []model.Todo{{ID: 2, Title: "Test todo 2", CreatedAt: time.Now()}},
+		NextCursor: "next",
+	}
+
+	testTable := []struct {
+		name           string
+		args           model.TodoFilter
+		mockBehavior   mockBehavior
+		expectedOutput *model.TodoPage
+	}{
+		{
+			name: "Defaults",
+			args: model.TodoFilter{},
+			mockBehavior: func(s *mock_store.MockTodoRepository, filter model.TodoFilter) {
+				s.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{
+					SortBy: model.SortByCreatedAt,
+					Order:  model.SortDesc,
+					Limit:  defaultListLimit,
+				}).Return(page, nil)
+			},
+			expectedOutput: &page,
+		},
+		{
+			name: "Limit capped",
+			args: model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Cursor: "abc", Limit: 1000},
+			mockBehavior: func(s *mock_store.MockTodoRepository, filter model.TodoFilter) {
+				filter.Limit = maxListLimit
+				s.EXPECT().ListTodos(gomock.Any(), filter).Return(page, nil)
+			},
+			expectedOutput: &page,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockTodoRepository(ctrl)
+			tt.mockBehavior(repo, tt.args)
+
+			service := &TodoService{todosRepo: repo}
+
+			output, err := service.ListTodos(context.Background(), tt.args)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedOutput, output)
 		})
 	}
 }
 
+func TestService_ListTrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := model.TodoPage{Todos: []model.Todo{{ID: 2, Title: "Test todo 2"}}}
+
+	repo := mock_store.NewMockTodoRepository(ctrl)
+	repo.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{
+		Trashed: true,
+		SortBy:  model.SortByCreatedAt,
+		Order:   model.SortDesc,
+		Limit:   defaultListLimit,
+	}).Return(page, nil)
+
+	service := &TodoService{todosRepo: repo}
+
+	output, err := service.ListTrash(context.Background(), model.TodoFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, &page, output)
+}
+
 func TestService_CreateTodo(t *testing.T) {
-	type mockBehavior func(s *mock_store.MockTodoRepository, title string)
+	type mockBehavior func(s *mock_store.MockTodoRepository, todo model.Todo)
 
-	exampleTodoInBase := &model.Todo{ID: 1, Title: "Title 1", Complete: false, CreatedAt: time.Now()}
+	exampleTodoInBase := &model.Todo{ID: 1, Title: "Title 1", Complete: false, Tags: []string{}, CreatedAt: time.Now()}
 
 	testTable := []struct {
 		name           string
-		args           string
+		args           model.Todo
 		mockBehavior   mockBehavior
 		expectedOutput *model.Todo
+		expectedError  error
 	}{
 		{
 			name: "Correct case 1",
-			args: "Title 1",
-			mockBehavior: func(s *mock_store.MockTodoRepository, title string) {
-				s.EXPECT().CreateTodo(title).Return(*exampleTodoInBase, nil)
+			args: model.Todo{Title: "Title 1"},
+			mockBehavior: func(s *mock_store.MockTodoRepository, todo model.Todo) {
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{Title: "Title 1", Tags: []string{}}).Return(*exampleTodoInBase, nil)
 			},
 			expectedOutput: exampleTodoInBase,
 		},
+		{
+			name: "Tags are normalized",
+			args: model.Todo{Title: "Title 1", Tags: []string{" Work", "work", "", "HOME "}},
+			mockBehavior: func(s *mock_store.MockTodoRepository, todo model.Todo) {
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{Title: "Title 1", Tags: []string{"work", "home"}}).Return(*exampleTodoInBase, nil)
+			},
+			expectedOutput: exampleTodoInBase,
+		},
+		{
+			name:          "Unknown timezone",
+			args:          model.Todo{Title: "Title 1", DueTimezone: "Mars/Olympus"},
+			mockBehavior:  func(s *mock_store.MockTodoRepository, todo model.Todo) {},
+			expectedError: model.ErrInvalidArgument,
+		},
 	}
 
 	ctrl := gomock.NewController(t)
@@ -126,9 +316,13 @@ func TestService_CreateTodo(t *testing.T) {
 			repo := mock_store.NewMockTodoRepository(ctrl)
 			tt.mockBehavior(repo, tt.args)
 
-			service := &TodoService{todosRepo: repo}
+			service := newTxService(ctrl, repo, anyAudit(ctrl))
 
-			output, err := service.CreateTodo(tt.args)
+			output, err := service.CreateTodo(context.Background(), tt.args)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedOutput, output)
 		})
@@ -153,7 +347,7 @@ func TestService_ToggleTodo(t *testing.T) {
 				if id == 1 {
 					exampleTodoInBase.Complete = !exampleTodoInBase.Complete
 				}
-				s.EXPECT().ToggleTodo(id).Return(*exampleTodoInBase, nil)
+				s.EXPECT().ToggleTodo(gomock.Any(), id, model.ToggleOptions{}).Return(*exampleTodoInBase, nil)
 			},
 			expectedComplete: true,
 		},
@@ -167,9 +361,9 @@ func TestService_ToggleTodo(t *testing.T) {
 			repo := mock_store.NewMockTodoRepository(ctrl)
 			tt.mockBehavior(repo, tt.args)
 
-			service := &TodoService{todosRepo: repo}
+			service := newTxService(ctrl, repo, anyAudit(ctrl))
 
-			output, err := service.ToggleTodo(tt.args)
+			output, err := service.ToggleTodo(context.Background(), tt.args, model.ToggleOptions{})
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedComplete, output.Complete)
 		})
@@ -198,7 +392,7 @@ func TestService_UpdateTodo(t *testing.T) {
 			mockBehavior: func(s *mock_store.MockTodoRepository, id model.ID, title string, complete bool) {
 				exampleTodoInBase.Complete = complete
 				exampleTodoInBase.Title = title
-				s.EXPECT().UpdateTodo(id, &title, &complete).Return(*exampleTodoInBase, nil)
+				s.EXPECT().UpdateTodo(gomock.Any(), id, model.TodoPatch{Title: &title, Complete: &complete}).Return(*exampleTodoInBase, nil)
 			},
 			expectedTitle:    "New title",
 			expectedComplete: true,
@@ -213,9 +407,9 @@ func TestService_UpdateTodo(t *testing.T) {
 			repo := mock_store.NewMockTodoRepository(ctrl)
 			tt.mockBehavior(repo, tt.argID, tt.argTitle, tt.argComplete)
 
-			service := &TodoService{todosRepo: repo}
+			service := newTxService(ctrl, repo, anyAudit(ctrl))
 
-			output, err := service.UpdateTodo(tt.argID, &tt.argTitle, &tt.argComplete)
+			output, err := service.UpdateTodo(context.Background(), tt.argID, model.TodoPatch{Title: &tt.argTitle, Complete: &tt.argComplete})
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedTitle, output.Title)
 			assert.Equal(t, tt.expectedComplete, output.Complete)
@@ -239,7 +433,7 @@ func TestService_DeleteTodo(t *testing.T) {
 			name:  "Toggle",
 			argId: 1,
 			mockBehavior: func(s *mock_store.MockTodoRepository, id model.ID) {
-				s.EXPECT().DeleteTodo(id).Return(*exampleTodoInBase, nil)
+				s.EXPECT().DeleteTodo(gomock.Any(), id, model.DeleteOptions{}).Return(*exampleTodoInBase, nil)
 			},
 			expectedTitle:    "Title 1",
 			expectedComplete: false,
@@ -254,12 +448,314 @@ func TestService_DeleteTodo(t *testing.T) {
 			repo := mock_store.NewMockTodoRepository(ctrl)
 			tt.mockBehavior(repo, tt.argId)
 
-			service := &TodoService{todosRepo: repo}
+			service := newTxService(ctrl, repo, anyAudit(ctrl))
 
-			output, err := service.DeleteTodo(tt.argId)
+			output, err := service.DeleteTodo(context.Background(), tt.argId, model.DeleteOptions{})
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedTitle, output.Title)
 			assert.Equal(t, tt.expectedComplete, output.Complete)
 		})
 	}
 }
+
+func TestService_GetTodoTree(t *testing.T) {
+	parent := func(id model.ID) *model.ID { return &id }
+
+	subtree := []model.Todo{
+		{ID: 1, Title: "Root"},
+		{ID: 2, Title: "Child 1", ParentID: parent(1), Complete: true},
+		{ID: 3, Title: "Child 2", ParentID: parent(1)},
+		{ID: 4, Title: "Grandchild 1", ParentID: parent(3), Complete: true},
+		{ID: 5, Title: "Grandchild 2", ParentID: parent(3)},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_store.NewMockTodoRepository(ctrl)
+	repo.EXPECT().GetSubtree(gomock.Any(), model.ID(1)).Return(subtree, nil)
+	repo.EXPECT().GetSubtree(gomock.Any(), model.ID(9)).Return(nil, nil)
+
+	service := &TodoService{todosRepo: repo}
+
+	tree, err := service.GetTodoTree(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ID(1), tree.ID)
+	assert.Equal(t, model.Progress{Completed: 2, Total: 4}, tree.Progress)
+	assert.Len(t, tree.Children, 2)
+	assert.Equal(t, model.ID(2), tree.Children[0].ID)
+	assert.Equal(t, model.Progress{}, tree.Children[0].Progress)
+	assert.Empty(t, tree.Children[0].Children)
+	assert.Equal(t, model.ID(3), tree.Children[1].ID)
+	assert.Equal(t, model.Progress{Completed: 1, Total: 2}, tree.Children[1].Progress)
+	assert.Len(t, tree.Children[1].Children, 2)
+
+	_, err = service.GetTodoTree(context.Background(), 9)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func TestService_Batch(t *testing.T) {
+	type mockBehavior func(repo *mock_store.MockTodoRepository)
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+	failure := errors.New("failure")
+
+	ops := []model.BatchOp{
+		{Type: model.BatchCreate, Todo: model.Todo{Title: "Title 1"}},
+		{Type: model.BatchToggle, ID: 2, Cascade: true},
+		{Type: model.BatchDelete, ID: 3},
+	}
+
+	testTable := []struct {
+		name            string
+		atomic          bool
+		mockBehavior    mockBehavior
+		expectedResults []model.BatchResult
+		expectedError   error
+	}{
+		{
+			name:   "Atomic",
+			atomic: true,
+			mockBehavior: func(repo *mock_store.MockTodoRepository) {
+				repo.EXPECT().CreateTodo(gomock.Any(), model.Todo{Title: "Title 1", Tags: []string{}}).Return(todo, nil)
+				repo.EXPECT().ToggleTodo(gomock.Any(), model.ID(2), model.ToggleOptions{Cascade: true}).Return(todo, nil)
+				repo.EXPECT().DeleteTodo(gomock.Any(), model.ID(3), model.DeleteOptions{}).Return(todo, nil)
+			},
+			expectedResults: []model.BatchResult{{Todo: &todo}, {Todo: &todo}, {Todo: &todo}},
+		},
+		{
+			name:   "Atomic stops at the first failure",
+			atomic: true,
+			mockBehavior: func(repo *mock_store.MockTodoRepository) {
+				repo.EXPECT().CreateTodo(gomock.Any(), gomock.Any()).Return(todo, nil)
+				repo.EXPECT().ToggleTodo(gomock.Any(), model.ID(2), model.ToggleOptions{Cascade: true}).Return(model.Todo{}, model.ErrNotFound)
+			},
+			expectedError: model.ErrNotFound,
+		},
+		{
+			name:   "Best effort goes on",
+			atomic: false,
+			mockBehavior: func(repo *mock_store.MockTodoRepository) {
+				repo.EXPECT().CreateTodo(gomock.Any(), gomock.Any()).Return(todo, nil)
+				repo.EXPECT().ToggleTodo(gomock.Any(), model.ID(2), model.ToggleOptions{Cascade: true}).Return(model.Todo{}, failure)
+				repo.EXPECT().DeleteTodo(gomock.Any(), model.ID(3), model.DeleteOptions{}).Return(todo, nil)
+			},
+			expectedResults: []model.BatchResult{{Todo: &todo}, {Err: failure}, {Todo: &todo}},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockTodoRepository(ctrl)
+			tt.mockBehavior(repo)
+
+			service := newTxService(ctrl, repo, anyAudit(ctrl))
+
+			results, err := service.Batch(context.Background(), ops, tt.atomic)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, results)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResults, results)
+		})
+	}
+}
+
+func TestService_Audit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	before := model.Todo{ID: 1, Title: "Title 1"}
+	after := model.Todo{ID: 1, Title: "Title 1", Complete: true, Version: 2}
+	failure := errors.New("failure")
+
+	repo := mock_store.NewMockTodoRepository(ctrl)
+	audit := mock_store.NewMockAuditRepository(ctrl)
+	webhooks := mock_store.NewMockWebhookRepository(ctrl)
+
+	tx := mock_store.NewMockTx(ctrl)
+	tx.EXPECT().Todos().Return(repo).AnyTimes()
+	tx.EXPECT().Lists().Return(anyLists(ctrl)).AnyTimes()
+	tx.EXPECT().Audit().Return(audit).AnyTimes()
+	tx.EXPECT().Webhooks().Return(webhooks).AnyTimes()
+
+	s := mock_store.NewMockStore(ctrl)
+	s.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func(tx store.Tx) error) error { return fn(tx) },
+	).AnyTimes()
+
+	service := &TodoService{store: s, todosRepo: repo}
+	ctx := WithRequestInfo(context.Background(), RequestInfo{Actor: "alice", RequestID: "req-1"})
+
+	gomock.InOrder(
+		repo.EXPECT().LockTodo(gomock.Any(), model.ID(1)).Return(before, nil),
+		repo.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), model.ToggleOptions{}).Return(after, nil),
+		audit.EXPECT().AppendAudit(gomock.Any(), model.AuditEntry{
+			TodoID:    1,
+			Action:    model.AuditToggle,
+			Before:    &before,
+			After:     &after,
+			Actor:     "alice",
+			RequestID: "req-1",
+		}).Return(model.AuditEntry{ID: 1, TodoID: 1, Action: model.AuditToggle, After: &after}, nil),
+		// The webhooks of its owner are told about it in the same transaction.
+		webhooks.EXPECT().EnqueueDeliveries(gomock.Any(), after.UserID, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error) {
+				assert.Equal(t, int64(1), delivery.EventID)
+				assert.Equal(t, model.ChangeUpdated, delivery.Event)
+				var change model.Change
+				assert.NoError(t, json.Unmarshal([]byte(delivery.Payload), &change))
+				assert.Equal(t, model.ChangeUpdated, change.Type)
+				assert.Equal(t, after.ID, change.Todo.ID)
+				assert.NotNil(t, delivery.NextAttemptAt)
+				return 1, nil
+			},
+		),
+	)
+
+	output, err := service.ToggleTodo(ctx, 1, model.ToggleOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, &after, output)
+
+	// The change fails along with its audit entry.
+	repo.EXPECT().LockTodo(gomock.Any(), model.ID(1)).Return(before, nil)
+	repo.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), gomock.Any()).Return(after, nil)
+	audit.EXPECT().AppendAudit(gomock.Any(), gomock.Any()).Return(model.AuditEntry{}, failure)
+
+	output, err = service.UpdateTodo(ctx, 1, model.TodoPatch{})
+	assert.ErrorIs(t, err, failure)
+	assert.Nil(t, output)
+
+	// Nothing is written or recorded for a todo that doesn't exist.
+	repo.EXPECT().LockTodo(gomock.Any(), model.ID(9)).Return(model.Todo{}, model.ErrNotFound)
+
+	_, err = service.DeleteTodo(ctx, 9, model.DeleteOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func TestService_AuditCascade(t *testing.T) {
+	ctx := context.Background()
+	s, lists, todos := newListTest(t)
+
+	inbox, err := lists.CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+	work, err := lists.CreateList(ctx, model.List{Name: "Work"})
+	require.NoError(t, err)
+
+	root, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Root"})
+	require.NoError(t, err)
+	child, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Child", ParentID: &root.ID})
+	require.NoError(t, err)
+	grandchild, err := todos.CreateTodo(ctx, model.Todo{ListID: inbox.ID, Title: "Grandchild", ParentID: &child.ID})
+	require.NoError(t, err)
+
+	// recorded returns the actions recorded since it was last called, by
+	// todo.
+	var last int64
+	recorded := func() map[model.ID]model.AuditAction {
+		t.Helper()
+
+		page, err := s.Audit().ListAudit(ctx, model.AuditFilter{AfterID: last, Limit: 100})
+		require.NoError(t, err)
+
+		ret := make(map[model.ID]model.AuditAction)
+		for _, entry := range page.Entries {
+			if entry.Before != nil {
+				assert.Equal(t, entry.Before.Version+1, entry.After.Version, "todo %d", entry.TodoID)
+			}
+			ret[entry.TodoID] = entry.Action
+			last = entry.ID
+		}
+		return ret
+	}
+	recorded()
+
+	_, err = todos.ToggleTodo(ctx, root.ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[model.ID]model.AuditAction{
+		root.ID:       model.AuditToggle,
+		child.ID:      model.AuditToggle,
+		grandchild.ID: model.AuditToggle,
+	}, recorded())
+
+	_, err = todos.UpdateTodo(ctx, root.ID, model.TodoPatch{ListID: &work.ID})
+	require.NoError(t, err)
+	assert.Equal(t, map[model.ID]model.AuditAction{
+		root.ID:       model.AuditUpdate,
+		child.ID:      model.AuditUpdate,
+		grandchild.ID: model.AuditUpdate,
+	}, recorded())
+
+	// The subtask handed up to the parent is updated.
+	_, err = todos.DeleteTodo(ctx, child.ID, model.DeleteOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[model.ID]model.AuditAction{
+		child.ID:      model.AuditDelete,
+		grandchild.ID: model.AuditUpdate,
+	}, recorded())
+
+	// The subtask already in the trash is left alone.
+	_, err = todos.DeleteTodo(ctx, root.ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[model.ID]model.AuditAction{
+		root.ID:       model.AuditDelete,
+		grandchild.ID: model.AuditDelete,
+	}, recorded())
+
+	_, err = todos.RestoreTodo(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[model.ID]model.AuditAction{
+		root.ID:       model.AuditRestore,
+		grandchild.ID: model.AuditRestore,
+	}, recorded())
+}
+
+func TestService_SearchTodos(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := model.SearchPage{Results: []model.SearchResult{{Todo: model.Todo{ID: 1}, Rank: 0.5, Snippet: "<mark>milk</mark>"}}}
+
+	repo := mock_store.NewMockTodoRepository(ctrl)
+	repo.EXPECT().SearchTodos(gomock.Any(), model.SearchFilter{Query: "milk", Limit: defaultListLimit}).Return(page, nil)
+	repo.EXPECT().SearchTodos(gomock.Any(), model.SearchFilter{Query: "milk", Limit: maxListLimit}).Return(page, nil)
+
+	service := &TodoService{todosRepo: repo}
+
+	output, err := service.SearchTodos(context.Background(), model.SearchFilter{Query: "milk"})
+	assert.NoError(t, err)
+	assert.Equal(t, &page, output)
+
+	_, err = service.SearchTodos(context.Background(), model.SearchFilter{Query: "milk", Limit: 1000})
+	assert.NoError(t, err)
+}
+
+func TestService_ListAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := model.ID(1)
+	page := model.AuditPage{Entries: []model.AuditEntry{{ID: 1, TodoID: id, Action: model.AuditCreate}}}
+
+	audit := mock_store.NewMockAuditRepository(ctrl)
+	audit.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{TodoID: &id, Limit: defaultListLimit}).Return(page, nil)
+	audit.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{Limit: maxListLimit}).Return(page, nil)
+
+	s := mock_store.NewMockStore(ctrl)
+	s.EXPECT().Audit().Return(audit).AnyTimes()
+
+	service := &TodoService{store: s}
+
+	output, err := service.ListAudit(context.Background(), model.AuditFilter{TodoID: &id})
+	assert.NoError(t, err)
+	assert.Equal(t, &page, output)
+
+	_, err = service.ListAudit(context.Background(), model.AuditFilter{Limit: 1000})
+	assert.NoError(t, err)
+}
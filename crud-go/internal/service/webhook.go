@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+//go:generate mockgen -source=webhook.go -destination=mocks/webhook.go
+
+type IWebhookService interface {
+	GetWebhook(ctx context.Context, id model.ID) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]model.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook model.Webhook) (*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id model.ID) (*model.Webhook, error)
+	// ListDeliveries lists the deliveries of a webhook, newest first.
+	ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (*model.DeliveryPage, error)
+	// Redeliver queues the payload of a delivery to be sent again, as a
+	// new delivery with retries of its own.
+	Redeliver(ctx context.Context, webhookID model.ID, id int64) (*model.WebhookDelivery, error)
+}
+
+var _ IWebhookService = &WebhookService{}
+
+type WebhookService struct {
+	logger       *slog.Logger
+	webhooksRepo store.WebhookRepository
+	now          func() time.Time
+}
+
+func NewWebhookService(logger *slog.Logger, store store.Store) IWebhookService {
+	return &WebhookService{
+		logger:       logger,
+		webhooksRepo: store.Webhooks(),
+		now:          time.Now,
+	}
+}
+
+// GetWebhook implements IWebhookService.
+func (w *WebhookService) GetWebhook(ctx context.Context, id model.ID) (*model.Webhook, error) {
+	webhook, err := w.webhooksRepo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks implements IWebhookService.
+func (w *WebhookService) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	return w.webhooksRepo.ListWebhooks(ctx)
+}
+
+// CreateWebhook implements IWebhookService.
+func (w *WebhookService) CreateWebhook(ctx context.Context, webhook model.Webhook) (*model.Webhook, error) {
+	webhook.Events = normalizeEvents(webhook.Events)
+
+	created, err := w.webhooksRepo.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateWebhook implements IWebhookService.
+func (w *WebhookService) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (*model.Webhook, error) {
+	if patch.Events != nil {
+		events := normalizeEvents(*patch.Events)
+		patch.Events = &events
+	}
+
+	updated, err := w.webhooksRepo.UpdateWebhook(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteWebhook implements IWebhookService.
+func (w *WebhookService) DeleteWebhook(ctx context.Context, id model.ID) (*model.Webhook, error) {
+	webhook, err := w.webhooksRepo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.webhooksRepo.DeleteWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListDeliveries implements IWebhookService.
+func (w *WebhookService) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (*model.DeliveryPage, error) {
+	if _, err := w.webhooksRepo.GetWebhook(ctx, filter.WebhookID); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	page, err := w.webhooksRepo.ListDeliveries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Redeliver implements IWebhookService.
+func (w *WebhookService) Redeliver(ctx context.Context, webhookID model.ID, id int64) (*model.WebhookDelivery, error) {
+	delivery, err := w.webhooksRepo.Redeliver(ctx, webhookID, id, w.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// normalizeEvents drops duplicate event types. Subscribing to all of
+// them is the same as subscribing to none, which also covers types added
+// later.
+func normalizeEvents(events []model.ChangeType) []model.ChangeType {
+	normalized := make([]model.ChangeType, 0, len(events))
+	for _, event := range events {
+		if !slices.Contains(normalized, event) {
+			normalized = append(normalized, event)
+		}
+	}
+	if len(normalized) == len(allChangeTypes) {
+		return []model.ChangeType{}
+	}
+	return normalized
+}
+
+var allChangeTypes = []model.ChangeType{model.ChangeCreated, model.ChangeUpdated, model.ChangeDeleted}
+
+// enqueueDeliveries queues the change entry records for the webhooks of
+// the owner of its todo subscribed to it. It runs in the transaction of
+// the change, so that every committed change is delivered and no other
+// one is.
+func enqueueDeliveries(ctx context.Context, tx store.Tx, entry model.AuditEntry) error {
+	change := model.ChangeOf(entry)
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Webhooks().EnqueueDeliveries(ctx, entry.UserID(), model.WebhookDelivery{
+		EventID:       entry.ID,
+		Event:         change.Type,
+		Payload:       string(payload),
+		NextAttemptAt: &now,
+	})
+	return err
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"crud/internal/model"
+	mock_store "crud/internal/store/mocks"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	testTable := []struct {
+		name           string
+		events         []model.ChangeType
+		expectedEvents []model.ChangeType
+	}{
+		{name: "All", events: nil, expectedEvents: []model.ChangeType{}},
+		{
+			name:           "Duplicates",
+			events:         []model.ChangeType{model.ChangeDeleted, model.ChangeCreated, model.ChangeDeleted},
+			expectedEvents: []model.ChangeType{model.ChangeDeleted, model.ChangeCreated},
+		},
+		{
+			name:           "Every type",
+			events:         []model.ChangeType{model.ChangeUpdated, model.ChangeCreated, model.ChangeDeleted},
+			expectedEvents: []model.ChangeType{},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_store.NewMockWebhookRepository(ctrl)
+			repo.EXPECT().CreateWebhook(gomock.Any(), model.Webhook{URL: "http://example.com", Events: tt.expectedEvents}).
+				Return(model.Webhook{ID: 1, Events: tt.expectedEvents}, nil)
+
+			service := &WebhookService{webhooksRepo: repo}
+
+			output, err := service.CreateWebhook(context.Background(), model.Webhook{URL: "http://example.com", Events: tt.events})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEvents, output.Events)
+		})
+	}
+}
+
+func TestWebhookService_ListDeliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := model.DeliveryPage{Deliveries: []model.WebhookDelivery{{ID: 1, WebhookID: 1}}}
+
+	repo := mock_store.NewMockWebhookRepository(ctrl)
+	repo.EXPECT().GetWebhook(gomock.Any(), model.ID(1)).Return(model.Webhook{ID: 1}, nil).Times(2)
+	repo.EXPECT().ListDeliveries(gomock.Any(), model.DeliveryFilter{WebhookID: 1, Limit: defaultListLimit}).Return(page, nil)
+	repo.EXPECT().ListDeliveries(gomock.Any(), model.DeliveryFilter{WebhookID: 1, Limit: maxListLimit}).Return(page, nil)
+	repo.EXPECT().GetWebhook(gomock.Any(), model.ID(2)).Return(model.Webhook{}, model.ErrNotFound)
+
+	service := &WebhookService{webhooksRepo: repo}
+
+	output, err := service.ListDeliveries(context.Background(), model.DeliveryFilter{WebhookID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, &page, output)
+
+	_, err = service.ListDeliveries(context.Background(), model.DeliveryFilter{WebhookID: 1, Limit: 1000})
+	assert.NoError(t, err)
+
+	_, err = service.ListDeliveries(context.Background(), model.DeliveryFilter{WebhookID: 2})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
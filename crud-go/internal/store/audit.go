@@ -0,0 +1,99 @@
+package store
+
+import (
+	"crud/internal/model"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+)
+
+// decodeIDCursor returns the id of the last row of the previous page of
+// a table paged by id, or 0 for the first page.
+func decodeIDCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+// encodeIDCursor is the cursor of the page after the row id.
+func encodeIDCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeAuditCursor returns the id after which the page starts: that of
+// the last entry of the previous page, or filter.AfterID if it is later.
+func decodeAuditCursor(filter model.AuditFilter) (int64, error) {
+	id, err := decodeIDCursor(filter.Cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(id, filter.AfterID), nil
+}
+
+// auditPageOf cuts the extra entry fetched past the limit off entries
+// and turns it into a cursor for the next page.
+func auditPageOf(filter model.AuditFilter, entries []model.AuditEntry) model.AuditPage {
+	if len(entries) <= filter.Limit {
+		return model.AuditPage{Entries: entries}
+	}
+
+	entries = entries[:filter.Limit]
+
+	return model.AuditPage{
+		Entries:    entries,
+		NextCursor: encodeIDCursor(entries[len(entries)-1].ID),
+	}
+}
+
+// auditTodoValue stores the before or after state of an entry as JSON.
+func auditTodoValue(todo *model.Todo) (interface{}, error) {
+	if todo == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// auditTodo scans a column written by auditTodoValue.
+type auditTodo struct {
+	todo **model.Todo
+}
+
+func (s auditTodo) Scan(src interface{}) error {
+	var data sql.NullString
+	if err := data.Scan(src); err != nil {
+		return err
+	}
+
+	if !data.Valid {
+		*s.todo = nil
+		return nil
+	}
+
+	var todo model.Todo
+	if err := json.Unmarshal([]byte(data.String), &todo); err != nil {
+		return err
+	}
+	*s.todo = &todo
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"sync"
+)
+
+// changeBuffer is how many entries a subscriber may fall behind before
+// it is dropped.
+const changeBuffer = 256
+
+var _ ChangeFeed = &changeHub{}
+
+// changeHub fans committed audit entries out to the subscribers of this
+// process. Stores feed it after a commit, or from the database when
+// other instances write too.
+type changeHub struct {
+	mu   sync.Mutex
+	subs map[chan model.AuditEntry]struct{}
+
+	// start is run once, by the first subscriber.
+	start     func()
+	startOnce sync.Once
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{subs: make(map[chan model.AuditEntry]struct{})}
+}
+
+func (h *changeHub) Subscribe(ctx context.Context) <-chan model.AuditEntry {
+	if h.start != nil {
+		h.startOnce.Do(h.start)
+	}
+
+	ch := make(chan model.AuditEntry, changeBuffer)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(ch)
+	}()
+
+	return ch
+}
+
+func (h *changeHub) unsubscribe(ch chan model.AuditEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// publish hands entries to every subscriber without waiting. One that
+// has no room left is dropped rather than slowing down the writers.
+func (h *changeHub) publish(entries ...model.AuditEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		for _, entry := range entries {
+			select {
+			case ch <- entry:
+				continue
+			default:
+			}
+
+			delete(h.subs, ch)
+			close(ch)
+			break
+		}
+	}
+}
+
+// close ends every subscription.
+func (h *changeHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
@@ -1,9 +1,18 @@
 package store
 
+const (
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
 type Config struct {
+	Driver      string
 	DatabaseUrl string
 }
 
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		Driver: DriverPostgres,
+	}
 }
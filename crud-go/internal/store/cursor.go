@@ -0,0 +1,74 @@
+package store
+
+import (
+	"crud/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// cursor points at the last todo of a page. It remembers the sort it
+// was issued for, so it can't be replayed against a different ordering.
+type cursor struct {
+	SortBy    model.TodoSortField `json:"s"`
+	Order     model.SortOrder     `json:"o"`
+	ID        model.ID            `json:"id"`
+	CreatedAt time.Time           `json:"c,omitempty"`
+	UpdatedAt time.Time           `json:"u,omitempty"`
+	DueAt     *time.Time          `json:"d,omitempty"`
+	Priority  model.Priority      `json:"p,omitempty"`
+}
+
+func newCursor(filter model.TodoFilter, last model.Todo) cursor {
+	return cursor{
+		SortBy:    filter.SortBy,
+		Order:     filter.Order,
+		ID:        last.ID,
+		CreatedAt: last.CreatedAt,
+		UpdatedAt: last.UpdatedAt,
+		DueAt:     last.DueAt,
+		Priority:  last.Priority,
+	}
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(filter model.TodoFilter) (*cursor, error) {
+	if filter.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.SortBy != filter.SortBy || c.Order != filter.Order {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// pageOf cuts the extra row fetched past the limit off todos and turns
+// it into a cursor for the next page.
+func pageOf(filter model.TodoFilter, todos []model.Todo) model.TodoPage {
+	if len(todos) <= filter.Limit {
+		return model.TodoPage{Todos: todos}
+	}
+
+	todos = todos[:filter.Limit]
+
+	return model.TodoPage{
+		Todos:      todos,
+		NextCursor: newCursor(filter, todos[len(todos)-1]).encode(),
+	}
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"crud/internal/model"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidCursor  = fmt.Errorf("%w: cursor", model.ErrInvalidArgument)
+	ErrParentNotFound = fmt.Errorf("%w: parent todo not found", model.ErrInvalidArgument)
+	// ErrCycle is returned when a todo would become a subtask of itself.
+	ErrCycle = fmt.Errorf("%w: todo can't be a subtask of itself or its subtasks", model.ErrInvalidArgument)
+	// ErrParentInOtherList is returned when a todo would become a
+	// subtask of a todo of another list.
+	ErrParentInOtherList = fmt.Errorf("%w: parent todo is in another list", model.ErrInvalidArgument)
+	ErrListNotFound      = fmt.Errorf("%w: list not found", model.ErrInvalidArgument)
+	ErrListArchived      = fmt.Errorf("%w: list is archived", model.ErrConflict)
+	ErrListNotEmpty      = fmt.Errorf("%w: list has todos", model.ErrConflict)
+)
+
+// storeError translates driver errors into the model error taxonomy.
+func storeError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+	return err
+}
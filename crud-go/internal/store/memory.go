@@ -0,0 +1,1349 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Store = &MemoryStore{}
+
+// MemoryStore keeps todos in process memory. It needs no database and is
+// meant for local runs and tests; everything is lost on restart.
+type MemoryStore struct {
+	todos     *MemoryTodoRepository
+	lists     *MemoryListRepository
+	audit     *MemoryAuditRepository
+	webhooks  *MemoryWebhookRepository
+	reminders *MemoryReminderRepository
+	leases    *MemoryLeaseRepository
+	changes   *changeHub
+}
+
+func NewMemoryStore(config *Config) Store {
+	changes := newChangeHub()
+
+	todos := newMemoryTodoRepository()
+
+	return &MemoryStore{
+		todos:     todos,
+		lists:     &MemoryListRepository{todos: todos},
+		audit:     &MemoryAuditRepository{changes: changes},
+		webhooks:  newMemoryWebhookRepository(),
+		reminders: newMemoryReminderRepository(todos),
+		leases:    &MemoryLeaseRepository{leases: make(map[string]memoryLease)},
+		changes:   changes,
+	}
+}
+
+func (s *MemoryStore) Todos() TodoRepository {
+	return s.todos
+}
+
+func (s *MemoryStore) Lists() ListRepository {
+	return s.lists
+}
+
+func (s *MemoryStore) Audit() AuditRepository {
+	return s.audit
+}
+
+func (s *MemoryStore) Webhooks() WebhookRepository {
+	return s.webhooks
+}
+
+func (s *MemoryStore) Reminders() ReminderRepository {
+	return s.reminders
+}
+
+func (s *MemoryStore) Leases() LeaseRepository {
+	return s.leases
+}
+
+func (s *MemoryStore) Changes() ChangeFeed {
+	return s.changes
+}
+
+func (s *MemoryStore) Migrator() Migrator {
+	return noopMigrator{}
+}
+
+// InTx runs fn on a copy of the todos and lists, the audit trail and
+// the webhooks, which replaces them if fn succeeds. Other callers wait
+// until the transaction is over.
+func (s *MemoryStore) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	s.todos.mu.Lock()
+	defer s.todos.mu.Unlock()
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+	s.webhooks.mu.Lock()
+	defer s.webhooks.mu.Unlock()
+
+	tx := &memoryTx{todos: s.todos.clone(), audit: s.audit.clone(), webhooks: s.webhooks.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.todos.replace(tx.todos)
+	appended := tx.audit.entries[len(s.audit.entries):]
+	s.audit.entries = tx.audit.entries
+	s.webhooks.replace(tx.webhooks)
+
+	s.changes.publish(appended...)
+
+	return nil
+}
+
+var _ Tx = &memoryTx{}
+
+type memoryTx struct {
+	todos    *MemoryTodoRepository
+	audit    *MemoryAuditRepository
+	webhooks *MemoryWebhookRepository
+}
+
+func (t *memoryTx) Todos() TodoRepository {
+	return t.todos
+}
+
+func (t *memoryTx) Lists() ListRepository {
+	return &MemoryListRepository{todos: t.todos}
+}
+
+func (t *memoryTx) Audit() AuditRepository {
+	return t.audit
+}
+
+func (t *memoryTx) Webhooks() WebhookRepository {
+	return t.webhooks
+}
+
+func (t *memoryTx) Savepoint(ctx context.Context, fn func() error) error {
+	t.todos.mu.RLock()
+	savepoint := t.todos.clone()
+	t.todos.mu.RUnlock()
+
+	t.audit.mu.RLock()
+	auditSavepoint := t.audit.clone()
+	t.audit.mu.RUnlock()
+
+	t.webhooks.mu.RLock()
+	webhooksSavepoint := t.webhooks.clone()
+	t.webhooks.mu.RUnlock()
+
+	if err := fn(); err != nil {
+		t.todos.mu.Lock()
+		t.todos.replace(savepoint)
+		t.todos.mu.Unlock()
+
+		t.audit.mu.Lock()
+		t.audit.entries = auditSavepoint.entries
+		t.audit.mu.Unlock()
+
+		t.webhooks.mu.Lock()
+		t.webhooks.replace(webhooksSavepoint)
+		t.webhooks.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Open() error {
+	return nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.changes.close()
+	return nil
+}
+
+var _ TodoRepository = &MemoryTodoRepository{}
+
+type MemoryTodoRepository struct {
+	mu     sync.RWMutex
+	lastID model.ID
+	todos  map[model.ID]model.Todo
+	// lists are kept here, under the same lock, as they are counted and
+	// deleted along with their todos.
+	lastListID model.ID
+	lists      map[model.ID]model.List
+}
+
+func newMemoryTodoRepository() *MemoryTodoRepository {
+	return &MemoryTodoRepository{
+		todos: make(map[model.ID]model.Todo),
+		lists: make(map[model.ID]model.List),
+	}
+}
+
+// clone copies the todos and lists of r. The caller holds the lock.
+func (r *MemoryTodoRepository) clone() *MemoryTodoRepository {
+	clone := newMemoryTodoRepository()
+	clone.lastID = r.lastID
+	for id, todo := range r.todos {
+		clone.todos[id] = todo
+	}
+	clone.lastListID = r.lastListID
+	for id, list := range r.lists {
+		clone.lists[id] = list
+	}
+	return clone
+}
+
+// replace takes over the todos and lists of other. The caller holds
+// the lock.
+func (r *MemoryTodoRepository) replace(other *MemoryTodoRepository) {
+	r.lastID, r.todos = other.lastID, other.todos
+	r.lastListID, r.lists = other.lastListID, other.lists
+}
+
+func (r *MemoryTodoRepository) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := make([]model.Todo, 0, len(ids))
+	seen := make(map[model.ID]bool, len(ids))
+
+	for _, id := range ids {
+		todo, ok := r.todos[id]
+		if !ok || todo.DeletedAt != nil || !owns(ctx, todo.UserID) || seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		ret = append(ret, todo)
+	}
+
+	return ret, nil
+}
+
+func (r *MemoryTodoRepository) GetSubtree(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	root, ok := r.todos[id]
+	if !ok || root.DeletedAt != nil || !owns(ctx, root.UserID) {
+		return nil, nil
+	}
+
+	ret := []model.Todo{root}
+	for _, descendantID := range r.descendants(id) {
+		if descendant := r.todos[descendantID]; descendant.DeletedAt == nil {
+			ret = append(ret, descendant)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+
+	return ret, nil
+}
+
+func (r *MemoryTodoRepository) LockTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || !owns(ctx, todo.UserID) {
+		return model.Todo{}, model.ErrNotFound
+	}
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) LockDescendants(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ret []model.Todo
+	for _, descendantID := range r.descendants(id) {
+		if descendant := r.todos[descendantID]; owns(ctx, descendant.UserID) {
+			ret = append(ret, descendant)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+
+	return ret, nil
+}
+
+// descendants returns the ids of all todos below id, including the ones
+// in the trash.
+func (r *MemoryTodoRepository) descendants(id model.ID) []model.ID {
+	children := make(map[model.ID][]model.ID)
+	for _, todo := range r.todos {
+		if todo.ParentID != nil {
+			children[*todo.ParentID] = append(children[*todo.ParentID], todo.ID)
+		}
+	}
+
+	var ret []model.ID
+	for queue := children[id]; len(queue) > 0; queue = queue[1:] {
+		ret = append(ret, queue[0])
+		queue = append(queue, children[queue[0]]...)
+	}
+
+	return ret
+}
+
+// checkParent makes sure parent exists, is in list, or the list of id
+// if nil, and isn't id or one of its descendants.
+func (r *MemoryTodoRepository) checkParent(ctx context.Context, id, parent model.ID, list *model.ID) error {
+	if !r.live(ctx, parent) {
+		return ErrParentNotFound
+	}
+
+	for ancestor := &parent; ancestor != nil; ancestor = r.todos[*ancestor].ParentID {
+		if *ancestor == id {
+			return ErrCycle
+		}
+	}
+
+	target := r.todos[id].ListID
+	if list != nil {
+		target = *list
+	}
+	if r.todos[parent].ListID != target {
+		return ErrParentInOtherList
+	}
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) ListTodos(ctx context.Context, filter model.TodoFilter) (model.TodoPage, error) {
+	after, err := decodeCursor(filter)
+	if err != nil {
+		return model.TodoPage{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	title := strings.ToLower(filter.TitleContains)
+
+	todos := make([]model.Todo, 0, len(r.todos))
+	for _, todo := range r.todos {
+		if (todo.DeletedAt != nil) != filter.Trashed || !owns(ctx, todo.UserID) {
+			continue
+		}
+		if filter.ListID != nil && todo.ListID != *filter.ListID {
+			continue
+		}
+		if filter.SeriesID != nil && todo.ID != *filter.SeriesID && (todo.SeriesID == nil || *todo.SeriesID != *filter.SeriesID) {
+			continue
+		}
+		if filter.Complete != nil && todo.Complete != *filter.Complete {
+			continue
+		}
+		if title != "" && !strings.Contains(strings.ToLower(todo.Title), title) {
+			continue
+		}
+		if filter.CreatedAfter != nil && todo.CreatedAt.Before(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !todo.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+		if filter.DueAfter != nil && (todo.DueAt == nil || todo.DueAt.Before(*filter.DueAfter)) {
+			continue
+		}
+		if filter.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*filter.DueBefore)) {
+			continue
+		}
+		if filter.MinPriority != nil && todo.Priority < *filter.MinPriority {
+			continue
+		}
+		if !hasTags(todo, filter.Tags) {
+			continue
+		}
+		if after != nil && !comesAfter(filter, todo, *after) {
+			continue
+		}
+
+		todos = append(todos, todo)
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		return comesAfter(filter, todos[j], newCursor(filter, todos[i]))
+	})
+
+	if len(todos) > filter.Limit+1 {
+		todos = todos[:filter.Limit+1]
+	}
+
+	return pageOf(filter, todos), nil
+}
+
+// comesAfter reports whether todo is ordered after the position c under
+// the sort of filter. It mirrors the keyset condition of the SQL stores.
+func (r *MemoryTodoRepository) SearchTodos(ctx context.Context, filter model.SearchFilter) (model.SearchPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := make([]model.Todo, 0, len(r.todos))
+	for _, todo := range r.todos {
+		if todo.DeletedAt == nil && owns(ctx, todo.UserID) {
+			todos = append(todos, todo)
+		}
+	}
+
+	return naiveSearch(todos, filter)
+}
+
+func comesAfter(filter model.TodoFilter, todo model.Todo, c cursor) bool {
+	cmp := compareSortKey(filter.SortBy, newCursor(filter, todo), c)
+	if cmp == 0 {
+		cmp = compareID(todo.ID, c.ID)
+	}
+
+	if filter.Order == model.SortDesc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// compareSortKey compares the sort key of a and b, treating a missing
+// due date as later than any other.
+func compareSortKey(field model.TodoSortField, a, b cursor) int {
+	switch field {
+	case model.SortByCreatedAt:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case model.SortByUpdatedAt:
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	case model.SortByDueAt:
+		switch {
+		case a.DueAt == nil && b.DueAt == nil:
+			return 0
+		case a.DueAt == nil:
+			return 1
+		case b.DueAt == nil:
+			return -1
+		default:
+			return a.DueAt.Compare(*b.DueAt)
+		}
+	case model.SortByPriority:
+		return compareID(model.ID(a.Priority), model.ID(b.Priority))
+	default:
+		return 0
+	}
+}
+
+func compareID(a, b model.ID) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r *MemoryTodoRepository) CreateTodo(ctx context.Context, todo model.Todo) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo.UserID = ownerOf(ctx, todo.UserID)
+	if todo.ParentID != nil {
+		if !r.live(ctx, *todo.ParentID) {
+			return model.Todo{}, ErrParentNotFound
+		}
+		parent := r.todos[*todo.ParentID]
+		if parent.ListID != todo.ListID {
+			return model.Todo{}, ErrParentInOtherList
+		}
+		todo.UserID = parent.UserID
+	}
+
+	r.lastID++
+
+	now := time.Now().UTC()
+
+	todo.ID = r.lastID
+	todo.Tags = cloneTags(todo.Tags)
+	todo.CreatedAt = now
+	todo.UpdatedAt = now
+	todo.Version = 1
+	todo.DeletedAt = nil
+	todo.CompletedAt = nil
+	if todo.Complete {
+		todo.CompletedAt = &now
+	}
+	localizeDue(&todo)
+
+	r.todos[todo.ID] = todo
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.get(ctx, id, opts.IfVersion)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	now := time.Now().UTC()
+
+	todo.Complete = !todo.Complete
+	todo.UpdatedAt = now
+	todo.Version++
+	todo.CompletedAt = nil
+	if todo.Complete {
+		todo.CompletedAt = &now
+	}
+	r.todos[id] = todo
+
+	if opts.Cascade {
+		for _, descendantID := range r.descendants(id) {
+			descendant := r.todos[descendantID]
+			if descendant.DeletedAt != nil || descendant.Complete == todo.Complete {
+				continue
+			}
+
+			descendant.Complete = todo.Complete
+			descendant.CompletedAt = todo.CompletedAt
+			descendant.UpdatedAt = now
+			descendant.Version++
+			r.todos[descendantID] = descendant
+		}
+	}
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.get(ctx, id, patch.IfVersion)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	if patch.ParentID.Set && patch.ParentID.Value != nil {
+		if err := r.checkParent(ctx, id, *patch.ParentID.Value, patch.ListID); err != nil {
+			return model.Todo{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	if patch.ListID != nil {
+		if !patch.ParentID.Set && todo.ListID != *patch.ListID {
+			todo.ParentID = nil
+		}
+		todo.ListID = *patch.ListID
+
+		// The subtree, trash included, goes along.
+		for _, descendantID := range r.descendants(id) {
+			descendant := r.todos[descendantID]
+			if descendant.ListID == todo.ListID {
+				continue
+			}
+
+			descendant.ListID = todo.ListID
+			descendant.UpdatedAt = now
+			descendant.Version++
+			r.todos[descendantID] = descendant
+		}
+	}
+	if patch.ParentID.Set {
+		todo.ParentID = patch.ParentID.Value
+	}
+	if patch.Title != nil {
+		todo.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		todo.Description = *patch.Description
+	}
+	if patch.Complete != nil {
+		todo.Complete = *patch.Complete
+		if !todo.Complete {
+			todo.CompletedAt = nil
+		} else if todo.CompletedAt == nil {
+			todo.CompletedAt = &now
+		}
+	}
+	if patch.Priority != nil {
+		todo.Priority = *patch.Priority
+	}
+	if patch.DueAt.Set {
+		todo.DueAt = patch.DueAt.Value
+	}
+	if patch.DueTimezone != nil {
+		todo.DueTimezone = *patch.DueTimezone
+	}
+	if patch.Recurrence != nil {
+		todo.Recurrence = *patch.Recurrence
+	}
+	if patch.Tags != nil {
+		todo.Tags = cloneTags(*patch.Tags)
+	}
+	todo.UpdatedAt = now
+	todo.Version++
+	localizeDue(&todo)
+
+	r.todos[id] = todo
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.get(ctx, id, opts.IfVersion)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	now := time.Now().UTC()
+
+	if opts.Cascade {
+		for _, descendantID := range r.descendants(id) {
+			descendant := r.todos[descendantID]
+			if descendant.DeletedAt != nil {
+				continue
+			}
+
+			descendant.DeletedAt = &now
+			descendant.UpdatedAt = now
+			descendant.Version++
+			r.todos[descendantID] = descendant
+		}
+	} else {
+		for childID, child := range r.todos {
+			if child.DeletedAt == nil && child.ParentID != nil && *child.ParentID == id {
+				child.ParentID = todo.ParentID
+				child.UpdatedAt = now
+				child.Version++
+				r.todos[childID] = child
+			}
+		}
+	}
+
+	todo.DeletedAt = &now
+	todo.UpdatedAt = now
+	todo.Version++
+	r.todos[id] = todo
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) RestoreTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt == nil || !owns(ctx, todo.UserID) {
+		return model.Todo{}, model.ErrNotFound
+	}
+
+	deletedAt := *todo.DeletedAt
+	now := time.Now().UTC()
+
+	if todo.ParentID != nil && !r.live(ctx, *todo.ParentID) {
+		todo.ParentID = nil
+	}
+	todo.DeletedAt = nil
+	todo.UpdatedAt = now
+	todo.Version++
+	r.todos[id] = todo
+
+	for _, descendantID := range r.descendants(id) {
+		descendant := r.todos[descendantID]
+		if descendant.DeletedAt == nil || !descendant.DeletedAt.Equal(deletedAt) {
+			continue
+		}
+
+		descendant.DeletedAt = nil
+		descendant.UpdatedAt = now
+		descendant.Version++
+		r.todos[descendantID] = descendant
+	}
+
+	return todo, nil
+}
+
+func (r *MemoryTodoRepository) PurgeTodos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, todo := range r.todos {
+		if todo.DeletedAt != nil && todo.DeletedAt.Before(deletedBefore) && owns(ctx, todo.UserID) {
+			delete(r.todos, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// live reports whether id exists, is not in the trash and is seen by
+// ctx. The caller holds the lock.
+func (r *MemoryTodoRepository) live(ctx context.Context, id model.ID) bool {
+	todo, ok := r.todos[id]
+	return ok && todo.DeletedAt == nil && owns(ctx, todo.UserID)
+}
+
+// get returns the todo to write, which must be at ifVersion if that is
+// set. The caller holds the lock.
+func (r *MemoryTodoRepository) get(ctx context.Context, id model.ID, ifVersion *int64) (model.Todo, error) {
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt != nil || !owns(ctx, todo.UserID) {
+		return model.Todo{}, model.ErrNotFound
+	}
+
+	if ifVersion != nil && todo.Version != *ifVersion {
+		return model.Todo{}, &model.VersionConflictError{Current: todo}
+	}
+
+	return todo, nil
+}
+
+// owns reports whether what owner owns is seen by ctx, like userScope
+// in the SQL stores.
+func owns(ctx context.Context, owner model.UserID) bool {
+	user, ok := model.UserFrom(ctx)
+	return !ok || owner == user
+}
+
+func hasTags(todo model.Todo, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(todo.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneTags copies tags so callers can't change a stored todo.
+func cloneTags(tags []string) []string {
+	return append([]string{}, tags...)
+}
+
+var _ ListRepository = &MemoryListRepository{}
+
+// MemoryListRepository keeps its lists with todos, whose lock and
+// transactions it shares.
+type MemoryListRepository struct {
+	todos *MemoryTodoRepository
+}
+
+// counted returns list with the counts of its live todos. The caller
+// holds the lock.
+func (r *MemoryListRepository) counted(list model.List) model.List {
+	list.Counts = model.ListCounts{}
+	for _, todo := range r.todos.todos {
+		if todo.ListID != list.ID || todo.DeletedAt != nil {
+			continue
+		}
+
+		list.Counts.Total++
+		if todo.Complete {
+			list.Counts.Completed++
+		}
+	}
+	return list
+}
+
+func (r *MemoryListRepository) GetList(ctx context.Context, id model.ID) (model.List, error) {
+	r.todos.mu.RLock()
+	defer r.todos.mu.RUnlock()
+
+	list, ok := r.todos.lists[id]
+	if !ok || !owns(ctx, list.UserID) {
+		return model.List{}, model.ErrNotFound
+	}
+
+	return r.counted(list), nil
+}
+
+func (r *MemoryListRepository) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	r.todos.mu.RLock()
+	defer r.todos.mu.RUnlock()
+
+	lists := []model.List{}
+	for _, list := range r.todos.lists {
+		if (list.ArchivedAt != nil) == filter.Archived && owns(ctx, list.UserID) {
+			lists = append(lists, r.counted(list))
+		}
+	}
+
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+
+	return lists, nil
+}
+
+func (r *MemoryListRepository) CreateList(ctx context.Context, list model.List) (model.List, error) {
+	r.todos.mu.Lock()
+	defer r.todos.mu.Unlock()
+
+	r.todos.lastListID++
+
+	now := time.Now().UTC()
+
+	list.ID = r.todos.lastListID
+	list.UserID = ownerOf(ctx, list.UserID)
+	list.ArchivedAt = nil
+	list.CreatedAt = now
+	list.UpdatedAt = now
+	list.Counts = model.ListCounts{}
+
+	r.todos.lists[list.ID] = list
+
+	return list, nil
+}
+
+func (r *MemoryListRepository) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (model.List, error) {
+	r.todos.mu.Lock()
+	defer r.todos.mu.Unlock()
+
+	list, ok := r.todos.lists[id]
+	if !ok || !owns(ctx, list.UserID) {
+		return model.List{}, model.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+
+	if patch.Name != nil {
+		list.Name = *patch.Name
+	}
+	if patch.Archived != nil {
+		if !*patch.Archived {
+			list.ArchivedAt = nil
+		} else if list.ArchivedAt == nil {
+			list.ArchivedAt = &now
+		}
+	}
+	list.UpdatedAt = now
+
+	r.todos.lists[id] = list
+
+	return r.counted(list), nil
+}
+
+func (r *MemoryListRepository) DeleteList(ctx context.Context, id model.ID) error {
+	r.todos.mu.Lock()
+	defer r.todos.mu.Unlock()
+
+	list, ok := r.todos.lists[id]
+	if !ok || !owns(ctx, list.UserID) {
+		return model.ErrNotFound
+	}
+	if r.counted(list).Counts.Total > 0 {
+		return ErrListNotEmpty
+	}
+
+	for todoID, todo := range r.todos.todos {
+		if todo.ListID == id {
+			delete(r.todos.todos, todoID)
+		}
+	}
+	delete(r.todos.lists, id)
+
+	return nil
+}
+
+var _ AuditRepository = &MemoryAuditRepository{}
+
+type MemoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []model.AuditEntry
+	// changes is only set outside of transactions, which publish their
+	// entries when they commit.
+	changes *changeHub
+}
+
+// clone copies the entries of r. The caller holds the lock.
+func (r *MemoryAuditRepository) clone() *MemoryAuditRepository {
+	return &MemoryAuditRepository{entries: slices.Clone(r.entries)}
+}
+
+func (r *MemoryAuditRepository) AppendAudit(ctx context.Context, entry model.AuditEntry) (model.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = int64(len(r.entries)) + 1
+	entry.CreatedAt = time.Now().UTC()
+	entry.Before = cloneAuditTodo(entry.Before)
+	entry.After = cloneAuditTodo(entry.After)
+
+	r.entries = append(r.entries, entry)
+
+	if r.changes != nil {
+		r.changes.publish(entry)
+	}
+
+	return entry, nil
+}
+
+func (r *MemoryAuditRepository) ListAudit(ctx context.Context, filter model.AuditFilter) (model.AuditPage, error) {
+	after, err := decodeAuditCursor(filter)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]model.AuditEntry, 0, filter.Limit+1)
+	for _, entry := range r.entries {
+		if len(entries) > filter.Limit {
+			break
+		}
+		if entry.ID <= after || !owns(ctx, entry.UserID()) {
+			continue
+		}
+		if filter.TodoID != nil && entry.TodoID != *filter.TodoID {
+			continue
+		}
+		if filter.CreatedAfter != nil && entry.CreatedAt.Before(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !entry.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return auditPageOf(filter, entries), nil
+}
+
+// cloneAuditTodo copies a todo so the entry doesn't change along with
+// the caller's copy.
+func cloneAuditTodo(todo *model.Todo) *model.Todo {
+	if todo == nil {
+		return nil
+	}
+
+	clone := *todo
+	clone.Tags = cloneTags(todo.Tags)
+	return &clone
+}
+
+var _ WebhookRepository = &MemoryWebhookRepository{}
+
+type MemoryWebhookRepository struct {
+	mu             sync.RWMutex
+	lastID         model.ID
+	webhooks       map[model.ID]model.Webhook
+	lastDeliveryID int64
+	deliveries     map[int64]model.WebhookDelivery
+}
+
+func newMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		webhooks:   make(map[model.ID]model.Webhook),
+		deliveries: make(map[int64]model.WebhookDelivery),
+	}
+}
+
+// clone copies the webhooks and deliveries of r. The caller holds the
+// lock.
+func (r *MemoryWebhookRepository) clone() *MemoryWebhookRepository {
+	clone := newMemoryWebhookRepository()
+	clone.lastID, clone.lastDeliveryID = r.lastID, r.lastDeliveryID
+	for id, webhook := range r.webhooks {
+		clone.webhooks[id] = webhook
+	}
+	for id, delivery := range r.deliveries {
+		clone.deliveries[id] = delivery
+	}
+	return clone
+}
+
+// replace takes over the state of other. The caller holds the lock.
+func (r *MemoryWebhookRepository) replace(other *MemoryWebhookRepository) {
+	r.lastID, r.webhooks = other.lastID, other.webhooks
+	r.lastDeliveryID, r.deliveries = other.lastDeliveryID, other.deliveries
+}
+
+// cloneWebhook copies webhook so the stored one doesn't change along
+// with the caller's copy.
+func cloneWebhook(webhook model.Webhook) model.Webhook {
+	webhook.Events = nonNilEvents(slices.Clone(webhook.Events))
+	return webhook
+}
+
+// webhook returns the webhook id if the user ctx acts for owns it. The
+// caller holds the lock.
+func (r *MemoryWebhookRepository) webhook(ctx context.Context, id model.ID) (model.Webhook, bool) {
+	webhook, ok := r.webhooks[id]
+	if !ok || !owns(ctx, webhook.UserID) {
+		return model.Webhook{}, false
+	}
+	return webhook, true
+}
+
+func (r *MemoryWebhookRepository) GetWebhook(ctx context.Context, id model.ID) (model.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhook, ok := r.webhook(ctx, id)
+	if !ok {
+		return model.Webhook{}, model.ErrNotFound
+	}
+
+	return cloneWebhook(webhook), nil
+}
+
+func (r *MemoryWebhookRepository) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := make([]model.Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		if owns(ctx, webhook.UserID) {
+			webhooks = append(webhooks, cloneWebhook(webhook))
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	return webhooks, nil
+}
+
+func (r *MemoryWebhookRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	webhook.ID = r.lastID
+	webhook.UserID = ownerOf(ctx, webhook.UserID)
+	webhook.CreatedAt = time.Now().UTC()
+	webhook.UpdatedAt = webhook.CreatedAt
+	webhook = cloneWebhook(webhook)
+
+	r.webhooks[webhook.ID] = webhook
+
+	return cloneWebhook(webhook), nil
+}
+
+func (r *MemoryWebhookRepository) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook, ok := r.webhook(ctx, id)
+	if !ok {
+		return model.Webhook{}, model.ErrNotFound
+	}
+
+	if patch.URL != nil {
+		webhook.URL = *patch.URL
+	}
+	if patch.Events != nil {
+		webhook.Events = *patch.Events
+	}
+	if patch.Secret != nil {
+		webhook.Secret = *patch.Secret
+	}
+	if patch.Active != nil {
+		webhook.Active = *patch.Active
+	}
+	webhook.UpdatedAt = time.Now().UTC()
+	webhook = cloneWebhook(webhook)
+
+	r.webhooks[id] = webhook
+
+	return cloneWebhook(webhook), nil
+}
+
+func (r *MemoryWebhookRepository) DeleteWebhook(ctx context.Context, id model.ID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhook(ctx, id); !ok {
+		return model.ErrNotFound
+	}
+
+	delete(r.webhooks, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+// addDelivery stores a new pending delivery. The caller holds the lock.
+func (r *MemoryWebhookRepository) addDelivery(delivery model.WebhookDelivery) model.WebhookDelivery {
+	r.lastDeliveryID++
+	delivery.ID = r.lastDeliveryID
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.LastAttemptAt = nil
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+	delivery.CreatedAt = time.Now().UTC()
+
+	r.deliveries[delivery.ID] = delivery
+	return delivery
+}
+
+func (r *MemoryWebhookRepository) EnqueueDeliveries(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]model.ID, 0, len(r.webhooks))
+	for id, webhook := range r.webhooks {
+		if webhook.UserID == owner && webhook.Active && webhook.Subscribed(delivery.Event) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		delivery.WebhookID = id
+		r.addDelivery(delivery)
+	}
+
+	return int64(len(ids)), nil
+}
+
+func (r *MemoryWebhookRepository) Redeliver(ctx context.Context, webhookID model.ID, id int64, due time.Time) (model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID {
+		return model.WebhookDelivery{}, model.ErrNotFound
+	}
+	if _, ok := r.webhook(ctx, webhookID); !ok {
+		return model.WebhookDelivery{}, model.ErrNotFound
+	}
+
+	delivery.NextAttemptAt = &due
+	delivery.RedeliveryOf = &id
+
+	return r.addDelivery(delivery), nil
+}
+
+func (r *MemoryWebhookRepository) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (model.DeliveryPage, error) {
+	before, err := decodeIDCursor(filter.Cursor)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := make([]model.WebhookDelivery, 0, filter.Limit+1)
+	if _, ok := r.webhook(ctx, filter.WebhookID); !ok {
+		return deliveryPageOf(filter, deliveries), nil
+	}
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID != filter.WebhookID {
+			continue
+		}
+		if before > 0 && delivery.ID >= before {
+			continue
+		}
+		if filter.Status != nil && delivery.Status != *filter.Status {
+			continue
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+
+	return deliveryPageOf(filter, deliveries), nil
+}
+
+func (r *MemoryWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []model.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status != model.DeliveryPending || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if webhook, ok := r.webhook(ctx, delivery.WebhookID); !ok || !webhook.Active {
+			continue
+		}
+
+		due = append(due, delivery)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(*due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = &leaseUntil
+		r.deliveries[due[i].ID] = due[i]
+	}
+
+	return due, nil
+}
+
+func (r *MemoryWebhookRepository) RecordAttempt(ctx context.Context, id int64, attempt model.DeliveryAttempt) (model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return model.WebhookDelivery{}, model.ErrNotFound
+	}
+	if _, ok := r.webhook(ctx, delivery.WebhookID); !ok {
+		return model.WebhookDelivery{}, model.ErrNotFound
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &attempt.At
+	delivery.ResponseStatus = attempt.ResponseStatus
+	delivery.LastError = attempt.Error
+	delivery.Status = attempt.Status
+	delivery.NextAttemptAt = attempt.NextAttemptAt
+
+	r.deliveries[id] = delivery
+
+	return delivery, nil
+}
+
+var _ ReminderRepository = &MemoryReminderRepository{}
+
+type MemoryReminderRepository struct {
+	mu        sync.Mutex
+	todos     *MemoryTodoRepository
+	lastID    int64
+	reminders map[int64]model.Reminder
+	claimed   map[reminderKey]bool
+}
+
+// reminderKey is what makes a reminder unique.
+type reminderKey struct {
+	todo   model.ID
+	offset time.Duration
+	due    int64
+}
+
+func newMemoryReminderRepository(todos *MemoryTodoRepository) *MemoryReminderRepository {
+	return &MemoryReminderRepository{
+		todos:     todos,
+		reminders: make(map[int64]model.Reminder),
+		claimed:   make(map[reminderKey]bool),
+	}
+}
+
+func (r *MemoryReminderRepository) ClaimReminders(ctx context.Context, offset time.Duration, from, now time.Time, limit int) ([]model.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.todos.mu.RLock()
+	var due []model.Todo
+	for _, todo := range r.todos.todos {
+		if todo.DeletedAt != nil || todo.Complete || todo.DueAt == nil {
+			continue
+		}
+		if !todo.DueAt.After(from.Add(offset)) || todo.DueAt.After(now.Add(offset)) {
+			continue
+		}
+		if r.claimed[reminderKey{todo.ID, offset, todo.DueAt.UnixNano()}] {
+			continue
+		}
+
+		due = append(due, todo)
+	}
+	r.todos.mu.RUnlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].DueAt.Equal(*due[j].DueAt) {
+			return due[i].DueAt.Before(*due[j].DueAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	reminders := make([]model.Reminder, 0, len(due))
+	for _, todo := range due {
+		r.lastID++
+		reminder := model.Reminder{
+			ID:        r.lastID,
+			TodoID:    todo.ID,
+			Offset:    offset,
+			DueAt:     todo.DueAt.UTC(),
+			CreatedAt: now,
+			Todo:      todo,
+		}
+
+		r.reminders[reminder.ID] = reminder
+		r.claimed[reminderKey{todo.ID, offset, todo.DueAt.UnixNano()}] = true
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, nil
+}
+
+func (r *MemoryReminderRepository) RecordReminder(ctx context.Context, id int64, sentAt time.Time, sendErr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return model.ErrNotFound
+	}
+
+	if sendErr == "" {
+		reminder.SentAt = &sentAt
+	}
+	reminder.Error = sendErr
+	r.reminders[id] = reminder
+
+	return nil
+}
+
+var _ LeaseRepository = &MemoryLeaseRepository{}
+
+type MemoryLeaseRepository struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+func (r *MemoryLeaseRepository) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if lease, ok := r.leases[name]; ok && lease.holder != holder && lease.expiresAt.After(now) {
+		return false, nil
+	}
+
+	r.leases[name] = memoryLease{holder: holder, expiresAt: until}
+	return true, nil
+}
+
+func (r *MemoryLeaseRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if lease, ok := r.leases[name]; ok && lease.holder == holder {
+		delete(r.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package store_test
+
+import (
+	"crud/internal/store"
+	"crud/internal/store/storetest"
+	"testing"
+)
+
+func TestMemoryTodoRepository(t *testing.T) {
+	storetest.RunTodoRepositoryTests(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore(store.NewConfig())
+	})
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// sqlStore is implemented by the stores keeping todos in a database, so
+// that the statistics of their connection pool can be exported.
+type sqlStore interface {
+	sqlDB() *sql.DB
+}
+
+func (s *PostgresStore) sqlDB() *sql.DB { return s.db }
+
+func (s *SqliteStore) sqlDB() *sql.DB { return s.db }
+
+type storeMetrics struct {
+	registerer prometheus.Registerer
+	driver     string
+	queries    *prometheus.HistogramVec
+}
+
+var _ Store = &instrumentedStore{}
+
+// instrumentedStore times the calls to its todo repositories, in and
+// outside of transactions, and exports the connection pool statistics
+// of SQL stores once they are opened.
+type instrumentedStore struct {
+	Store
+	metrics *storeMetrics
+}
+
+// Instrument records the latency of the todo queries of s, as
+// crud_store_query_duration_seconds by operation and outcome, and the
+// connection pool statistics of SQL stores with registerer.
+func Instrument(s Store, driver string, registerer prometheus.Registerer) Store {
+	metrics := &storeMetrics{
+		registerer: registerer,
+		driver:     driver,
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   "crud",
+			Subsystem:   "store",
+			Name:        "query_duration_seconds",
+			Help:        "Latency of todo repository calls.",
+			ConstLabels: prometheus.Labels{"driver": driver},
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+	registerer.MustRegister(metrics.queries)
+
+	return &instrumentedStore{Store: s, metrics: metrics}
+}
+
+func (s *instrumentedStore) Open() error {
+	if err := s.Store.Open(); err != nil {
+		return err
+	}
+
+	if db, ok := s.Store.(sqlStore); ok {
+		return s.metrics.registerer.Register(collectors.NewDBStatsCollector(db.sqlDB(), s.metrics.driver))
+	}
+	return nil
+}
+
+func (s *instrumentedStore) Todos() TodoRepository {
+	return &instrumentedTodoRepository{next: s.Store.Todos(), metrics: s.metrics}
+}
+
+func (s *instrumentedStore) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	return s.Store.InTx(ctx, func(tx Tx) error {
+		return fn(&instrumentedTx{Tx: tx, metrics: s.metrics})
+	})
+}
+
+type instrumentedTx struct {
+	Tx
+	metrics *storeMetrics
+}
+
+func (t *instrumentedTx) Todos() TodoRepository {
+	return &instrumentedTodoRepository{next: t.Tx.Todos(), metrics: t.metrics}
+}
+
+var _ TodoRepository = &instrumentedTodoRepository{}
+
+type instrumentedTodoRepository struct {
+	next    TodoRepository
+	metrics *storeMetrics
+}
+
+// observe records a call of operation that started at start and failed
+// with *err, if not nil. It is deferred, so err is read when the call
+// has returned.
+func (r *instrumentedTodoRepository) observe(operation string, start time.Time, err *error) {
+	outcome := "ok"
+	if *err != nil {
+		outcome = "error"
+	}
+	r.metrics.queries.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedTodoRepository) GetTodos(ctx context.Context, ids []model.ID) (_ []model.Todo, err error) {
+	defer r.observe("GetTodos", time.Now(), &err)
+	return r.next.GetTodos(ctx, ids)
+}
+
+func (r *instrumentedTodoRepository) LockTodo(ctx context.Context, id model.ID) (_ model.Todo, err error) {
+	defer r.observe("LockTodo", time.Now(), &err)
+	return r.next.LockTodo(ctx, id)
+}
+
+func (r *instrumentedTodoRepository) GetSubtree(ctx context.Context, id model.ID) (_ []model.Todo, err error) {
+	defer r.observe("GetSubtree", time.Now(), &err)
+	return r.next.GetSubtree(ctx, id)
+}
+
+func (r *instrumentedTodoRepository) LockDescendants(ctx context.Context, id model.ID) (_ []model.Todo, err error) {
+	defer r.observe("LockDescendants", time.Now(), &err)
+	return r.next.LockDescendants(ctx, id)
+}
+
+func (r *instrumentedTodoRepository) ListTodos(ctx context.Context, filter model.TodoFilter) (_ model.TodoPage, err error) {
+	defer r.observe("ListTodos", time.Now(), &err)
+	return r.next.ListTodos(ctx, filter)
+}
+
+func (r *instrumentedTodoRepository) SearchTodos(ctx context.Context, filter model.SearchFilter) (_ model.SearchPage, err error) {
+	defer r.observe("SearchTodos", time.Now(), &err)
+	return r.next.SearchTodos(ctx, filter)
+}
+
+func (r *instrumentedTodoRepository) CreateTodo(ctx context.Context, todo model.Todo) (_ model.Todo, err error) {
+	defer r.observe("CreateTodo", time.Now(), &err)
+	return r.next.CreateTodo(ctx, todo)
+}
+
+func (r *instrumentedTodoRepository) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (_ model.Todo, err error) {
+	defer r.observe("ToggleTodo", time.Now(), &err)
+	return r.next.ToggleTodo(ctx, id, opts)
+}
+
+func (r *instrumentedTodoRepository) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (_ model.Todo, err error) {
+	defer r.observe("UpdateTodo", time.Now(), &err)
+	return r.next.UpdateTodo(ctx, id, patch)
+}
+
+func (r *instrumentedTodoRepository) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (_ model.Todo, err error) {
+	defer r.observe("DeleteTodo", time.Now(), &err)
+	return r.next.DeleteTodo(ctx, id, opts)
+}
+
+func (r *instrumentedTodoRepository) RestoreTodo(ctx context.Context, id model.ID) (_ model.Todo, err error) {
+	defer r.observe("RestoreTodo", time.Now(), &err)
+	return r.next.RestoreTodo(ctx, id)
+}
+
+func (r *instrumentedTodoRepository) PurgeTodos(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	defer r.observe("PurgeTodos", time.Now(), &err)
+	return r.next.PurgeTodos(ctx, deletedBefore)
+}
@@ -0,0 +1,52 @@
+package store_test
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"crud/internal/store/storetest"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedTodoRepository(t *testing.T) {
+	storetest.RunTodoRepositoryTests(t, func(t *testing.T) store.Store {
+		return store.Instrument(store.NewMemoryStore(store.NewConfig()), store.DriverMemory, prometheus.NewRegistry())
+	})
+}
+
+func TestInstrument(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	s := store.Instrument(store.NewSqliteStore(&store.Config{
+		Driver:      store.DriverSqlite,
+		DatabaseUrl: filepath.Join(t.TempDir(), "crud.db"),
+	}), store.DriverSqlite, registry)
+	require.NoError(t, s.Open())
+	defer s.Close()
+	require.NoError(t, s.Migrator().Up())
+
+	ctx := context.Background()
+
+	_, err := s.Todos().CreateTodo(ctx, model.Todo{Title: "Title 1"})
+	require.NoError(t, err)
+	_, err = s.Todos().ToggleTodo(ctx, 2, model.ToggleOptions{})
+	require.ErrorIs(t, err, model.ErrNotFound)
+	require.NoError(t, s.InTx(ctx, func(tx store.Tx) error {
+		_, err := tx.Todos().GetTodos(ctx, []model.ID{1})
+		return err
+	}))
+
+	count, err := testutil.GatherAndCount(registry, "crud_store_query_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "one series per operation and outcome")
+
+	count, err = testutil.GatherAndCount(registry, "go_sql_open_connections", "go_sql_max_open_connections")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
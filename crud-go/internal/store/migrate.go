@@ -0,0 +1,366 @@
+package store
+
+import (
+	"context"
+	"crud/migrations"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrDirtyMigration   = errors.New("database schema is dirty, fix it by hand and reset schema_migrations")
+	ErrUnknownMigration = errors.New("unknown migration version")
+	ErrSchemaOutdated   = errors.New("database schema is not at the latest migration")
+)
+
+type Migrator interface {
+	// Up applies every pending migration.
+	Up() error
+	// Down rolls back the given number of applied migrations.
+	Down(steps int) error
+	// To migrates up or down until version is the current one.
+	// Version 0 rolls back everything.
+	To(version uint) error
+	Status() (MigrationStatus, error)
+	// Check fails with ErrSchemaOutdated unless the latest migration is
+	// applied, or with ErrDirtyMigration if one failed. A schema migrated
+	// past the latest one, by a newer instance in a rolling deploy, is
+	// fine. Unlike Status it doesn't wait for migrations in progress.
+	Check(ctx context.Context) error
+}
+
+type MigrationStatus struct {
+	Current uint
+	Latest  uint
+	Dirty   bool
+	Pending []uint
+}
+
+type migration struct {
+	version uint
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations reads "<version>_<name>.up.sql" and ".down.sql" pairs
+// from dir of the embedded migrations, ordered by version.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrations.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*migration)
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			continue
+		}
+
+		versionPart, title, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(versionPart, 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: bad version", entry.Name())
+		}
+
+		content, err := fs.ReadFile(migrations.FS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &migration{version: uint(version), name: title}
+			byVersion[uint(version)] = m
+		}
+
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	ret := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		ret = append(ret, *m)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].version < ret[j].version })
+
+	return ret, nil
+}
+
+var _ Migrator = &sqlMigrator{}
+
+// sqlMigrator applies embedded migrations to a SQL database. It keeps
+// the schema_migrations table of golang-migrate, so databases migrated
+// with the migrate CLI carry on where they were.
+type sqlMigrator struct {
+	db  *sql.DB
+	dir string
+	// lock is run first in every migration transaction, to keep several
+	// instances from migrating at once.
+	lock string
+}
+
+func newSqlMigrator(db *sql.DB, dir string, lock string) *sqlMigrator {
+	return &sqlMigrator{
+		db:   db,
+		dir:  dir,
+		lock: lock,
+	}
+}
+
+func (m *sqlMigrator) Up() error {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	// A schema a newer instance has migrated further is left as it is,
+	// rather than failing the start of this one.
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if status.Current > status.Latest {
+		return nil
+	}
+
+	return m.To(migrations[len(migrations)-1].version)
+}
+
+func (m *sqlMigrator) Down(steps int) error {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	current := indexOf(migrations, status.Current)
+	if current < 0 && status.Current != 0 {
+		return fmt.Errorf("%w %d", ErrUnknownMigration, status.Current)
+	}
+
+	target := current - steps
+	if target < 0 {
+		return m.To(0)
+	}
+
+	return m.To(migrations[target].version)
+}
+
+func (m *sqlMigrator) To(version uint) error {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && indexOf(migrations, version) < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownMigration, version)
+	}
+
+	for {
+		done := false
+
+		err := m.locked(func(tx *sql.Tx) (err error) {
+			done, err = m.step(tx, migrations, version)
+			return err
+		})
+
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// step moves the schema one migration towards target. The version is
+// read under the lock, so a concurrent migrator that got there first is
+// noticed instead of applied twice.
+func (m *sqlMigrator) step(tx *sql.Tx, migrations []migration, target uint) (bool, error) {
+	current, dirty, err := readVersion(context.Background(), tx)
+	if err != nil {
+		return false, err
+	}
+
+	if dirty {
+		return false, ErrDirtyMigration
+	}
+
+	if current == target {
+		return true, nil
+	}
+
+	currentIndex := indexOf(migrations, current)
+	if currentIndex < 0 && current != 0 {
+		return false, fmt.Errorf("%w %d", ErrUnknownMigration, current)
+	}
+
+	var (
+		applied migration
+		query   string
+		version uint
+	)
+
+	if current < target {
+		applied = migrations[currentIndex+1]
+		query, version = applied.up, applied.version
+	} else {
+		applied = migrations[currentIndex]
+		query = applied.down
+		if currentIndex > 0 {
+			version = migrations[currentIndex-1].version
+		}
+	}
+
+	if _, err := tx.Exec(query); err != nil {
+		return false, fmt.Errorf("migration %d_%s: %w", applied.version, applied.name, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM schema_migrations`); err != nil {
+		return false, err
+	}
+
+	if version != 0 {
+		if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO schema_migrations (version, dirty) VALUES (%d, false)`, version)); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func (m *sqlMigrator) Status() (MigrationStatus, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+
+	err = m.locked(func(tx *sql.Tx) (err error) {
+		status.Current, status.Dirty, err = readVersion(context.Background(), tx)
+		return err
+	})
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	for _, migration := range migrations {
+		status.Latest = migration.version
+		if migration.version > status.Current {
+			status.Pending = append(status.Pending, migration.version)
+		}
+	}
+
+	return status, nil
+}
+
+func (m *sqlMigrator) Check(ctx context.Context) error {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return err
+	}
+
+	var latest uint
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+
+	current, dirty, err := readVersion(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		return ErrDirtyMigration
+	case current < latest:
+		return fmt.Errorf("%w: at %d of %d", ErrSchemaOutdated, current, latest)
+	}
+
+	return nil
+}
+
+// locked runs fn in a transaction holding the migration lock, with the
+// version table created.
+func (m *sqlMigrator) locked(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.lock != "" {
+		if _, err := tx.Exec(m.lock); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rowQuerier is a *sql.DB or a *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func readVersion(ctx context.Context, q rowQuerier) (uint, bool, error) {
+	var (
+		version uint
+		dirty   bool
+	)
+
+	err := q.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+func indexOf(migrations []migration, version uint) int {
+	for i, migration := range migrations {
+		if migration.version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+var _ Migrator = noopMigrator{}
+
+// noopMigrator serves stores without a schema.
+type noopMigrator struct{}
+
+func (noopMigrator) Up() error             { return nil }
+func (noopMigrator) Down(steps int) error  { return nil }
+func (noopMigrator) To(version uint) error { return nil }
+func (noopMigrator) Status() (MigrationStatus, error) {
+	return MigrationStatus{}, nil
+}
+func (noopMigrator) Check(ctx context.Context) error { return nil }
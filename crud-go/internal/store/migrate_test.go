@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlMigrator(t *testing.T) {
+	s := openSqlite(t)
+	defer s.Close()
+
+	migrator := s.Migrator()
+
+	status, err := migrator.Status()
+	require.NoError(t, err)
+	assert.Zero(t, status.Current)
+	assert.NotZero(t, status.Latest)
+	assert.Len(t, status.Pending, int(status.Latest))
+
+	latest := status.Latest
+
+	assert.ErrorIs(t, migrator.Check(context.Background()), store.ErrSchemaOutdated)
+
+	require.NoError(t, migrator.Up())
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest, status.Current)
+	assert.Empty(t, status.Pending)
+	assert.NoError(t, migrator.Check(context.Background()))
+
+	_, err = s.Todos().CreateTodo(context.Background(), model.Todo{Title: "Survives migrations"})
+	require.NoError(t, err)
+
+	// Up is idempotent
+	require.NoError(t, migrator.Up())
+
+	require.NoError(t, migrator.Down(1))
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, status.Current)
+	assert.ErrorIs(t, migrator.Check(context.Background()), store.ErrSchemaOutdated)
+
+	require.NoError(t, migrator.To(1))
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), status.Current)
+
+	require.NoError(t, migrator.Down(100))
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Zero(t, status.Current)
+
+	_, err = s.Todos().CreateTodo(context.Background(), model.Todo{Title: "No table"})
+	assert.Error(t, err)
+
+	assert.Error(t, migrator.To(latest+1))
+}
+
+func TestSqlMigrator_NewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crud.db")
+	s := store.NewSqliteStore(&store.Config{Driver: store.DriverSqlite, DatabaseUrl: path})
+	require.NoError(t, s.Open())
+	defer s.Close()
+	require.NoError(t, s.Migrator().Up())
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// A newer instance has migrated past what this one knows.
+	_, err = db.Exec(`UPDATE schema_migrations SET version = version + 1`)
+	require.NoError(t, err)
+	assert.NoError(t, s.Migrator().Check(context.Background()))
+	assert.NoError(t, s.Migrator().Up(), "auto-migrate leaves the newer schema alone")
+
+	_, err = db.Exec(`UPDATE schema_migrations SET dirty = TRUE`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Migrator().Check(context.Background()), store.ErrDirtyMigration)
+}
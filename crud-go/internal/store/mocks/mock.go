@@ -5,9 +5,11 @@
 package mock_store
 
 import (
+	context "context"
 	model "crud/internal/model"
 	store "crud/internal/store"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,6 +37,34 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// Audit mocks base method.
+func (m *MockStore) Audit() store.AuditRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audit")
+	ret0, _ := ret[0].(store.AuditRepository)
+	return ret0
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockStoreMockRecorder) Audit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockStore)(nil).Audit))
+}
+
+// Changes mocks base method.
+func (m *MockStore) Changes() store.ChangeFeed {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes")
+	ret0, _ := ret[0].(store.ChangeFeed)
+	return ret0
+}
+
+// Changes indicates an expected call of Changes.
+func (mr *MockStoreMockRecorder) Changes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockStore)(nil).Changes))
+}
+
 // Close mocks base method.
 func (m *MockStore) Close() error {
 	m.ctrl.T.Helper()
@@ -49,6 +79,62 @@ func (mr *MockStoreMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStore)(nil).Close))
 }
 
+// InTx mocks base method.
+func (m *MockStore) InTx(ctx context.Context, fn func(store.Tx) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTx indicates an expected call of InTx.
+func (mr *MockStoreMockRecorder) InTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTx", reflect.TypeOf((*MockStore)(nil).InTx), ctx, fn)
+}
+
+// Leases mocks base method.
+func (m *MockStore) Leases() store.LeaseRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Leases")
+	ret0, _ := ret[0].(store.LeaseRepository)
+	return ret0
+}
+
+// Leases indicates an expected call of Leases.
+func (mr *MockStoreMockRecorder) Leases() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Leases", reflect.TypeOf((*MockStore)(nil).Leases))
+}
+
+// Lists mocks base method.
+func (m *MockStore) Lists() store.ListRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lists")
+	ret0, _ := ret[0].(store.ListRepository)
+	return ret0
+}
+
+// Lists indicates an expected call of Lists.
+func (mr *MockStoreMockRecorder) Lists() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lists", reflect.TypeOf((*MockStore)(nil).Lists))
+}
+
+// Migrator mocks base method.
+func (m *MockStore) Migrator() store.Migrator {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Migrator")
+	ret0, _ := ret[0].(store.Migrator)
+	return ret0
+}
+
+// Migrator indicates an expected call of Migrator.
+func (mr *MockStoreMockRecorder) Migrator() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Migrator", reflect.TypeOf((*MockStore)(nil).Migrator))
+}
+
 // Open mocks base method.
 func (m *MockStore) Open() error {
 	m.ctrl.T.Helper()
@@ -63,6 +149,34 @@ func (mr *MockStoreMockRecorder) Open() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockStore)(nil).Open))
 }
 
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
+}
+
+// Reminders mocks base method.
+func (m *MockStore) Reminders() store.ReminderRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reminders")
+	ret0, _ := ret[0].(store.ReminderRepository)
+	return ret0
+}
+
+// Reminders indicates an expected call of Reminders.
+func (mr *MockStoreMockRecorder) Reminders() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reminders", reflect.TypeOf((*MockStore)(nil).Reminders))
+}
+
 // Todos mocks base method.
 func (m *MockStore) Todos() store.TodoRepository {
 	m.ctrl.T.Helper()
@@ -77,6 +191,113 @@ func (mr *MockStoreMockRecorder) Todos() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Todos", reflect.TypeOf((*MockStore)(nil).Todos))
 }
 
+// Webhooks mocks base method.
+func (m *MockStore) Webhooks() store.WebhookRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhooks")
+	ret0, _ := ret[0].(store.WebhookRepository)
+	return ret0
+}
+
+// Webhooks indicates an expected call of Webhooks.
+func (mr *MockStoreMockRecorder) Webhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhooks", reflect.TypeOf((*MockStore)(nil).Webhooks))
+}
+
+// MockTx is a mock of Tx interface.
+type MockTx struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxMockRecorder
+}
+
+// MockTxMockRecorder is the mock recorder for MockTx.
+type MockTxMockRecorder struct {
+	mock *MockTx
+}
+
+// NewMockTx creates a new mock instance.
+func NewMockTx(ctrl *gomock.Controller) *MockTx {
+	mock := &MockTx{ctrl: ctrl}
+	mock.recorder = &MockTxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTx) EXPECT() *MockTxMockRecorder {
+	return m.recorder
+}
+
+// Audit mocks base method.
+func (m *MockTx) Audit() store.AuditRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audit")
+	ret0, _ := ret[0].(store.AuditRepository)
+	return ret0
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockTxMockRecorder) Audit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockTx)(nil).Audit))
+}
+
+// Lists mocks base method.
+func (m *MockTx) Lists() store.ListRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lists")
+	ret0, _ := ret[0].(store.ListRepository)
+	return ret0
+}
+
+// Lists indicates an expected call of Lists.
+func (mr *MockTxMockRecorder) Lists() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lists", reflect.TypeOf((*MockTx)(nil).Lists))
+}
+
+// Savepoint mocks base method.
+func (m *MockTx) Savepoint(ctx context.Context, fn func() error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Savepoint", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Savepoint indicates an expected call of Savepoint.
+func (mr *MockTxMockRecorder) Savepoint(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Savepoint", reflect.TypeOf((*MockTx)(nil).Savepoint), ctx, fn)
+}
+
+// Todos mocks base method.
+func (m *MockTx) Todos() store.TodoRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Todos")
+	ret0, _ := ret[0].(store.TodoRepository)
+	return ret0
+}
+
+// Todos indicates an expected call of Todos.
+func (mr *MockTxMockRecorder) Todos() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Todos", reflect.TypeOf((*MockTx)(nil).Todos))
+}
+
+// Webhooks mocks base method.
+func (m *MockTx) Webhooks() store.WebhookRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhooks")
+	ret0, _ := ret[0].(store.WebhookRepository)
+	return ret0
+}
+
+// Webhooks indicates an expected call of Webhooks.
+func (mr *MockTxMockRecorder) Webhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhooks", reflect.TypeOf((*MockTx)(nil).Webhooks))
+}
+
 // MockTodoRepository is a mock of TodoRepository interface.
 type MockTodoRepository struct {
 	ctrl     *gomock.Controller
@@ -101,76 +322,644 @@ func (m *MockTodoRepository) EXPECT() *MockTodoRepositoryMockRecorder {
 }
 
 // CreateTodo mocks base method.
-func (m *MockTodoRepository) CreateTodo(title string) (model.Todo, error) {
+func (m *MockTodoRepository) CreateTodo(ctx context.Context, todo model.Todo) (model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTodo", title)
+	ret := m.ctrl.Call(m, "CreateTodo", ctx, todo)
 	ret0, _ := ret[0].(model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateTodo indicates an expected call of CreateTodo.
-func (mr *MockTodoRepositoryMockRecorder) CreateTodo(title interface{}) *gomock.Call {
+func (mr *MockTodoRepositoryMockRecorder) CreateTodo(ctx, todo interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTodo", reflect.TypeOf((*MockTodoRepository)(nil).CreateTodo), title)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTodo", reflect.TypeOf((*MockTodoRepository)(nil).CreateTodo), ctx, todo)
 }
 
 // DeleteTodo mocks base method.
-func (m *MockTodoRepository) DeleteTodo(id model.ID) (model.Todo, error) {
+func (m *MockTodoRepository) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTodo", id)
+	ret := m.ctrl.Call(m, "DeleteTodo", ctx, id, opts)
 	ret0, _ := ret[0].(model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteTodo indicates an expected call of DeleteTodo.
-func (mr *MockTodoRepositoryMockRecorder) DeleteTodo(id interface{}) *gomock.Call {
+func (mr *MockTodoRepositoryMockRecorder) DeleteTodo(ctx, id, opts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTodo", reflect.TypeOf((*MockTodoRepository)(nil).DeleteTodo), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTodo", reflect.TypeOf((*MockTodoRepository)(nil).DeleteTodo), ctx, id, opts)
+}
+
+// GetSubtree mocks base method.
+func (m *MockTodoRepository) GetSubtree(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubtree", ctx, id)
+	ret0, _ := ret[0].([]model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubtree indicates an expected call of GetSubtree.
+func (mr *MockTodoRepositoryMockRecorder) GetSubtree(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubtree", reflect.TypeOf((*MockTodoRepository)(nil).GetSubtree), ctx, id)
 }
 
 // GetTodos mocks base method.
-func (m *MockTodoRepository) GetTodos(ids []model.ID) ([]model.Todo, error) {
+func (m *MockTodoRepository) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTodos", ids)
+	ret := m.ctrl.Call(m, "GetTodos", ctx, ids)
 	ret0, _ := ret[0].([]model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTodos indicates an expected call of GetTodos.
-func (mr *MockTodoRepositoryMockRecorder) GetTodos(ids interface{}) *gomock.Call {
+func (mr *MockTodoRepositoryMockRecorder) GetTodos(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodos", reflect.TypeOf((*MockTodoRepository)(nil).GetTodos), ctx, ids)
+}
+
+// ListTodos mocks base method.
+func (m *MockTodoRepository) ListTodos(ctx context.Context, filter model.TodoFilter) (model.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTodos", ctx, filter)
+	ret0, _ := ret[0].(model.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTodos indicates an expected call of ListTodos.
+func (mr *MockTodoRepositoryMockRecorder) ListTodos(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTodos", reflect.TypeOf((*MockTodoRepository)(nil).ListTodos), ctx, filter)
+}
+
+// LockDescendants mocks base method.
+func (m *MockTodoRepository) LockDescendants(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockDescendants", ctx, id)
+	ret0, _ := ret[0].([]model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockDescendants indicates an expected call of LockDescendants.
+func (mr *MockTodoRepositoryMockRecorder) LockDescendants(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockDescendants", reflect.TypeOf((*MockTodoRepository)(nil).LockDescendants), ctx, id)
+}
+
+// LockTodo mocks base method.
+func (m *MockTodoRepository) LockTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockTodo", ctx, id)
+	ret0, _ := ret[0].(model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockTodo indicates an expected call of LockTodo.
+func (mr *MockTodoRepositoryMockRecorder) LockTodo(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockTodo", reflect.TypeOf((*MockTodoRepository)(nil).LockTodo), ctx, id)
+}
+
+// PurgeTodos mocks base method.
+func (m *MockTodoRepository) PurgeTodos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTodos", ctx, deletedBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTodos indicates an expected call of PurgeTodos.
+func (mr *MockTodoRepositoryMockRecorder) PurgeTodos(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTodos", reflect.TypeOf((*MockTodoRepository)(nil).PurgeTodos), ctx, deletedBefore)
+}
+
+// RestoreTodo mocks base method.
+func (m *MockTodoRepository) RestoreTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTodo", ctx, id)
+	ret0, _ := ret[0].(model.Todo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreTodo indicates an expected call of RestoreTodo.
+func (mr *MockTodoRepositoryMockRecorder) RestoreTodo(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTodos", reflect.TypeOf((*MockTodoRepository)(nil).GetTodos), ids)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTodo", reflect.TypeOf((*MockTodoRepository)(nil).RestoreTodo), ctx, id)
+}
+
+// SearchTodos mocks base method.
+func (m *MockTodoRepository) SearchTodos(ctx context.Context, filter model.SearchFilter) (model.SearchPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchTodos", ctx, filter)
+	ret0, _ := ret[0].(model.SearchPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchTodos indicates an expected call of SearchTodos.
+func (mr *MockTodoRepositoryMockRecorder) SearchTodos(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTodos", reflect.TypeOf((*MockTodoRepository)(nil).SearchTodos), ctx, filter)
 }
 
 // ToggleTodo mocks base method.
-func (m *MockTodoRepository) ToggleTodo(id model.ID) (model.Todo, error) {
+func (m *MockTodoRepository) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ToggleTodo", id)
+	ret := m.ctrl.Call(m, "ToggleTodo", ctx, id, opts)
 	ret0, _ := ret[0].(model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ToggleTodo indicates an expected call of ToggleTodo.
-func (mr *MockTodoRepositoryMockRecorder) ToggleTodo(id interface{}) *gomock.Call {
+func (mr *MockTodoRepositoryMockRecorder) ToggleTodo(ctx, id, opts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ToggleTodo", reflect.TypeOf((*MockTodoRepository)(nil).ToggleTodo), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ToggleTodo", reflect.TypeOf((*MockTodoRepository)(nil).ToggleTodo), ctx, id, opts)
 }
 
 // UpdateTodo mocks base method.
-func (m *MockTodoRepository) UpdateTodo(id model.ID, title *string, complete *bool) (model.Todo, error) {
+func (m *MockTodoRepository) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (model.Todo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTodo", id, title, complete)
+	ret := m.ctrl.Call(m, "UpdateTodo", ctx, id, patch)
 	ret0, _ := ret[0].(model.Todo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateTodo indicates an expected call of UpdateTodo.
-func (mr *MockTodoRepositoryMockRecorder) UpdateTodo(id, title, complete interface{}) *gomock.Call {
+func (mr *MockTodoRepositoryMockRecorder) UpdateTodo(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTodo", reflect.TypeOf((*MockTodoRepository)(nil).UpdateTodo), ctx, id, patch)
+}
+
+// MockListRepository is a mock of ListRepository interface.
+type MockListRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockListRepositoryMockRecorder
+}
+
+// MockListRepositoryMockRecorder is the mock recorder for MockListRepository.
+type MockListRepositoryMockRecorder struct {
+	mock *MockListRepository
+}
+
+// NewMockListRepository creates a new mock instance.
+func NewMockListRepository(ctrl *gomock.Controller) *MockListRepository {
+	mock := &MockListRepository{ctrl: ctrl}
+	mock.recorder = &MockListRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockListRepository) EXPECT() *MockListRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateList mocks base method.
+func (m *MockListRepository) CreateList(ctx context.Context, list model.List) (model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateList", ctx, list)
+	ret0, _ := ret[0].(model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateList indicates an expected call of CreateList.
+func (mr *MockListRepositoryMockRecorder) CreateList(ctx, list interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateList", reflect.TypeOf((*MockListRepository)(nil).CreateList), ctx, list)
+}
+
+// DeleteList mocks base method.
+func (m *MockListRepository) DeleteList(ctx context.Context, id model.ID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteList", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteList indicates an expected call of DeleteList.
+func (mr *MockListRepositoryMockRecorder) DeleteList(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteList", reflect.TypeOf((*MockListRepository)(nil).DeleteList), ctx, id)
+}
+
+// GetList mocks base method.
+func (m *MockListRepository) GetList(ctx context.Context, id model.ID) (model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetList", ctx, id)
+	ret0, _ := ret[0].(model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetList indicates an expected call of GetList.
+func (mr *MockListRepositoryMockRecorder) GetList(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockListRepository)(nil).GetList), ctx, id)
+}
+
+// ListLists mocks base method.
+func (m *MockListRepository) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLists", ctx, filter)
+	ret0, _ := ret[0].([]model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLists indicates an expected call of ListLists.
+func (mr *MockListRepositoryMockRecorder) ListLists(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLists", reflect.TypeOf((*MockListRepository)(nil).ListLists), ctx, filter)
+}
+
+// UpdateList mocks base method.
+func (m *MockListRepository) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (model.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateList", ctx, id, patch)
+	ret0, _ := ret[0].(model.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateList indicates an expected call of UpdateList.
+func (mr *MockListRepositoryMockRecorder) UpdateList(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateList", reflect.TypeOf((*MockListRepository)(nil).UpdateList), ctx, id, patch)
+}
+
+// MockAuditRepository is a mock of AuditRepository interface.
+type MockAuditRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditRepositoryMockRecorder
+}
+
+// MockAuditRepositoryMockRecorder is the mock recorder for MockAuditRepository.
+type MockAuditRepositoryMockRecorder struct {
+	mock *MockAuditRepository
+}
+
+// NewMockAuditRepository creates a new mock instance.
+func NewMockAuditRepository(ctrl *gomock.Controller) *MockAuditRepository {
+	mock := &MockAuditRepository{ctrl: ctrl}
+	mock.recorder = &MockAuditRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditRepository) EXPECT() *MockAuditRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AppendAudit mocks base method.
+func (m *MockAuditRepository) AppendAudit(ctx context.Context, entry model.AuditEntry) (model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAudit", ctx, entry)
+	ret0, _ := ret[0].(model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendAudit indicates an expected call of AppendAudit.
+func (mr *MockAuditRepositoryMockRecorder) AppendAudit(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAudit", reflect.TypeOf((*MockAuditRepository)(nil).AppendAudit), ctx, entry)
+}
+
+// ListAudit mocks base method.
+func (m *MockAuditRepository) ListAudit(ctx context.Context, filter model.AuditFilter) (model.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAudit", ctx, filter)
+	ret0, _ := ret[0].(model.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAudit indicates an expected call of ListAudit.
+func (mr *MockAuditRepositoryMockRecorder) ListAudit(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAudit", reflect.TypeOf((*MockAuditRepository)(nil).ListAudit), ctx, filter)
+}
+
+// MockWebhookRepository is a mock of WebhookRepository interface.
+type MockWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepositoryMockRecorder
+}
+
+// MockWebhookRepositoryMockRecorder is the mock recorder for MockWebhookRepository.
+type MockWebhookRepositoryMockRecorder struct {
+	mock *MockWebhookRepository
+}
+
+// NewMockWebhookRepository creates a new mock instance.
+func NewMockWebhookRepository(ctrl *gomock.Controller) *MockWebhookRepository {
+	mock := &MockWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepository) EXPECT() *MockWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ClaimDeliveries mocks base method.
+func (m *MockWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", ctx, now, leaseUntil, limit)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) ClaimDeliveries(ctx, now, leaseUntil, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).ClaimDeliveries), ctx, now, leaseUntil, limit)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockWebhookRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) CreateWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).CreateWebhook), ctx, webhook)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockWebhookRepository) DeleteWebhook(ctx context.Context, id model.ID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteWebhook), ctx, id)
+}
+
+// EnqueueDeliveries mocks base method.
+func (m *MockWebhookRepository) EnqueueDeliveries(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDeliveries", ctx, owner, delivery)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueDeliveries indicates an expected call of EnqueueDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) EnqueueDeliveries(ctx, owner, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).EnqueueDeliveries), ctx, owner, delivery)
+}
+
+// GetWebhook mocks base method.
+func (m *MockWebhookRepository) GetWebhook(ctx context.Context, id model.ID) (model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", ctx, id)
+	ret0, _ := ret[0].(model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) GetWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).GetWebhook), ctx, id)
+}
+
+// ListDeliveries mocks base method.
+func (m *MockWebhookRepository) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (model.DeliveryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeliveries", ctx, filter)
+	ret0, _ := ret[0].(model.DeliveryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeliveries indicates an expected call of ListDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) ListDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).ListDeliveries), ctx, filter)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockWebhookRepository) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", ctx)
+	ret0, _ := ret[0].([]model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockWebhookRepositoryMockRecorder) ListWebhooks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockWebhookRepository)(nil).ListWebhooks), ctx)
+}
+
+// RecordAttempt mocks base method.
+func (m *MockWebhookRepository) RecordAttempt(ctx context.Context, id int64, attempt model.DeliveryAttempt) (model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAttempt", ctx, id, attempt)
+	ret0, _ := ret[0].(model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordAttempt indicates an expected call of RecordAttempt.
+func (mr *MockWebhookRepositoryMockRecorder) RecordAttempt(ctx, id, attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAttempt", reflect.TypeOf((*MockWebhookRepository)(nil).RecordAttempt), ctx, id, attempt)
+}
+
+// Redeliver mocks base method.
+func (m *MockWebhookRepository) Redeliver(ctx context.Context, webhookID model.ID, id int64, due time.Time) (model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, webhookID, id, due)
+	ret0, _ := ret[0].(model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockWebhookRepositoryMockRecorder) Redeliver(ctx, webhookID, id, due interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockWebhookRepository)(nil).Redeliver), ctx, webhookID, id, due)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockWebhookRepository) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", ctx, id, patch)
+	ret0, _ := ret[0].(model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) UpdateWebhook(ctx, id, patch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).UpdateWebhook), ctx, id, patch)
+}
+
+// MockReminderRepository is a mock of ReminderRepository interface.
+type MockReminderRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockReminderRepositoryMockRecorder
+}
+
+// MockReminderRepositoryMockRecorder is the mock recorder for MockReminderRepository.
+type MockReminderRepositoryMockRecorder struct {
+	mock *MockReminderRepository
+}
+
+// NewMockReminderRepository creates a new mock instance.
+func NewMockReminderRepository(ctrl *gomock.Controller) *MockReminderRepository {
+	mock := &MockReminderRepository{ctrl: ctrl}
+	mock.recorder = &MockReminderRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReminderRepository) EXPECT() *MockReminderRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ClaimReminders mocks base method.
+func (m *MockReminderRepository) ClaimReminders(ctx context.Context, offset time.Duration, from, now time.Time, limit int) ([]model.Reminder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimReminders", ctx, offset, from, now, limit)
+	ret0, _ := ret[0].([]model.Reminder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimReminders indicates an expected call of ClaimReminders.
+func (mr *MockReminderRepositoryMockRecorder) ClaimReminders(ctx, offset, from, now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimReminders", reflect.TypeOf((*MockReminderRepository)(nil).ClaimReminders), ctx, offset, from, now, limit)
+}
+
+// RecordReminder mocks base method.
+func (m *MockReminderRepository) RecordReminder(ctx context.Context, id int64, sentAt time.Time, sendErr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordReminder", ctx, id, sentAt, sendErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordReminder indicates an expected call of RecordReminder.
+func (mr *MockReminderRepositoryMockRecorder) RecordReminder(ctx, id, sentAt, sendErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordReminder", reflect.TypeOf((*MockReminderRepository)(nil).RecordReminder), ctx, id, sentAt, sendErr)
+}
+
+// MockLeaseRepository is a mock of LeaseRepository interface.
+type MockLeaseRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaseRepositoryMockRecorder
+}
+
+// MockLeaseRepositoryMockRecorder is the mock recorder for MockLeaseRepository.
+type MockLeaseRepositoryMockRecorder struct {
+	mock *MockLeaseRepository
+}
+
+// NewMockLeaseRepository creates a new mock instance.
+func NewMockLeaseRepository(ctrl *gomock.Controller) *MockLeaseRepository {
+	mock := &MockLeaseRepository{ctrl: ctrl}
+	mock.recorder = &MockLeaseRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLeaseRepository) EXPECT() *MockLeaseRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AcquireLease mocks base method.
+func (m *MockLeaseRepository) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireLease", ctx, name, holder, now, until)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcquireLease indicates an expected call of AcquireLease.
+func (mr *MockLeaseRepositoryMockRecorder) AcquireLease(ctx, name, holder, now, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireLease", reflect.TypeOf((*MockLeaseRepository)(nil).AcquireLease), ctx, name, holder, now, until)
+}
+
+// ReleaseLease mocks base method.
+func (m *MockLeaseRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLease", ctx, name, holder)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseLease indicates an expected call of ReleaseLease.
+func (mr *MockLeaseRepositoryMockRecorder) ReleaseLease(ctx, name, holder interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLease", reflect.TypeOf((*MockLeaseRepository)(nil).ReleaseLease), ctx, name, holder)
+}
+
+// MockChangeFeed is a mock of ChangeFeed interface.
+type MockChangeFeed struct {
+	ctrl     *gomock.Controller
+	recorder *MockChangeFeedMockRecorder
+}
+
+// MockChangeFeedMockRecorder is the mock recorder for MockChangeFeed.
+type MockChangeFeedMockRecorder struct {
+	mock *MockChangeFeed
+}
+
+// NewMockChangeFeed creates a new mock instance.
+func NewMockChangeFeed(ctrl *gomock.Controller) *MockChangeFeed {
+	mock := &MockChangeFeed{ctrl: ctrl}
+	mock.recorder = &MockChangeFeedMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChangeFeed) EXPECT() *MockChangeFeedMockRecorder {
+	return m.recorder
+}
+
+// Subscribe mocks base method.
+func (m *MockChangeFeed) Subscribe(ctx context.Context) <-chan model.AuditEntry {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx)
+	ret0, _ := ret[0].(<-chan model.AuditEntry)
+	return ret0
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockChangeFeedMockRecorder) Subscribe(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTodo", reflect.TypeOf((*MockTodoRepository)(nil).UpdateTodo), id, title, complete)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockChangeFeed)(nil).Subscribe), ctx)
 }
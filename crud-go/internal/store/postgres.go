@@ -1,12 +1,18 @@
 package store
 
 import (
+	"context"
 	"crud/internal/model"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var _ Store = &PostgresStore{}
@@ -15,27 +21,165 @@ type PostgresStore struct {
 	config *Config
 	db     *sql.DB
 
-	todos TodoRepository
+	todos     TodoRepository
+	lists     ListRepository
+	audit     AuditRepository
+	webhooks  WebhookRepository
+	reminders ReminderRepository
+	leases    LeaseRepository
+
+	changes    *changeHub
+	listenerMu sync.Mutex
+	listener   *pq.Listener
 }
 
 func NewPostgresStore(config *Config) Store { // ?
 	store := &PostgresStore{
-		config: config,
+		config:  config,
+		changes: newChangeHub(),
 	}
 
 	todoRepo := newPostgresTodoRepository(store)
 
 	store.todos = todoRepo
+	store.lists = &PostgresListRepository{store: store}
+	store.audit = &PostgresAuditRepository{store: store}
+	store.webhooks = &PostgresWebhookRepository{store: store}
+	store.reminders = &PostgresReminderRepository{store: store}
+	store.leases = &PostgresLeaseRepository{store: store}
+	store.changes.start = store.listen
 
 	return store
 }
 
 func (s *PostgresStore) Todos() TodoRepository {
-	return s.Todos()
+	return s.todos
+}
+
+func (s *PostgresStore) Lists() ListRepository {
+	return s.lists
+}
+
+func (s *PostgresStore) Audit() AuditRepository {
+	return s.audit
+}
+
+func (s *PostgresStore) Webhooks() WebhookRepository {
+	return s.webhooks
+}
+
+func (s *PostgresStore) Reminders() ReminderRepository {
+	return s.reminders
+}
+
+func (s *PostgresStore) Leases() LeaseRepository {
+	return s.leases
+}
+
+func (s *PostgresStore) Changes() ChangeFeed {
+	return s.changes
+}
+
+// postgresChangesChannel is where every audit entry is announced, with
+// its id as the payload, when its transaction commits.
+const postgresChangesChannel = "todo_changes"
+
+// listen starts forwarding the entries announced by any instance to the
+// subscribers of this one. Until the listener is connected nothing is
+// forwarded. If an entry can't be read the subscriptions are ended, so
+// that subscribers can catch up from the audit trail.
+func (s *PostgresStore) listen() {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+
+	s.listener = pq.NewListener(s.config.DatabaseUrl, time.Second, time.Minute, nil)
+	listener := s.listener
+
+	go func() {
+		if err := listener.Listen(postgresChangesChannel); err != nil {
+			s.changes.close()
+			return
+		}
+
+		var lastID int64
+		for notification := range listener.Notify {
+			entries, err := s.announcedAudit(notification, lastID)
+			if err != nil {
+				s.changes.close()
+				continue
+			}
+
+			for _, entry := range entries {
+				lastID = max(lastID, entry.ID)
+			}
+			s.changes.publish(entries...)
+		}
+	}()
+}
+
+// announcedAudit reads the entry announced by notification. A nil
+// notification follows a reconnect, after which everything since lastID
+// is read, as announcements may have been lost in between.
+func (s *PostgresStore) announcedAudit(notification *pq.Notification, lastID int64) ([]model.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := model.AuditFilter{Limit: 1}
+
+	if notification == nil {
+		if lastID == 0 {
+			return nil, nil
+		}
+		filter = model.AuditFilter{AfterID: lastID, Limit: 100}
+	} else {
+		id, err := strconv.ParseInt(notification.Extra, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s payload %q: %w", postgresChangesChannel, notification.Extra, err)
+		}
+		filter.AfterID = id - 1
+	}
+
+	var entries []model.AuditEntry
+	for {
+		page, err := s.audit.ListAudit(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page.Entries...)
+		if page.NextCursor == "" || notification != nil {
+			return entries, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// postgresMigrationLock is the pg_advisory_xact_lock key migrations take.
+const postgresMigrationLock = 7_385_901_264
+
+func (s *PostgresStore) Migrator() Migrator {
+	return newSqlMigrator(s.db, "postgres", fmt.Sprintf(`SELECT pg_advisory_xact_lock(%d)`, postgresMigrationLock))
+}
+
+func (s *PostgresStore) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&sqlTx{
+			tx:       tx,
+			todos:    &PostgresTodoRepository{store: s, tx: tx},
+			lists:    &PostgresListRepository{store: s, tx: tx},
+			audit:    &PostgresAuditRepository{store: s, tx: tx},
+			webhooks: &PostgresWebhookRepository{store: s, tx: tx},
+		})
+	})
 }
 
 func (s *PostgresStore) Open() error {
-	db, err := sql.Open("postgres", s.config.DatabaseUrl)
+	dsn, err := utcSession(s.config.DatabaseUrl)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return err
 	}
@@ -49,7 +193,32 @@ func (s *PostgresStore) Open() error {
 	return nil
 }
 
+// utcSession runs the sessions of dsn in UTC. The TIMESTAMP columns hold
+// UTC, which CURRENT_TIMESTAMP only gives in a UTC session, and Postgres
+// drops the offset of a time compared with them, so times are turned to
+// UTC before they are sent too.
+func utcSession(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			return "", err
+		}
+	}
+	return dsn + " timezone=UTC", nil
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *PostgresStore) Close() error {
+	s.listenerMu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.listenerMu.Unlock()
+
+	s.changes.close()
 	return s.db.Close()
 }
 
@@ -57,6 +226,8 @@ var _ TodoRepository = &PostgresTodoRepository{}
 
 type PostgresTodoRepository struct {
 	store *PostgresStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
 }
 
 func newPostgresTodoRepository(store *PostgresStore) TodoRepository {
@@ -65,93 +236,1426 @@ func newPostgresTodoRepository(store *PostgresStore) TodoRepository {
 	}
 }
 
-func (r *PostgresTodoRepository) GetTodos(ids []model.ID) ([]model.Todo, error) {
+func (r *PostgresTodoRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// inTx runs fn in a transaction of its own, or in the one of the
+// repository, whose owner then decides what happens on failure.
+func (r *PostgresTodoRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return inTx(ctx, r.store.db, fn)
+}
+
+const postgresTodoColumns = `id, user_id, list_id, parent_id, title, description, complete, priority, due_at, due_timezone, recurrence, series_id, tags, created_at, updated_at, completed_at, deleted_at, version`
+
+// postgresSortKeys are the ORDER BY expressions of the sort fields. Todos
+// without a due date sort as if due at infinity.
+var postgresSortKeys = map[model.TodoSortField]string{
+	model.SortByCreatedAt: "created_at",
+	model.SortByUpdatedAt: "updated_at",
+	model.SortByDueAt:     "COALESCE(due_at, 'infinity')",
+	model.SortByPriority:  "priority",
+}
+
+func postgresSortValue(field model.TodoSortField, c *cursor) interface{} {
+	switch field {
+	case model.SortByCreatedAt:
+		return c.CreatedAt
+	case model.SortByUpdatedAt:
+		return c.UpdatedAt
+	case model.SortByDueAt:
+		if c.DueAt == nil {
+			return "infinity"
+		}
+		return *c.DueAt
+	case model.SortByPriority:
+		return c.Priority
+	default:
+		return c.ID
+	}
+}
+
+func scanPostgresTodo(row rowScanner) (model.Todo, error) {
+	var todo model.Todo
+
+	if err := row.Scan(
+		&todo.ID,
+		&todo.UserID,
+		&todo.ListID,
+		&todo.ParentID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Complete,
+		&todo.Priority,
+		&todo.DueAt,
+		&todo.DueTimezone,
+		&todo.Recurrence,
+		&todo.SeriesID,
+		pq.Array(&todo.Tags),
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.CompletedAt,
+		&todo.DeletedAt,
+		&todo.Version,
+	); err != nil {
+		return model.Todo{}, storeError(err)
+	}
+
+	localizeDue(&todo)
+
+	return todo, nil
+}
+
+func (r *PostgresTodoRepository) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
 	ret := make([]model.Todo, 0, len(ids))
 
-	idStrings := make([]string, len(ids))
+	idParam := make([]int64, len(ids))
 	for i, id := range ids {
-		idStrings[i] = fmt.Sprintf("%d", id)
+		idParam[i] = int64(id)
 	}
 
-	idParam := strings.Join(idStrings, ", ")
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+postgresTodoColumns+` FROM todos WHERE id = ANY($1) AND deleted_at IS NULL AND `+userScope(ctx),
+		pq.Array(idParam),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	query := fmt.Sprintf(`SELECT id, title, complete, created_at FROM todos WHERE id IN (%s)`, idParam)
+	for rows.Next() {
+		todo, err := scanPostgresTodo(rows)
+		if err != nil {
+			return nil, err
+		}
 
-	if rows, err := r.store.db.Query(query); err == nil {
-		for rows.Next() {
-			var todo model.Todo
+		ret = append(ret, todo)
+	}
 
-			if err := rows.Scan(&todo.ID, &todo.Title, &todo.Complete, &todo.CreatedAt); err != nil {
-				return nil, err
-			}
+	return ret, rows.Err()
+}
+
+func (r *PostgresTodoRepository) LockTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	return scanPostgresTodo(r.q().QueryRowContext(ctx,
+		`SELECT `+postgresTodoColumns+` FROM todos WHERE id = $1 AND `+userScope(ctx)+` FOR UPDATE`,
+		id,
+	))
+}
+
+// postgresDescendants selects the ids of all descendants of $1 as
+// descendants, including the ones in the trash.
+const postgresDescendants = `WITH RECURSIVE descendants AS (
+		SELECT id FROM todos WHERE parent_id = $1
+		UNION ALL
+		SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id
+	)`
+
+func (r *PostgresTodoRepository) GetSubtree(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	rows, err := r.q().QueryContext(ctx,
+		postgresDescendants+`
+		SELECT `+postgresTodoColumns+` FROM todos
+			WHERE (id = $1 OR id IN (SELECT id FROM descendants)) AND deleted_at IS NULL AND `+userScope(ctx)+`
+			ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-			ret = append(ret, todo)
+	var ret []model.Todo
+	for rows.Next() {
+		todo, err := scanPostgresTodo(rows)
+		if err != nil {
+			return nil, err
 		}
 
-	} else {
+		ret = append(ret, todo)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *PostgresTodoRepository) LockDescendants(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	rows, err := r.q().QueryContext(ctx,
+		postgresDescendants+`
+		SELECT `+postgresTodoColumns+` FROM todos
+			WHERE id IN (SELECT id FROM descendants) AND `+userScope(ctx)+`
+			ORDER BY id FOR UPDATE`,
+		id,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var ret []model.Todo
+	for rows.Next() {
+		todo, err := scanPostgresTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, todo)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *PostgresTodoRepository) ListTodos(ctx context.Context, filter model.TodoFilter) (model.TodoPage, error) {
+	after, err := decodeCursor(filter)
+	if err != nil {
+		return model.TodoPage{}, err
+	}
+
+	var (
+		where = []string{"deleted_at IS NULL", userScope(ctx)}
+		args  []interface{}
+	)
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Trashed {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+	if filter.ListID != nil {
+		where = append(where, "list_id = "+arg(*filter.ListID))
+	}
+	if filter.SeriesID != nil {
+		series := arg(*filter.SeriesID)
+		where = append(where, fmt.Sprintf("(id = %s OR series_id = %s)", series, series))
+	}
+	if filter.Complete != nil {
+		where = append(where, "complete = "+arg(*filter.Complete))
+	}
+	if filter.TitleContains != "" {
+		where = append(where, "title ILIKE "+arg("%"+escapeLike(filter.TitleContains)+"%"))
+	}
+	if filter.CreatedAfter != nil {
+		where = append(where, "created_at >= "+arg(filter.CreatedAfter.UTC()))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "created_at < "+arg(filter.CreatedBefore.UTC()))
+	}
+	if filter.DueAfter != nil {
+		where = append(where, "due_at >= "+arg(*filter.DueAfter))
+	}
+	if filter.DueBefore != nil {
+		where = append(where, "due_at < "+arg(*filter.DueBefore))
+	}
+	if filter.MinPriority != nil {
+		where = append(where, "priority >= "+arg(*filter.MinPriority))
+	}
+	if len(filter.Tags) > 0 {
+		where = append(where, "tags @> "+arg(pq.Array(filter.Tags)))
+	}
+
+	direction, compare := "ASC", ">"
+	if filter.Order == model.SortDesc {
+		direction, compare = "DESC", "<"
+	}
+
+	// Keyset pagination: id breaks ties, so (sort key, id) is unique and
+	// the next page starts strictly after the last row of the previous one.
+	var orderBy string
+	if key, ok := postgresSortKeys[filter.SortBy]; ok {
+		orderBy = fmt.Sprintf("%s %s, id %s", key, direction, direction)
+		if after != nil {
+			where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", key, compare, arg(postgresSortValue(filter.SortBy, after)), arg(after.ID)))
+		}
+	} else {
+		orderBy = "id " + direction
+		if after != nil {
+			where = append(where, fmt.Sprintf("id %s %s", compare, arg(after.ID)))
+		}
+	}
+
+	query := `SELECT ` + postgresTodoColumns + ` FROM todos WHERE ` + strings.Join(where, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %s", orderBy, arg(filter.Limit+1))
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return model.TodoPage{}, err
+	}
+	defer rows.Close()
+
+	todos := make([]model.Todo, 0, filter.Limit+1)
+	for rows.Next() {
+		todo, err := scanPostgresTodo(rows)
+		if err != nil {
+			return model.TodoPage{}, err
+		}
+
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return model.TodoPage{}, err
+	}
+
+	return pageOf(filter, todos), nil
+}
+
+// postgresForeignKeyViolation is the SQLSTATE of a parent_id that
+// references no todo.
+const postgresForeignKeyViolation = "23503"
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == postgresForeignKeyViolation
+}
+
+// postgresSearchText is the escaped text snippets are cut from. The
+// parser takes the entities for single tokens, so they are neither
+// matched nor split.
+const postgresSearchText = `replace(replace(replace(title || ' ' || description, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+
+// SearchTodos matches the terms as prefixes against the search column
+// and its GIN index, and ranks the matches with ts_rank.
+func (r *PostgresTodoRepository) SearchTodos(ctx context.Context, filter model.SearchFilter) (model.SearchPage, error) {
+	offset, err := decodeSearchCursor(filter.Cursor)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+
+	terms := searchTerms(filter.Query)
+	if len(terms) == 0 {
+		return model.SearchPage{Results: []model.SearchResult{}}, nil
+	}
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+postgresTodoColumns+`, ts_rank(search, query) AS rank,
+				ts_headline('simple', `+postgresSearchText+`, query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5')
+			FROM todos, to_tsquery('simple', $1) query
+			WHERE deleted_at IS NULL AND `+userScope(ctx)+` AND search @@ query
+			ORDER BY rank DESC, id
+			LIMIT $2 OFFSET $3`,
+		postgresTSQuery(terms),
+		filter.Limit+1,
+		offset,
+	)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+	defer rows.Close()
 
-	return ret, nil
+	results := make([]model.SearchResult, 0, filter.Limit+1)
+	for rows.Next() {
+		var result model.SearchResult
+		todo, err := scanPostgresTodo(withExtraColumns(rows, &result.Rank, &result.Snippet))
+		if err != nil {
+			return model.SearchPage{}, err
+		}
+
+		result.Todo = todo
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return model.SearchPage{}, err
+	}
+
+	return searchPageOf(filter, offset, results), nil
+}
+
+// postgresTSQuery matches todos having words that begin with every term.
+// Terms are made of letters and digits only, so they need no quoting.
+func postgresTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
 }
 
-func (r *PostgresTodoRepository) CreateTodo(title string) (model.Todo, error) {
-	var todo model.Todo = model.Todo{
-		Title:    title,
-		Complete: false,
+func (r *PostgresTodoRepository) CreateTodo(ctx context.Context, todo model.Todo) (model.Todo, error) {
+	insert := func(q querier, owner model.UserID) (model.Todo, error) {
+		created, err := scanPostgresTodo(q.QueryRowContext(ctx,
+			`INSERT INTO todos (user_id, list_id, parent_id, title, description, complete, priority, due_at, due_timezone, recurrence, series_id, tags, completed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CASE WHEN $6 THEN CURRENT_TIMESTAMP END)
+				RETURNING `+postgresTodoColumns,
+			owner,
+			todo.ListID,
+			todo.ParentID,
+			todo.Title,
+			todo.Description,
+			todo.Complete,
+			todo.Priority,
+			todo.DueAt,
+			todo.DueTimezone,
+			todo.Recurrence,
+			todo.SeriesID,
+			pq.Array(nonNilTags(todo.Tags)),
+		))
+		if isForeignKeyViolation(err) {
+			return model.Todo{}, ErrParentNotFound
+		}
+		return created, err
+	}
+
+	if todo.ParentID == nil {
+		return insert(r.q(), ownerOf(ctx, todo.UserID))
 	}
 
-	if err := r.store.db.QueryRow(
-		`INSERT INTO todos (title, complete) VALUES ($1, $2) RETURNING id, created_at`,
-		todo.Title,
-		todo.Complete,
-	).Scan(&todo.ID, &todo.CreatedAt); err != nil {
+	var created model.Todo
+
+	// The foreign key doesn't know about the trash, owners or lists, so
+	// check the parent and keep it from being deleted until the todo is
+	// in.
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var (
+			owner model.UserID
+			list  model.ID
+		)
+		if err := tx.QueryRowContext(ctx,
+			`SELECT user_id, list_id FROM todos WHERE id = $1 AND deleted_at IS NULL AND `+userScope(ctx)+` FOR SHARE`,
+			*todo.ParentID,
+		).Scan(&owner, &list); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrParentNotFound
+			}
+			return err
+		}
+		if list != todo.ListID {
+			return ErrParentInOtherList
+		}
+
+		var err error
+		created, err = insert(tx, owner)
+		return err
+	})
+	if err != nil {
 		return model.Todo{}, err
 	}
 
-	return todo, nil
+	return created, nil
+}
+
+func (r *PostgresTodoRepository) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (model.Todo, error) {
+	query := `UPDATE todos
+		SET complete = NOT complete,
+			completed_at = CASE WHEN complete THEN NULL ELSE CURRENT_TIMESTAMP END,
+			updated_at = CURRENT_TIMESTAMP,
+			version = version + 1
+		WHERE id=$1 AND deleted_at IS NULL AND ` + userScope(ctx)
+	args := []interface{}{id}
+	if opts.IfVersion != nil {
+		query += ` AND version = $2`
+		args = append(args, *opts.IfVersion)
+	}
+
+	var toggled model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		toggled, err = scanPostgresTodo(tx.QueryRowContext(ctx, query+` RETURNING `+postgresTodoColumns, args...))
+		if err != nil || !opts.Cascade {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			postgresDescendants+`
+			UPDATE todos
+				SET complete = $2,
+					completed_at = CASE WHEN $2 THEN CURRENT_TIMESTAMP END,
+					updated_at = CURRENT_TIMESTAMP,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants) AND deleted_at IS NULL AND complete <> $2`,
+			id,
+			toggled.Complete,
+		)
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, opts.IfVersion, err)
+	}
+
+	return toggled, nil
 }
 
-func (r *PostgresTodoRepository) ToggleTodo(id model.ID) (model.Todo, error) {
-	var todo model.Todo = model.Todo{}
+// postgresTreeLock is the pg_advisory_xact_lock key taken while moving a
+// todo, so two concurrent moves can't build a cycle between them.
+const postgresTreeLock = 7_385_901_265
+
+// checkParent makes sure parent exists, is in list, or the list of id
+// if nil, and isn't id or one of its descendants. A todo that isn't
+// there is left to the update to report.
+func (r *PostgresTodoRepository) checkParent(ctx context.Context, tx *sql.Tx, id, parent model.ID, list *model.ID) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`SELECT pg_advisory_xact_lock(%d)`, postgresTreeLock)); err != nil {
+		return err
+	}
 
-	if err := r.store.db.QueryRow(
-		`UPDATE todos SET complete = NOT complete WHERE id=$1 RETURNING id, title, complete, created_at`,
+	var exists, cycle, sameList bool
+	if err := tx.QueryRowContext(ctx,
+		`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, list_id FROM todos WHERE id = $1 AND deleted_at IS NULL AND `+userScope(ctx)+`
+			UNION ALL
+			SELECT t.id, t.parent_id, t.list_id FROM todos t JOIN ancestors a ON t.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors), EXISTS (SELECT 1 FROM ancestors WHERE id = $2),
+			EXISTS (SELECT 1 FROM ancestors WHERE id = $1
+				AND list_id = COALESCE($3, (SELECT list_id FROM todos WHERE id = $2 AND `+userScope(ctx)+`), list_id))`,
+		parent,
 		id,
-	).Scan(&todo.ID, &todo.Title, &todo.Complete, &todo.CreatedAt); err != nil {
+		list,
+	).Scan(&exists, &cycle, &sameList); err != nil {
+		return err
+	}
+
+	switch {
+	case !exists:
+		return ErrParentNotFound
+	case cycle:
+		return ErrCycle
+	case !sameList:
+		return ErrParentInOtherList
+	default:
+		return nil
+	}
+}
+
+func (r *PostgresTodoRepository) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (model.Todo, error) {
+	args := []interface{}{id}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	sets := []string{"updated_at = CURRENT_TIMESTAMP", "version = version + 1"}
+
+	if patch.ListID != nil {
+		list := arg(*patch.ListID)
+		sets = append(sets, "list_id = "+list)
+		if !patch.ParentID.Set {
+			sets = append(sets, fmt.Sprintf("parent_id = CASE WHEN list_id = %s THEN parent_id END", list))
+		}
+	}
+	if patch.ParentID.Set {
+		sets = append(sets, "parent_id = "+arg(patch.ParentID.Value))
+	}
+	if patch.Title != nil {
+		sets = append(sets, "title = "+arg(*patch.Title))
+	}
+	if patch.Description != nil {
+		sets = append(sets, "description = "+arg(*patch.Description))
+	}
+	if patch.Complete != nil {
+		complete := arg(*patch.Complete)
+		sets = append(sets,
+			"complete = "+complete,
+			fmt.Sprintf("completed_at = CASE WHEN %s THEN COALESCE(completed_at, CURRENT_TIMESTAMP) END", complete),
+		)
+	}
+	if patch.Priority != nil {
+		sets = append(sets, "priority = "+arg(*patch.Priority))
+	}
+	if patch.DueAt.Set {
+		sets = append(sets, "due_at = "+arg(patch.DueAt.Value))
+	}
+	if patch.DueTimezone != nil {
+		sets = append(sets, "due_timezone = "+arg(*patch.DueTimezone))
+	}
+	if patch.Recurrence != nil {
+		sets = append(sets, "recurrence = "+arg(*patch.Recurrence))
+	}
+	if patch.Tags != nil {
+		sets = append(sets, "tags = "+arg(pq.Array(nonNilTags(*patch.Tags))))
+	}
+
+	query := `UPDATE todos SET ` + strings.Join(sets, ", ") + ` WHERE id=$1 AND deleted_at IS NULL AND ` + userScope(ctx)
+	if patch.IfVersion != nil {
+		query += ` AND version = ` + arg(*patch.IfVersion)
+	}
+	query += ` RETURNING ` + postgresTodoColumns
+
+	move := patch.ParentID.Set && patch.ParentID.Value != nil
+	if !move && patch.ListID == nil {
+		updated, err := scanPostgresTodo(r.q().QueryRowContext(ctx, query, args...))
+		if err != nil {
+			return model.Todo{}, checkVersion(ctx, r, id, patch.IfVersion, err)
+		}
+		return updated, nil
+	}
+
+	var updated model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if move {
+			if err := r.checkParent(ctx, tx, id, *patch.ParentID.Value, patch.ListID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		updated, err = scanPostgresTodo(tx.QueryRowContext(ctx, query, args...))
+		if err != nil || patch.ListID == nil {
+			return err
+		}
+
+		// The subtree, trash included, goes along.
+		_, err = tx.ExecContext(ctx,
+			postgresDescendants+`
+			UPDATE todos
+				SET list_id = $2,
+					updated_at = CURRENT_TIMESTAMP,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants) AND list_id <> $2`,
+			id,
+			*patch.ListID,
+		)
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, patch.IfVersion, err)
+	}
+
+	return updated, nil
+}
+
+func (r *PostgresTodoRepository) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (model.Todo, error) {
+	// clock_timestamp rather than CURRENT_TIMESTAMP, which is the start
+	// of the transaction: deletions in one transaction, as in a batch,
+	// must not share the deleted_at that RestoreTodo looks for. Both are
+	// UTC in the sessions of utcSession, as PurgeTodos expects.
+	query := `UPDATE todos
+		SET deleted_at = clock_timestamp(),
+			updated_at = CURRENT_TIMESTAMP,
+			version = version + 1
+		WHERE id=$1 AND deleted_at IS NULL AND ` + userScope(ctx)
+	args := []interface{}{id}
+	if opts.IfVersion != nil {
+		query += ` AND version = $2`
+		args = append(args, *opts.IfVersion)
+	}
+
+	var deleted model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		deleted, err = scanPostgresTodo(tx.QueryRowContext(ctx, query+` RETURNING `+postgresTodoColumns, args...))
+		if err != nil {
+			return err
+		}
+
+		// The subtree shares the deleted_at of its root.
+		if opts.Cascade {
+			_, err = tx.ExecContext(ctx,
+				postgresDescendants+`
+				UPDATE todos
+					SET deleted_at = $2,
+						updated_at = CURRENT_TIMESTAMP,
+						version = version + 1
+					WHERE id IN (SELECT id FROM descendants) AND deleted_at IS NULL`,
+				id,
+				deleted.DeletedAt,
+			)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE todos
+					SET parent_id = $2,
+						updated_at = CURRENT_TIMESTAMP,
+						version = version + 1
+					WHERE parent_id = $1 AND deleted_at IS NULL`,
+				id,
+				deleted.ParentID,
+			)
+		}
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, opts.IfVersion, err)
+	}
+
+	return deleted, nil
+}
+
+func (r *PostgresTodoRepository) RestoreTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	var restored model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			postgresDescendants+`
+			UPDATE todos
+				SET deleted_at = NULL,
+					updated_at = CURRENT_TIMESTAMP,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants)
+					AND deleted_at = (SELECT deleted_at FROM todos WHERE id = $1)`,
+			id,
+		); err != nil {
+			return err
+		}
+
+		var err error
+		restored, err = scanPostgresTodo(tx.QueryRowContext(ctx,
+			`UPDATE todos
+				SET deleted_at = NULL,
+					updated_at = CURRENT_TIMESTAMP,
+					version = version + 1,
+					parent_id = CASE
+						WHEN EXISTS (SELECT 1 FROM todos p WHERE p.id = todos.parent_id AND p.deleted_at IS NULL) THEN parent_id
+					END
+				WHERE id=$1 AND deleted_at IS NOT NULL AND `+userScope(ctx)+`
+				RETURNING `+postgresTodoColumns,
+			id,
+		))
+		return err
+	})
+	if err != nil {
 		return model.Todo{}, err
 	}
 
-	return todo, nil
+	return restored, nil
+}
+
+func (r *PostgresTodoRepository) PurgeTodos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	deletedBefore = deletedBefore.UTC()
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// Whatever still points at a purged todo loses its parent rather
+		// than failing the foreign key.
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE todos
+				SET parent_id = NULL,
+					version = version + 1
+				WHERE parent_id IN (SELECT id FROM todos WHERE deleted_at < $1 AND `+userScope(ctx)+`)
+					AND (deleted_at IS NULL OR deleted_at >= $1)`,
+			deletedBefore,
+		); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE deleted_at < $1 AND `+userScope(ctx), deletedBefore)
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
 }
 
-func (r *PostgresTodoRepository) UpdateTodo(id model.ID, title *string, complete *bool) (model.Todo, error) {
-	var todo model.Todo = model.Todo{}
+var _ ListRepository = &PostgresListRepository{}
 
-	if err := r.store.db.QueryRow(
-		`UPDATE todos 
-			SET title=COALESCE($2, title), 
-				complete=COALESCE($3, complete) 
-			WHERE id=$1 RETURNING id, title, complete, created_at`,
+type PostgresListRepository struct {
+	store *PostgresStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func (r *PostgresListRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// inTx runs fn in a transaction of its own, or in the one of the
+// repository.
+func (r *PostgresListRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return inTx(ctx, r.store.db, fn)
+}
+
+// postgresListColumns are the columns of a list followed by the counts
+// of its live todos.
+const postgresListColumns = `id, user_id, name, archived_at, created_at, updated_at,
+	(SELECT COUNT(*) FROM todos WHERE list_id = lists.id AND deleted_at IS NULL),
+	(SELECT COUNT(*) FROM todos WHERE list_id = lists.id AND deleted_at IS NULL AND complete)`
+
+func scanPostgresList(row rowScanner) (model.List, error) {
+	var list model.List
+
+	if err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		&list.ArchivedAt,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+		&list.Counts.Total,
+		&list.Counts.Completed,
+	); err != nil {
+		return model.List{}, storeError(err)
+	}
+
+	return list, nil
+}
+
+func (r *PostgresListRepository) GetList(ctx context.Context, id model.ID) (model.List, error) {
+	return scanPostgresList(r.q().QueryRowContext(ctx,
+		`SELECT `+postgresListColumns+` FROM lists WHERE id = $1 AND `+userScope(ctx), id))
+}
+
+func (r *PostgresListRepository) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	archived := "archived_at IS NULL"
+	if filter.Archived {
+		archived = "archived_at IS NOT NULL"
+	}
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+postgresListColumns+` FROM lists WHERE `+archived+` AND `+userScope(ctx)+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []model.List{}
+	for rows.Next() {
+		list, err := scanPostgresList(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (r *PostgresListRepository) CreateList(ctx context.Context, list model.List) (model.List, error) {
+	return scanPostgresList(r.q().QueryRowContext(ctx,
+		`INSERT INTO lists (user_id, name)
+			VALUES ($1, $2)
+			RETURNING `+postgresListColumns,
+		ownerOf(ctx, list.UserID),
+		list.Name,
+	))
+}
+
+func (r *PostgresListRepository) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (model.List, error) {
+	sets := []string{"updated_at = CURRENT_TIMESTAMP"}
+	args := []interface{}{id}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if patch.Name != nil {
+		sets = append(sets, "name = "+arg(*patch.Name))
+	}
+	if patch.Archived != nil {
+		sets = append(sets, fmt.Sprintf("archived_at = CASE WHEN %s THEN COALESCE(archived_at, CURRENT_TIMESTAMP) END", arg(*patch.Archived)))
+	}
+
+	return scanPostgresList(r.q().QueryRowContext(ctx,
+		`UPDATE lists SET `+strings.Join(sets, ", ")+` WHERE id = $1 AND `+userScope(ctx)+` RETURNING `+postgresListColumns,
+		args...,
+	))
+}
+
+func (r *PostgresListRepository) DeleteList(ctx context.Context, id model.ID) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		// The lock keeps todos from being added while the list goes.
+		var live bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM todos WHERE list_id = lists.id AND deleted_at IS NULL)
+				FROM lists WHERE id = $1 AND `+userScope(ctx)+` FOR UPDATE`,
+			id,
+		).Scan(&live); err != nil {
+			return storeError(err)
+		}
+		if live {
+			return ErrListNotEmpty
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE list_id = $1`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM lists WHERE id = $1`, id)
+		return err
+	})
+}
+
+var _ AuditRepository = &PostgresAuditRepository{}
+
+type PostgresAuditRepository struct {
+	store *PostgresStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func (r *PostgresAuditRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+const postgresAuditColumns = `id, todo_id, action, before, after, actor, request_id, created_at`
+
+func scanPostgresAudit(row rowScanner) (model.AuditEntry, error) {
+	var entry model.AuditEntry
+
+	if err := row.Scan(
+		&entry.ID,
+		&entry.TodoID,
+		&entry.Action,
+		auditTodo{&entry.Before},
+		auditTodo{&entry.After},
+		&entry.Actor,
+		&entry.RequestID,
+		&entry.CreatedAt,
+	); err != nil {
+		return model.AuditEntry{}, storeError(err)
+	}
+
+	return entry, nil
+}
+
+func (r *PostgresAuditRepository) AppendAudit(ctx context.Context, entry model.AuditEntry) (model.AuditEntry, error) {
+	before, err := auditTodoValue(entry.Before)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+	after, err := auditTodoValue(entry.After)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	entry, err = scanPostgresAudit(r.q().QueryRowContext(ctx,
+		`INSERT INTO todo_audit (todo_id, user_id, action, before, after, actor, request_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+postgresAuditColumns,
+		entry.TodoID,
+		entry.UserID(),
+		entry.Action,
+		before,
+		after,
+		entry.Actor,
+		entry.RequestID,
+	))
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	// Notifications are only sent once the transaction commits.
+	if _, err := r.q().ExecContext(ctx,
+		`SELECT pg_notify($1, $2)`,
+		postgresChangesChannel,
+		strconv.FormatInt(entry.ID, 10),
+	); err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	return entry, nil
+}
+
+func (r *PostgresAuditRepository) ListAudit(ctx context.Context, filter model.AuditFilter) (model.AuditPage, error) {
+	after, err := decodeAuditCursor(filter)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+
+	var (
+		where = []string{userScope(ctx)}
+		args  []interface{}
+	)
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.TodoID != nil {
+		where = append(where, "todo_id = "+arg(*filter.TodoID))
+	}
+	if filter.CreatedAfter != nil {
+		where = append(where, "created_at >= "+arg(filter.CreatedAfter.UTC()))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "created_at < "+arg(filter.CreatedBefore.UTC()))
+	}
+	if after > 0 {
+		where = append(where, "id > "+arg(after))
+	}
+
+	query := `SELECT ` + postgresAuditColumns + ` FROM todo_audit WHERE ` + strings.Join(where, " AND ")
+	query += " ORDER BY id LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditEntry, 0, filter.Limit+1)
+	for rows.Next() {
+		entry, err := scanPostgresAudit(rows)
+		if err != nil {
+			return model.AuditPage{}, err
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return model.AuditPage{}, err
+	}
+
+	return auditPageOf(filter, entries), nil
+}
+
+var _ WebhookRepository = &PostgresWebhookRepository{}
+
+type PostgresWebhookRepository struct {
+	store *PostgresStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func (r *PostgresWebhookRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// postgresEvents scans the TEXT[] events column.
+type postgresEvents struct {
+	events *[]model.ChangeType
+}
+
+func (s postgresEvents) Scan(src interface{}) error {
+	var events []string
+	if err := pq.Array(&events).Scan(src); err != nil {
+		return err
+	}
+
+	*s.events = make([]model.ChangeType, 0, len(events))
+	for _, event := range events {
+		*s.events = append(*s.events, model.ChangeType(event))
+	}
+
+	return nil
+}
+
+func postgresEventsValue(events []model.ChangeType) interface{} {
+	values := make([]string, 0, len(events))
+	for _, event := range events {
+		values = append(values, string(event))
+	}
+	return pq.Array(values)
+}
+
+const postgresWebhookColumns = `id, user_id, url, events, secret, active, created_at, updated_at`
+
+func scanPostgresWebhook(row rowScanner) (model.Webhook, error) {
+	var webhook model.Webhook
+
+	if err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		postgresEvents{&webhook.Events},
+		&webhook.Secret,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	); err != nil {
+		return model.Webhook{}, storeError(err)
+	}
+
+	return webhook, nil
+}
+
+const postgresDeliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, redelivery_of, created_at`
+
+func scanPostgresDelivery(row rowScanner) (model.WebhookDelivery, error) {
+	var (
+		delivery       model.WebhookDelivery
+		responseStatus sql.NullInt64
+		redeliveryOf   sql.NullInt64
+	)
+
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastAttemptAt,
+		&responseStatus,
+		&delivery.LastError,
+		&redeliveryOf,
+		&delivery.CreatedAt,
+	); err != nil {
+		return model.WebhookDelivery{}, storeError(err)
+	}
+
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = &redeliveryOf.Int64
+	}
+
+	return delivery, nil
+}
+
+func scanPostgresDeliveries(rows *sql.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanPostgresDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *PostgresWebhookRepository) GetWebhook(ctx context.Context, id model.ID) (model.Webhook, error) {
+	return scanPostgresWebhook(r.q().QueryRowContext(ctx,
+		`SELECT `+postgresWebhookColumns+` FROM webhooks WHERE id = $1 AND `+userScope(ctx), id))
+}
+
+func (r *PostgresWebhookRepository) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	rows, err := r.q().QueryContext(ctx, `SELECT `+postgresWebhookColumns+` FROM webhooks WHERE `+userScope(ctx)+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		webhook, err := scanPostgresWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *PostgresWebhookRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	return scanPostgresWebhook(r.q().QueryRowContext(ctx,
+		`INSERT INTO webhooks (user_id, url, events, secret, active)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+postgresWebhookColumns,
+		ownerOf(ctx, webhook.UserID),
+		webhook.URL,
+		postgresEventsValue(webhook.Events),
+		webhook.Secret,
+		webhook.Active,
+	))
+}
+
+func (r *PostgresWebhookRepository) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (model.Webhook, error) {
+	sets := []string{"updated_at = CURRENT_TIMESTAMP"}
+	args := []interface{}{id}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if patch.URL != nil {
+		sets = append(sets, "url = "+arg(*patch.URL))
+	}
+	if patch.Events != nil {
+		sets = append(sets, "events = "+arg(postgresEventsValue(*patch.Events)))
+	}
+	if patch.Secret != nil {
+		sets = append(sets, "secret = "+arg(*patch.Secret))
+	}
+	if patch.Active != nil {
+		sets = append(sets, "active = "+arg(*patch.Active))
+	}
+
+	return scanPostgresWebhook(r.q().QueryRowContext(ctx,
+		`UPDATE webhooks SET `+strings.Join(sets, ", ")+` WHERE id = $1 AND `+userScope(ctx)+` RETURNING `+postgresWebhookColumns,
+		args...,
+	))
+}
+
+func (r *PostgresWebhookRepository) DeleteWebhook(ctx context.Context, id model.ID) error {
+	result, err := r.q().ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND `+userScope(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresWebhookRepository) EnqueueDeliveries(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error) {
+	result, err := r.q().ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at)
+			SELECT id, $1, $2, $3, $4 FROM webhooks
+			WHERE user_id = $5 AND active AND (cardinality(events) = 0 OR $2 = ANY(events))
+			ORDER BY id`,
+		delivery.EventID,
+		delivery.Event,
+		delivery.Payload,
+		delivery.NextAttemptAt,
+		owner,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *PostgresWebhookRepository) Redeliver(ctx context.Context, webhookID model.ID, id int64, due time.Time) (model.WebhookDelivery, error) {
+	return scanPostgresDelivery(r.q().QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at, redelivery_of)
+			SELECT webhook_id, event_id, event, payload, $1, id FROM webhook_deliveries
+			WHERE id = $2 AND webhook_id = $3
+				AND webhook_id IN (SELECT id FROM webhooks WHERE `+userScope(ctx)+`)
+			RETURNING `+postgresDeliveryColumns,
+		due,
 		id,
-		title,
-		complete,
-	).Scan(&todo.ID, &todo.Title, &todo.Complete, &todo.CreatedAt); err != nil {
-		return model.Todo{}, err
+		webhookID,
+	))
+}
+
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (model.DeliveryPage, error) {
+	before, err := decodeIDCursor(filter.Cursor)
+	if err != nil {
+		return model.DeliveryPage{}, err
 	}
 
-	return todo, nil
+	args := []interface{}{filter.WebhookID}
+	where := []string{"webhook_id = $1", "webhook_id IN (SELECT id FROM webhooks WHERE " + userScope(ctx) + ")"}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Status != nil {
+		where = append(where, "status = "+arg(*filter.Status))
+	}
+	if before > 0 {
+		where = append(where, "id < "+arg(before))
+	}
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+postgresDeliveryColumns+` FROM webhook_deliveries
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY id DESC LIMIT `+arg(filter.Limit+1),
+		args...,
+	)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	deliveries, err := scanPostgresDeliveries(rows)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	return deliveryPageOf(filter, nonNilDeliveries(deliveries)), nil
 }
 
-func (r *PostgresTodoRepository) DeleteTodo(id model.ID) (model.Todo, error) {
-	var todo model.Todo = model.Todo{}
+// ClaimDeliveries skips the deliveries other instances are claiming, so
+// that each is sent by one of them.
+func (r *PostgresWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.q().QueryContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = $1
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+					JOIN webhooks w ON w.id = d.webhook_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= $2 AND w.active AND `+userScope(ctx)+`
+				ORDER BY d.next_attempt_at, d.id
+				LIMIT $3
+				FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING `+postgresDeliveryColumns,
+		leaseUntil,
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := scanPostgresDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING doesn't keep the order of the subquery.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	return deliveries, nil
+}
 
-	if err := r.store.db.QueryRow(
-		`DELETE FROM todos WHERE id=$1 RETURNING id, title, complete, created_at`,
+func (r *PostgresWebhookRepository) RecordAttempt(ctx context.Context, id int64, attempt model.DeliveryAttempt) (model.WebhookDelivery, error) {
+	return scanPostgresDelivery(r.q().QueryRowContext(ctx,
+		`UPDATE webhook_deliveries SET
+				attempts = attempts + 1,
+				last_attempt_at = $2,
+				response_status = $3,
+				last_error = $4,
+				status = $5,
+				next_attempt_at = $6
+			WHERE id = $1 AND webhook_id IN (SELECT id FROM webhooks WHERE `+userScope(ctx)+`)
+			RETURNING `+postgresDeliveryColumns,
 		id,
-	).Scan(&todo.ID, &todo.Title, &todo.Complete, &todo.CreatedAt); err != nil {
-		return model.Todo{}, err
+		attempt.At,
+		attempt.ResponseStatus,
+		attempt.Error,
+		attempt.Status,
+		attempt.NextAttemptAt,
+	))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var _ ReminderRepository = &PostgresReminderRepository{}
+
+type PostgresReminderRepository struct {
+	store *PostgresStore
+}
+
+// ClaimReminders leaves the todos claimed by a concurrent call to it,
+// which the unique key of reminders turns away.
+func (r *PostgresReminderRepository) ClaimReminders(ctx context.Context, offset time.Duration, from, now time.Time, limit int) ([]model.Reminder, error) {
+	rows, err := r.store.db.QueryContext(ctx,
+		`INSERT INTO reminders (todo_id, offset_seconds, due_at, created_at)
+			SELECT id, $1::BIGINT, due_at, $4::TIMESTAMP FROM todos
+			WHERE deleted_at IS NULL AND NOT complete AND due_at > $2 AND due_at <= $3
+				AND NOT EXISTS (
+					SELECT 1 FROM reminders r
+					WHERE r.todo_id = todos.id AND r.offset_seconds = $1 AND r.due_at = todos.due_at
+				)
+			ORDER BY due_at, id
+			LIMIT $5
+			ON CONFLICT DO NOTHING
+			RETURNING id, todo_id, due_at, created_at`,
+		int64(offset/time.Second),
+		from.Add(offset),
+		now.Add(offset),
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return todo, nil
+	var reminders []model.Reminder
+	for rows.Next() {
+		reminder := model.Reminder{Offset: offset}
+		if err := rows.Scan(&reminder.ID, &reminder.TodoID, &reminder.DueAt, &reminder.CreatedAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return withReminderTodos(ctx, r.store.todos, reminders)
+}
+
+func (r *PostgresReminderRepository) RecordReminder(ctx context.Context, id int64, sentAt time.Time, sendErr string) error {
+	var sent *time.Time
+	if sendErr == "" {
+		sent = &sentAt
+	}
+
+	result, err := r.store.db.ExecContext(ctx,
+		`UPDATE reminders SET sent_at = $2, error = $3 WHERE id = $1`,
+		id,
+		sent,
+		sendErr,
+	)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+var _ LeaseRepository = &PostgresLeaseRepository{}
+
+type PostgresLeaseRepository struct {
+	store *PostgresStore
+}
+
+func (r *PostgresLeaseRepository) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	var got string
+	err := r.store.db.QueryRowContext(ctx,
+		`INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, $4)
+			ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+				WHERE leases.holder = EXCLUDED.holder OR leases.expires_at <= $3
+			RETURNING holder`,
+		name,
+		holder,
+		now,
+		until,
+	).Scan(&got)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *PostgresLeaseRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := r.store.db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	return err
 }
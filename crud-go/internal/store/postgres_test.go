@@ -0,0 +1,36 @@
+package store_test
+
+import (
+	"crud/internal/store"
+	"crud/internal/store/storetest"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresTodoRepository runs against the database in
+// TEST_DATABASE_URL. It is migrated up and its todos, lists, audit log
+// and webhooks are truncated before every test.
+func TestPostgresTodoRepository(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	storetest.RunTodoRepositoryTests(t, func(t *testing.T) store.Store {
+		s := store.NewPostgresStore(&store.Config{Driver: store.DriverPostgres, DatabaseUrl: url})
+		require.NoError(t, s.Open())
+		require.NoError(t, s.Migrator().Up())
+
+		db, err := sql.Open("postgres", url)
+		require.NoError(t, err)
+		defer db.Close()
+
+		_, err = db.Exec(`TRUNCATE todos, lists, todo_audit, webhooks, webhook_deliveries, reminders, leases RESTART IDENTITY`)
+		require.NoError(t, err)
+
+		return s
+	})
+}
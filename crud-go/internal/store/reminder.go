@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"sort"
+)
+
+// withReminderTodos fills in the todos of reminders, dropping those
+// whose todo is gone since it was claimed, and puts them in id order,
+// which RETURNING doesn't keep.
+func withReminderTodos(ctx context.Context, todos TodoRepository, reminders []model.Reminder) ([]model.Reminder, error) {
+	if len(reminders) == 0 {
+		return reminders, nil
+	}
+
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].ID < reminders[j].ID })
+
+	ids := make([]model.ID, len(reminders))
+	for i, reminder := range reminders {
+		ids[i] = reminder.TodoID
+	}
+
+	found, err := todos.GetTodos(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[model.ID]model.Todo, len(found))
+	for _, todo := range found {
+		byID[todo.ID] = todo
+	}
+
+	ret := reminders[:0]
+	for _, reminder := range reminders {
+		todo, ok := byID[reminder.TodoID]
+		if !ok {
+			continue
+		}
+		reminder.Todo = todo
+		ret = append(ret, reminder)
+	}
+
+	return ret, nil
+}
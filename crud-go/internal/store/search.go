@@ -0,0 +1,197 @@
+package store
+
+import (
+	"crud/internal/model"
+	"html"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms bounds the words of a search query that are used.
+const maxSearchTerms = 16
+
+// searchTerms splits a search query into lower case words, dropping
+// everything but letters and digits, so that they are safe to put into
+// a tsquery.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, term := range strings.FieldsFunc(strings.ToLower(query), isNotWordRune) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Search results are ordered by rank, so pages are cut by offset.
+func decodeSearchCursor(cursor string) (int, error) {
+	offset, err := decodeIDCursor(cursor)
+	return int(offset), err
+}
+
+// searchPageOf cuts the extra result fetched past the limit off results
+// and turns it into a cursor for the next page.
+func searchPageOf(filter model.SearchFilter, offset int, results []model.SearchResult) model.SearchPage {
+	if len(results) <= filter.Limit {
+		return model.SearchPage{Results: results}
+	}
+
+	return model.SearchPage{
+		Results:    results[:filter.Limit],
+		NextCursor: encodeIDCursor(int64(offset + filter.Limit)),
+	}
+}
+
+// Words of the title weigh more than those of the description, as in the
+// Postgres search column.
+const (
+	titleWeight       = 1.0
+	descriptionWeight = 0.4
+)
+
+// naiveSearch is the search of the stores without a full-text index. It
+// ranks todos by how many of their words match the terms.
+func naiveSearch(todos []model.Todo, filter model.SearchFilter) (model.SearchPage, error) {
+	offset, err := decodeSearchCursor(filter.Cursor)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+
+	terms := searchTerms(filter.Query)
+	if len(terms) == 0 {
+		return model.SearchPage{Results: []model.SearchResult{}}, nil
+	}
+
+	results := []model.SearchResult{}
+	for _, todo := range todos {
+		rank, ok := naiveRank(todo, terms)
+		if !ok {
+			continue
+		}
+
+		results = append(results, model.SearchResult{
+			Todo:    todo,
+			Rank:    rank,
+			Snippet: naiveSnippet(searchText(todo), terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Todo.ID < results[j].Todo.ID
+	})
+
+	results = results[min(offset, len(results)):]
+	return searchPageOf(filter, offset, results[:min(len(results), filter.Limit+1)]), nil
+}
+
+// naiveRank tells whether every term begins a word of todo and how well
+// todo matches, between 0 and 1.
+func naiveRank(todo model.Todo, terms []string) (float64, bool) {
+	title := strings.FieldsFunc(strings.ToLower(todo.Title), isNotWordRune)
+	description := strings.FieldsFunc(strings.ToLower(todo.Description), isNotWordRune)
+
+	var score float64
+	for _, term := range terms {
+		matches := titleWeight*float64(countPrefixed(title, term)) + descriptionWeight*float64(countPrefixed(description, term))
+		if matches == 0 {
+			return 0, false
+		}
+		score += matches
+	}
+
+	return score / (score + 1), true
+}
+
+func countPrefixed(words []string, prefix string) int {
+	var n int
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// searchText is what snippets are cut from.
+func searchText(todo model.Todo) string {
+	if todo.Description == "" {
+		return todo.Title
+	}
+	return todo.Title + " " + todo.Description
+}
+
+// Snippets are snippetWords words long and start up to snippetLead
+// words before the first match.
+const (
+	snippetWords = 20
+	snippetLead  = 5
+)
+
+// naiveSnippet cuts the words around the first match out of text, like
+// ts_headline does, and marks the matching ones.
+func naiveSnippet(text string, terms []string) string {
+	type span struct{ start, end int }
+
+	var words []span
+	start := -1
+	for i, r := range text {
+		switch {
+		case !isNotWordRune(r) && start < 0:
+			start = i
+		case isNotWordRune(r) && start >= 0:
+			words = append(words, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, span{start, len(text)})
+	}
+
+	matches := func(w span) bool {
+		word := strings.ToLower(text[w.start:w.end])
+		return slices.ContainsFunc(terms, func(term string) bool { return strings.HasPrefix(word, term) })
+	}
+
+	first := slices.IndexFunc(words, matches)
+	if first < 0 {
+		return ""
+	}
+
+	from := max(0, first-snippetLead)
+	to := min(len(words), from+snippetWords)
+
+	// What comes before the first and after the last word goes along
+	// when the snippet reaches that far.
+	var b strings.Builder
+	if from == 0 {
+		b.WriteString(html.EscapeString(text[:words[0].start]))
+	}
+	for i, w := range words[from:to] {
+		if i > 0 {
+			b.WriteString(html.EscapeString(text[words[from+i-1].end:w.start]))
+		}
+
+		word := html.EscapeString(text[w.start:w.end])
+		if matches(w) {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+	}
+	if to == len(words) {
+		b.WriteString(html.EscapeString(text[words[to-1].end:]))
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"buy", "milk", "молоко"}, searchTerms(" Buy, milk & МОЛОКО buy:*"))
+	assert.Empty(t, searchTerms("!? -"))
+}
+
+func TestNaiveSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+
+	testTable := []struct {
+		name     string
+		text     string
+		terms    []string
+		expected string
+	}{
+		{name: "Whole text", text: "Buy milk <now>!", terms: []string{"mi"}, expected: "Buy <mark>milk</mark> &lt;now&gt;!"},
+		{name: "Every match", text: "Milk, milkshake and silk", terms: []string{"milk"}, expected: "<mark>Milk</mark>, <mark>milkshake</mark> and silk"},
+		{name: "Around the first match", text: long + "milk " + long, terms: []string{"milk"}, expected: strings.Repeat("word ", 5) + "<mark>milk</mark>" + strings.Repeat(" word", 14)},
+		{name: "No match", text: "Buy bread", terms: []string{"milk"}, expected: ""},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, naiveSnippet(tt.text, tt.terms))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"database/sql"
+	"fmt"
+)
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// extraColumns scans the columns after those of a row scanner into
+// extra.
+type extraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func withExtraColumns(row rowScanner, extra ...interface{}) rowScanner {
+	return extraColumns{row: row, extra: extra}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+// querier is a *sql.DB or *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and
+// rolled back otherwise.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var _ Tx = &sqlTx{}
+
+// sqlTx is the Tx of the SQL stores. Postgres and SQLite share the
+// savepoint syntax.
+type sqlTx struct {
+	tx         *sql.Tx
+	todos      TodoRepository
+	lists      ListRepository
+	audit      AuditRepository
+	webhooks   WebhookRepository
+	savepoints int
+
+	// appended collects the audit entries to publish after the commit
+	// in stores that can't have the database do it.
+	appended *[]model.AuditEntry
+}
+
+func (t *sqlTx) Todos() TodoRepository {
+	return t.todos
+}
+
+func (t *sqlTx) Lists() ListRepository {
+	return t.lists
+}
+
+func (t *sqlTx) Audit() AuditRepository {
+	return t.audit
+}
+
+func (t *sqlTx) Webhooks() WebhookRepository {
+	return t.webhooks
+}
+
+func (t *sqlTx) Savepoint(ctx context.Context, fn func() error) error {
+	t.savepoints++
+	name := fmt.Sprintf("sp_%d", t.savepoints)
+
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	var appended int
+	if t.appended != nil {
+		appended = len(*t.appended)
+	}
+
+	if err := fn(); err != nil {
+		if t.appended != nil {
+			*t.appended = (*t.appended)[:appended]
+		}
+
+		if _, rollbackErr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return fmt.Errorf("%w (rolling back to savepoint: %w)", err, rollbackErr)
+		}
+		return err
+	}
+
+	_, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
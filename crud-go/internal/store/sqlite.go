@@ -0,0 +1,1653 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+)
+
+var _ Store = &SqliteStore{}
+
+// SqliteStore keeps todos in a single SQLite file, for deployments
+// without a Postgres server. Config.DatabaseUrl is the database path.
+type SqliteStore struct {
+	config *Config
+	db     *sql.DB
+
+	todos     TodoRepository
+	lists     ListRepository
+	audit     AuditRepository
+	webhooks  WebhookRepository
+	reminders ReminderRepository
+	leases    LeaseRepository
+	changes   *changeHub
+}
+
+func NewSqliteStore(config *Config) Store {
+	store := &SqliteStore{
+		config:  config,
+		changes: newChangeHub(),
+	}
+
+	store.todos = newSqliteTodoRepository(store)
+	store.lists = &SqliteListRepository{store: store}
+	store.audit = &SqliteAuditRepository{store: store}
+	store.webhooks = &SqliteWebhookRepository{store: store}
+	store.reminders = &SqliteReminderRepository{store: store}
+	store.leases = &SqliteLeaseRepository{store: store}
+
+	return store
+}
+
+func (s *SqliteStore) Todos() TodoRepository {
+	return s.todos
+}
+
+func (s *SqliteStore) Lists() ListRepository {
+	return s.lists
+}
+
+func (s *SqliteStore) Audit() AuditRepository {
+	return s.audit
+}
+
+func (s *SqliteStore) Webhooks() WebhookRepository {
+	return s.webhooks
+}
+
+func (s *SqliteStore) Reminders() ReminderRepository {
+	return s.reminders
+}
+
+func (s *SqliteStore) Leases() LeaseRepository {
+	return s.leases
+}
+
+func (s *SqliteStore) Changes() ChangeFeed {
+	return s.changes
+}
+
+func (s *SqliteStore) Migrator() Migrator {
+	// A single connection already serializes migrations.
+	return newSqlMigrator(s.db, "sqlite", "")
+}
+
+// InTx publishes the audit entries of the transaction once it commits.
+// Only this process writes to the file, so the changes need to go no
+// further.
+func (s *SqliteStore) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	var appended []model.AuditEntry
+
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&sqlTx{
+			tx:       tx,
+			todos:    &SqliteTodoRepository{store: s, tx: tx},
+			lists:    &SqliteListRepository{store: s, tx: tx},
+			audit:    &SqliteAuditRepository{store: s, tx: tx, appended: &appended},
+			webhooks: &SqliteWebhookRepository{store: s, tx: tx},
+			appended: &appended,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.changes.publish(appended...)
+
+	return nil
+}
+
+func (s *SqliteStore) Open() error {
+	db, err := sql.Open("sqlite", s.config.DatabaseUrl)
+	if err != nil {
+		return err
+	}
+
+	// SQLite allows a single writer, so one connection avoids "database
+	// is locked" errors. It also keeps ":memory:" databases alive.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+
+	return nil
+}
+
+func (s *SqliteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SqliteStore) Close() error {
+	s.changes.close()
+	return s.db.Close()
+}
+
+var _ TodoRepository = &SqliteTodoRepository{}
+
+type SqliteTodoRepository struct {
+	store *SqliteStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func newSqliteTodoRepository(store *SqliteStore) TodoRepository {
+	return &SqliteTodoRepository{
+		store: store,
+	}
+}
+
+func (r *SqliteTodoRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// inTx runs fn in a transaction of its own, or in the one of the
+// repository, whose owner then decides what happens on failure.
+func (r *SqliteTodoRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return inTx(ctx, r.store.db, fn)
+}
+
+// sqliteTimeLayout matches the created_at column default. It is fixed
+// width, so stored times compare correctly as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000Z"
+
+func sqliteTimeValue(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// sqliteTime scans a timestamp column into a time.Time.
+type sqliteTime struct {
+	t *time.Time
+}
+
+func (s sqliteTime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*s.t = v.UTC()
+		return nil
+	case string:
+		t, err := time.Parse(sqliteTimeLayout, v)
+		if err != nil {
+			return err
+		}
+		*s.t = t
+		return nil
+	case []byte:
+		return s.Scan(string(v))
+	default:
+		return fmt.Errorf("unsupported time value %T", src)
+	}
+}
+
+// sqliteNullTime scans a nullable timestamp column.
+type sqliteNullTime struct {
+	t **time.Time
+}
+
+func (s sqliteNullTime) Scan(src interface{}) error {
+	if src == nil {
+		*s.t = nil
+		return nil
+	}
+
+	var t time.Time
+	if err := (sqliteTime{&t}).Scan(src); err != nil {
+		return err
+	}
+	*s.t = &t
+
+	return nil
+}
+
+func sqliteNullTimeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTimeValue(*t)
+}
+
+// sqliteTags scans the JSON array the tags column holds.
+type sqliteTags struct {
+	tags *[]string
+}
+
+func (s sqliteTags) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), s.tags)
+	case []byte:
+		return json.Unmarshal(v, s.tags)
+	default:
+		return fmt.Errorf("unsupported tags value %T", src)
+	}
+}
+
+func sqliteTagsValue(tags []string) (string, error) {
+	b, err := json.Marshal(nonNilTags(tags))
+	return string(b), err
+}
+
+// sqliteNow is the current time in sqliteTimeLayout.
+const sqliteNow = `strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`
+
+const sqliteTodoColumns = `id, user_id, list_id, parent_id, title, description, complete, priority, due_at, due_timezone, recurrence, series_id, tags, created_at, updated_at, completed_at, deleted_at, version`
+
+// sqliteSortKeys are the ORDER BY expressions of the sort fields. Todos
+// without a due date sort after every representable due date.
+var sqliteSortKeys = map[model.TodoSortField]string{
+	model.SortByCreatedAt: "created_at",
+	model.SortByUpdatedAt: "updated_at",
+	model.SortByDueAt:     "COALESCE(due_at, '9999-12-31T23:59:59.999Z')",
+	model.SortByPriority:  "priority",
+}
+
+func sqliteSortValue(field model.TodoSortField, c *cursor) interface{} {
+	switch field {
+	case model.SortByCreatedAt:
+		return sqliteTimeValue(c.CreatedAt)
+	case model.SortByUpdatedAt:
+		return sqliteTimeValue(c.UpdatedAt)
+	case model.SortByDueAt:
+		if c.DueAt == nil {
+			return "9999-12-31T23:59:59.999Z"
+		}
+		return sqliteTimeValue(*c.DueAt)
+	case model.SortByPriority:
+		return c.Priority
+	default:
+		return c.ID
+	}
+}
+
+func scanSqliteTodo(row rowScanner) (model.Todo, error) {
+	var todo model.Todo
+
+	if err := row.Scan(
+		&todo.ID,
+		&todo.UserID,
+		&todo.ListID,
+		&todo.ParentID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Complete,
+		&todo.Priority,
+		sqliteNullTime{&todo.DueAt},
+		&todo.DueTimezone,
+		&todo.Recurrence,
+		&todo.SeriesID,
+		sqliteTags{&todo.Tags},
+		sqliteTime{&todo.CreatedAt},
+		sqliteTime{&todo.UpdatedAt},
+		sqliteNullTime{&todo.CompletedAt},
+		sqliteNullTime{&todo.DeletedAt},
+		&todo.Version,
+	); err != nil {
+		return model.Todo{}, storeError(err)
+	}
+
+	localizeDue(&todo)
+
+	return todo, nil
+}
+
+func (r *SqliteTodoRepository) GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error) {
+	ret := make([]model.Todo, 0, len(ids))
+
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	params := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		params[i] = "?"
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM todos WHERE id IN (%s) AND deleted_at IS NULL AND %s`, sqliteTodoColumns, strings.Join(params, ", "), userScope(ctx))
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		todo, err := scanSqliteTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, todo)
+	}
+
+	return ret, rows.Err()
+}
+
+// LockTodo needs no lock: the single connection already keeps other
+// transactions out.
+func (r *SqliteTodoRepository) LockTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	return scanSqliteTodo(r.q().QueryRowContext(ctx,
+		`SELECT `+sqliteTodoColumns+` FROM todos WHERE id = ? AND `+userScope(ctx),
+		id,
+	))
+}
+
+// sqliteDescendants selects the ids of all descendants of ?1 as
+// descendants, including the ones in the trash.
+const sqliteDescendants = `WITH RECURSIVE descendants AS (
+		SELECT id FROM todos WHERE parent_id = ?1
+		UNION ALL
+		SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id
+	)`
+
+func (r *SqliteTodoRepository) GetSubtree(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	rows, err := r.q().QueryContext(ctx,
+		sqliteDescendants+`
+		SELECT `+sqliteTodoColumns+` FROM todos
+			WHERE (id = ?1 OR id IN (SELECT id FROM descendants)) AND deleted_at IS NULL AND `+userScope(ctx)+`
+			ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []model.Todo
+	for rows.Next() {
+		todo, err := scanSqliteTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, todo)
+	}
+
+	return ret, rows.Err()
+}
+
+// LockDescendants needs no lock either.
+func (r *SqliteTodoRepository) LockDescendants(ctx context.Context, id model.ID) ([]model.Todo, error) {
+	rows, err := r.q().QueryContext(ctx,
+		sqliteDescendants+`
+		SELECT `+sqliteTodoColumns+` FROM todos
+			WHERE id IN (SELECT id FROM descendants) AND `+userScope(ctx)+`
+			ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []model.Todo
+	for rows.Next() {
+		todo, err := scanSqliteTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, todo)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *SqliteTodoRepository) ListTodos(ctx context.Context, filter model.TodoFilter) (model.TodoPage, error) {
+	after, err := decodeCursor(filter)
+	if err != nil {
+		return model.TodoPage{}, err
+	}
+
+	var (
+		where = []string{"deleted_at IS NULL", userScope(ctx)}
+		args  []interface{}
+	)
+
+	if filter.Trashed {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+	if filter.ListID != nil {
+		where = append(where, "list_id = ?")
+		args = append(args, *filter.ListID)
+	}
+	if filter.SeriesID != nil {
+		where = append(where, "(id = ? OR series_id = ?)")
+		args = append(args, *filter.SeriesID, *filter.SeriesID)
+	}
+	if filter.Complete != nil {
+		where = append(where, "complete = ?")
+		args = append(args, *filter.Complete)
+	}
+	if filter.TitleContains != "" {
+		// LIKE is case insensitive for ASCII in SQLite, like ILIKE.
+		where = append(where, `title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.TitleContains)+"%")
+	}
+	if filter.CreatedAfter != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, sqliteTimeValue(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, sqliteTimeValue(*filter.CreatedBefore))
+	}
+	if filter.DueAfter != nil {
+		where = append(where, "due_at >= ?")
+		args = append(args, sqliteTimeValue(*filter.DueAfter))
+	}
+	if filter.DueBefore != nil {
+		where = append(where, "due_at < ?")
+		args = append(args, sqliteTimeValue(*filter.DueBefore))
+	}
+	if filter.MinPriority != nil {
+		where = append(where, "priority >= ?")
+		args = append(args, *filter.MinPriority)
+	}
+	for _, tag := range filter.Tags {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ?)")
+		args = append(args, tag)
+	}
+
+	direction, compare := "ASC", ">"
+	if filter.Order == model.SortDesc {
+		direction, compare = "DESC", "<"
+	}
+
+	var orderBy string
+	if key, ok := sqliteSortKeys[filter.SortBy]; ok {
+		orderBy = fmt.Sprintf("%s %s, id %s", key, direction, direction)
+		if after != nil {
+			where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", key, compare))
+			args = append(args, sqliteSortValue(filter.SortBy, after), after.ID)
+		}
+	} else {
+		orderBy = "id " + direction
+		if after != nil {
+			where = append(where, fmt.Sprintf("id %s ?", compare))
+			args = append(args, after.ID)
+		}
+	}
+
+	query := `SELECT ` + sqliteTodoColumns + ` FROM todos WHERE ` + strings.Join(where, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s LIMIT ?", orderBy)
+	args = append(args, filter.Limit+1)
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return model.TodoPage{}, err
+	}
+	defer rows.Close()
+
+	todos := make([]model.Todo, 0, filter.Limit+1)
+	for rows.Next() {
+		todo, err := scanSqliteTodo(rows)
+		if err != nil {
+			return model.TodoPage{}, err
+		}
+
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return model.TodoPage{}, err
+	}
+
+	return pageOf(filter, todos), nil
+}
+
+// SearchTodos narrows the todos down with LIKE and ranks them in Go.
+// LIKE only ignores the case of ASCII letters, so other terms are left
+// to the ranking.
+func (r *SqliteTodoRepository) SearchTodos(ctx context.Context, filter model.SearchFilter) (model.SearchPage, error) {
+	where := []string{"deleted_at IS NULL", userScope(ctx)}
+	var args []interface{}
+
+	for _, term := range searchTerms(filter.Query) {
+		if !isASCII(term) {
+			continue
+		}
+		// Terms are made of letters and digits only, nothing to escape.
+		where = append(where, "(title || ' ' || description) LIKE ?")
+		args = append(args, "%"+term+"%")
+	}
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+sqliteTodoColumns+` FROM todos WHERE `+strings.Join(where, " AND "),
+		args...,
+	)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+	defer rows.Close()
+
+	var todos []model.Todo
+	for rows.Next() {
+		todo, err := scanSqliteTodo(rows)
+		if err != nil {
+			return model.SearchPage{}, err
+		}
+
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return model.SearchPage{}, err
+	}
+
+	return naiveSearch(todos, filter)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *SqliteTodoRepository) CreateTodo(ctx context.Context, todo model.Todo) (model.Todo, error) {
+	tags, err := sqliteTagsValue(todo.Tags)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	var created model.Todo
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		owner := ownerOf(ctx, todo.UserID)
+		if todo.ParentID != nil {
+			var (
+				list model.ID
+				err  error
+			)
+			if owner, list, err = sqliteParent(ctx, tx, *todo.ParentID); err != nil {
+				return err
+			}
+			if list != todo.ListID {
+				return ErrParentInOtherList
+			}
+		}
+
+		var err error
+		created, err = scanSqliteTodo(tx.QueryRowContext(ctx,
+			`INSERT INTO todos (user_id, list_id, parent_id, title, description, complete, priority, due_at, due_timezone, recurrence, series_id, tags, updated_at, completed_at)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, `+sqliteNow+`, CASE WHEN ?6 THEN `+sqliteNow+` END)
+				RETURNING `+sqliteTodoColumns,
+			owner,
+			todo.ListID,
+			todo.ParentID,
+			todo.Title,
+			todo.Description,
+			todo.Complete,
+			todo.Priority,
+			sqliteNullTimeValue(todo.DueAt),
+			todo.DueTimezone,
+			todo.Recurrence,
+			todo.SeriesID,
+			tags,
+		))
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	return created, nil
+}
+
+// sqliteParent checks parent_id by hand, the column was added without
+// a foreign key, which SQLite couldn't drop again, and returns the owner
+// and the list of the parent.
+func sqliteParent(ctx context.Context, tx *sql.Tx, parent model.ID) (model.UserID, model.ID, error) {
+	var (
+		owner model.UserID
+		list  model.ID
+	)
+	err := tx.QueryRowContext(ctx,
+		`SELECT user_id, list_id FROM todos WHERE id = ? AND deleted_at IS NULL AND `+userScope(ctx),
+		parent,
+	).Scan(&owner, &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrParentNotFound
+	}
+
+	return owner, list, err
+}
+
+func (r *SqliteTodoRepository) ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (model.Todo, error) {
+	query := `UPDATE todos
+		SET complete = NOT complete,
+			completed_at = CASE WHEN complete THEN NULL ELSE ` + sqliteNow + ` END,
+			updated_at = ` + sqliteNow + `,
+			version = version + 1
+		WHERE id=?1 AND deleted_at IS NULL AND ` + userScope(ctx)
+	args := []interface{}{id}
+	if opts.IfVersion != nil {
+		query += ` AND version = ?2`
+		args = append(args, *opts.IfVersion)
+	}
+
+	var toggled model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		toggled, err = scanSqliteTodo(tx.QueryRowContext(ctx, query+` RETURNING `+sqliteTodoColumns, args...))
+		if err != nil || !opts.Cascade {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			sqliteDescendants+`
+			UPDATE todos
+				SET complete = ?2,
+					completed_at = CASE WHEN ?2 THEN `+sqliteNow+` END,
+					updated_at = `+sqliteNow+`,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants) AND deleted_at IS NULL AND complete <> ?2`,
+			id,
+			toggled.Complete,
+		)
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, opts.IfVersion, err)
+	}
+
+	return toggled, nil
+}
+
+// checkParent makes sure parent exists, is in list, or the list of id
+// if nil, and isn't id or one of its descendants.
+func (r *SqliteTodoRepository) checkParent(ctx context.Context, tx *sql.Tx, id, parent model.ID, list *model.ID) error {
+	_, parentList, err := sqliteParent(ctx, tx, parent)
+	if err != nil {
+		return err
+	}
+
+	var cycle bool
+	if err := tx.QueryRowContext(ctx,
+		`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM todos WHERE id = ?1
+			UNION ALL
+			SELECT t.id, t.parent_id FROM todos t JOIN ancestors a ON t.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?2)`,
+		parent,
+		id,
+	).Scan(&cycle); err != nil {
+		return err
+	}
+
+	if cycle {
+		return ErrCycle
+	}
+
+	// A todo that isn't there is left to the update to report.
+	if list == nil {
+		var current model.ID
+		err := tx.QueryRowContext(ctx, `SELECT list_id FROM todos WHERE id = ? AND `+userScope(ctx), id).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		list = &current
+	}
+	if parentList != *list {
+		return ErrParentInOtherList
+	}
+
+	return nil
+}
+
+func (r *SqliteTodoRepository) UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (model.Todo, error) {
+	var args []interface{}
+
+	sets := []string{"updated_at = " + sqliteNow, "version = version + 1"}
+
+	if patch.ListID != nil {
+		sets = append(sets, "list_id = ?")
+		args = append(args, *patch.ListID)
+		if !patch.ParentID.Set {
+			sets = append(sets, "parent_id = CASE WHEN list_id = ? THEN parent_id END")
+			args = append(args, *patch.ListID)
+		}
+	}
+	if patch.ParentID.Set {
+		sets = append(sets, "parent_id = ?")
+		args = append(args, patch.ParentID.Value)
+	}
+	if patch.Title != nil {
+		sets = append(sets, "title = ?")
+		args = append(args, *patch.Title)
+	}
+	if patch.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *patch.Description)
+	}
+	if patch.Complete != nil {
+		sets = append(sets,
+			"complete = ?",
+			"completed_at = CASE WHEN ? THEN COALESCE(completed_at, "+sqliteNow+") END",
+		)
+		args = append(args, *patch.Complete, *patch.Complete)
+	}
+	if patch.Priority != nil {
+		sets = append(sets, "priority = ?")
+		args = append(args, *patch.Priority)
+	}
+	if patch.DueAt.Set {
+		sets = append(sets, "due_at = ?")
+		args = append(args, sqliteNullTimeValue(patch.DueAt.Value))
+	}
+	if patch.DueTimezone != nil {
+		sets = append(sets, "due_timezone = ?")
+		args = append(args, *patch.DueTimezone)
+	}
+	if patch.Recurrence != nil {
+		sets = append(sets, "recurrence = ?")
+		args = append(args, *patch.Recurrence)
+	}
+	if patch.Tags != nil {
+		tags, err := sqliteTagsValue(*patch.Tags)
+		if err != nil {
+			return model.Todo{}, err
+		}
+
+		sets = append(sets, "tags = ?")
+		args = append(args, tags)
+	}
+
+	args = append(args, id)
+	where := "id = ? AND deleted_at IS NULL AND " + userScope(ctx)
+	if patch.IfVersion != nil {
+		where += " AND version = ?"
+		args = append(args, *patch.IfVersion)
+	}
+
+	var updated model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if patch.ParentID.Set && patch.ParentID.Value != nil {
+			if err := r.checkParent(ctx, tx, id, *patch.ParentID.Value, patch.ListID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		updated, err = scanSqliteTodo(tx.QueryRowContext(ctx,
+			`UPDATE todos SET `+strings.Join(sets, ", ")+` WHERE `+where+` RETURNING `+sqliteTodoColumns,
+			args...,
+		))
+		if err != nil || patch.ListID == nil {
+			return err
+		}
+
+		// The subtree, trash included, goes along.
+		_, err = tx.ExecContext(ctx,
+			sqliteDescendants+`
+			UPDATE todos
+				SET list_id = ?2,
+					updated_at = `+sqliteNow+`,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants) AND list_id <> ?2`,
+			id,
+			*patch.ListID,
+		)
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, patch.IfVersion, err)
+	}
+
+	return updated, nil
+}
+
+// sqliteDeletedAt is the deleted_at of a new deletion. The column only
+// has millisecond precision, so it is kept strictly increasing: a
+// deletion in the same millisecond as an earlier one would otherwise be
+// restored together with it.
+const sqliteDeletedAt = `max(` + sqliteNow + `, coalesce((SELECT strftime('%Y-%m-%dT%H:%M:%fZ', max(deleted_at), '+0.001 seconds') FROM todos), ''))`
+
+func (r *SqliteTodoRepository) DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (model.Todo, error) {
+	query := `UPDATE todos
+		SET deleted_at = ` + sqliteDeletedAt + `,
+			updated_at = ` + sqliteNow + `,
+			version = version + 1
+		WHERE id=?1 AND deleted_at IS NULL AND ` + userScope(ctx)
+	args := []interface{}{id}
+	if opts.IfVersion != nil {
+		query += ` AND version = ?2`
+		args = append(args, *opts.IfVersion)
+	}
+
+	var deleted model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		deleted, err = scanSqliteTodo(tx.QueryRowContext(ctx, query+` RETURNING `+sqliteTodoColumns, args...))
+		if err != nil {
+			return err
+		}
+
+		// The subtree takes over the deleted_at of the todo, which
+		// RestoreTodo looks for.
+		if opts.Cascade {
+			_, err = tx.ExecContext(ctx,
+				sqliteDescendants+`
+				UPDATE todos
+					SET deleted_at = ?2,
+						updated_at = ?2,
+						version = version + 1
+					WHERE id IN (SELECT id FROM descendants) AND deleted_at IS NULL`,
+				id,
+				sqliteTimeValue(*deleted.DeletedAt),
+			)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE todos
+					SET parent_id = ?2,
+						updated_at = `+sqliteNow+`,
+						version = version + 1
+					WHERE parent_id = ?1 AND deleted_at IS NULL`,
+				id,
+				deleted.ParentID,
+			)
+		}
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, checkVersion(ctx, r, id, opts.IfVersion, err)
+	}
+
+	return deleted, nil
+}
+
+func (r *SqliteTodoRepository) RestoreTodo(ctx context.Context, id model.ID) (model.Todo, error) {
+	var restored model.Todo
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			sqliteDescendants+`
+			UPDATE todos
+				SET deleted_at = NULL,
+					updated_at = `+sqliteNow+`,
+					version = version + 1
+				WHERE id IN (SELECT id FROM descendants)
+					AND deleted_at = (SELECT deleted_at FROM todos WHERE id = ?1)`,
+			id,
+		); err != nil {
+			return err
+		}
+
+		var err error
+		restored, err = scanSqliteTodo(tx.QueryRowContext(ctx,
+			`UPDATE todos
+				SET deleted_at = NULL,
+					updated_at = `+sqliteNow+`,
+					version = version + 1,
+					parent_id = CASE
+						WHEN EXISTS (SELECT 1 FROM todos p WHERE p.id = todos.parent_id AND p.deleted_at IS NULL) THEN parent_id
+					END
+				WHERE id=?1 AND deleted_at IS NOT NULL AND `+userScope(ctx)+`
+				RETURNING `+sqliteTodoColumns,
+			id,
+		))
+		return err
+	})
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	return restored, nil
+}
+
+func (r *SqliteTodoRepository) PurgeTodos(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// Whatever still points at a purged todo loses its parent, as
+		// in the Postgres store.
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE todos
+				SET parent_id = NULL,
+					version = version + 1
+				WHERE parent_id IN (SELECT id FROM todos WHERE deleted_at < ?1 AND `+userScope(ctx)+`)
+					AND (deleted_at IS NULL OR deleted_at >= ?1)`,
+			sqliteTimeValue(deletedBefore),
+		); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE deleted_at < ?1 AND `+userScope(ctx), sqliteTimeValue(deletedBefore))
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+var _ ListRepository = &SqliteListRepository{}
+
+type SqliteListRepository struct {
+	store *SqliteStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func (r *SqliteListRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// inTx runs fn in a transaction of its own, or in the one of the
+// repository.
+func (r *SqliteListRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return inTx(ctx, r.store.db, fn)
+}
+
+// sqliteListColumns are the columns of a list followed by the counts of
+// its live todos.
+const sqliteListColumns = `id, user_id, name, archived_at, created_at, updated_at,
+	(SELECT COUNT(*) FROM todos WHERE list_id = lists.id AND deleted_at IS NULL),
+	(SELECT COUNT(*) FROM todos WHERE list_id = lists.id AND deleted_at IS NULL AND complete)`
+
+func scanSqliteList(row rowScanner) (model.List, error) {
+	var list model.List
+
+	if err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		sqliteNullTime{&list.ArchivedAt},
+		sqliteTime{&list.CreatedAt},
+		sqliteTime{&list.UpdatedAt},
+		&list.Counts.Total,
+		&list.Counts.Completed,
+	); err != nil {
+		return model.List{}, storeError(err)
+	}
+
+	return list, nil
+}
+
+func (r *SqliteListRepository) GetList(ctx context.Context, id model.ID) (model.List, error) {
+	return scanSqliteList(r.q().QueryRowContext(ctx,
+		`SELECT `+sqliteListColumns+` FROM lists WHERE id = ? AND `+userScope(ctx), id))
+}
+
+func (r *SqliteListRepository) ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error) {
+	archived := "archived_at IS NULL"
+	if filter.Archived {
+		archived = "archived_at IS NOT NULL"
+	}
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+sqliteListColumns+` FROM lists WHERE `+archived+` AND `+userScope(ctx)+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []model.List{}
+	for rows.Next() {
+		list, err := scanSqliteList(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (r *SqliteListRepository) CreateList(ctx context.Context, list model.List) (model.List, error) {
+	return scanSqliteList(r.q().QueryRowContext(ctx,
+		`INSERT INTO lists (user_id, name, updated_at)
+			VALUES (?, ?, `+sqliteNow+`)
+			RETURNING `+sqliteListColumns,
+		ownerOf(ctx, list.UserID),
+		list.Name,
+	))
+}
+
+func (r *SqliteListRepository) UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (model.List, error) {
+	sets := []string{"updated_at = " + sqliteNow}
+	var args []interface{}
+
+	if patch.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *patch.Name)
+	}
+	if patch.Archived != nil {
+		sets = append(sets, "archived_at = CASE WHEN ? THEN COALESCE(archived_at, "+sqliteNow+") END")
+		args = append(args, *patch.Archived)
+	}
+	args = append(args, id)
+
+	return scanSqliteList(r.q().QueryRowContext(ctx,
+		`UPDATE lists SET `+strings.Join(sets, ", ")+` WHERE id = ? AND `+userScope(ctx)+` RETURNING `+sqliteListColumns,
+		args...,
+	))
+}
+
+func (r *SqliteListRepository) DeleteList(ctx context.Context, id model.ID) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var live bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM todos WHERE list_id = lists.id AND deleted_at IS NULL)
+				FROM lists WHERE id = ? AND `+userScope(ctx),
+			id,
+		).Scan(&live); err != nil {
+			return storeError(err)
+		}
+		if live {
+			return ErrListNotEmpty
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE list_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ?`, id)
+		return err
+	})
+}
+
+var _ AuditRepository = &SqliteAuditRepository{}
+
+type SqliteAuditRepository struct {
+	store *SqliteStore
+	// tx is set for the repository of a transaction, along with where
+	// its entries are kept until the commit.
+	tx       *sql.Tx
+	appended *[]model.AuditEntry
+}
+
+func (r *SqliteAuditRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+const sqliteAuditColumns = `id, todo_id, action, before, after, actor, request_id, created_at`
+
+func scanSqliteAudit(row rowScanner) (model.AuditEntry, error) {
+	var entry model.AuditEntry
+
+	if err := row.Scan(
+		&entry.ID,
+		&entry.TodoID,
+		&entry.Action,
+		auditTodo{&entry.Before},
+		auditTodo{&entry.After},
+		&entry.Actor,
+		&entry.RequestID,
+		sqliteTime{&entry.CreatedAt},
+	); err != nil {
+		return model.AuditEntry{}, storeError(err)
+	}
+
+	return entry, nil
+}
+
+func (r *SqliteAuditRepository) AppendAudit(ctx context.Context, entry model.AuditEntry) (model.AuditEntry, error) {
+	before, err := auditTodoValue(entry.Before)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+	after, err := auditTodoValue(entry.After)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	entry, err = scanSqliteAudit(r.q().QueryRowContext(ctx,
+		`INSERT INTO todo_audit (todo_id, user_id, action, before, after, actor, request_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING `+sqliteAuditColumns,
+		entry.TodoID,
+		entry.UserID(),
+		entry.Action,
+		before,
+		after,
+		entry.Actor,
+		entry.RequestID,
+	))
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	if r.tx != nil {
+		*r.appended = append(*r.appended, entry)
+	} else {
+		r.store.changes.publish(entry)
+	}
+
+	return entry, nil
+}
+
+func (r *SqliteAuditRepository) ListAudit(ctx context.Context, filter model.AuditFilter) (model.AuditPage, error) {
+	after, err := decodeAuditCursor(filter)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+
+	var (
+		where = []string{userScope(ctx)}
+		args  []interface{}
+	)
+
+	if filter.TodoID != nil {
+		where = append(where, "todo_id = ?")
+		args = append(args, *filter.TodoID)
+	}
+	if filter.CreatedAfter != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, sqliteTimeValue(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, sqliteTimeValue(*filter.CreatedBefore))
+	}
+	if after > 0 {
+		where = append(where, "id > ?")
+		args = append(args, after)
+	}
+
+	query := `SELECT ` + sqliteAuditColumns + ` FROM todo_audit WHERE ` + strings.Join(where, " AND ")
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit+1)
+
+	rows, err := r.q().QueryContext(ctx, query, args...)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditEntry, 0, filter.Limit+1)
+	for rows.Next() {
+		entry, err := scanSqliteAudit(rows)
+		if err != nil {
+			return model.AuditPage{}, err
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return model.AuditPage{}, err
+	}
+
+	return auditPageOf(filter, entries), nil
+}
+
+var _ WebhookRepository = &SqliteWebhookRepository{}
+
+type SqliteWebhookRepository struct {
+	store *SqliteStore
+	// tx is set for the repository of a transaction.
+	tx *sql.Tx
+}
+
+func (r *SqliteWebhookRepository) q() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.store.db
+}
+
+// sqliteEvents scans the JSON array the events column holds.
+type sqliteEvents struct {
+	events *[]model.ChangeType
+}
+
+func (s sqliteEvents) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), s.events)
+	case []byte:
+		return json.Unmarshal(v, s.events)
+	default:
+		return fmt.Errorf("unsupported events value %T", src)
+	}
+}
+
+func sqliteEventsValue(events []model.ChangeType) (string, error) {
+	b, err := json.Marshal(nonNilEvents(events))
+	return string(b), err
+}
+
+const sqliteWebhookColumns = `id, user_id, url, events, secret, active, created_at, updated_at`
+
+func scanSqliteWebhook(row rowScanner) (model.Webhook, error) {
+	var webhook model.Webhook
+
+	if err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		sqliteEvents{&webhook.Events},
+		&webhook.Secret,
+		&webhook.Active,
+		sqliteTime{&webhook.CreatedAt},
+		sqliteTime{&webhook.UpdatedAt},
+	); err != nil {
+		return model.Webhook{}, storeError(err)
+	}
+
+	return webhook, nil
+}
+
+const sqliteDeliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, redelivery_of, created_at`
+
+func scanSqliteDelivery(row rowScanner) (model.WebhookDelivery, error) {
+	var (
+		delivery       model.WebhookDelivery
+		responseStatus sql.NullInt64
+		redeliveryOf   sql.NullInt64
+	)
+
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		sqliteNullTime{&delivery.NextAttemptAt},
+		sqliteNullTime{&delivery.LastAttemptAt},
+		&responseStatus,
+		&delivery.LastError,
+		&redeliveryOf,
+		sqliteTime{&delivery.CreatedAt},
+	); err != nil {
+		return model.WebhookDelivery{}, storeError(err)
+	}
+
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = &redeliveryOf.Int64
+	}
+
+	return delivery, nil
+}
+
+func scanSqliteDeliveries(rows *sql.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanSqliteDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *SqliteWebhookRepository) GetWebhook(ctx context.Context, id model.ID) (model.Webhook, error) {
+	return scanSqliteWebhook(r.q().QueryRowContext(ctx,
+		`SELECT `+sqliteWebhookColumns+` FROM webhooks WHERE id = ? AND `+userScope(ctx), id))
+}
+
+func (r *SqliteWebhookRepository) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	rows, err := r.q().QueryContext(ctx, `SELECT `+sqliteWebhookColumns+` FROM webhooks WHERE `+userScope(ctx)+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		webhook, err := scanSqliteWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *SqliteWebhookRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	events, err := sqliteEventsValue(webhook.Events)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	return scanSqliteWebhook(r.q().QueryRowContext(ctx,
+		`INSERT INTO webhooks (user_id, url, events, secret, active)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING `+sqliteWebhookColumns,
+		ownerOf(ctx, webhook.UserID),
+		webhook.URL,
+		events,
+		webhook.Secret,
+		webhook.Active,
+	))
+}
+
+func (r *SqliteWebhookRepository) UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (model.Webhook, error) {
+	sets := []string{"updated_at = " + sqliteNow}
+	var args []interface{}
+
+	if patch.URL != nil {
+		sets = append(sets, "url = ?")
+		args = append(args, *patch.URL)
+	}
+	if patch.Events != nil {
+		events, err := sqliteEventsValue(*patch.Events)
+		if err != nil {
+			return model.Webhook{}, err
+		}
+		sets = append(sets, "events = ?")
+		args = append(args, events)
+	}
+	if patch.Secret != nil {
+		sets = append(sets, "secret = ?")
+		args = append(args, *patch.Secret)
+	}
+	if patch.Active != nil {
+		sets = append(sets, "active = ?")
+		args = append(args, *patch.Active)
+	}
+	args = append(args, id)
+
+	return scanSqliteWebhook(r.q().QueryRowContext(ctx,
+		`UPDATE webhooks SET `+strings.Join(sets, ", ")+` WHERE id = ? AND `+userScope(ctx)+` RETURNING `+sqliteWebhookColumns,
+		args...,
+	))
+}
+
+func (r *SqliteWebhookRepository) DeleteWebhook(ctx context.Context, id model.ID) error {
+	result, err := r.q().ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND `+userScope(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SqliteWebhookRepository) EnqueueDeliveries(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error) {
+	result, err := r.q().ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at)
+			SELECT id, ?1, ?2, ?3, ?4 FROM webhooks
+			WHERE user_id = ?5 AND active AND (events = '[]' OR EXISTS (SELECT 1 FROM json_each(events) WHERE value = ?2))
+			ORDER BY id`,
+		delivery.EventID,
+		delivery.Event,
+		delivery.Payload,
+		sqliteNullTimeValue(delivery.NextAttemptAt),
+		owner,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SqliteWebhookRepository) Redeliver(ctx context.Context, webhookID model.ID, id int64, due time.Time) (model.WebhookDelivery, error) {
+	return scanSqliteDelivery(r.q().QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at, redelivery_of)
+			SELECT webhook_id, event_id, event, payload, ?, id FROM webhook_deliveries
+			WHERE id = ? AND webhook_id = ?
+				AND webhook_id IN (SELECT id FROM webhooks WHERE `+userScope(ctx)+`)
+			RETURNING `+sqliteDeliveryColumns,
+		sqliteTimeValue(due),
+		id,
+		webhookID,
+	))
+}
+
+func (r *SqliteWebhookRepository) ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (model.DeliveryPage, error) {
+	before, err := decodeIDCursor(filter.Cursor)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	where := []string{"webhook_id = ?", "webhook_id IN (SELECT id FROM webhooks WHERE " + userScope(ctx) + ")"}
+	args := []interface{}{filter.WebhookID}
+
+	if filter.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, *filter.Status)
+	}
+	if before > 0 {
+		where = append(where, "id < ?")
+		args = append(args, before)
+	}
+	args = append(args, filter.Limit+1)
+
+	rows, err := r.q().QueryContext(ctx,
+		`SELECT `+sqliteDeliveryColumns+` FROM webhook_deliveries
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY id DESC LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	deliveries, err := scanSqliteDeliveries(rows)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+
+	return deliveryPageOf(filter, nonNilDeliveries(deliveries)), nil
+}
+
+// ClaimDeliveries needs no locking, as the single connection already
+// runs one statement at a time.
+func (r *SqliteWebhookRepository) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.q().QueryContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = ?1
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+					JOIN webhooks w ON w.id = d.webhook_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= ?2 AND w.active AND `+userScope(ctx)+`
+				ORDER BY d.next_attempt_at, d.id
+				LIMIT ?3
+			)
+			RETURNING `+sqliteDeliveryColumns,
+		sqliteTimeValue(leaseUntil),
+		sqliteTimeValue(now),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := scanSqliteDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING doesn't keep the order of the subquery.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	return deliveries, nil
+}
+
+func (r *SqliteWebhookRepository) RecordAttempt(ctx context.Context, id int64, attempt model.DeliveryAttempt) (model.WebhookDelivery, error) {
+	return scanSqliteDelivery(r.q().QueryRowContext(ctx,
+		`UPDATE webhook_deliveries SET
+				attempts = attempts + 1,
+				last_attempt_at = ?,
+				response_status = ?,
+				last_error = ?,
+				status = ?,
+				next_attempt_at = ?
+			WHERE id = ? AND webhook_id IN (SELECT id FROM webhooks WHERE `+userScope(ctx)+`)
+			RETURNING `+sqliteDeliveryColumns,
+		sqliteTimeValue(attempt.At),
+		attempt.ResponseStatus,
+		attempt.Error,
+		attempt.Status,
+		sqliteNullTimeValue(attempt.NextAttemptAt),
+		id,
+	))
+}
+
+var _ ReminderRepository = &SqliteReminderRepository{}
+
+type SqliteReminderRepository struct {
+	store *SqliteStore
+}
+
+func (r *SqliteReminderRepository) ClaimReminders(ctx context.Context, offset time.Duration, from, now time.Time, limit int) ([]model.Reminder, error) {
+	rows, err := r.store.db.QueryContext(ctx,
+		`INSERT INTO reminders (todo_id, offset_seconds, due_at, created_at)
+			SELECT id, ?1, due_at, ?4 FROM todos
+			WHERE deleted_at IS NULL AND NOT complete AND due_at > ?2 AND due_at <= ?3
+				AND NOT EXISTS (
+					SELECT 1 FROM reminders r
+					WHERE r.todo_id = todos.id AND r.offset_seconds = ?1 AND r.due_at = todos.due_at
+				)
+			ORDER BY due_at, id
+			LIMIT ?5
+			ON CONFLICT DO NOTHING
+			RETURNING id, todo_id, due_at, created_at`,
+		int64(offset/time.Second),
+		sqliteTimeValue(from.Add(offset)),
+		sqliteTimeValue(now.Add(offset)),
+		sqliteTimeValue(now),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []model.Reminder
+	for rows.Next() {
+		reminder := model.Reminder{Offset: offset}
+		if err := rows.Scan(&reminder.ID, &reminder.TodoID, sqliteTime{&reminder.DueAt}, sqliteTime{&reminder.CreatedAt}); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return withReminderTodos(ctx, r.store.todos, reminders)
+}
+
+func (r *SqliteReminderRepository) RecordReminder(ctx context.Context, id int64, sentAt time.Time, sendErr string) error {
+	var sent *time.Time
+	if sendErr == "" {
+		sent = &sentAt
+	}
+
+	result, err := r.store.db.ExecContext(ctx,
+		`UPDATE reminders SET sent_at = ?, error = ? WHERE id = ?`,
+		sqliteNullTimeValue(sent),
+		sendErr,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+var _ LeaseRepository = &SqliteLeaseRepository{}
+
+type SqliteLeaseRepository struct {
+	store *SqliteStore
+}
+
+func (r *SqliteLeaseRepository) AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error) {
+	var got string
+	err := r.store.db.QueryRowContext(ctx,
+		`INSERT INTO leases (name, holder, expires_at) VALUES (?1, ?2, ?4)
+			ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+				WHERE leases.holder = excluded.holder OR leases.expires_at <= ?3
+			RETURNING holder`,
+		name,
+		holder,
+		sqliteTimeValue(now),
+		sqliteTimeValue(until),
+	).Scan(&got)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SqliteLeaseRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := r.store.db.ExecContext(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+	return err
+}
@@ -0,0 +1,31 @@
+package store_test
+
+import (
+	"crud/internal/store"
+	"crud/internal/store/storetest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func openSqlite(t *testing.T) store.Store {
+	t.Helper()
+
+	s := store.NewSqliteStore(&store.Config{
+		Driver:      store.DriverSqlite,
+		DatabaseUrl: filepath.Join(t.TempDir(), "crud.db"),
+	})
+	require.NoError(t, s.Open())
+
+	return s
+}
+
+func TestSqliteTodoRepository(t *testing.T) {
+	storetest.RunTodoRepositoryTests(t, func(t *testing.T) store.Store {
+		s := openSqlite(t)
+		require.NoError(t, s.Migrator().Up())
+
+		return s
+	})
+}
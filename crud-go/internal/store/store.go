@@ -1,7 +1,10 @@
 package store
 
 import (
+	"context"
 	"crud/internal/model"
+	"fmt"
+	"time"
 )
 
 //go:generate mockgen -source=store.go -destination=mocks/mock.go
@@ -9,14 +12,175 @@ import (
 type Store interface {
 	Open() error
 	Close() error
+	// Ping checks that the database of an opened store can be reached.
+	Ping(ctx context.Context) error
 
+	// Migrator manages the schema of an opened store.
+	Migrator() Migrator
+
+	Todos() TodoRepository
+	Lists() ListRepository
+	Audit() AuditRepository
+	Webhooks() WebhookRepository
+	Reminders() ReminderRepository
+	Leases() LeaseRepository
+	// Changes delivers audit entries once they are committed, also those
+	// written by other processes sharing the database.
+	Changes() ChangeFeed
+
+	// InTx runs fn as a unit of work: the repositories of tx share one
+	// transaction, which is committed if fn returns nil and rolled back
+	// otherwise.
+	InTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx is a transaction in progress.
+type Tx interface {
 	Todos() TodoRepository
+	Lists() ListRepository
+	Audit() AuditRepository
+	Webhooks() WebhookRepository
+
+	// Savepoint runs fn so that if it fails only its own changes are
+	// rolled back and the transaction can go on.
+	Savepoint(ctx context.Context, fn func() error) error
 }
 
+// TodoRepository stores todos. Todos form a forest through ParentID;
+// repositories reject a parent that doesn't exist with ErrParentNotFound
+// and a move that would make a cycle with ErrCycle. Every write bumps the
+// version of the todos it changes and fails with a
+// *model.VersionConflictError if an expected version is stale.
+//
+// Deleting moves a todo to the trash, where only ListTodos with
+// Trashed, RestoreTodo and PurgeTodos see it; to everything else it is
+// gone.
 type TodoRepository interface {
-	GetTodos(ids []model.ID) ([]model.Todo, error)
-	CreateTodo(title string) (model.Todo, error)
-	ToggleTodo(id model.ID) (model.Todo, error)
-	UpdateTodo(id model.ID, title *string, complete *bool) (model.Todo, error)
-	DeleteTodo(id model.ID) (model.Todo, error)
+	GetTodos(ctx context.Context, ids []model.ID) ([]model.Todo, error)
+	// LockTodo returns the todo id, even from the trash, and keeps other
+	// transactions from changing it until the current one ends.
+	LockTodo(ctx context.Context, id model.ID) (model.Todo, error)
+	// GetSubtree returns the todo id and all of its descendants ordered
+	// by id, or nothing when it doesn't exist.
+	GetSubtree(ctx context.Context, id model.ID) ([]model.Todo, error)
+	// LockDescendants returns the descendants of id ordered by id, even
+	// those in the trash, and keeps other transactions from changing
+	// them until the current one ends.
+	LockDescendants(ctx context.Context, id model.ID) ([]model.Todo, error)
+	ListTodos(ctx context.Context, filter model.TodoFilter) (model.TodoPage, error)
+	SearchTodos(ctx context.Context, filter model.SearchFilter) (model.SearchPage, error)
+	CreateTodo(ctx context.Context, todo model.Todo) (model.Todo, error)
+	ToggleTodo(ctx context.Context, id model.ID, opts model.ToggleOptions) (model.Todo, error)
+	UpdateTodo(ctx context.Context, id model.ID, patch model.TodoPatch) (model.Todo, error)
+	DeleteTodo(ctx context.Context, id model.ID, opts model.DeleteOptions) (model.Todo, error)
+	// RestoreTodo takes the todo out of the trash along with the
+	// descendants that were deleted with it. A todo whose parent is no
+	// longer there is restored at the top level.
+	RestoreTodo(ctx context.Context, id model.ID) (model.Todo, error)
+	// PurgeTodos permanently removes the todos put into the trash before
+	// the given time and returns how many there were.
+	PurgeTodos(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// ListRepository stores the lists todos are kept in. Lists are returned
+// with the counts of their todos. Whether a todo may go into a list is
+// up to the caller; repositories only keep a todo in the list of its
+// parent, rejecting a parent of another list with ErrParentInOtherList.
+type ListRepository interface {
+	GetList(ctx context.Context, id model.ID) (model.List, error)
+	// ListLists returns the lists matching filter ordered by id.
+	ListLists(ctx context.Context, filter model.ListFilter) ([]model.List, error)
+	CreateList(ctx context.Context, list model.List) (model.List, error)
+	UpdateList(ctx context.Context, id model.ID, patch model.ListPatch) (model.List, error)
+	// DeleteList permanently removes the list along with its todos in
+	// the trash. It fails with ErrListNotEmpty while the list has live
+	// todos.
+	DeleteList(ctx context.Context, id model.ID) error
+}
+
+// AuditRepository keeps the audit trail of todos. Entries are never
+// changed or removed, not even when their todo is purged.
+type AuditRepository interface {
+	// AppendAudit stores entry, filling in its id and creation time.
+	AppendAudit(ctx context.Context, entry model.AuditEntry) (model.AuditEntry, error)
+	ListAudit(ctx context.Context, filter model.AuditFilter) (model.AuditPage, error)
+}
+
+// WebhookRepository stores webhooks and the log of their deliveries.
+// Deleting a webhook deletes its deliveries. Like todos, webhooks belong
+// to the user a new one is created for, and the user ctx acts for only
+// sees their own and their deliveries.
+type WebhookRepository interface {
+	GetWebhook(ctx context.Context, id model.ID) (model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]model.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id model.ID, patch model.WebhookPatch) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id model.ID) error
+
+	// Times are taken from the caller, so that all scheduling follows the
+	// clock of the dispatcher.
+
+	// EnqueueDeliveries queues a copy of delivery, pending and due at
+	// delivery.NextAttemptAt, for every active webhook of owner, the
+	// owner of the todo changed, subscribed to delivery.Event and returns
+	// how many there were.
+	EnqueueDeliveries(ctx context.Context, owner model.UserID, delivery model.WebhookDelivery) (int64, error)
+	// Redeliver queues a new delivery of the payload of delivery id, due
+	// at due.
+	Redeliver(ctx context.Context, webhookID model.ID, id int64, due time.Time) (model.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter model.DeliveryFilter) (model.DeliveryPage, error)
+	// ClaimDeliveries returns up to limit pending deliveries of active
+	// webhooks that are due at now, and postpones them until leaseUntil
+	// so that no one else picks them up meanwhile.
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error)
+	// RecordAttempt counts an attempt of delivery id and stores its
+	// outcome.
+	RecordAttempt(ctx context.Context, id int64, attempt model.DeliveryAttempt) (model.WebhookDelivery, error)
+}
+
+// ReminderRepository keeps the reminders of due todos. Times are taken
+// from the caller, like those of deliveries.
+type ReminderRepository interface {
+	// ClaimReminders creates and returns up to limit reminders, with
+	// their todos, for the live and open todos due at offset after a
+	// time in (from, now], that have no reminder for offset and their
+	// due date yet. A reminder is claimed only once, whoever asks.
+	ClaimReminders(ctx context.Context, offset time.Duration, from, now time.Time, limit int) ([]model.Reminder, error)
+	// RecordReminder stores that reminder id was sent at sentAt, or why
+	// it failed.
+	RecordReminder(ctx context.Context, id int64, sentAt time.Time, sendErr string) error
+}
+
+// LeaseRepository hands out named leases, so that a job shared by
+// several instances runs on one of them at a time.
+type LeaseRepository interface {
+	// AcquireLease takes or renews the lease name for holder until until
+	// and tells whether it got it. It gets it unless another holder has
+	// it beyond now.
+	AcquireLease(ctx context.Context, name, holder string, now, until time.Time) (bool, error)
+	// ReleaseLease gives up the lease name if holder has it.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// ChangeFeed broadcasts committed audit entries. Entries of concurrent
+// transactions may arrive out of id order.
+type ChangeFeed interface {
+	// Subscribe delivers the entries committed from now on. The channel
+	// is closed when ctx is done, the store is closed, or the subscriber
+	// falls too far behind.
+	Subscribe(ctx context.Context) <-chan model.AuditEntry
+}
+
+// New creates the Store selected by config.Driver.
+func New(config *Config) (Store, error) {
+	switch config.Driver {
+	case DriverPostgres, "":
+		return NewPostgresStore(config), nil
+	case DriverSqlite:
+		return NewSqliteStore(config), nil
+	case DriverMemory:
+		return NewMemoryStore(config), nil
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", config.Driver)
+	}
 }
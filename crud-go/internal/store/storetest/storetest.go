@@ -0,0 +1,1966 @@
+// Package storetest is a conformance suite every store.TodoRepository
+// implementation has to pass.
+package storetest
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// OpenFunc returns an opened, empty store. It is called once per test.
+type OpenFunc func(t *testing.T) store.Store
+
+// inbox is the list the tests create their todos in, the first list of
+// every store.
+const inbox model.ID = 1
+
+func openWithInbox(t *testing.T, open OpenFunc) store.Store {
+	t.Helper()
+
+	s := open(t)
+	t.Cleanup(func() { s.Close() })
+
+	list, err := s.Lists().CreateList(ctx, model.List{Name: "Inbox"})
+	require.NoError(t, err)
+	require.Equal(t, inbox, list.ID)
+
+	return s
+}
+
+func RunTodoRepositoryTests(t *testing.T, open OpenFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo store.TodoRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateWithFields", testCreateWithFields},
+		{"GetMissing", testGetMissing},
+		{"Toggle", testToggle},
+		{"Update", testUpdate},
+		{"UpdateFields", testUpdateFields},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"ListFilters", testListFilters},
+		{"ListRichFilters", testListRichFilters},
+		{"ListPagination", testListPagination},
+		{"ListSorts", testListSorts},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"Subtree", testSubtree},
+		{"LockDescendants", testLockDescendants},
+		{"ParentNotFound", testParentNotFound},
+		{"MoveCycle", testMoveCycle},
+		{"ToggleCascade", testToggleCascade},
+		{"DeleteCascade", testDeleteCascade},
+		{"DeleteReparent", testDeleteReparent},
+		{"Versions", testVersions},
+		{"VersionConflict", testVersionConflict},
+		{"Trash", testTrash},
+		{"Restore", testRestore},
+		{"Purge", testPurge},
+		{"Lock", testLock},
+		{"Search", testSearch},
+		{"Owners", testOwners},
+		{"Series", testSeries},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, openWithInbox(t, open).Todos())
+		})
+	}
+
+	txTests := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxSavepoint", testTxSavepoint},
+		{"RestoreTx", testRestoreTx},
+		{"Audit", testAudit},
+		{"AuditTx", testAuditTx},
+		{"AuditOwners", testAuditOwners},
+		{"Changes", testChanges},
+		{"Webhooks", testWebhooks},
+		{"Deliveries", testDeliveries},
+		{"DeliveriesTx", testDeliveriesTx},
+		{"WebhookOwners", testWebhookOwners},
+		{"Lists", testLists},
+		{"ListCounts", testListCounts},
+		{"ListMove", testListMove},
+		{"ListDelete", testListDelete},
+		{"ListOwners", testListOwners},
+		{"Reminders", testReminders},
+		{"Leases", testLeases},
+	}
+
+	for _, tt := range txTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, openWithInbox(t, open))
+		})
+	}
+}
+
+func createTodos(t *testing.T, repo store.TodoRepository, titles ...string) []model.Todo {
+	t.Helper()
+
+	todos := make([]model.Todo, 0, len(titles))
+	for _, title := range titles {
+		todo, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: title})
+		require.NoError(t, err)
+		todos = append(todos, todo)
+	}
+
+	return todos
+}
+
+func testCreateAndGet(t *testing.T, repo store.TodoRepository) {
+	before := time.Now().Add(-time.Minute)
+
+	created, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Test todo"})
+	require.NoError(t, err)
+
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "Test todo", created.Title)
+	assert.False(t, created.Complete)
+	assert.True(t, created.CreatedAt.After(before), "created_at is defaulted")
+	assert.True(t, created.UpdatedAt.Equal(created.CreatedAt) || created.UpdatedAt.After(created.CreatedAt))
+	assert.Equal(t, model.PriorityNone, created.Priority)
+	assert.Nil(t, created.DueAt)
+	assert.Nil(t, created.CompletedAt)
+	assert.NotNil(t, created.Tags, "tags encode as an empty array")
+	assert.Empty(t, created.Tags)
+
+	todos, err := repo.GetTodos(ctx, []model.ID{created.ID})
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+
+	assert.Equal(t, created.ID, todos[0].ID)
+	assert.Equal(t, created.Title, todos[0].Title)
+	assert.Equal(t, created.Complete, todos[0].Complete)
+	assert.True(t, created.CreatedAt.Equal(todos[0].CreatedAt))
+}
+
+// dueTime is a due date with no sub-second part, which every store keeps
+// exactly.
+var dueTime = time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func testCreateWithFields(t *testing.T, repo store.TodoRepository) {
+	dueAt := dueTime
+
+	created, err := repo.CreateTodo(ctx, model.Todo{
+		ListID:      inbox,
+		Title:       "Pay rent",
+		Description: "Transfer to the landlord",
+		Complete:    true,
+		Priority:    model.PriorityHigh,
+		DueAt:       &dueAt,
+		DueTimezone: "America/New_York",
+		Tags:        []string{"home", "money"},
+	})
+	require.NoError(t, err)
+
+	got, err := repo.GetTodos(ctx, []model.ID{created.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	for _, todo := range []model.Todo{created, got[0]} {
+		assert.Equal(t, "Pay rent", todo.Title)
+		assert.Equal(t, "Transfer to the landlord", todo.Description)
+		assert.True(t, todo.Complete)
+		assert.Equal(t, model.PriorityHigh, todo.Priority)
+		require.NotNil(t, todo.DueAt)
+		assert.True(t, dueTime.Equal(*todo.DueAt))
+		assert.Equal(t, "America/New_York", todo.DueAt.Location().String(), "due date is shown in its timezone")
+		assert.Equal(t, "America/New_York", todo.DueTimezone)
+		assert.Equal(t, []string{"home", "money"}, todo.Tags)
+		assert.NotNil(t, todo.CompletedAt, "completed_at is set for a complete todo")
+	}
+}
+
+func testGetMissing(t *testing.T, repo store.TodoRepository) {
+	todos := createTodos(t, repo, "Todo 1", "Todo 2")
+
+	got, err := repo.GetTodos(ctx, []model.ID{todos[1].ID, todos[1].ID + 1000})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, todos[1].ID, got[0].ID)
+
+	got, err = repo.GetTodos(ctx, []model.ID{todos[0].ID + 1000})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testToggle(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+
+	toggled, err := repo.ToggleTodo(ctx, todo.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+	assert.True(t, toggled.Complete)
+	assert.Equal(t, todo.Title, toggled.Title)
+	assert.True(t, todo.CreatedAt.Equal(toggled.CreatedAt))
+	assert.NotNil(t, toggled.CompletedAt)
+	assert.False(t, toggled.UpdatedAt.Before(todo.UpdatedAt))
+
+	toggled, err = repo.ToggleTodo(ctx, todo.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+	assert.False(t, toggled.Complete)
+	assert.Nil(t, toggled.CompletedAt)
+}
+
+func testUpdate(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+
+	title := "New title"
+	updated, err := repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+	assert.False(t, updated.Complete)
+
+	complete := true
+	updated, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{Complete: &complete})
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+	assert.True(t, updated.Complete)
+	require.NotNil(t, updated.CompletedAt)
+
+	completedAt := *updated.CompletedAt
+	updated, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{Complete: &complete})
+	require.NoError(t, err)
+	require.NotNil(t, updated.CompletedAt)
+	assert.True(t, completedAt.Equal(*updated.CompletedAt), "completing again keeps completed_at")
+
+	got, err := repo.GetTodos(ctx, []model.ID{todo.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, title, got[0].Title)
+	assert.True(t, got[0].Complete)
+}
+
+func testUpdateFields(t *testing.T, repo store.TodoRepository) {
+	dueAt := dueTime
+
+	todo, err := repo.CreateTodo(ctx, model.Todo{
+		ListID:      inbox,
+		Title:       "Todo",
+		Description: "Old",
+		Priority:    model.PriorityLow,
+		DueAt:       &dueAt,
+		Tags:        []string{"a"},
+	})
+	require.NoError(t, err)
+
+	description, priority, timezone := "New", model.PriorityUrgent, "Europe/Berlin"
+	tags := []string{"b", "c"}
+
+	updated, err := repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{
+		Description: &description,
+		Priority:    &priority,
+		DueTimezone: &timezone,
+		Tags:        &tags,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Todo", updated.Title)
+	assert.Equal(t, description, updated.Description)
+	assert.Equal(t, priority, updated.Priority)
+	assert.Equal(t, tags, updated.Tags)
+	require.NotNil(t, updated.DueAt, "due date is kept when left out of the patch")
+	assert.True(t, dueTime.Equal(*updated.DueAt))
+	assert.Equal(t, timezone, updated.DueAt.Location().String())
+	assert.False(t, updated.UpdatedAt.Before(todo.UpdatedAt))
+
+	updated, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{DueAt: model.NullableOf[time.Time](nil)})
+	require.NoError(t, err)
+	assert.Nil(t, updated.DueAt, "due date is cleared by null")
+
+	later := dueTime.Add(time.Hour)
+	updated, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{DueAt: model.NullableOf(&later)})
+	require.NoError(t, err)
+	require.NotNil(t, updated.DueAt)
+	assert.True(t, later.Equal(*updated.DueAt))
+
+	got, err := repo.GetTodos(ctx, []model.ID{todo.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, updated.Description, got[0].Description)
+	assert.Equal(t, updated.Priority, got[0].Priority)
+	assert.Equal(t, updated.Tags, got[0].Tags)
+	require.NotNil(t, got[0].DueAt)
+	assert.True(t, later.Equal(*got[0].DueAt))
+}
+
+func testSeries(t *testing.T, repo store.TodoRepository) {
+	dueAt := dueTime
+
+	first, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Water plants", DueAt: &dueAt, Recurrence: "FREQ=WEEKLY"})
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY", first.Recurrence)
+	assert.Nil(t, first.SeriesID)
+
+	next := dueTime.AddDate(0, 0, 7)
+	second, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Water plants", DueAt: &next, Recurrence: "FREQ=WEEKLY", SeriesID: &first.ID})
+	require.NoError(t, err)
+	require.NotNil(t, second.SeriesID)
+	assert.Equal(t, first.ID, *second.SeriesID)
+
+	createTodos(t, repo, "Other")
+
+	series := listAll(t, repo, model.TodoFilter{SeriesID: &first.ID, SortBy: model.SortByID, Order: model.SortAsc, Limit: 10})
+	assert.Equal(t, []model.ID{first.ID, second.ID}, ids(series))
+
+	stop := ""
+	updated, err := repo.UpdateTodo(ctx, second.ID, model.TodoPatch{Recurrence: &stop})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Recurrence)
+
+	got, err := repo.GetTodos(ctx, []model.ID{first.ID, second.ID})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "FREQ=WEEKLY", got[0].Recurrence)
+	assert.Empty(t, got[1].Recurrence)
+	require.NotNil(t, got[1].SeriesID)
+	assert.Equal(t, first.ID, *got[1].SeriesID)
+}
+
+func testDelete(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+
+	deleted, err := repo.DeleteTodo(ctx, todo.ID, model.DeleteOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, todo.ID, deleted.ID)
+	assert.Equal(t, todo.Title, deleted.Title)
+
+	got, err := repo.GetTodos(ctx, []model.ID{todo.ID})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testNotFound(t *testing.T, repo store.TodoRepository) {
+	missing := createTodos(t, repo, "Todo")[0].ID + 1000
+	title := "Title"
+
+	_, err := repo.ToggleTodo(ctx, missing, model.ToggleOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = repo.UpdateTodo(ctx, missing, model.TodoPatch{Title: &title})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = repo.DeleteTodo(ctx, missing, model.DeleteOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func testVersions(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+	assert.Equal(t, int64(1), todos[0].Version)
+
+	title := "New title"
+	updated, err := repo.UpdateTodo(ctx, todos[0].ID, model.TodoPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	toggled, err := repo.ToggleTodo(ctx, todos[0].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), toggled.Version)
+
+	got := get(t, repo, todos[1].ID)
+	assert.Equal(t, int64(2), got.Version, "a cascade bumps the descendants it changes")
+
+	_, err = repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	got = get(t, repo, todos[3].ID)
+	assert.Equal(t, int64(3), got.Version, "moving up to the parent of a deleted todo bumps the version")
+}
+
+func testVersionConflict(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+	stale, current := int64(1), int64(2)
+	title := "New title"
+
+	updated, err := repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{IfVersion: &stale, Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, current, updated.Version)
+
+	var conflict *model.VersionConflictError
+
+	other := "Other title"
+	_, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{IfVersion: &stale, Title: &other})
+	require.ErrorAs(t, err, &conflict)
+	assert.ErrorIs(t, err, model.ErrPreconditionFailed)
+	assert.Equal(t, current, conflict.Current.Version)
+	assert.Equal(t, title, conflict.Current.Title)
+
+	_, err = repo.ToggleTodo(ctx, todo.ID, model.ToggleOptions{IfVersion: &stale})
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, current, conflict.Current.Version)
+
+	_, err = repo.DeleteTodo(ctx, todo.ID, model.DeleteOptions{IfVersion: &stale})
+	require.ErrorAs(t, err, &conflict)
+
+	got := get(t, repo, todo.ID)
+	assert.Equal(t, title, got.Title, "stale writes change nothing")
+	assert.False(t, got.Complete)
+	assert.Equal(t, current, got.Version)
+
+	toggled, err := repo.ToggleTodo(ctx, todo.ID, model.ToggleOptions{IfVersion: &current})
+	require.NoError(t, err)
+	assert.True(t, toggled.Complete)
+
+	_, err = repo.DeleteTodo(ctx, todo.ID, model.DeleteOptions{IfVersion: &toggled.Version})
+	require.NoError(t, err)
+
+	_, err = repo.ToggleTodo(ctx, todo.ID, model.ToggleOptions{IfVersion: &current})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	assert.NotErrorIs(t, err, model.ErrPreconditionFailed)
+}
+
+func listAll(t *testing.T, repo store.TodoRepository, filter model.TodoFilter) []model.Todo {
+	t.Helper()
+
+	var todos []model.Todo
+	for {
+		page, err := repo.ListTodos(ctx, filter)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Todos), filter.Limit)
+
+		todos = append(todos, page.Todos...)
+		if page.NextCursor == "" {
+			return todos
+		}
+
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func ids(todos []model.Todo) []model.ID {
+	ret := make([]model.ID, len(todos))
+	for i, todo := range todos {
+		ret[i] = todo.ID
+	}
+	return ret
+}
+
+func testListFilters(t *testing.T, repo store.TodoRepository) {
+	todos := createTodos(t, repo, "Buy milk", "buy BREAD", "Walk the dog", "100% done", "snake_case")
+
+	_, err := repo.ToggleTodo(ctx, todos[2].ID, model.ToggleOptions{})
+	require.NoError(t, err)
+
+	base := model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Limit: 10}
+	complete, incomplete := true, false
+
+	testTable := []struct {
+		name     string
+		filter   func(f *model.TodoFilter)
+		expected []model.Todo
+	}{
+		{
+			name:     "All",
+			filter:   func(f *model.TodoFilter) {},
+			expected: todos,
+		},
+		{
+			name:     "Complete",
+			filter:   func(f *model.TodoFilter) { f.Complete = &complete },
+			expected: todos[2:3],
+		},
+		{
+			name:     "Incomplete",
+			filter:   func(f *model.TodoFilter) { f.Complete = &incomplete },
+			expected: []model.Todo{todos[0], todos[1], todos[3], todos[4]},
+		},
+		{
+			name:     "Title is case insensitive",
+			filter:   func(f *model.TodoFilter) { f.TitleContains = "BUY" },
+			expected: todos[0:2],
+		},
+		{
+			name:     "Title matches wildcards literally",
+			filter:   func(f *model.TodoFilter) { f.TitleContains = "%" },
+			expected: todos[3:4],
+		},
+		{
+			name:     "Title matches underscore literally",
+			filter:   func(f *model.TodoFilter) { f.TitleContains = "e_c" },
+			expected: todos[4:5],
+		},
+		{
+			name: "Created range",
+			filter: func(f *model.TodoFilter) {
+				after, before := todos[0].CreatedAt.Add(-time.Hour), todos[0].CreatedAt.Add(time.Hour)
+				f.CreatedAfter, f.CreatedBefore = &after, &before
+			},
+			expected: todos,
+		},
+		{
+			name: "Created range with an offset",
+			filter: func(f *model.TodoFilter) {
+				after := time.Now().Add(-time.Hour).In(time.FixedZone("", 2*60*60))
+				f.CreatedAfter = &after
+			},
+			expected: todos,
+		},
+		{
+			name: "Created in the future",
+			filter: func(f *model.TodoFilter) {
+				after := time.Now().Add(time.Hour)
+				f.CreatedAfter = &after
+			},
+			expected: nil,
+		},
+		{
+			name: "Created in the past",
+			filter: func(f *model.TodoFilter) {
+				before := time.Now().Add(-time.Hour)
+				f.CreatedBefore = &before
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := base
+			tt.filter(&filter)
+
+			page, err := repo.ListTodos(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, ids(tt.expected), ids(page.Todos))
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+// createRichTodos creates todos with these priorities and due dates:
+//
+//	0: high,   due +2h
+//	1: none,   no due date
+//	2: low,    due +1h
+//	3: high,   due +2h
+//	4: none,   due +0h
+//	5: urgent, no due date
+//	6: low,    due +1h
+func createRichTodos(t *testing.T, repo store.TodoRepository) []model.Todo {
+	t.Helper()
+
+	due := func(hours int) *time.Time {
+		dueAt := dueTime.Add(time.Duration(hours) * time.Hour)
+		return &dueAt
+	}
+
+	specs := []model.Todo{
+		{Title: "Todo 0", Priority: model.PriorityHigh, DueAt: due(2), Tags: []string{"work", "urgent"}},
+		{Title: "Todo 1", Priority: model.PriorityNone, Tags: []string{"home"}},
+		{Title: "Todo 2", Priority: model.PriorityLow, DueAt: due(1), Tags: []string{"work"}},
+		{Title: "Todo 3", Priority: model.PriorityHigh, DueAt: due(2)},
+		{Title: "Todo 4", Priority: model.PriorityNone, DueAt: due(0), Tags: []string{"home", "work"}},
+		{Title: "Todo 5", Priority: model.PriorityUrgent},
+		{Title: "Todo 6", Priority: model.PriorityLow, DueAt: due(1), Tags: []string{"urgent"}},
+	}
+
+	todos := make([]model.Todo, 0, len(specs))
+	for _, spec := range specs {
+		spec.ListID = inbox
+		todo, err := repo.CreateTodo(ctx, spec)
+		require.NoError(t, err)
+		todos = append(todos, todo)
+	}
+
+	return todos
+}
+
+func testListRichFilters(t *testing.T, repo store.TodoRepository) {
+	todos := createRichTodos(t, repo)
+
+	base := model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Limit: 10}
+	pick := func(indexes ...int) []model.Todo {
+		ret := make([]model.Todo, len(indexes))
+		for i, index := range indexes {
+			ret[i] = todos[index]
+		}
+		return ret
+	}
+
+	testTable := []struct {
+		name     string
+		filter   func(f *model.TodoFilter)
+		expected []model.Todo
+	}{
+		{
+			name: "Due after",
+			filter: func(f *model.TodoFilter) {
+				after := dueTime.Add(time.Hour)
+				f.DueAfter = &after
+			},
+			expected: pick(0, 2, 3, 6),
+		},
+		{
+			name: "Due before",
+			filter: func(f *model.TodoFilter) {
+				before := dueTime.Add(time.Hour)
+				f.DueBefore = &before
+			},
+			expected: pick(4),
+		},
+		{
+			name: "Min priority",
+			filter: func(f *model.TodoFilter) {
+				priority := model.PriorityHigh
+				f.MinPriority = &priority
+			},
+			expected: pick(0, 3, 5),
+		},
+		{
+			name:     "Tag",
+			filter:   func(f *model.TodoFilter) { f.Tags = []string{"work"} },
+			expected: pick(0, 2, 4),
+		},
+		{
+			name:     "All tags",
+			filter:   func(f *model.TodoFilter) { f.Tags = []string{"work", "home"} },
+			expected: pick(4),
+		},
+		{
+			name:     "Unknown tag",
+			filter:   func(f *model.TodoFilter) { f.Tags = []string{"missing"} },
+			expected: nil,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := base
+			tt.filter(&filter)
+
+			page, err := repo.ListTodos(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, ids(tt.expected), ids(page.Todos))
+		})
+	}
+}
+
+func testListPagination(t *testing.T, repo store.TodoRepository) {
+	todos := createTodos(t, repo, "Todo 1", "Todo 2", "Todo 3", "Todo 4", "Todo 5", "Todo 6", "Todo 7")
+
+	ascending := ids(todos)
+	descending := make([]model.ID, len(ascending))
+	for i, id := range ascending {
+		descending[len(ascending)-1-i] = id
+	}
+
+	testTable := []struct {
+		sortBy   model.TodoSortField
+		order    model.SortOrder
+		expected []model.ID
+	}{
+		{model.SortByID, model.SortAsc, ascending},
+		{model.SortByID, model.SortDesc, descending},
+		{model.SortByCreatedAt, model.SortAsc, ascending},
+		{model.SortByCreatedAt, model.SortDesc, descending},
+	}
+
+	for _, tt := range testTable {
+		t.Run(string(tt.sortBy)+" "+string(tt.order), func(t *testing.T) {
+			for _, limit := range []int{1, 3, 7, 10} {
+				got := listAll(t, repo, model.TodoFilter{SortBy: tt.sortBy, Order: tt.order, Limit: limit})
+				assert.Equal(t, tt.expected, ids(got), "limit %d", limit)
+			}
+		})
+	}
+}
+
+func testListSorts(t *testing.T, repo store.TodoRepository) {
+	todos := createRichTodos(t, repo)
+
+	// Make todo 0 the most recently updated one.
+	time.Sleep(10 * time.Millisecond)
+	title := "Todo 0 updated"
+	_, err := repo.UpdateTodo(ctx, todos[0].ID, model.TodoPatch{Title: &title})
+	require.NoError(t, err)
+
+	order := func(indexes ...int) []model.ID {
+		ret := make([]model.ID, len(indexes))
+		for i, index := range indexes {
+			ret[i] = todos[index].ID
+		}
+		return ret
+	}
+
+	testTable := []struct {
+		sortBy   model.TodoSortField
+		order    model.SortOrder
+		expected []model.ID
+	}{
+		{model.SortByPriority, model.SortAsc, order(1, 4, 2, 6, 0, 3, 5)},
+		{model.SortByPriority, model.SortDesc, order(5, 3, 0, 6, 2, 4, 1)},
+		{model.SortByDueAt, model.SortAsc, order(4, 2, 6, 0, 3, 1, 5)},
+		{model.SortByDueAt, model.SortDesc, order(5, 1, 3, 0, 6, 2, 4)},
+		{model.SortByUpdatedAt, model.SortAsc, order(1, 2, 3, 4, 5, 6, 0)},
+		{model.SortByUpdatedAt, model.SortDesc, order(0, 6, 5, 4, 3, 2, 1)},
+	}
+
+	for _, tt := range testTable {
+		t.Run(string(tt.sortBy)+" "+string(tt.order), func(t *testing.T) {
+			for _, limit := range []int{1, 3, 7, 10} {
+				got := listAll(t, repo, model.TodoFilter{SortBy: tt.sortBy, Order: tt.order, Limit: limit})
+				assert.Equal(t, tt.expected, ids(got), "limit %d", limit)
+			}
+		})
+	}
+}
+
+func testListInvalidCursor(t *testing.T, repo store.TodoRepository) {
+	createTodos(t, repo, "Todo 1", "Todo 2")
+
+	filter := model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Limit: 1}
+
+	page, err := repo.ListTodos(ctx, filter)
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+
+	filter.Cursor = "not a cursor"
+	_, err = repo.ListTodos(ctx, filter)
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+
+	filter.Cursor = page.NextCursor
+	filter.Order = model.SortDesc
+	_, err = repo.ListTodos(ctx, filter)
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+}
+
+// createTree creates this tree and returns its todos by index:
+//
+//	0
+//	├── 1
+//	│   └── 3
+//	│       └── 4
+//	└── 2
+//	5
+func createTree(t *testing.T, repo store.TodoRepository) []model.Todo {
+	t.Helper()
+
+	parents := []int{-1, 0, 0, 1, 3, -1}
+
+	todos := make([]model.Todo, 0, len(parents))
+	for i, parent := range parents {
+		todo := model.Todo{ListID: inbox, Title: fmt.Sprintf("Todo %d", i)}
+		if parent >= 0 {
+			todo.ParentID = &todos[parent].ID
+		}
+
+		created, err := repo.CreateTodo(ctx, todo)
+		require.NoError(t, err)
+		todos = append(todos, created)
+	}
+
+	return todos
+}
+
+func get(t *testing.T, repo store.TodoRepository, id model.ID) model.Todo {
+	t.Helper()
+
+	got, err := repo.GetTodos(ctx, []model.ID{id})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	return got[0]
+}
+
+func testSubtree(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	require.NotNil(t, todos[3].ParentID)
+	assert.Equal(t, todos[1].ID, *todos[3].ParentID)
+	assert.Nil(t, todos[0].ParentID)
+
+	subtree, err := repo.GetSubtree(ctx, todos[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, ids(todos[:5]), ids(subtree))
+
+	subtree, err = repo.GetSubtree(ctx, todos[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{todos[1].ID, todos[3].ID, todos[4].ID}, ids(subtree))
+
+	subtree, err = repo.GetSubtree(ctx, todos[5].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{todos[5].ID}, ids(subtree))
+
+	subtree, err = repo.GetSubtree(ctx, todos[5].ID+1000)
+	require.NoError(t, err)
+	assert.Empty(t, subtree)
+}
+
+func testLockDescendants(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	_, err := repo.DeleteTodo(ctx, todos[3].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+
+	descendants, err := repo.LockDescendants(ctx, todos[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, ids(todos[1:5]), ids(descendants), "descendants in the trash are included")
+
+	descendants, err = repo.LockDescendants(ctx, todos[5].ID)
+	require.NoError(t, err)
+	assert.Empty(t, descendants)
+}
+
+func testParentNotFound(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+	missing := todo.ID + 1000
+
+	_, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Orphan", ParentID: &missing})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+
+	_, err = repo.UpdateTodo(ctx, todo.ID, model.TodoPatch{ParentID: model.NullableOf(&missing)})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+}
+
+func testMoveCycle(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	for _, parent := range []int{1, 3, 4} {
+		_, err := repo.UpdateTodo(ctx, todos[1].ID, model.TodoPatch{ParentID: model.NullableOf(&todos[parent].ID)})
+		assert.ErrorIs(t, err, store.ErrCycle, "moving 1 under %d", parent)
+	}
+
+	moved, err := repo.UpdateTodo(ctx, todos[1].ID, model.TodoPatch{ParentID: model.NullableOf(&todos[5].ID)})
+	require.NoError(t, err)
+	require.NotNil(t, moved.ParentID)
+	assert.Equal(t, todos[5].ID, *moved.ParentID)
+
+	moved, err = repo.UpdateTodo(ctx, todos[1].ID, model.TodoPatch{ParentID: model.NullableOf[model.ID](nil)})
+	require.NoError(t, err)
+	assert.Nil(t, moved.ParentID)
+
+	subtree, err := repo.GetSubtree(ctx, todos[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{todos[0].ID, todos[2].ID}, ids(subtree))
+}
+
+func testToggleCascade(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	_, err := repo.ToggleTodo(ctx, todos[4].ID, model.ToggleOptions{})
+	require.NoError(t, err)
+
+	toggled, err := repo.ToggleTodo(ctx, todos[1].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.True(t, toggled.Complete)
+
+	for i, complete := range []bool{false, true, false, true, true, false} {
+		todo := get(t, repo, todos[i].ID)
+		assert.Equal(t, complete, todo.Complete, "todo %d", i)
+		assert.Equal(t, complete, todo.CompletedAt != nil, "todo %d", i)
+	}
+
+	_, err = repo.ToggleTodo(ctx, todos[0].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	_, err = repo.ToggleTodo(ctx, todos[0].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+
+	for i, complete := range []bool{false, false, false, false, false, false} {
+		assert.Equal(t, complete, get(t, repo, todos[i].ID).Complete, "todo %d", i)
+	}
+}
+
+func testDeleteCascade(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	deleted, err := repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+	assert.Equal(t, todos[1].ID, deleted.ID)
+
+	got, err := repo.GetTodos(ctx, ids(todos))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []model.ID{todos[0].ID, todos[2].ID, todos[5].ID}, ids(got))
+}
+
+func testDeleteReparent(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	_, err := repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	child := get(t, repo, todos[3].ID)
+	require.NotNil(t, child.ParentID, "children move up to the parent")
+	assert.Equal(t, todos[0].ID, *child.ParentID)
+
+	_, err = repo.DeleteTodo(ctx, todos[0].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	assert.Nil(t, get(t, repo, todos[3].ID).ParentID, "children of a top level todo become top level")
+	assert.Nil(t, get(t, repo, todos[2].ID).ParentID)
+	assert.NotNil(t, get(t, repo, todos[4].ID).ParentID, "grandchildren stay where they are")
+}
+
+func trash(t *testing.T, repo store.TodoRepository) []model.Todo {
+	t.Helper()
+	return listAll(t, repo, model.TodoFilter{Trashed: true, SortBy: model.SortByID, Order: model.SortAsc, Limit: 2})
+}
+
+func testTrash(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+	title := "New title"
+
+	deleted, err := repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+
+	assert.Equal(t, []model.ID{todos[1].ID, todos[3].ID, todos[4].ID}, ids(trash(t, repo)))
+	assert.Equal(t, []model.ID{todos[0].ID, todos[2].ID, todos[5].ID},
+		ids(listAll(t, repo, model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Limit: 10})))
+
+	subtree, err := repo.GetSubtree(ctx, todos[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{todos[0].ID, todos[2].ID}, ids(subtree))
+
+	_, err = repo.ToggleTodo(ctx, todos[1].ID, model.ToggleOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.UpdateTodo(ctx, todos[3].ID, model.TodoPatch{Title: &title})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Todo", ParentID: &todos[1].ID})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+	_, err = repo.UpdateTodo(ctx, todos[5].ID, model.TodoPatch{ParentID: model.NullableOf(&todos[3].ID)})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+
+	_, err = repo.ToggleTodo(ctx, todos[0].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	_, err = repo.RestoreTodo(ctx, todos[1].ID)
+	require.NoError(t, err)
+	assert.False(t, get(t, repo, todos[3].ID).Complete, "a cascade leaves the trash alone")
+}
+
+func testRestore(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	_, err := repo.DeleteTodo(ctx, todos[4].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+	_, err = repo.DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+
+	_, err = repo.RestoreTodo(ctx, todos[0].ID)
+	assert.ErrorIs(t, err, model.ErrNotFound, "only trashed todos can be restored")
+
+	restored, err := repo.RestoreTodo(ctx, todos[1].ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	require.NotNil(t, restored.ParentID)
+	assert.Equal(t, todos[0].ID, *restored.ParentID)
+	assert.Equal(t, todos[3].ID, get(t, repo, todos[3].ID).ID, "descendants deleted along come back")
+	assert.Equal(t, []model.ID{todos[4].ID}, ids(trash(t, repo)), "descendants deleted before stay in the trash")
+
+	_, err = repo.DeleteTodo(ctx, todos[0].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+
+	restored, err = repo.RestoreTodo(ctx, todos[3].ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.ParentID, "a todo whose parent is in the trash comes back at the top level")
+	assert.Equal(t, []model.ID{todos[0].ID, todos[1].ID, todos[2].ID, todos[4].ID}, ids(trash(t, repo)))
+}
+
+func testPurge(t *testing.T, repo store.TodoRepository) {
+	todos := createTree(t, repo)
+
+	_, err := repo.DeleteTodo(ctx, todos[3].ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+
+	purged, err := repo.PurgeTodos(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged, "todos trashed after the cut-off are kept")
+
+	purged, err = repo.PurgeTodos(ctx, time.Now().Add(-time.Hour).In(time.FixedZone("", 2*60*60)))
+	require.NoError(t, err)
+	assert.Zero(t, purged, "the cut-off is an instant, whatever its offset")
+
+	_, err = repo.DeleteTodo(ctx, todos[0].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	purged, err = repo.PurgeTodos(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.Empty(t, trash(t, repo))
+
+	_, err = repo.RestoreTodo(ctx, todos[0].ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	got, err := repo.GetTodos(ctx, ids(todos))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []model.ID{todos[1].ID, todos[2].ID, todos[5].ID}, ids(got))
+}
+
+func testLock(t *testing.T, repo store.TodoRepository) {
+	todo := createTodos(t, repo, "Todo")[0]
+
+	locked, err := repo.LockTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, todo.ID, locked.ID)
+
+	_, err = repo.DeleteTodo(ctx, todo.ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	locked, err = repo.LockTodo(ctx, todo.ID)
+	require.NoError(t, err, "trashed todos can be locked")
+	assert.NotNil(t, locked.DeletedAt)
+
+	_, err = repo.LockTodo(ctx, todo.ID+1000)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func testOwners(t *testing.T, repo store.TodoRepository) {
+	alice := model.WithUser(ctx, 1)
+	bob := model.WithUser(ctx, 2)
+
+	todo, err := repo.CreateTodo(alice, model.Todo{ListID: inbox, Title: "Alice's todo", Tags: []string{"work"}, UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID(1), todo.UserID, "todos belong to the user creating them")
+
+	subtask, err := repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Alice's subtask", ParentID: &todo.ID})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID(1), subtask.UserID, "subtasks belong to the owner of their parent")
+
+	other, err := repo.CreateTodo(bob, model.Todo{ListID: inbox, Title: "Bob's todo", Tags: []string{"work"}})
+	require.NoError(t, err)
+
+	// Bob doesn't see Alice's todos.
+	got, err := repo.GetTodos(bob, []model.ID{todo.ID, other.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{other.ID}, ids(got))
+
+	page, err := repo.ListTodos(bob, model.TodoFilter{Tags: []string{"work"}, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{other.ID}, ids(page.Todos))
+
+	assert.Len(t, listAll(t, repo, model.TodoFilter{Limit: 10}), 3, "a context without a user sees everyone's todos")
+
+	results, err := repo.SearchTodos(bob, model.SearchFilter{Query: "todo", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results.Results, 1)
+	assert.Equal(t, other.ID, results.Results[0].Todo.ID)
+
+	subtree, err := repo.GetSubtree(bob, todo.ID)
+	require.NoError(t, err)
+	assert.Empty(t, subtree)
+
+	// Nor can he change them or hang his under them.
+	_, err = repo.LockTodo(bob, todo.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.ToggleTodo(bob, todo.ID, model.ToggleOptions{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	title := "Bob's now"
+	_, err = repo.UpdateTodo(bob, todo.ID, model.TodoPatch{Title: &title})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.UpdateTodo(bob, other.ID, model.TodoPatch{ParentID: model.NullableOf(&todo.ID)})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+	_, err = repo.CreateTodo(bob, model.Todo{ListID: inbox, Title: "Bob's subtask", ParentID: &todo.ID})
+	assert.ErrorIs(t, err, store.ErrParentNotFound)
+	_, err = repo.DeleteTodo(bob, todo.ID, model.DeleteOptions{Cascade: true})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	_, err = repo.DeleteTodo(alice, todo.ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+
+	_, err = repo.RestoreTodo(bob, todo.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	page, err = repo.ListTodos(bob, model.TodoFilter{Trashed: true, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Todos)
+
+	purged, err := repo.PurgeTodos(bob, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	restored, err := repo.RestoreTodo(alice, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID(1), restored.UserID)
+
+	got, err = repo.GetTodos(alice, []model.ID{todo.ID, subtask.ID, other.ID})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []model.ID{todo.ID, subtask.ID}, ids(got))
+}
+
+func testTxCommit(t *testing.T, s store.Store) {
+	todo := createTodos(t, s.Todos(), "Todo")[0]
+
+	var created model.Todo
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		var err error
+		created, err = tx.Todos().CreateTodo(ctx, model.Todo{ListID: inbox, Title: "In tx", ParentID: &todo.ID})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Todos().ToggleTodo(ctx, todo.ID, model.ToggleOptions{Cascade: true})
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.True(t, get(t, s.Todos(), todo.ID).Complete)
+	assert.True(t, get(t, s.Todos(), created.ID).Complete)
+}
+
+func testTxRollback(t *testing.T, s store.Store) {
+	todos := createTree(t, s.Todos())
+	failure := errors.New("failure")
+
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		if _, err := tx.Todos().CreateTodo(ctx, model.Todo{ListID: inbox, Title: "In tx"}); err != nil {
+			return err
+		}
+		if _, err := tx.Todos().DeleteTodo(ctx, todos[0].ID, model.DeleteOptions{Cascade: true}); err != nil {
+			return err
+		}
+
+		got, err := tx.Todos().GetTodos(ctx, ids(todos))
+		require.NoError(t, err)
+		assert.Len(t, got, 1, "the transaction sees its own changes")
+
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	got, err := s.Todos().GetTodos(ctx, ids(todos))
+	require.NoError(t, err)
+	assert.Len(t, got, len(todos), "deletes are rolled back")
+
+	page, err := s.Todos().ListTodos(ctx, model.TodoFilter{SortBy: model.SortByID, Order: model.SortAsc, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, ids(todos), ids(page.Todos), "creates are rolled back")
+}
+
+func testTxSavepoint(t *testing.T, s store.Store) {
+	todo := createTodos(t, s.Todos(), "Todo")[0]
+	missing := todo.ID + 1000
+
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		err := tx.Savepoint(ctx, func() error {
+			if _, err := tx.Todos().ToggleTodo(ctx, todo.ID, model.ToggleOptions{}); err != nil {
+				return err
+			}
+			_, err := tx.Todos().ToggleTodo(ctx, missing, model.ToggleOptions{})
+			return err
+		})
+		assert.ErrorIs(t, err, model.ErrNotFound)
+
+		return tx.Savepoint(ctx, func() error {
+			title := "Renamed"
+			_, err := tx.Todos().UpdateTodo(ctx, todo.ID, model.TodoPatch{Title: &title})
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	got := get(t, s.Todos(), todo.ID)
+	assert.False(t, got.Complete, "the failed savepoint is rolled back")
+	assert.Equal(t, "Renamed", got.Title, "the transaction goes on after a failed savepoint")
+}
+
+func testRestoreTx(t *testing.T, s store.Store) {
+	todos := createTree(t, s.Todos())
+
+	// A batch deleting a subtask, then its parent with the rest.
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		if _, err := tx.Todos().DeleteTodo(ctx, todos[3].ID, model.DeleteOptions{}); err != nil {
+			return err
+		}
+		_, err := tx.Todos().DeleteTodo(ctx, todos[1].ID, model.DeleteOptions{Cascade: true})
+		return err
+	})
+	require.NoError(t, err)
+
+	_, err = s.Todos().RestoreTodo(ctx, todos[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ID{todos[3].ID}, ids(trash(t, s.Todos())), "a subtask deleted before in the same transaction stays in the trash")
+}
+
+func testAudit(t *testing.T, s store.Store) {
+	todo := createTodos(t, s.Todos(), "Todo")[0]
+	after := todo
+	after.Complete = true
+	after.Tags = []string{"work"}
+
+	start := time.Now().Add(-time.Minute)
+
+	created, err := s.Audit().AppendAudit(ctx, model.AuditEntry{
+		TodoID:    todo.ID,
+		Action:    model.AuditCreate,
+		After:     &todo,
+		Actor:     "alice",
+		RequestID: "req-1",
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.False(t, created.CreatedAt.Before(start))
+
+	_, err = s.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: todo.ID + 1, Action: model.AuditCreate, After: &todo})
+	require.NoError(t, err)
+	_, err = s.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: todo.ID, Action: model.AuditToggle, Before: &todo, After: &after})
+	require.NoError(t, err)
+
+	var entries []model.AuditEntry
+	filter := model.AuditFilter{TodoID: &todo.ID, Limit: 1}
+	for {
+		page, err := s.Audit().ListAudit(ctx, filter)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Entries), filter.Limit)
+
+		entries = append(entries, page.Entries...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, created.ID, entries[0].ID)
+	assert.Equal(t, model.AuditCreate, entries[0].Action)
+	assert.Nil(t, entries[0].Before)
+	require.NotNil(t, entries[0].After)
+	assert.Equal(t, todo.Title, entries[0].After.Title)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, model.AuditToggle, entries[1].Action)
+	require.NotNil(t, entries[1].Before)
+	require.NotNil(t, entries[1].After)
+	assert.False(t, entries[1].Before.Complete)
+	assert.True(t, entries[1].After.Complete)
+	assert.Equal(t, []string{"work"}, entries[1].After.Tags)
+
+	later := time.Now().Add(time.Minute)
+
+	page, err := s.Audit().ListAudit(ctx, model.AuditFilter{CreatedAfter: &start, CreatedBefore: &later, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 3)
+
+	page, err = s.Audit().ListAudit(ctx, model.AuditFilter{CreatedAfter: &later, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+
+	// An offset names the same instant as UTC does.
+	earlier := time.Now().Add(-time.Hour).In(time.FixedZone("", 2*60*60))
+	page, err = s.Audit().ListAudit(ctx, model.AuditFilter{CreatedAfter: &earlier, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 3)
+
+	_, err = s.Audit().ListAudit(ctx, model.AuditFilter{Cursor: "nope", Limit: 10})
+	assert.ErrorIs(t, err, store.ErrInvalidCursor)
+}
+
+func testAuditOwners(t *testing.T, s store.Store) {
+	alice := model.WithUser(ctx, 1)
+	bob := model.WithUser(ctx, 2)
+
+	todo, err := s.Todos().CreateTodo(alice, model.Todo{ListID: inbox, Title: "Alice's todo"})
+	require.NoError(t, err)
+
+	deleted := todo
+	now := time.Now()
+	deleted.DeletedAt = &now
+
+	_, err = s.Audit().AppendAudit(alice, model.AuditEntry{TodoID: todo.ID, Action: model.AuditCreate, After: &todo})
+	require.NoError(t, err)
+	_, err = s.Audit().AppendAudit(alice, model.AuditEntry{TodoID: todo.ID, Action: model.AuditDelete, Before: &todo, After: &deleted})
+	require.NoError(t, err)
+
+	page, err := s.Audit().ListAudit(bob, model.AuditFilter{TodoID: &todo.ID, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+
+	page, err = s.Audit().ListAudit(alice, model.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 2)
+}
+
+func testAuditTx(t *testing.T, s store.Store) {
+	todo := createTodos(t, s.Todos(), "Todo")[0]
+	failure := errors.New("failure")
+
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		if _, err := tx.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: todo.ID, Action: model.AuditUpdate}); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	err = s.InTx(ctx, func(tx store.Tx) error {
+		err := tx.Savepoint(ctx, func() error {
+			if _, err := tx.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: todo.ID, Action: model.AuditToggle}); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		_, err = tx.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: todo.ID, Action: model.AuditDelete})
+		return err
+	})
+	require.NoError(t, err)
+
+	page, err := s.Audit().ListAudit(ctx, model.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1, "entries of rolled back transactions and savepoints are gone")
+	assert.Equal(t, model.AuditDelete, page.Entries[0].Action)
+}
+
+func testChanges(t *testing.T, s store.Store) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes := s.Changes().Subscribe(ctx)
+
+	// Some feeds take a moment to connect, so write until something comes
+	// through. These entries are told apart by their todo id.
+	const warmUp = model.ID(1_000_000)
+	require.Eventually(t, func() bool {
+		_, err := s.Audit().AppendAudit(ctx, model.AuditEntry{TodoID: warmUp, Action: model.AuditCreate})
+		require.NoError(t, err)
+
+		select {
+		case <-changes:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, time.Millisecond)
+
+	receive := func() model.AuditEntry {
+		t.Helper()
+		for {
+			select {
+			case entry, ok := <-changes:
+				require.True(t, ok, "subscription ended")
+				if entry.TodoID != warmUp {
+					return entry
+				}
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "no change received")
+			}
+		}
+	}
+
+	failure := errors.New("failure")
+	appendAudit := func(a store.AuditRepository, id model.ID) error {
+		_, err := a.AppendAudit(ctx, model.AuditEntry{TodoID: id, Action: model.AuditUpdate})
+		return err
+	}
+
+	err := s.InTx(ctx, func(tx store.Tx) error {
+		require.NoError(t, appendAudit(tx.Audit(), 1))
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	err = s.InTx(ctx, func(tx store.Tx) error {
+		require.NoError(t, appendAudit(tx.Audit(), 2))
+		assert.ErrorIs(t, tx.Savepoint(ctx, func() error {
+			require.NoError(t, appendAudit(tx.Audit(), 3))
+			return failure
+		}), failure)
+		return appendAudit(tx.Audit(), 4)
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, appendAudit(s.Audit(), 5))
+
+	// Rolled back entries are never published, so the next ones are
+	// those committed.
+	assert.Equal(t, model.ID(2), receive().TodoID)
+	assert.Equal(t, model.ID(4), receive().TodoID)
+
+	entry := receive()
+	assert.Equal(t, model.ID(5), entry.TodoID)
+	assert.Equal(t, model.AuditUpdate, entry.Action)
+	assert.NotZero(t, entry.ID)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		for {
+			select {
+			case _, ok := <-changes:
+				if !ok {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second, time.Millisecond, "subscription ends with its context")
+}
+
+func testWebhooks(t *testing.T, s store.Store) {
+	repo := s.Webhooks()
+
+	created, err := repo.CreateWebhook(ctx, model.Webhook{
+		URL:    "http://example.com/hook",
+		Events: []model.ChangeType{model.ChangeCreated, model.ChangeDeleted},
+		Secret: "0123456789abcdef",
+		Active: true,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, created.ID)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	other, err := repo.CreateWebhook(ctx, model.Webhook{URL: "http://example.com/other", Secret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, []model.ChangeType{}, other.Events)
+	assert.False(t, other.Active)
+
+	got, err := repo.GetWebhook(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.URL, got.URL)
+	assert.Equal(t, created.Events, got.Events)
+	assert.Equal(t, "0123456789abcdef", got.Secret)
+	assert.True(t, got.Active)
+
+	url, events, active := "http://example.com/new", []model.ChangeType{model.ChangeUpdated}, false
+	updated, err := repo.UpdateWebhook(ctx, created.ID, model.WebhookPatch{URL: &url, Events: &events, Active: &active})
+	require.NoError(t, err)
+	assert.Equal(t, url, updated.URL)
+	assert.Equal(t, events, updated.Events)
+	assert.False(t, updated.Active)
+	assert.Equal(t, "0123456789abcdef", updated.Secret, "unset fields are kept")
+
+	webhooks, err := repo.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.Equal(t, created.ID, webhooks[0].ID)
+	assert.Equal(t, other.ID, webhooks[1].ID)
+
+	require.NoError(t, repo.DeleteWebhook(ctx, other.ID))
+
+	_, err = repo.GetWebhook(ctx, other.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.UpdateWebhook(ctx, other.ID, model.WebhookPatch{URL: &url})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteWebhook(ctx, other.ID), model.ErrNotFound)
+}
+
+func testWebhookOwners(t *testing.T, s store.Store) {
+	repo := s.Webhooks()
+	alice := model.WithUser(ctx, 1)
+	bob := model.WithUser(ctx, 2)
+	now := time.Now().UTC()
+
+	webhook, err := repo.CreateWebhook(alice, model.Webhook{URL: "http://example.com/alice", Secret: "secret", Active: true, UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID(1), webhook.UserID, "webhooks belong to the user creating them")
+	_, err = repo.CreateWebhook(bob, model.Webhook{URL: "http://example.com/bob", Secret: "secret", Active: true})
+	require.NoError(t, err)
+
+	url := "http://example.com/new"
+	_, err = repo.GetWebhook(bob, webhook.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.UpdateWebhook(bob, webhook.ID, model.WebhookPatch{URL: &url})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteWebhook(bob, webhook.ID), model.ErrNotFound)
+
+	webhooks, err := repo.ListWebhooks(bob)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, "http://example.com/bob", webhooks[0].URL)
+
+	queued, err := repo.EnqueueDeliveries(ctx, 1, model.WebhookDelivery{EventID: 1, Event: model.ChangeCreated, Payload: "{}", NextAttemptAt: &now})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), queued, "only the webhooks of the owner of the todo get its changes")
+
+	page, err := repo.ListDeliveries(bob, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Deliveries)
+
+	page, err = repo.ListDeliveries(alice, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	delivery := page.Deliveries[0]
+
+	_, err = repo.Redeliver(bob, webhook.ID, delivery.ID, now)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.RecordAttempt(bob, delivery.ID, model.DeliveryAttempt{})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	claimed, err := repo.ClaimDeliveries(bob, now.Add(time.Minute), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+}
+
+func testDeliveries(t *testing.T, s store.Store) {
+	repo := s.Webhooks()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	createWebhook := func(events []model.ChangeType, active bool) model.Webhook {
+		t.Helper()
+		webhook, err := repo.CreateWebhook(ctx, model.Webhook{URL: "http://example.com/hook", Events: events, Secret: "secret", Active: active})
+		require.NoError(t, err)
+		return webhook
+	}
+
+	all := createWebhook(nil, true)
+	deletes := createWebhook([]model.ChangeType{model.ChangeDeleted}, true)
+	createWebhook(nil, false)
+
+	for i, event := range []model.ChangeType{model.ChangeCreated, model.ChangeDeleted} {
+		queued, err := repo.EnqueueDeliveries(ctx, 0, model.WebhookDelivery{
+			EventID:       int64(i + 1),
+			Event:         event,
+			Payload:       fmt.Sprintf(`{"id":%d}`, i+1),
+			NextAttemptAt: &now,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), queued, "only active webhooks subscribed to %s get it", event)
+	}
+
+	// A claimed delivery isn't claimed again until its lease runs out.
+	lease := now.Add(time.Minute)
+	claimed, err := repo.ClaimDeliveries(ctx, now, lease, 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, all.ID, claimed[0].WebhookID)
+	assert.Equal(t, model.ChangeCreated, claimed[0].Event)
+	assert.Equal(t, `{"id":1}`, claimed[0].Payload)
+	assert.Equal(t, model.DeliveryPending, claimed[0].Status)
+	require.NotNil(t, claimed[0].NextAttemptAt)
+	assert.True(t, lease.Equal(*claimed[0].NextAttemptAt))
+
+	rest, err := repo.ClaimDeliveries(ctx, now, lease, 10)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, deletes.ID, rest[0].WebhookID)
+
+	none, err := repo.ClaimDeliveries(ctx, now.Add(time.Second), lease, 10)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	status, retry := http.StatusInternalServerError, now.Add(time.Second)
+	failed, err := repo.RecordAttempt(ctx, claimed[0].ID, model.DeliveryAttempt{
+		At:             now,
+		ResponseStatus: &status,
+		Error:          "500 Internal Server Error",
+		Status:         model.DeliveryPending,
+		NextAttemptAt:  &retry,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed.Attempts)
+	require.NotNil(t, failed.ResponseStatus)
+	assert.Equal(t, status, *failed.ResponseStatus)
+	assert.Equal(t, "500 Internal Server Error", failed.LastError)
+	require.NotNil(t, failed.LastAttemptAt)
+	assert.True(t, now.Equal(*failed.LastAttemptAt))
+
+	retried, err := repo.ClaimDeliveries(ctx, retry, lease, 10)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	assert.Equal(t, claimed[0].ID, retried[0].ID)
+
+	status = http.StatusOK
+	succeeded, err := repo.RecordAttempt(ctx, claimed[0].ID, model.DeliveryAttempt{At: retry, ResponseStatus: &status, Status: model.DeliverySucceeded})
+	require.NoError(t, err)
+	assert.Equal(t, 2, succeeded.Attempts)
+	assert.Equal(t, model.DeliverySucceeded, succeeded.Status)
+	assert.Nil(t, succeeded.NextAttemptAt)
+	assert.Empty(t, succeeded.LastError)
+
+	redelivered, err := repo.Redeliver(ctx, all.ID, claimed[0].ID, now)
+	require.NoError(t, err)
+	assert.NotEqual(t, claimed[0].ID, redelivered.ID)
+	assert.Equal(t, model.DeliveryPending, redelivered.Status)
+	assert.Zero(t, redelivered.Attempts)
+	assert.Equal(t, claimed[0].Payload, redelivered.Payload)
+	require.NotNil(t, redelivered.RedeliveryOf)
+	assert.Equal(t, claimed[0].ID, *redelivered.RedeliveryOf)
+
+	_, err = repo.Redeliver(ctx, deletes.ID, claimed[0].ID, now)
+	assert.ErrorIs(t, err, model.ErrNotFound, "deliveries belong to their webhook")
+
+	var deliveries []model.WebhookDelivery
+	filter := model.DeliveryFilter{WebhookID: all.ID, Limit: 1}
+	for {
+		page, err := repo.ListDeliveries(ctx, filter)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Deliveries), filter.Limit)
+
+		deliveries = append(deliveries, page.Deliveries...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, redelivered.ID, deliveries[0].ID, "newest first")
+	assert.Equal(t, claimed[0].ID, deliveries[2].ID)
+
+	pending := model.DeliveryPending
+	page, err := repo.ListDeliveries(ctx, model.DeliveryFilter{WebhookID: all.ID, Status: &pending, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 2)
+	assert.Equal(t, redelivered.ID, page.Deliveries[0].ID)
+
+	_, err = repo.ListDeliveries(ctx, model.DeliveryFilter{WebhookID: all.ID, Cursor: "nope", Limit: 10})
+	assert.ErrorIs(t, err, store.ErrInvalidCursor)
+
+	require.NoError(t, repo.DeleteWebhook(ctx, all.ID))
+	page, err = repo.ListDeliveries(ctx, model.DeliveryFilter{WebhookID: all.ID, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Deliveries, "deliveries go with their webhook")
+}
+
+func testDeliveriesTx(t *testing.T, s store.Store) {
+	webhook, err := s.Webhooks().CreateWebhook(ctx, model.Webhook{URL: "http://example.com/hook", Secret: "secret", Active: true})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	failure := errors.New("failure")
+	enqueue := func(tx store.Tx, eventID int64) error {
+		_, err := tx.Webhooks().EnqueueDeliveries(ctx, 0, model.WebhookDelivery{EventID: eventID, Event: model.ChangeUpdated, Payload: "{}", NextAttemptAt: &now})
+		return err
+	}
+
+	err = s.InTx(ctx, func(tx store.Tx) error {
+		require.NoError(t, enqueue(tx, 1))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	err = s.InTx(ctx, func(tx store.Tx) error {
+		assert.ErrorIs(t, tx.Savepoint(ctx, func() error {
+			require.NoError(t, enqueue(tx, 2))
+			return failure
+		}), failure)
+		return enqueue(tx, 3)
+	})
+	require.NoError(t, err)
+
+	page, err := s.Webhooks().ListDeliveries(ctx, model.DeliveryFilter{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1, "deliveries of rolled back transactions and savepoints are gone")
+	assert.Equal(t, int64(3), page.Deliveries[0].EventID)
+}
+
+func testSearch(t *testing.T, repo store.TodoRepository) {
+	var todos []model.Todo
+	for _, todo := range []model.Todo{
+		{Title: "Buy milk", Description: "Whole milk from the <market>"},
+		{Title: "Call mom", Description: "Ask for the milkshake recipe"},
+		{Title: "Milkman invoice"},
+		{Title: "Write report", Description: "Quarterly numbers"},
+		{Title: "Milk the cow"},
+	} {
+		todo.ListID = inbox
+		created, err := repo.CreateTodo(ctx, todo)
+		require.NoError(t, err)
+		todos = append(todos, created)
+	}
+
+	_, err := repo.DeleteTodo(ctx, todos[4].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	search := func(query string, limit int) []model.SearchResult {
+		t.Helper()
+
+		var results []model.SearchResult
+		filter := model.SearchFilter{Query: query, Limit: limit}
+		for {
+			page, err := repo.SearchTodos(ctx, filter)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.Results), filter.Limit)
+
+			results = append(results, page.Results...)
+			if page.NextCursor == "" {
+				return results
+			}
+			filter.Cursor = page.NextCursor
+		}
+	}
+
+	resultIDs := func(results []model.SearchResult) []model.ID {
+		ids := make([]model.ID, len(results))
+		for i, result := range results {
+			ids[i] = result.Todo.ID
+		}
+		return ids
+	}
+
+	results := search("MILK", 1)
+	assert.Equal(t, []model.ID{todos[0].ID, todos[2].ID, todos[1].ID}, resultIDs(results), "title matches rank first, trashed todos are left out")
+	assert.Greater(t, results[0].Rank, results[2].Rank)
+	assert.Contains(t, results[0].Snippet, "<mark>milk</mark>")
+	assert.Contains(t, results[0].Snippet, "&lt;market&gt;", "snippets are escaped")
+	assert.Contains(t, results[2].Snippet, "<mark>milkshake</mark>")
+
+	assert.Equal(t, []model.ID{todos[0].ID}, resultIDs(search("mi, bu", 10)), "every term has to match")
+	assert.Equal(t, []model.ID{todos[3].ID}, resultIDs(search("quart rep", 10)))
+	assert.Empty(t, search("nothing", 10))
+	assert.Empty(t, search("!?", 10))
+
+	_, err = repo.SearchTodos(ctx, model.SearchFilter{Query: "milk", Cursor: "nope", Limit: 10})
+	assert.ErrorIs(t, err, store.ErrInvalidCursor)
+}
+
+func testLists(t *testing.T, s store.Store) {
+	repo := s.Lists()
+
+	work, err := repo.CreateList(ctx, model.List{Name: "Work"})
+	require.NoError(t, err)
+	assert.Equal(t, "Work", work.Name)
+	assert.Nil(t, work.ArchivedAt)
+	assert.False(t, work.CreatedAt.IsZero())
+
+	got, err := repo.GetList(ctx, work.ID)
+	require.NoError(t, err)
+	assert.Equal(t, work.ID, got.ID)
+	assert.Equal(t, "Work", got.Name)
+
+	name, archived := "Office", true
+	updated, err := repo.UpdateList(ctx, work.ID, model.ListPatch{Name: &name, Archived: &archived})
+	require.NoError(t, err)
+	assert.Equal(t, "Office", updated.Name)
+	require.NotNil(t, updated.ArchivedAt)
+
+	lists, err := repo.ListLists(ctx, model.ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, inbox, lists[0].ID, "archived lists are left out")
+
+	lists, err = repo.ListLists(ctx, model.ListFilter{Archived: true})
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, work.ID, lists[0].ID)
+
+	archived = false
+	updated, err = repo.UpdateList(ctx, work.ID, model.ListPatch{Archived: &archived})
+	require.NoError(t, err)
+	assert.Equal(t, "Office", updated.Name)
+	assert.Nil(t, updated.ArchivedAt)
+
+	_, err = repo.GetList(ctx, 99)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.UpdateList(ctx, 99, model.ListPatch{Name: &name})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteList(ctx, 99), model.ErrNotFound)
+}
+
+func testListCounts(t *testing.T, s store.Store) {
+	todos := createTree(t, s.Todos())
+
+	_, err := s.Todos().ToggleTodo(ctx, todos[1].ID, model.ToggleOptions{Cascade: true})
+	require.NoError(t, err)
+	_, err = s.Todos().DeleteTodo(ctx, todos[5].ID, model.DeleteOptions{})
+	require.NoError(t, err)
+
+	list, err := s.Lists().GetList(ctx, inbox)
+	require.NoError(t, err)
+	assert.Equal(t, model.ListCounts{Total: 5, Completed: 3}, list.Counts, "subtasks count, trashed todos don't")
+
+	lists, err := s.Lists().ListLists(ctx, model.ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, list.Counts, lists[0].Counts)
+}
+
+func testListMove(t *testing.T, s store.Store) {
+	repo := s.Todos()
+	todos := createTree(t, repo)
+
+	work, err := s.Lists().CreateList(ctx, model.List{Name: "Work"})
+	require.NoError(t, err)
+
+	moved, err := repo.UpdateTodo(ctx, todos[1].ID, model.TodoPatch{ListID: &work.ID})
+	require.NoError(t, err)
+	assert.Equal(t, work.ID, moved.ListID)
+	assert.Nil(t, moved.ParentID, "a todo leaving its parent behind moves to the top level")
+	assert.Equal(t, todos[1].Version+1, moved.Version)
+
+	for _, id := range []model.ID{todos[3].ID, todos[4].ID} {
+		todo := get(t, repo, id)
+		assert.Equal(t, work.ID, todo.ListID, "subtasks move along")
+		assert.NotNil(t, todo.ParentID)
+	}
+	for _, id := range []model.ID{todos[0].ID, todos[2].ID, todos[5].ID} {
+		assert.Equal(t, inbox, get(t, repo, id).ListID)
+	}
+
+	_, err = repo.CreateTodo(ctx, model.Todo{ListID: inbox, Title: "Todo", ParentID: &todos[1].ID})
+	assert.ErrorIs(t, err, store.ErrParentInOtherList)
+	_, err = repo.UpdateTodo(ctx, todos[5].ID, model.TodoPatch{ParentID: model.NullableOf(&todos[1].ID)})
+	assert.ErrorIs(t, err, store.ErrParentInOtherList)
+
+	moved, err = repo.UpdateTodo(ctx, todos[2].ID, model.TodoPatch{ListID: &work.ID, ParentID: model.NullableOf(&todos[4].ID)})
+	require.NoError(t, err)
+	assert.Equal(t, work.ID, moved.ListID)
+	require.NotNil(t, moved.ParentID, "a todo can move to a new parent in the list")
+	assert.Equal(t, todos[4].ID, *moved.ParentID)
+
+	_, err = repo.UpdateTodo(ctx, todos[0].ID, model.TodoPatch{ListID: &work.ID, ParentID: model.NullableOf(&todos[4].ID)})
+	require.NoError(t, err)
+	_, err = repo.UpdateTodo(ctx, todos[4].ID, model.TodoPatch{ParentID: model.NullableOf(&todos[0].ID)})
+	assert.ErrorIs(t, err, store.ErrCycle)
+
+	workTodos := listAll(t, repo, model.TodoFilter{ListID: &work.ID, SortBy: model.SortByID, Order: model.SortAsc, Limit: 10})
+	assert.Equal(t, []model.ID{todos[0].ID, todos[1].ID, todos[2].ID, todos[3].ID, todos[4].ID}, ids(workTodos))
+}
+
+func testListDelete(t *testing.T, s store.Store) {
+	work, err := s.Lists().CreateList(ctx, model.List{Name: "Work"})
+	require.NoError(t, err)
+
+	todo, err := s.Todos().CreateTodo(ctx, model.Todo{ListID: work.ID, Title: "Todo"})
+	require.NoError(t, err)
+	subtask, err := s.Todos().CreateTodo(ctx, model.Todo{ListID: work.ID, Title: "Subtask", ParentID: &todo.ID})
+	require.NoError(t, err)
+	other := createTodos(t, s.Todos(), "Other")[0]
+
+	assert.ErrorIs(t, s.Lists().DeleteList(ctx, work.ID), store.ErrListNotEmpty)
+
+	_, err = s.Todos().DeleteTodo(ctx, todo.ID, model.DeleteOptions{Cascade: true})
+	require.NoError(t, err)
+	require.NoError(t, s.Lists().DeleteList(ctx, work.ID))
+
+	_, err = s.Lists().GetList(ctx, work.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	for _, id := range []model.ID{todo.ID, subtask.ID} {
+		_, err = s.Todos().LockTodo(ctx, id)
+		assert.ErrorIs(t, err, model.ErrNotFound, "the trashed todos of the list are purged")
+	}
+	assert.Equal(t, other.ID, get(t, s.Todos(), other.ID).ID)
+}
+
+func testListOwners(t *testing.T, s store.Store) {
+	alice := model.WithUser(ctx, 1)
+	bob := model.WithUser(ctx, 2)
+
+	list, err := s.Lists().CreateList(alice, model.List{Name: "Alice's list", UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID(1), list.UserID, "lists belong to the user creating them")
+
+	_, err = s.Lists().GetList(bob, list.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = s.Lists().UpdateList(bob, list.ID, model.ListPatch{Name: &list.Name})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	assert.ErrorIs(t, s.Lists().DeleteList(bob, list.ID), model.ErrNotFound)
+
+	lists, err := s.Lists().ListLists(bob, model.ListFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, lists)
+
+	lists, err = s.Lists().ListLists(alice, model.ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, list.ID, lists[0].ID)
+
+	lists, err = s.Lists().ListLists(ctx, model.ListFilter{})
+	require.NoError(t, err)
+	assert.Len(t, lists, 2, "without a user all lists are seen")
+}
+
+func testReminders(t *testing.T, s store.Store) {
+	repo := s.Reminders()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	from := now.Add(-time.Hour)
+
+	create := func(title string, due time.Time) model.Todo {
+		t.Helper()
+		todo, err := s.Todos().CreateTodo(ctx, model.Todo{ListID: inbox, Title: title, DueAt: &due, DueTimezone: "Europe/Berlin"})
+		require.NoError(t, err)
+		return todo
+	}
+
+	soon := create("Soon", now.Add(30*time.Minute))
+	sooner := create("Sooner", now.Add(10*time.Minute))
+	create("Later", now.Add(2*time.Hour))
+	create("Missed", now.Add(-2*time.Hour))
+	done := create("Done", now.Add(20*time.Minute))
+	_, err := s.Todos().ToggleTodo(ctx, done.ID, model.ToggleOptions{})
+	require.NoError(t, err)
+	deleted := create("Deleted", now.Add(20*time.Minute))
+	_, err = s.Todos().DeleteTodo(ctx, deleted.ID, model.DeleteOptions{})
+	require.NoError(t, err)
+	_, err = s.Todos().CreateTodo(ctx, model.Todo{ListID: inbox, Title: "No due date"})
+	require.NoError(t, err)
+
+	claimed, err := repo.ClaimReminders(ctx, time.Hour, from, now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "only open todos due within the hour")
+	assert.Equal(t, sooner.ID, claimed[0].TodoID, "in due order")
+	assert.Equal(t, soon.ID, claimed[1].TodoID)
+	assert.Equal(t, time.Hour, claimed[0].Offset)
+	assert.True(t, sooner.DueAt.Equal(claimed[0].DueAt))
+	assert.Equal(t, "Sooner", claimed[0].Todo.Title)
+	assert.Equal(t, "Europe/Berlin", claimed[0].Todo.DueTimezone)
+	assert.Nil(t, claimed[0].SentAt)
+
+	again, err := repo.ClaimReminders(ctx, time.Hour, from, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, again, "a reminder is claimed once")
+
+	at, err := repo.ClaimReminders(ctx, 0, from, now.Add(15*time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, at, 1, "every offset has reminders of its own")
+	assert.Equal(t, sooner.ID, at[0].TodoID)
+
+	// Another due date is another reminder.
+	later := now.Add(40 * time.Minute)
+	_, err = s.Todos().UpdateTodo(ctx, soon.ID, model.TodoPatch{DueAt: model.NullableOf(&later)})
+	require.NoError(t, err)
+	moved, err := repo.ClaimReminders(ctx, time.Hour, from, now, 10)
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+	assert.Equal(t, soon.ID, moved[0].TodoID)
+
+	require.NoError(t, repo.RecordReminder(ctx, claimed[0].ID, now, ""))
+	require.NoError(t, repo.RecordReminder(ctx, claimed[1].ID, now, "connection refused"))
+	assert.ErrorIs(t, repo.RecordReminder(ctx, 999, now, ""), model.ErrNotFound)
+}
+
+func testLeases(t *testing.T, s store.Store) {
+	repo := s.Leases()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	got, err := repo.AcquireLease(ctx, "reminders", "a", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got)
+
+	got, err = repo.AcquireLease(ctx, "reminders", "b", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, got, "the lease is held by a")
+
+	got, err = repo.AcquireLease(ctx, "purge", "b", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got, "leases are by name")
+
+	got, err = repo.AcquireLease(ctx, "reminders", "a", now.Add(30*time.Second), now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got, "the holder renews its lease")
+
+	got, err = repo.AcquireLease(ctx, "reminders", "b", now.Add(time.Minute), now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.False(t, got, "the renewed lease runs longer")
+
+	got, err = repo.AcquireLease(ctx, "reminders", "b", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got, "an expired lease is taken over")
+
+	require.NoError(t, repo.ReleaseLease(ctx, "reminders", "a"))
+	got, err = repo.AcquireLease(ctx, "reminders", "a", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.False(t, got, "only the holder releases a lease")
+
+	require.NoError(t, repo.ReleaseLease(ctx, "reminders", "b"))
+	got, err = repo.AcquireLease(ctx, "reminders", "a", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"crud/internal/model"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// userScope is the condition that keeps a query to the rows of the user
+// ctx acts for, or one every row meets if it acts for none. The id is a
+// number, so it goes into the query as is.
+func userScope(ctx context.Context) string {
+	user, ok := model.UserFrom(ctx)
+	if !ok {
+		return "1 = 1"
+	}
+	return fmt.Sprintf("user_id = %d", user)
+}
+
+// ownerOf returns the owner of a new todo at the top level or a new
+// list: the user ctx acts for, or else owner, the one it comes with.
+func ownerOf(ctx context.Context, owner model.UserID) model.UserID {
+	if user, ok := model.UserFrom(ctx); ok {
+		return user
+	}
+	return owner
+}
+
+// localizeDue shows the due date of todo in its own timezone.
+func localizeDue(todo *model.Todo) {
+	if todo.DueAt == nil || todo.DueTimezone == "" {
+		return
+	}
+
+	if location, err := time.LoadLocation(todo.DueTimezone); err == nil {
+		dueAt := todo.DueAt.In(location)
+		todo.DueAt = &dueAt
+	}
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// checkVersion tells a write that found no todo at the expected version
+// apart from one that found no todo at all: if the todo exists, err
+// becomes a *model.VersionConflictError carrying it.
+func checkVersion(ctx context.Context, repo TodoRepository, id model.ID, ifVersion *int64, err error) error {
+	if ifVersion == nil || !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	todos, getErr := repo.GetTodos(ctx, []model.ID{id})
+	if getErr != nil {
+		return getErr
+	}
+	if len(todos) == 0 {
+		return err
+	}
+
+	return &model.VersionConflictError{Current: todos[0]}
+}
@@ -0,0 +1,32 @@
+package store
+
+import "crud/internal/model"
+
+func nonNilEvents(events []model.ChangeType) []model.ChangeType {
+	if events == nil {
+		return []model.ChangeType{}
+	}
+	return events
+}
+
+func nonNilDeliveries(deliveries []model.WebhookDelivery) []model.WebhookDelivery {
+	if deliveries == nil {
+		return []model.WebhookDelivery{}
+	}
+	return deliveries
+}
+
+// deliveryPageOf cuts the extra delivery fetched past the limit off
+// deliveries and turns it into a cursor for the next page.
+func deliveryPageOf(filter model.DeliveryFilter, deliveries []model.WebhookDelivery) model.DeliveryPage {
+	if len(deliveries) <= filter.Limit {
+		return model.DeliveryPage{Deliveries: deliveries}
+	}
+
+	deliveries = deliveries[:filter.Limit]
+
+	return model.DeliveryPage{
+		Deliveries: deliveries,
+		NextCursor: encodeIDCursor(deliveries[len(deliveries)-1].ID),
+	}
+}
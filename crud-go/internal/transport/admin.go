@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// AdminServer serves the operational endpoints, /metrics in the
+// Prometheus text format, on an address of their own so that they
+// needn't be exposed along with the API.
+type AdminServer struct {
+	config *HttpConfig
+	logger *slog.Logger
+	router *mux.Router
+	server *http.Server
+}
+
+func NewAdminServer(logger *slog.Logger, config *HttpConfig, gatherer prometheus.Gatherer) *AdminServer {
+	s := &AdminServer{
+		config: config,
+		logger: logger,
+		router: mux.NewRouter(),
+	}
+
+	s.router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+	s.router.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})).Methods("GET").Name("metrics")
+
+	s.server = &http.Server{
+		Addr:         config.AdminBindAddress,
+		Handler:      s.router,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+
+	return s
+}
+
+// Start serves until Shutdown is called, then returns nil.
+func (s *AdminServer) Start() error {
+	s.logger.Info("Starting admin server", "bind_address", s.config.AdminBindAddress)
+
+	if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown waits for in-flight scrapes until ctx is done.
+func (s *AdminServer) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+
+	return nil
+}
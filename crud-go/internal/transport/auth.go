@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/service"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+
+	// userIDClaim holds the user a token of the auth service was issued
+	// to.
+	userIDClaim = "user_id"
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid bearer token")
+)
+
+// publicRoutes are the probes, which answer without a token.
+var publicRoutes = map[string]bool{
+	"healthz": true,
+	"readyz":  true,
+}
+
+// RequireAuth makes every request but the probes carry a bearer token
+// signed with secret, as the auth service issues them. It is called
+// before Start.
+func (s *HttpServer) RequireAuth(secret []byte) {
+	s.authSecret = secret
+}
+
+// withAuth puts the user of the request's token into its context, so
+// the stores only see the todos of that user. Without a token, or with
+// one that is forged or expired, the request is answered 401. The user
+// is also the actor of the request.
+func (s *HttpServer) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authSecret == nil || publicRoutes[routeName(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := authenticate(s.authSecret, r.Header.Get(headerAuthorization))
+		if err != nil {
+			challenge := "Bearer"
+			if errors.Is(err, errInvalidToken) {
+				challenge = `Bearer error="invalid_token"`
+			}
+			w.Header().Set(headerWWWAuthenticate, challenge)
+			writeProblem(w, r, Problem{
+				Type:   ProblemUnauthorized,
+				Title:  "Unauthorized",
+				Status: http.StatusUnauthorized,
+				Detail: err.Error(),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+// withUser makes ctx act for user, who is also the actor. The actor the
+// request names is not kept: anyone holding a token could put another
+// name into the audit trail with it.
+func withUser(ctx context.Context, user model.UserID) context.Context {
+	info := service.RequestInfoFrom(ctx)
+	info.Actor = fmt.Sprintf("user:%d", user)
+	return service.WithRequestInfo(model.WithUser(ctx, user), info)
+}
+
+// authenticate returns the user of the bearer token in authorization,
+// the value of an Authorization header, if it is signed with secret.
+// Tokens must be HS256 and expire.
+func authenticate(secret []byte, authorization string) (model.UserID, error) {
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return 0, errMissingToken
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims,
+		func(*jwt.Token) (any, error) { return secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+
+	// JSON numbers decode as float64.
+	id, ok := claims[userIDClaim].(float64)
+	if !ok || id < 1 || id != math.Trunc(id) {
+		return 0, errInvalidToken
+	}
+
+	return model.UserID(id), nil
+}
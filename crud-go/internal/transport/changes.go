@@ -0,0 +1,236 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamWriteTimeout bounds a single write to a change stream, so a
+// client that stopped reading doesn't hold the feed forever.
+const streamWriteTimeout = 10 * time.Second
+
+// upgrader only accepts same-origin browser connections.
+var upgrader = websocket.Upgrader{}
+
+type WatchChangesRequest struct {
+	TodoIDs []model.ID `json:"todoIds,omitempty" validate:"omitempty,max=100,dive,min=1"`
+	Types   []string   `json:"types,omitempty" validate:"omitempty,dive,oneof=created updated deleted"`
+	Tags    []string   `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=32"`
+	// LastEventID is the id of the last change the client got.
+	LastEventID int64 `json:"lastEventId,omitempty" validate:"omitempty,min=0"`
+}
+
+func (r *WatchChangesRequest) filter() model.ChangeFilter {
+	filter := model.ChangeFilter{
+		TodoIDs: r.TodoIDs,
+		Tags:    r.Tags,
+		AfterID: r.LastEventID,
+	}
+	for _, t := range r.Types {
+		filter.Types = append(filter.Types, model.ChangeType(t))
+	}
+	return filter
+}
+
+// decodeWatchChangesRequest reads WatchChangesRequest from the URL query.
+// The Last-Event-ID header, sent by browsers reconnecting an event
+// stream, wins over the lastEventId parameter.
+func decodeWatchChangesRequest(r *http.Request) (*WatchChangesRequest, error) {
+	query := r.URL.Query()
+	req := &WatchChangesRequest{
+		Types: splitQuery(query["type"]),
+		Tags:  splitQuery(query["tags"]),
+	}
+
+	for _, v := range splitQuery(query["todoId"]) {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("todoId: %w", err)
+		}
+		req.TodoIDs = append(req.TodoIDs, model.ID(id))
+	}
+
+	lastEventID, name := r.Header.Get("Last-Event-ID"), "Last-Event-ID"
+	if lastEventID == "" {
+		lastEventID, name = query.Get("lastEventId"), "lastEventId"
+	}
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		req.LastEventID = id
+	}
+
+	return req, nil
+}
+
+// watchChanges streams todo changes as Server-Sent Events, or as JSON
+// messages when the request is a WebSocket upgrade.
+func (s *HttpServer) watchChanges(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeWatchChangesRequest(r)
+	if err != nil {
+		writeProblem(w, r, malformedRequestProblem(err))
+		return
+	}
+
+	if err := validateRequest(req); err != nil {
+		writeProblem(w, r, validationProblem(err))
+		return
+	}
+
+	ctx, cancel := s.streamContext(r.Context())
+	defer cancel()
+
+	changes, err := s.service.WatchChanges(ctx, req.filter())
+	if err != nil {
+		problem := errorProblem(err)
+		if problem.Status >= http.StatusInternalServerError {
+			s.logger.Error("Watch changes error", "error", err.Error())
+		}
+		writeProblem(w, r, problem)
+		return
+	}
+
+	s.logger.Debug("Streaming changes", "last_event_id", req.LastEventID)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamWebSocket(ctx, cancel, w, r, changes)
+		return
+	}
+
+	s.streamEvents(ctx, w, changes)
+}
+
+// streamContext is cancelled when the server starts shutting down, as
+// streams would otherwise keep it from draining.
+func (s *HttpServer) streamContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+func (s *HttpServer) streamEvents(ctx context.Context, w http.ResponseWriter, changes <-chan model.Change) {
+	rc := http.NewResponseController(w)
+	// The server write timeout is meant for single responses.
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat, stop := s.heartbeat()
+	defer stop()
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(change)
+			if err != nil {
+				s.logger.Error("Generating change event error", "error", err.Error())
+				return
+			}
+
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+		case <-heartbeat:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-ctx.Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *HttpServer) streamWebSocket(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, r *http.Request, changes <-chan model.Change) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered with an error.
+		return
+	}
+	defer conn.Close()
+
+	// Clients only ever send control frames. Reading handles them and
+	// notices when the client goes away; one that stops answering pings
+	// is gone too.
+	if s.config.StreamHeartbeat > 0 {
+		conn.SetReadDeadline(time.Now().Add(2 * s.config.StreamHeartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * s.config.StreamHeartbeat))
+		})
+	}
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat, stop := s.heartbeat()
+	defer stop()
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				closeWebSocket(conn, websocket.CloseTryAgainLater, "feed interrupted, resume with lastEventId")
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(change); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			closeWebSocket(conn, websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+// heartbeat ticks every StreamHeartbeat, or never if it is zero.
+func (s *HttpServer) heartbeat() (<-chan time.Time, func()) {
+	if s.config.StreamHeartbeat <= 0 {
+		return nil, func() {}
+	}
+
+	ticker := time.NewTicker(s.config.StreamHeartbeat)
+	return ticker.C, ticker.Stop
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, text string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(streamWriteTimeout))
+}
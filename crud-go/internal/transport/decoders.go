@@ -1,59 +1,115 @@
 package transport
 
 import (
+	"context"
 	"crud/internal/model"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+// newValidator reports fields by their JSON names, so validation
+// problems refer to what the client actually sent.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// statusCoder is implemented by responses that succeed with a status
+// other than 200 OK.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// etagger is implemented by responses carrying a single todo, whose
+// version is sent as the ETag header.
+type etagger interface {
+	ETag() string
+}
+
+// etag is the entity tag of a todo at its current version.
+func etag(todo model.Todo) string {
+	return `"` + strconv.FormatInt(todo.Version, 10) + `"`
+}
 
 func pipe[Request any, Response any](
 	decoder func(*http.Request) (*Request, error),
-	endpoint func(*Request) (Response, error),
+	endpoint func(context.Context, *Request) (Response, error),
 	encoder func(data interface{}) ([]byte, error),
 	logger *slog.Logger,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("Piping request")
 
-		w.Header().Set("Content-Type", "application/json")
-
 		decoded, err := decoder(r)
 		if err != nil {
 			logger.Debug("Request parse error", "error", err.Error())
-			w.Header().Set("status", "400")
-			w.Write([]byte("Error while decode incoming request"))
+			writeProblem(w, r, malformedRequestProblem(err))
 			return
 		}
 
 		if err := validateRequest(decoded); err != nil {
 			logger.Debug("Request validate error", "error", err.Error())
-			w.Header().Set("status", "400")
-			w.Write([]byte("Invalid request " + err.Error()))
+			writeProblem(w, r, validationProblem(err))
 			return
 		}
 
-		response, err := endpoint(decoded)
+		ctx := r.Context()
+
+		response, err := endpoint(ctx, decoded)
 		if err != nil {
-			logger.Debug("Request process error", "error", err.Error())
-			w.Header().Set("status", "500")
-			w.Write([]byte(err.Error()))
+			// Drivers don't always report a cancelled query as a context
+			// error, so make sure the reason is not lost.
+			if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+				err = fmt.Errorf("%w: %w", ctxErr, err)
+			}
+
+			problem := errorProblem(err)
+			if problem.Status >= http.StatusInternalServerError {
+				logger.Error("Request process error", "error", err.Error())
+			} else {
+				logger.Debug("Request process error", "error", err.Error())
+			}
+			writeProblem(w, r, problem)
 			return
 		}
 
 		encoded, err := encoder(response)
 		if err != nil {
-			logger.Debug("Generating response string error", "error", err.Error())
-			w.Header().Set("status", "500")
-			w.Write([]byte("Error while encode response"))
+			logger.Error("Generating response string error", "error", err.Error())
+			writeProblem(w, r, errorProblem(err))
 			return
 		}
 
-		w.Header().Set("status", "200")
+		status := http.StatusOK
+		if s, ok := any(response).(statusCoder); ok {
+			status = s.StatusCode()
+		}
+
+		if e, ok := any(response).(etagger); ok {
+			w.Header().Set("ETag", e.ETag())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
 		w.Write(encoded)
 
 		logger.Debug("Request processed succesfully")
@@ -66,6 +122,255 @@ func decodeRequest[T any](r *http.Request) (*T, error) {
 		return nil, err
 	}
 
+	if err := decodeIfMatch(r, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// idRequest is implemented by requests addressing a single todo, so the
+// id can be taken from the /todos/{id} path.
+type idRequest interface {
+	setId(id model.ID)
+}
+
+// decodeIdRequest decodes an optional JSON body and fills the todo id
+// from the route path, which always wins over an id in the body.
+func decodeIdRequest[T any, PT interface {
+	*T
+	idRequest
+}](r *http.Request) (*T, error) {
+	req := PT(new(T))
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+	req.setId(model.ID(id))
+
+	if v := r.URL.Query().Get("cascade"); v != "" {
+		if c, ok := any(req).(cascadeRequest); ok {
+			cascade, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("cascade: %w", err)
+			}
+			c.setCascade(cascade)
+		}
+	}
+
+	if err := decodeIfMatch(r, req); err != nil {
+		return nil, err
+	}
+
+	return (*T)(req), nil
+}
+
+// cascadeRequest is implemented by requests that may also apply to the
+// subtasks of a todo. The flag can be given as a cascade query parameter.
+type cascadeRequest interface {
+	setCascade(cascade bool)
+}
+
+// versionRequest is implemented by requests that write a todo only if
+// it is still at the version the client last saw.
+type versionRequest interface {
+	setVersion(version int64)
+}
+
+// decodeIfMatch takes the expected version from an If-Match header
+// holding an ETag sent by the server; the header wins over a version in
+// the body. "*" matches any version, like no header at all.
+func decodeIfMatch(r *http.Request, req any) error {
+	v, ok := req.(versionRequest)
+	if !ok {
+		return nil
+	}
+
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return nil
+	}
+
+	version, ok := strings.CutPrefix(tag, `"`)
+	if version, ok = strings.CutSuffix(version, `"`); !ok {
+		return fmt.Errorf("If-Match: %q is not a single strong entity tag", tag)
+	}
+
+	n, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return fmt.Errorf("If-Match: %q is not an entity tag of this server", tag)
+	}
+	v.setVersion(n)
+
+	return nil
+}
+
+// splitQuery returns the values of a query parameter that may be
+// repeated, comma separated, or both.
+func splitQuery(values []string) []string {
+	var ret []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+// decodeListTodosRequest reads ListTodosRequest from the URL query, so
+// list pages can be fetched with a plain GET and bookmarked.
+func decodeListTodosRequest(r *http.Request) (*ListTodosRequest, error) {
+	query := r.URL.Query()
+	req := &ListTodosRequest{
+		Title:  query.Get("title"),
+		SortBy: query.Get("sortBy"),
+		Order:  query.Get("order"),
+		Cursor: query.Get("cursor"),
+	}
+
+	if v := query.Get("listId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("listId: %w", err)
+		}
+		listID := model.ID(id)
+		req.ListID = &listID
+	}
+
+	if v := query.Get("seriesId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("seriesId: %w", err)
+		}
+		seriesID := model.ID(id)
+		req.SeriesID = &seriesID
+	}
+
+	if v := query.Get("complete"); v != "" {
+		complete, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("complete: %w", err)
+		}
+		req.Complete = &complete
+	}
+
+	if v := query.Get("createdAfter"); v != "" {
+		createdAfter, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("createdAfter: %w", err)
+		}
+		req.CreatedAfter = &createdAfter
+	}
+
+	if v := query.Get("createdBefore"); v != "" {
+		createdBefore, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("createdBefore: %w", err)
+		}
+		req.CreatedBefore = &createdBefore
+	}
+
+	if v := query.Get("dueAfter"); v != "" {
+		dueAfter, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("dueAfter: %w", err)
+		}
+		req.DueAfter = &dueAfter
+	}
+
+	if v := query.Get("dueBefore"); v != "" {
+		dueBefore, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("dueBefore: %w", err)
+		}
+		req.DueBefore = &dueBefore
+	}
+
+	if v := query.Get("minPriority"); v != "" {
+		var minPriority model.Priority
+		if err := minPriority.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("minPriority: %w", err)
+		}
+		req.MinPriority = &minPriority
+	}
+
+	req.Tags = splitQuery(query["tags"])
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		req.Limit = limit
+	}
+
+	return req, nil
+}
+
+// decodeSearchTodosRequest reads SearchTodosRequest from the URL query.
+func decodeSearchTodosRequest(r *http.Request) (*SearchTodosRequest, error) {
+	query := r.URL.Query()
+	req := &SearchTodosRequest{
+		Query:  query.Get("q"),
+		Cursor: query.Get("cursor"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		req.Limit = limit
+	}
+
+	return req, nil
+}
+
+// decodeListAuditRequest reads ListAuditRequest from the URL query. On
+// /todos/{id}/history the todo is taken from the path.
+func decodeListAuditRequest(r *http.Request) (*ListAuditRequest, error) {
+	query := r.URL.Query()
+	req := &ListAuditRequest{Cursor: query.Get("cursor")}
+
+	if v, ok := mux.Vars(r)["id"]; ok {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("id: %w", err)
+		}
+		todoID := model.ID(id)
+		req.TodoID = &todoID
+	}
+
+	if v := query.Get("createdAfter"); v != "" {
+		createdAfter, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("createdAfter: %w", err)
+		}
+		req.CreatedAfter = &createdAfter
+	}
+
+	if v := query.Get("createdBefore"); v != "" {
+		createdBefore, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("createdBefore: %w", err)
+		}
+		req.CreatedBefore = &createdBefore
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		req.Limit = limit
+	}
+
 	return req, nil
 }
 
@@ -85,36 +390,305 @@ type GetTodosResponse struct {
 	Todos []model.Todo `json:"todos"`
 }
 
+type ListTodosRequest struct {
+	ListID        *model.ID       `json:"listId,omitempty" validate:"omitempty,min=1"`
+	SeriesID      *model.ID       `json:"seriesId,omitempty" validate:"omitempty,min=1"`
+	Complete      *bool           `json:"complete,omitempty" validate:"omitempty"`
+	Title         string          `json:"title,omitempty" validate:"omitempty,max=30"`
+	CreatedAfter  *time.Time      `json:"createdAfter,omitempty" validate:"omitempty"`
+	CreatedBefore *time.Time      `json:"createdBefore,omitempty" validate:"omitempty"`
+	DueAfter      *time.Time      `json:"dueAfter,omitempty" validate:"omitempty"`
+	DueBefore     *time.Time      `json:"dueBefore,omitempty" validate:"omitempty"`
+	MinPriority   *model.Priority `json:"minPriority,omitempty" validate:"omitempty"`
+	Tags          []string        `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=32"`
+	SortBy        string          `json:"sortBy,omitempty" validate:"omitempty,oneof=id created_at updated_at due_at priority"`
+	Order         string          `json:"order,omitempty" validate:"omitempty,oneof=asc desc"`
+	Cursor        string          `json:"cursor,omitempty" validate:"omitempty"`
+	Limit         int             `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *ListTodosRequest) filter() model.TodoFilter {
+	return model.TodoFilter{
+		ListID:        r.ListID,
+		SeriesID:      r.SeriesID,
+		Complete:      r.Complete,
+		TitleContains: r.Title,
+		CreatedAfter:  r.CreatedAfter,
+		CreatedBefore: r.CreatedBefore,
+		DueAfter:      r.DueAfter,
+		DueBefore:     r.DueBefore,
+		MinPriority:   r.MinPriority,
+		Tags:          r.Tags,
+		SortBy:        model.TodoSortField(r.SortBy),
+		Order:         model.SortOrder(r.Order),
+		Cursor:        r.Cursor,
+		Limit:         r.Limit,
+	}
+}
+
+type ListTodosResponse struct {
+	Todos      []model.Todo `json:"todos"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// SearchTodosRequest matches every word of Query against the beginnings
+// of the words of titles and descriptions.
+type SearchTodosRequest struct {
+	Query  string `json:"q" validate:"required,max=200"`
+	Cursor string `json:"cursor,omitempty" validate:"omitempty"`
+	Limit  int    `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *SearchTodosRequest) filter() model.SearchFilter {
+	return model.SearchFilter{
+		Query:  r.Query,
+		Cursor: r.Cursor,
+		Limit:  r.Limit,
+	}
+}
+
+type SearchTodosResponse struct {
+	Results    []model.SearchResult `json:"results"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// CreateTodoRequest puts the todo in ListID, which subtasks share with
+// their parent. A todo with a Recurrence needs a DueAt.
 type CreateTodoRequest struct {
-	Title string `json:"title" validate:"required,min=3,max=30"`
+	ListID      model.ID       `json:"listId" validate:"required,min=1"`
+	ParentID    *model.ID      `json:"parentId,omitempty" validate:"omitempty,min=1"`
+	Title       string         `json:"title" validate:"required,min=3,max=30"`
+	Description string         `json:"description,omitempty" validate:"omitempty,max=10000"`
+	Priority    model.Priority `json:"priority,omitempty" validate:"omitempty"`
+	DueAt       *time.Time     `json:"dueAt,omitempty" validate:"omitempty"`
+	DueTimezone string         `json:"dueTimezone,omitempty" validate:"omitempty,timezone"`
+	Recurrence  string         `json:"recurrence,omitempty" validate:"omitempty,max=500"`
+	Tags        []string       `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=32"`
+}
+
+func (r *CreateTodoRequest) todo() model.Todo {
+	return model.Todo{
+		ListID:      r.ListID,
+		ParentID:    r.ParentID,
+		Title:       r.Title,
+		Description: r.Description,
+		Priority:    r.Priority,
+		DueAt:       r.DueAt,
+		DueTimezone: r.DueTimezone,
+		Recurrence:  r.Recurrence,
+		Tags:        r.Tags,
+	}
 }
 
 type CreateTodoResponse struct {
 	Todo model.Todo `json:"todo"`
 }
 
-type ToggleTodoRequest struct {
+func (r CreateTodoResponse) ETag() string { return etag(r.Todo) }
+
+func (CreateTodoResponse) StatusCode() int { return http.StatusCreated }
+
+type GetTodoRequest struct {
+	Id model.ID `json:"id" validate:"required,min=1"`
+}
+
+func (r *GetTodoRequest) setId(id model.ID) { r.Id = id }
+
+type GetTodoResponse struct {
+	Todo model.Todo `json:"todo"`
+}
+
+func (r GetTodoResponse) ETag() string { return etag(r.Todo) }
+
+type GetTodoTreeRequest struct {
 	Id model.ID `json:"id" validate:"required,min=1"`
 }
 
+func (r *GetTodoTreeRequest) setId(id model.ID) { r.Id = id }
+
+type GetTodoTreeResponse struct {
+	Todo model.TodoTree `json:"todo"`
+}
+
+// ToggleTodoRequest with Cascade sets all subtasks to the new state of
+// the todo. Like updates and deletes, it fails with 412 if Version, or
+// the If-Match header, doesn't match the version of the todo.
+type ToggleTodoRequest struct {
+	Id      model.ID `json:"id" validate:"required,min=1"`
+	Cascade bool     `json:"cascade,omitempty"`
+	Version *int64   `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+func (r *ToggleTodoRequest) setId(id model.ID) { r.Id = id }
+
+func (r *ToggleTodoRequest) setCascade(cascade bool) { r.Cascade = cascade }
+
+func (r *ToggleTodoRequest) setVersion(version int64) { r.Version = &version }
+
+func (r *ToggleTodoRequest) options() model.ToggleOptions {
+	return model.ToggleOptions{Cascade: r.Cascade, IfVersion: r.Version}
+}
+
 type ToggleTodoResponse struct {
 	Todo model.Todo `json:"todo"`
 }
 
+func (r ToggleTodoResponse) ETag() string { return etag(r.Todo) }
+
+// UpdateTodoRequest changes only the fields present in the body. A null
+// dueAt clears the due date and a null parentId makes a top level todo.
+// A new listId moves the todo with its subtasks. An empty recurrence
+// stops the todo from recurring. Scope "series" applies the title,
+// description, priority, dueTimezone, recurrence and tags to the open
+// occurrences of the series of the todo as well.
 type UpdateTodoRequest struct {
-	Id       model.ID `json:"id" validate:"required,min=1"`
-	Title    *string  `json:"title,omitempty" validate:"omitempty,min=3,max=30"`
-	Complete *bool    `json:"complete,omitempty" validate:"omitempty"`
+	Id          model.ID                  `json:"id" validate:"required,min=1"`
+	ListID      *model.ID                 `json:"listId,omitempty" validate:"omitempty,min=1"`
+	ParentID    model.Nullable[model.ID]  `json:"parentId"`
+	Title       *string                   `json:"title,omitempty" validate:"omitempty,min=3,max=30"`
+	Description *string                   `json:"description,omitempty" validate:"omitempty,max=10000"`
+	Complete    *bool                     `json:"complete,omitempty" validate:"omitempty"`
+	Priority    *model.Priority           `json:"priority,omitempty" validate:"omitempty"`
+	DueAt       model.Nullable[time.Time] `json:"dueAt"`
+	DueTimezone *string                   `json:"dueTimezone,omitempty" validate:"omitempty,timezone"`
+	Recurrence  *string                   `json:"recurrence,omitempty" validate:"omitempty,max=500"`
+	Tags        *[]string                 `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=32"`
+	Version     *int64                    `json:"version,omitempty" validate:"omitempty,min=1"`
+	Scope       string                    `json:"scope,omitempty" validate:"omitempty,oneof=occurrence series"`
+}
+
+func (r *UpdateTodoRequest) setId(id model.ID) { r.Id = id }
+
+func (r *UpdateTodoRequest) setVersion(version int64) { r.Version = &version }
+
+func (r *UpdateTodoRequest) patch() model.TodoPatch {
+	return model.TodoPatch{
+		IfVersion:   r.Version,
+		ListID:      r.ListID,
+		ParentID:    r.ParentID,
+		Title:       r.Title,
+		Description: r.Description,
+		Complete:    r.Complete,
+		Priority:    r.Priority,
+		DueAt:       r.DueAt,
+		DueTimezone: r.DueTimezone,
+		Recurrence:  r.Recurrence,
+		Tags:        r.Tags,
+		Series:      r.Scope == "series",
+	}
 }
 
 type UpdateTodoResponse struct {
 	Todo model.Todo `json:"todo"`
 }
 
+func (r UpdateTodoResponse) ETag() string { return etag(r.Todo) }
+
+// DeleteTodoRequest with Cascade deletes all subtasks too. Otherwise
+// the children of the todo move up to its parent.
 type DeleteTodoRequest struct {
-	Id model.ID `json:"id" validate:"required,min=1"`
+	Id      model.ID `json:"id" validate:"required,min=1"`
+	Cascade bool     `json:"cascade,omitempty"`
+	Version *int64   `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+func (r *DeleteTodoRequest) setId(id model.ID) { r.Id = id }
+
+func (r *DeleteTodoRequest) setCascade(cascade bool) { r.Cascade = cascade }
+
+func (r *DeleteTodoRequest) setVersion(version int64) { r.Version = &version }
+
+func (r *DeleteTodoRequest) options() model.DeleteOptions {
+	return model.DeleteOptions{Cascade: r.Cascade, IfVersion: r.Version}
 }
 
 type DeleteTodoResponse struct {
 	Todo model.Todo `json:"todo"`
 }
+
+// RestoreTodoRequest takes a todo out of the trash, along with the
+// subtasks deleted with it.
+type RestoreTodoRequest struct {
+	Id model.ID `json:"id" validate:"required,min=1"`
+}
+
+func (r *RestoreTodoRequest) setId(id model.ID) { r.Id = id }
+
+type RestoreTodoResponse struct {
+	Todo model.Todo `json:"todo"`
+}
+
+func (r RestoreTodoResponse) ETag() string { return etag(r.Todo) }
+
+type ListAuditRequest struct {
+	TodoID        *model.ID  `json:"todoId,omitempty" validate:"omitempty,min=1"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty" validate:"omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty" validate:"omitempty"`
+	Cursor        string     `json:"cursor,omitempty" validate:"omitempty"`
+	Limit         int        `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *ListAuditRequest) filter() model.AuditFilter {
+	return model.AuditFilter{
+		TodoID:        r.TodoID,
+		CreatedAfter:  r.CreatedAfter,
+		CreatedBefore: r.CreatedBefore,
+		Cursor:        r.Cursor,
+		Limit:         r.Limit,
+	}
+}
+
+type ListAuditResponse struct {
+	Entries    []model.AuditEntry `json:"entries"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+// BatchRequest runs mixed operations in one transaction. In the atomic
+// mode, the default, either all of them succeed or none does; in the
+// best effort mode every operation succeeds or fails on its own.
+type BatchRequest struct {
+	Mode       string           `json:"mode,omitempty" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+// BatchOperation carries the request of its op under the key of the
+// same name, e.g. {"op": "toggle", "toggle": {"id": 1}}.
+type BatchOperation struct {
+	Op     string             `json:"op" validate:"required,oneof=create update toggle delete"`
+	Create *CreateTodoRequest `json:"create,omitempty" validate:"required_if=Op create"`
+	Update *UpdateTodoRequest `json:"update,omitempty" validate:"required_if=Op update"`
+	Toggle *ToggleTodoRequest `json:"toggle,omitempty" validate:"required_if=Op toggle"`
+	Delete *DeleteTodoRequest `json:"delete,omitempty" validate:"required_if=Op delete"`
+}
+
+func (o *BatchOperation) op() model.BatchOp {
+	switch model.BatchOpType(o.Op) {
+	case model.BatchCreate:
+		return model.BatchOp{Type: model.BatchCreate, Todo: o.Create.todo()}
+	case model.BatchUpdate:
+		return model.BatchOp{Type: model.BatchUpdate, ID: o.Update.Id, Patch: o.Update.patch()}
+	case model.BatchToggle:
+		return model.BatchOp{Type: model.BatchToggle, ID: o.Toggle.Id, Cascade: o.Toggle.Cascade, IfVersion: o.Toggle.Version}
+	case model.BatchDelete:
+		return model.BatchOp{Type: model.BatchDelete, ID: o.Delete.Id, Cascade: o.Delete.Cascade, IfVersion: o.Delete.Version}
+	default:
+		return model.BatchOp{Type: model.BatchOpType(o.Op)}
+	}
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of one operation, in the order they were
+// sent: the todo it returned, or the problem it failed with.
+type BatchResult struct {
+	Status  int         `json:"status"`
+	Todo    *model.Todo `json:"todo,omitempty"`
+	Problem *Problem    `json:"problem,omitempty"`
+}
@@ -0,0 +1,517 @@
+package transport
+
+import (
+	"bufio"
+	"crud/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of exports and imports, picked with the format query
+// parameter. NDJSON is the default.
+const (
+	FormatNDJSON   = "ndjson"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// maxImportLine bounds a single line of an NDJSON or Markdown import.
+const maxImportLine = 1 << 20
+
+// todoWriter writes todos in one of the export formats.
+type todoWriter interface {
+	Write(todo model.Todo) error
+	// Close writes out whatever is still buffered.
+	Close() error
+}
+
+type todoFormat struct {
+	contentType string
+	extension   string
+	writer      func(w io.Writer) todoWriter
+	read        func(r io.Reader) ([]ImportTodo, error)
+}
+
+var todoFormats = map[string]todoFormat{
+	FormatNDJSON: {
+		contentType: "application/x-ndjson",
+		extension:   "ndjson",
+		writer:      func(w io.Writer) todoWriter { return ndjsonWriter{json.NewEncoder(w)} },
+		read:        readNDJSON,
+	},
+	FormatCSV: {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		writer:      newCSVWriter,
+		read:        readCSV,
+	},
+	FormatMarkdown: {
+		contentType: "text/markdown; charset=utf-8",
+		extension:   "md",
+		writer:      func(w io.Writer) todoWriter { return &markdownWriter{w: w} },
+		read:        readMarkdown,
+	},
+}
+
+func decodeFormat(r *http.Request) (todoFormat, error) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = FormatNDJSON
+	}
+
+	format, ok := todoFormats[name]
+	if !ok {
+		return todoFormat{}, fmt.Errorf("format: unknown format %q", name)
+	}
+	return format, nil
+}
+
+// exportTodos streams the todos matching the list parameters of the
+// request. The headers are only sent with the first todo, so that an
+// early error can still be answered with a problem; a later one cuts
+// the export short.
+func (s *HttpServer) exportTodos(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeListTodosRequest(r)
+	if err != nil {
+		writeProblem(w, r, malformedRequestProblem(err))
+		return
+	}
+
+	if err := validateRequest(req); err != nil {
+		writeProblem(w, r, validationProblem(err))
+		return
+	}
+
+	format, err := decodeFormat(r)
+	if err != nil {
+		writeProblem(w, r, malformedRequestProblem(err))
+		return
+	}
+
+	var out todoWriter
+	start := func() {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="todos.`+format.extension+`"`)
+		w.WriteHeader(http.StatusOK)
+		out = format.writer(w)
+	}
+
+	ctx := r.Context()
+
+	err = s.service.ExportTodos(ctx, req.filter(), func(todo model.Todo) error {
+		if out == nil {
+			start()
+		}
+		return out.Write(todo)
+	})
+	if err != nil {
+		if out != nil {
+			s.logger.Warn("Export cut short", "error", err.Error())
+			return
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w: %w", ctxErr, err)
+		}
+
+		problem := errorProblem(err)
+		if problem.Status >= http.StatusInternalServerError {
+			s.logger.Error("Export todos error", "error", err.Error())
+		}
+		writeProblem(w, r, problem)
+		return
+	}
+
+	if out == nil {
+		start()
+	}
+	if err := out.Close(); err != nil {
+		s.logger.Warn("Export cut short", "error", err.Error())
+	}
+}
+
+// ImportTodo is a todo read from an import. Its Id only links subtasks
+// to their parent within the import, the todos get new ids; a todo whose
+// parentId is not found earlier in the import comes in at the top level.
+type ImportTodo struct {
+	Id          model.ID       `json:"id,omitempty"`
+	ParentID    *model.ID      `json:"parentId,omitempty" validate:"omitempty,min=1"`
+	Title       string         `json:"title" validate:"required,min=3,max=30"`
+	Description string         `json:"description,omitempty" validate:"omitempty,max=10000"`
+	Complete    bool           `json:"complete,omitempty"`
+	Priority    model.Priority `json:"priority,omitempty" validate:"omitempty"`
+	DueAt       *time.Time     `json:"dueAt,omitempty" validate:"omitempty"`
+	DueTimezone string         `json:"dueTimezone,omitempty" validate:"omitempty,timezone"`
+	Recurrence  string         `json:"recurrence,omitempty" validate:"omitempty,max=500"`
+	Tags        []string       `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=32"`
+}
+
+func (t *ImportTodo) todo() model.Todo {
+	return model.Todo{
+		ID:          t.Id,
+		ParentID:    t.ParentID,
+		Title:       t.Title,
+		Description: t.Description,
+		Complete:    t.Complete,
+		Priority:    t.Priority,
+		DueAt:       t.DueAt,
+		DueTimezone: t.DueTimezone,
+		Recurrence:  t.Recurrence,
+		Tags:        t.Tags,
+	}
+}
+
+// ImportTodosRequest creates Todos in the list ListID in one
+// transaction, whatever lists they were exported from. A dry run
+// reports the todos that would be created and rolls them back.
+type ImportTodosRequest struct {
+	ListID model.ID     `json:"listId" validate:"required,min=1"`
+	DryRun bool         `json:"dryRun,omitempty"`
+	Todos  []ImportTodo `json:"todos" validate:"required,min=1,max=1000,dive"`
+}
+
+func (r *ImportTodosRequest) todos() []model.Todo {
+	todos := make([]model.Todo, len(r.Todos))
+	for i := range r.Todos {
+		todos[i] = r.Todos[i].todo()
+		todos[i].ListID = r.ListID
+	}
+	return todos
+}
+
+type ImportTodosResponse struct {
+	DryRun bool         `json:"dryRun,omitempty"`
+	Todos  []model.Todo `json:"todos"`
+}
+
+func (r ImportTodosResponse) StatusCode() int {
+	if r.DryRun {
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// decodeImportTodosRequest reads the todos from the body in the format
+// of the format query parameter.
+func decodeImportTodosRequest(r *http.Request) (*ImportTodosRequest, error) {
+	format, err := decodeFormat(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &ImportTodosRequest{}
+
+	if v := r.URL.Query().Get("listId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("listId: %w", err)
+		}
+		req.ListID = model.ID(id)
+	}
+
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("dryRun: %w", err)
+		}
+		req.DryRun = dryRun
+	}
+
+	if req.Todos, err = format.read(r.Body); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w ndjsonWriter) Write(todo model.Todo) error { return w.enc.Encode(todo) }
+
+func (ndjsonWriter) Close() error { return nil }
+
+// readNDJSON reads one todo per line, as exported. Fields the server
+// keeps, like createdAt, are ignored.
+func readNDJSON(r io.Reader) ([]ImportTodo, error) {
+	var todos []ImportTodo
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var todo ImportTodo
+		if err := json.Unmarshal(scanner.Bytes(), &todo); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		todos = append(todos, todo)
+	}
+
+	return todos, scanner.Err()
+}
+
+// csvColumns are the columns of a CSV export. An import needs a title
+// column; the others may be left out and come in any order.
+var csvColumns = []string{"id", "listId", "parentId", "title", "description", "complete", "priority", "dueAt", "dueTimezone", "recurrence", "tags", "createdAt", "updatedAt", "completedAt"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) todoWriter {
+	c := csvWriter{csv.NewWriter(w)}
+	c.w.Write(csvColumns)
+	return c
+}
+
+func (w csvWriter) Write(todo model.Todo) error {
+	var parentID string
+	if todo.ParentID != nil {
+		parentID = strconv.FormatUint(uint64(*todo.ParentID), 10)
+	}
+
+	return w.w.Write([]string{
+		strconv.FormatUint(uint64(todo.ID), 10),
+		strconv.FormatUint(uint64(todo.ListID), 10),
+		parentID,
+		todo.Title,
+		todo.Description,
+		strconv.FormatBool(todo.Complete),
+		todo.Priority.String(),
+		csvTime(todo.DueAt),
+		todo.DueTimezone,
+		todo.Recurrence,
+		strings.Join(todo.Tags, ","),
+		csvTime(&todo.CreatedAt),
+		csvTime(&todo.UpdatedAt),
+		csvTime(todo.CompletedAt),
+	})
+}
+
+func (w csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func readCSV(r io.Reader) ([]ImportTodo, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("line 1: no title column")
+	}
+
+	var todos []ImportTodo
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return todos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var todo ImportTodo
+		for name, i := range columns {
+			if err := todo.setCSVField(name, record[i]); err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("line %d: %s: %w", line, name, err)
+			}
+		}
+		todos = append(todos, todo)
+	}
+}
+
+// setCSVField sets the field of a CSV column. Empty values and columns
+// only the server sets are ignored.
+func (t *ImportTodo) setCSVField(name, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch name {
+	case "id":
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		t.Id = model.ID(id)
+	case "parentId":
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		parentID := model.ID(id)
+		t.ParentID = &parentID
+	case "title":
+		t.Title = value
+	case "description":
+		t.Description = value
+	case "complete":
+		complete, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		t.Complete = complete
+	case "priority":
+		return t.Priority.UnmarshalText([]byte(value))
+	case "dueAt":
+		dueAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		t.DueAt = &dueAt
+	case "dueTimezone":
+		t.DueTimezone = value
+	case "recurrence":
+		t.Recurrence = value
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// markdownWriter writes a checklist with subtasks indented below their
+// parent, as readMarkdown reads them back. A subtask may come before its
+// parent in the export, so the items are held until Close. Subtasks of
+// todos left out of the export are at the top level.
+type markdownWriter struct {
+	w     io.Writer
+	items []checklistItem
+}
+
+type checklistItem struct {
+	id       model.ID
+	parentID *model.ID
+	text     string
+}
+
+func (w *markdownWriter) Write(todo model.Todo) error {
+	mark := " "
+	if todo.Complete {
+		mark = "x"
+	}
+
+	title := strings.Join(strings.Fields(todo.Title), " ")
+	w.items = append(w.items, checklistItem{
+		id:       todo.ID,
+		parentID: todo.ParentID,
+		text:     fmt.Sprintf("- [%s] %s", mark, title),
+	})
+	return nil
+}
+
+func (w *markdownWriter) Close() error {
+	exported := make(map[model.ID]bool, len(w.items))
+	for _, item := range w.items {
+		exported[item.id] = true
+	}
+
+	var roots []int
+	children := make(map[model.ID][]int)
+	for i, item := range w.items {
+		if item.parentID != nil && exported[*item.parentID] {
+			children[*item.parentID] = append(children[*item.parentID], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	out := bufio.NewWriter(w.w)
+	var write func(i, depth int)
+	write = func(i, depth int) {
+		item := w.items[i]
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", depth), item.text)
+		for _, child := range children[item.id] {
+			write(child, depth+1)
+		}
+	}
+	for _, i := range roots {
+		write(i, 0)
+	}
+	return out.Flush()
+}
+
+// markdownItem matches a checklist item, e.g. "  - [x] Buy milk".
+var markdownItem = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\] (.*)$`)
+
+// readMarkdown reads the checklist items of a Markdown document and
+// leaves everything else out. An item indented below another one
+// becomes its subtask.
+func readMarkdown(r io.Reader) ([]ImportTodo, error) {
+	type parent struct {
+		indent int
+		id     model.ID
+	}
+
+	var todos []ImportTodo
+	var parents []parent
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		match := markdownItem.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		indent := len(strings.ReplaceAll(match[1], "\t", "    "))
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+
+		// Items are told apart by their line.
+		todo := ImportTodo{
+			Id:       model.ID(line),
+			Title:    strings.TrimSpace(match[3]),
+			Complete: match[2] != " ",
+		}
+		if len(parents) > 0 {
+			parentID := parents[len(parents)-1].id
+			todo.ParentID = &parentID
+		}
+
+		todos = append(todos, todo)
+		parents = append(parents, parent{indent: indent, id: todo.Id})
+	}
+
+	return todos, scanner.Err()
+}
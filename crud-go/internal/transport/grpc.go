@@ -0,0 +1,383 @@
+package transport
+
+import (
+	"context"
+	todov1 "crud/api/todo/v1"
+	"crud/internal/model"
+	"crud/internal/service"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Metadata keys of gRPC calls, the lower-cased headers of the HTTP API.
+const (
+	metadataAuthorization = "authorization"
+	metadataActor         = "x-actor"
+	metadataRequestID     = "x-request-id"
+
+	metadataRateLimitLimit     = "ratelimit-limit"
+	metadataRateLimitRemaining = "ratelimit-remaining"
+	metadataRateLimitReset     = "ratelimit-reset"
+)
+
+// grpcReadMethods are the calls that take from the read bucket of a
+// client, like the GET routes of the HTTP API; all others are writes.
+var grpcReadMethods = map[string]bool{
+	"GetTodo":      true,
+	"GetTodos":     true,
+	"GetTodoTree":  true,
+	"ListTodos":    true,
+	"ListTrash":    true,
+	"SearchTodos":  true,
+	"ListAudit":    true,
+	"WatchChanges": true,
+}
+
+// GrpcServer serves the todo API over gRPC, with the service and the
+// authentication of the HttpServer.
+type GrpcServer struct {
+	todov1.UnimplementedTodoServiceServer
+
+	config  *GrpcConfig
+	logger  *slog.Logger
+	server  *grpc.Server
+	service service.ITodoService
+	metrics *grpcMetrics
+	// readLimiter and writeLimiter limit the calls of each client. They
+	// are nil, which allows everything, unless ShareRateLimits is called.
+	readLimiter  *rateLimiter
+	writeLimiter *rateLimiter
+	// authSecret signs the bearer tokens of calls. Calls are not
+	// authenticated when it is nil.
+	authSecret []byte
+
+	// shutdown is closed when draining starts, which ends the change
+	// streams.
+	shutdown chan struct{}
+}
+
+// NewGrpcServer creates the gRPC server of todos, usually the service of
+// the HttpServer. Its metrics are registered with registerer.
+func NewGrpcServer(logger *slog.Logger, todos service.ITodoService, config *GrpcConfig, registerer prometheus.Registerer) *GrpcServer {
+	s := &GrpcServer{
+		config:   config,
+		logger:   logger,
+		service:  todos,
+		metrics:  newGrpcMetrics(registerer),
+		shutdown: make(chan struct{}),
+	}
+
+	s.server = grpc.NewServer(
+		grpc.MaxRecvMsgSize(config.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	todov1.RegisterTodoServiceServer(s.server, s)
+
+	return s
+}
+
+// RequireAuth makes every call carry a bearer token signed with secret,
+// as the auth service issues them. It is called before Start.
+func (s *GrpcServer) RequireAuth(secret []byte) {
+	s.authSecret = secret
+}
+
+// ShareRateLimits makes calls take from the buckets of the HTTP API, so
+// a client has the same budget over both. It is called before Start.
+func (s *GrpcServer) ShareRateLimits(http *HttpServer) {
+	s.readLimiter = http.readLimiter
+	s.writeLimiter = http.writeLimiter
+}
+
+// Start serves until Shutdown is called, then returns nil.
+func (s *GrpcServer) Start() error {
+	s.logger.Info(`Starting gRPC server`, "bind_address", s.config.BindAddress)
+
+	listener, err := net.Listen("tcp", s.config.BindAddress)
+	if err != nil {
+		return err
+	}
+
+	return s.serve(listener)
+}
+
+func (s *GrpcServer) serve(listener net.Listener) error {
+	if err := s.server.Serve(listener); !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown ends the change streams and drains in-flight calls. Whatever
+// is still running when ctx is done gets its connection closed.
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	close(s.shutdown)
+
+	drained := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// unaryInterceptor is the middleware of single calls: it counts and
+// times them, puts the request info and the user into the context,
+// limits the rate of calls, bounds the call with RequestTimeout and
+// turns the errors of the service into statuses.
+func (s *GrpcServer) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var resp any
+	err := s.withMetrics(info.FullMethod, func() error {
+		ctx, err := s.callContext(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.limitRate(ctx, info.FullMethod); err != nil {
+			return err
+		}
+
+		if s.config.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.config.RequestTimeout)
+			defer cancel()
+		}
+
+		resp, err = handler(ctx, req)
+		if err != nil {
+			return s.callError(ctx, info.FullMethod, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// streamInterceptor is the middleware of streaming calls, which run for
+// as long as the client stays, so they have no timeout.
+func (s *GrpcServer) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return s.withMetrics(info.FullMethod, func() error {
+		ctx, err := s.callContext(stream.Context())
+		if err != nil {
+			return err
+		}
+		if err := s.limitRate(ctx, info.FullMethod); err != nil {
+			return err
+		}
+
+		if err := handler(srv, &serverStream{ServerStream: stream, ctx: ctx}); err != nil {
+			return s.callError(ctx, info.FullMethod, err)
+		}
+		return nil
+	})
+}
+
+// serverStream is a stream with the context of callContext.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// callContext does for a call what withRequestInfo and withAuth do for
+// an HTTP request: the request id is sent back as header metadata, and
+// a call without a valid token fails with UNAUTHENTICATED.
+func (s *GrpcServer) callContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	info := newRequestInfo(first(metadataActor), first(metadataRequestID))
+	grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, info.RequestID))
+	ctx = service.WithRequestInfo(ctx, info)
+
+	if s.authSecret == nil {
+		return ctx, nil
+	}
+
+	user, err := authenticate(s.authSecret, first(metadataAuthorization))
+	if err != nil {
+		return nil, &rejection{stage: "auth", status: status.New(codes.Unauthenticated, err.Error())}
+	}
+
+	return withUser(ctx, user), nil
+}
+
+// limitRate takes a token for a call from the bucket of its client, as
+// withRateLimit does for an HTTP request, and sends the rate limit
+// headers as header metadata. A rejected call is RESOURCE_EXHAUSTED,
+// with when to retry as a detail.
+func (s *GrpcServer) limitRate(ctx context.Context, method string) error {
+	limiter := s.writeLimiter
+	if grpcReadMethods[path.Base(method)] {
+		limiter = s.readLimiter
+	}
+	if limiter == nil {
+		return nil
+	}
+
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	decision := limiter.take(clientKey(ctx, remoteAddr))
+
+	grpc.SetHeader(ctx, metadata.Pairs(
+		metadataRateLimitLimit, strconv.Itoa(limiter.burst),
+		metadataRateLimitRemaining, strconv.Itoa(decision.remaining),
+		metadataRateLimitReset, seconds(decision.reset),
+	))
+
+	if decision.allowed {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "too many requests")
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.retryAfter)}); err == nil {
+		st = detailed
+	}
+	return &rejection{stage: "rate_limit", status: st}
+}
+
+// rejection is the status of a call turned away before reaching the
+// service, which the metrics count by the stage that turned it away.
+type rejection struct {
+	stage  string
+	status *status.Status
+}
+
+func (r *rejection) Error() string { return r.status.Err().Error() }
+
+func (r *rejection) GRPCStatus() *status.Status { return r.status }
+
+// withMetrics counts and times a call by its method and status code. A
+// rejection also counts it as rejected.
+func (s *GrpcServer) withMetrics(method string, call func() error) error {
+	start := time.Now()
+
+	err := call()
+
+	code := status.Code(err).String()
+	s.metrics.requests.WithLabelValues(method, code).Inc()
+	s.metrics.duration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+
+	var rejected *rejection
+	if errors.As(err, &rejected) {
+		s.metrics.rejected.WithLabelValues(method, rejected.stage).Inc()
+	}
+	return err
+}
+
+// callError maps an error returned by a call onto its status, like
+// errorProblem does for HTTP. Statuses made by the handlers are kept.
+func (s *GrpcServer) callError(ctx context.Context, method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	// Drivers don't always report a cancelled query as a context error,
+	// so make sure the reason is not lost.
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	st := errorStatus(err)
+	if st.Code() == codes.Internal {
+		s.logger.Error("Call process error", "method", method, "error", err.Error())
+	} else {
+		s.logger.Debug("Call process error", "method", method, "error", err.Error())
+	}
+	return st.Err()
+}
+
+// errorStatus is the status of an error returned by the service. A
+// write at a stale version is ABORTED, with the current todo as a
+// detail. Anything outside the model error taxonomy is an internal
+// error and its text is not exposed to the client.
+func errorStatus(err error) *status.Status {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, "client closed request")
+	case errors.Is(err, model.ErrNotFound):
+		return status.New(codes.NotFound, err.Error())
+	case errors.Is(err, model.ErrPreconditionFailed):
+		st := status.New(codes.Aborted, err.Error())
+		var conflict *model.VersionConflictError
+		if errors.As(err, &conflict) {
+			if detailed, err := st.WithDetails(todoMessage(conflict.Current)); err == nil {
+				st = detailed
+			}
+		}
+		return st
+	case errors.Is(err, model.ErrConflict):
+		return status.New(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrInvalidArgument):
+		return status.New(codes.InvalidArgument, err.Error())
+	default:
+		return status.New(codes.Internal, "internal server error")
+	}
+}
+
+// malformedStatus rejects a request that can't be turned into a request
+// of the HTTP API.
+func malformedStatus(err error) error {
+	return &rejection{stage: "decode", status: status.New(codes.InvalidArgument, err.Error())}
+}
+
+// validationStatus rejects a request that fails the validation of the
+// HTTP API, listing the failed rules as field violations.
+func validationStatus(err error) error {
+	problem := validationProblem(err)
+
+	st := status.New(codes.InvalidArgument, problem.Title)
+	if len(problem.Errors) == 0 {
+		return &rejection{stage: "validate", status: status.New(codes.InvalidArgument, problem.Detail)}
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(problem.Errors))
+	for i, e := range problem.Errors {
+		description := e.Rule
+		if e.Param != "" {
+			description += "=" + e.Param
+		}
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: description}
+	}
+
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return &rejection{stage: "validate", status: st}
+}
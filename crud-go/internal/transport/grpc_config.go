@@ -0,0 +1,22 @@
+package transport
+
+import "time"
+
+type GrpcConfig struct {
+	// BindAddress is where the gRPC API is served, apart from the HTTP
+	// one. Empty turns the gRPC server off.
+	BindAddress string
+	// RequestTimeout bounds every call but the streaming ones, including
+	// its database work.
+	RequestTimeout time.Duration
+	// MaxMessageSize is the largest request message accepted, in bytes.
+	MaxMessageSize int
+}
+
+func NewGrpcConfig() *GrpcConfig {
+	return &GrpcConfig{
+		BindAddress:    ":50051",
+		RequestTimeout: 10 * time.Second,
+		MaxMessageSize: 4 << 20,
+	}
+}
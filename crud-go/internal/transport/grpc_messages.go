@@ -0,0 +1,368 @@
+package transport
+
+import (
+	todov1 "crud/api/todo/v1"
+	"crud/internal/model"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The messages of the gRPC API are turned into the requests of the HTTP
+// API, so both are validated and mapped onto the service alike.
+
+var (
+	sortFields = map[todov1.SortField]string{
+		todov1.SortField_SORT_FIELD_UNSPECIFIED: "",
+		todov1.SortField_SORT_FIELD_ID:          string(model.SortByID),
+		todov1.SortField_SORT_FIELD_CREATED_AT:  string(model.SortByCreatedAt),
+		todov1.SortField_SORT_FIELD_UPDATED_AT:  string(model.SortByUpdatedAt),
+		todov1.SortField_SORT_FIELD_DUE_AT:      string(model.SortByDueAt),
+		todov1.SortField_SORT_FIELD_PRIORITY:    string(model.SortByPriority),
+	}
+	sortOrders = map[todov1.SortOrder]string{
+		todov1.SortOrder_SORT_ORDER_UNSPECIFIED: "",
+		todov1.SortOrder_SORT_ORDER_ASC:         string(model.SortAsc),
+		todov1.SortOrder_SORT_ORDER_DESC:        string(model.SortDesc),
+	}
+	auditActions = map[model.AuditAction]todov1.AuditAction{
+		model.AuditCreate:  todov1.AuditAction_AUDIT_ACTION_CREATE,
+		model.AuditUpdate:  todov1.AuditAction_AUDIT_ACTION_UPDATE,
+		model.AuditToggle:  todov1.AuditAction_AUDIT_ACTION_TOGGLE,
+		model.AuditDelete:  todov1.AuditAction_AUDIT_ACTION_DELETE,
+		model.AuditRestore: todov1.AuditAction_AUDIT_ACTION_RESTORE,
+	}
+	changeTypes = map[model.ChangeType]todov1.ChangeType{
+		model.ChangeCreated: todov1.ChangeType_CHANGE_TYPE_CREATED,
+		model.ChangeUpdated: todov1.ChangeType_CHANGE_TYPE_UPDATED,
+		model.ChangeDeleted: todov1.ChangeType_CHANGE_TYPE_DELETED,
+	}
+)
+
+func todoMessage(todo model.Todo) *todov1.Todo {
+	return &todov1.Todo{
+		Id:          uint64(todo.ID),
+		UserId:      uint64(todo.UserID),
+		ListId:      uint64(todo.ListID),
+		ParentId:    (*uint64)(todo.ParentID),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Complete:    todo.Complete,
+		Priority:    todov1.Priority(todo.Priority),
+		DueAt:       timestampMessage(todo.DueAt),
+		DueTimezone: todo.DueTimezone,
+		Recurrence:  todo.Recurrence,
+		SeriesId:    (*uint64)(todo.SeriesID),
+		Tags:        todo.Tags,
+		CreatedAt:   timestamppb.New(todo.CreatedAt),
+		UpdatedAt:   timestamppb.New(todo.UpdatedAt),
+		CompletedAt: timestampMessage(todo.CompletedAt),
+		DeletedAt:   timestampMessage(todo.DeletedAt),
+		Version:     todo.Version,
+	}
+}
+
+func todoMessages(todos []model.Todo) []*todov1.Todo {
+	messages := make([]*todov1.Todo, len(todos))
+	for i, todo := range todos {
+		messages[i] = todoMessage(todo)
+	}
+	return messages
+}
+
+func todoTreeMessage(tree model.TodoTree) *todov1.TodoTree {
+	message := &todov1.TodoTree{
+		Todo: todoMessage(tree.Todo),
+		Progress: &todov1.Progress{
+			Completed: int32(tree.Progress.Completed),
+			Total:     int32(tree.Progress.Total),
+		},
+		Children: make([]*todov1.TodoTree, len(tree.Children)),
+	}
+	for i, child := range tree.Children {
+		message.Children[i] = todoTreeMessage(child)
+	}
+	return message
+}
+
+func auditEntryMessage(entry model.AuditEntry) *todov1.AuditEntry {
+	message := &todov1.AuditEntry{
+		Id:        entry.ID,
+		TodoId:    uint64(entry.TodoID),
+		Action:    auditActions[entry.Action],
+		Actor:     entry.Actor,
+		RequestId: entry.RequestID,
+		CreatedAt: timestamppb.New(entry.CreatedAt),
+	}
+	if entry.Before != nil {
+		message.Before = todoMessage(*entry.Before)
+	}
+	if entry.After != nil {
+		message.After = todoMessage(*entry.After)
+	}
+	return message
+}
+
+func changeMessage(change model.Change) *todov1.Change {
+	return &todov1.Change{
+		Id:        change.ID,
+		Type:      changeTypes[change.Type],
+		Action:    auditActions[change.Action],
+		Todo:      todoMessage(change.Todo),
+		Actor:     change.Actor,
+		CreatedAt: timestamppb.New(change.CreatedAt),
+	}
+}
+
+func timestampMessage(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// timeOf is the time of a timestamp that is set, which has to be valid.
+func timeOf(ts *timestamppb.Timestamp, field string) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	t := ts.AsTime()
+	return &t, nil
+}
+
+func priorityOf(p todov1.Priority, field string) (model.Priority, error) {
+	if _, ok := todov1.Priority_name[int32(p)]; !ok {
+		return 0, fmt.Errorf("%s: unknown priority %d", field, p)
+	}
+	return model.Priority(p), nil
+}
+
+func listTodosRequest(message *todov1.ListTodosRequest) (*ListTodosRequest, error) {
+	req := &ListTodosRequest{
+		ListID:   (*model.ID)(message.ListId),
+		SeriesID: (*model.ID)(message.SeriesId),
+		Complete: message.Complete,
+		Title:    message.Title,
+		Tags:     message.Tags,
+	}
+
+	var ok bool
+	if req.SortBy, ok = sortFields[message.SortBy]; !ok {
+		return nil, fmt.Errorf("sort_by: unknown field %d", message.SortBy)
+	}
+	if req.Order, ok = sortOrders[message.Order]; !ok {
+		return nil, fmt.Errorf("order: unknown order %d", message.Order)
+	}
+
+	var err error
+	if req.CreatedAfter, err = timeOf(message.CreatedAfter, "created_after"); err != nil {
+		return nil, err
+	}
+	if req.CreatedBefore, err = timeOf(message.CreatedBefore, "created_before"); err != nil {
+		return nil, err
+	}
+	if req.DueAfter, err = timeOf(message.DueAfter, "due_after"); err != nil {
+		return nil, err
+	}
+	if req.DueBefore, err = timeOf(message.DueBefore, "due_before"); err != nil {
+		return nil, err
+	}
+
+	if message.MinPriority != nil {
+		minPriority, err := priorityOf(*message.MinPriority, "min_priority")
+		if err != nil {
+			return nil, err
+		}
+		req.MinPriority = &minPriority
+	}
+
+	return req, nil
+}
+
+func createTodoRequest(message *todov1.CreateTodoRequest) (*CreateTodoRequest, error) {
+	req := &CreateTodoRequest{
+		ListID:      model.ID(message.ListId),
+		ParentID:    (*model.ID)(message.ParentId),
+		Title:       message.Title,
+		Description: message.Description,
+		DueTimezone: message.DueTimezone,
+		Recurrence:  message.Recurrence,
+		Tags:        message.Tags,
+	}
+
+	var err error
+	if req.Priority, err = priorityOf(message.Priority, "priority"); err != nil {
+		return nil, err
+	}
+	if req.DueAt, err = timeOf(message.DueAt, "due_at"); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// updateTodoRequest takes the fields named by the update mask from the
+// todo of message. A named field the todo leaves unset is cleared where
+// that is possible, and takes its zero value otherwise.
+func updateTodoRequest(message *todov1.UpdateTodoRequest) (*UpdateTodoRequest, error) {
+	req := &UpdateTodoRequest{
+		Id:      model.ID(message.Id),
+		Version: message.Version,
+	}
+
+	switch message.Scope {
+	case todov1.UpdateScope_UPDATE_SCOPE_OCCURRENCE:
+	case todov1.UpdateScope_UPDATE_SCOPE_SERIES:
+		req.Scope = "series"
+	default:
+		return nil, fmt.Errorf("scope: unknown scope %d", message.Scope)
+	}
+
+	todo := message.Todo
+	if todo == nil {
+		todo = &todov1.Todo{}
+	}
+
+	for _, path := range message.UpdateMask.GetPaths() {
+		switch path {
+		case "list_id":
+			req.ListID = (*model.ID)(&todo.ListId)
+		case "parent_id":
+			req.ParentID = model.NullableOf((*model.ID)(todo.ParentId))
+		case "title":
+			req.Title = &todo.Title
+		case "description":
+			req.Description = &todo.Description
+		case "complete":
+			req.Complete = &todo.Complete
+		case "priority":
+			priority, err := priorityOf(todo.Priority, "todo.priority")
+			if err != nil {
+				return nil, err
+			}
+			req.Priority = &priority
+		case "due_at":
+			dueAt, err := timeOf(todo.DueAt, "todo.due_at")
+			if err != nil {
+				return nil, err
+			}
+			req.DueAt = model.NullableOf(dueAt)
+		case "due_timezone":
+			req.DueTimezone = &todo.DueTimezone
+		case "recurrence":
+			req.Recurrence = &todo.Recurrence
+		case "tags":
+			tags := todo.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			req.Tags = &tags
+		default:
+			return nil, fmt.Errorf("update_mask: %q can't be updated", path)
+		}
+	}
+
+	return req, nil
+}
+
+func toggleTodoRequest(message *todov1.ToggleTodoRequest) *ToggleTodoRequest {
+	return &ToggleTodoRequest{
+		Id:      model.ID(message.Id),
+		Cascade: message.Cascade,
+		Version: message.Version,
+	}
+}
+
+func deleteTodoRequest(message *todov1.DeleteTodoRequest) *DeleteTodoRequest {
+	return &DeleteTodoRequest{
+		Id:      model.ID(message.Id),
+		Cascade: message.Cascade,
+		Version: message.Version,
+	}
+}
+
+func batchRequest(message *todov1.BatchRequest) (*BatchRequest, error) {
+	req := &BatchRequest{Operations: make([]BatchOperation, len(message.Operations))}
+
+	switch message.Mode {
+	case todov1.BatchMode_BATCH_MODE_ATOMIC:
+		req.Mode = BatchModeAtomic
+	case todov1.BatchMode_BATCH_MODE_BEST_EFFORT:
+		req.Mode = BatchModeBestEffort
+	default:
+		return nil, fmt.Errorf("mode: unknown mode %d", message.Mode)
+	}
+
+	for i, operation := range message.Operations {
+		var err error
+		switch op := operation.GetOp().(type) {
+		case *todov1.BatchOperation_Create:
+			req.Operations[i].Op = string(model.BatchCreate)
+			req.Operations[i].Create, err = createTodoRequest(op.Create)
+		case *todov1.BatchOperation_Update:
+			req.Operations[i].Op = string(model.BatchUpdate)
+			req.Operations[i].Update, err = updateTodoRequest(op.Update)
+		case *todov1.BatchOperation_Toggle:
+			req.Operations[i].Op = string(model.BatchToggle)
+			req.Operations[i].Toggle = toggleTodoRequest(op.Toggle)
+		case *todov1.BatchOperation_Delete:
+			req.Operations[i].Op = string(model.BatchDelete)
+			req.Operations[i].Delete = deleteTodoRequest(op.Delete)
+		default:
+			err = fmt.Errorf("no operation")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operations[%d]: %w", i, err)
+		}
+	}
+
+	return req, nil
+}
+
+func listAuditRequest(message *todov1.ListAuditRequest) (*ListAuditRequest, error) {
+	req := &ListAuditRequest{
+		TodoID: (*model.ID)(message.TodoId),
+		Cursor: message.Cursor,
+		Limit:  int(message.Limit),
+	}
+
+	var err error
+	if req.CreatedAfter, err = timeOf(message.CreatedAfter, "created_after"); err != nil {
+		return nil, err
+	}
+	if req.CreatedBefore, err = timeOf(message.CreatedBefore, "created_before"); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func watchChangesRequest(message *todov1.WatchChangesRequest) (*WatchChangesRequest, error) {
+	req := &WatchChangesRequest{
+		Tags:        message.Tags,
+		LastEventID: message.AfterId,
+	}
+
+	for _, id := range message.TodoIds {
+		req.TodoIDs = append(req.TodoIDs, model.ID(id))
+	}
+
+	for _, t := range message.Types {
+		found := false
+		for changeType, value := range changeTypes {
+			if value == t {
+				req.Types = append(req.Types, string(changeType))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("types: unknown type %d", t)
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,446 @@
+package transport
+
+import (
+	"context"
+	todov1 "crud/api/todo/v1"
+	"crud/internal/model"
+	"crud/internal/service"
+	mock_service "crud/internal/service/mocks"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newGrpcTestServer serves svc over an in-memory connection and returns
+// a client of it.
+func newGrpcTestServer(t *testing.T, svc *mock_service.MockITodoService, secret []byte) (*GrpcServer, todov1.TodoServiceClient) {
+	t.Helper()
+
+	server := NewGrpcServer(slog.New(slog.NewTextHandler(io.Discard, nil)), svc, NewGrpcConfig(), prometheus.NewRegistry())
+	if secret != nil {
+		server.RequireAuth(secret)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	go server.serve(listener)
+	t.Cleanup(server.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return server, todov1.NewTodoServiceClient(conn)
+}
+
+func TestGrpc_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	current := model.Todo{ID: 3, Title: "Title 3", Version: 4}
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&model.Todo{ID: 1, Title: "Title 1"}, nil)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(3)).Return(nil, errors.New("connection reset by peer"))
+	svc.EXPECT().ToggleTodo(gomock.Any(), model.ID(3), model.ToggleOptions{IfVersion: ptr(int64(2))}).
+		Return(nil, &model.VersionConflictError{Current: current})
+	svc.EXPECT().DeleteTodo(gomock.Any(), model.ID(4), gomock.Any()).
+		Return(nil, errors.Join(model.ErrConflict, errors.New("list is not empty")))
+
+	_, client := newGrpcTestServer(t, svc, nil)
+	ctx := context.Background()
+
+	todo, err := client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Title 1", todo.Title)
+
+	_, err = client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 3})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, err.Error(), "connection reset", "internal errors are not exposed")
+
+	_, err = client.ToggleTodo(ctx, &todov1.ToggleTodoRequest{Id: 3, Version: ptr(int64(2))})
+	st := status.Convert(err)
+	assert.Equal(t, codes.Aborted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		assert.Equal(t, int64(4), st.Details()[0].(*todov1.Todo).Version, "the current todo comes along")
+	}
+
+	_, err = client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: 4})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Requests are validated like those of the HTTP API.
+	_, err = client.GetTodo(ctx, &todov1.GetTodoRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.CreateTodo(ctx, &todov1.CreateTodoRequest{ListId: 1, Title: "ab"})
+	st = status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+		if assert.Len(t, violations, 1) {
+			assert.Equal(t, "title", violations[0].Field)
+			assert.Equal(t, "min=3", violations[0].Description)
+		}
+	}
+
+	_, err = client.CreateTodo(ctx, &todov1.CreateTodoRequest{ListId: 1, Title: "Title", Priority: 9})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: 1, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"version"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGrpc_CreateAndUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dueAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	todo := model.Todo{ID: 1, ListID: 1, Title: "Title 1", Priority: model.PriorityHigh, DueAt: &dueAt, Tags: []string{"work"}, Version: 2}
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().CreateTodo(gomock.Any(), model.Todo{
+		ListID:      1,
+		ParentID:    ptr(model.ID(5)),
+		Title:       "Title 1",
+		Priority:    model.PriorityHigh,
+		DueAt:       &dueAt,
+		DueTimezone: "Europe/Berlin",
+		Tags:        []string{"work"},
+	}).Return(&todo, nil)
+	svc.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{
+		IfVersion: ptr(int64(2)),
+		Title:     ptr("Title 2"),
+		ParentID:  model.NullableOf[model.ID](nil),
+		DueAt:     model.NullableOf(&dueAt),
+		Tags:      &[]string{},
+		Series:    true,
+	}).Return(&todo, nil)
+
+	_, client := newGrpcTestServer(t, svc, nil)
+	ctx := context.Background()
+
+	created, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{
+		ListId:      1,
+		ParentId:    ptr(uint64(5)),
+		Title:       "Title 1",
+		Priority:    todov1.Priority_PRIORITY_HIGH,
+		DueAt:       timestamppb.New(dueAt),
+		DueTimezone: "Europe/Berlin",
+		Tags:        []string{"work"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, todov1.Priority_PRIORITY_HIGH, created.Priority)
+	assert.True(t, dueAt.Equal(created.DueAt.AsTime()))
+	assert.Nil(t, created.CompletedAt)
+	assert.Equal(t, int64(2), created.Version)
+
+	// Named fields the todo leaves unset are cleared; others are kept.
+	_, err = client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{
+		Id:         1,
+		Todo:       &todov1.Todo{Title: "Title 2", Description: "kept", DueAt: timestamppb.New(dueAt)},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title", "parent_id", "due_at", "tags"}},
+		Version:    ptr(int64(2)),
+		Scope:      todov1.UpdateScope_UPDATE_SCOPE_SERIES,
+	})
+	require.NoError(t, err)
+}
+
+func TestGrpc_ListTodos(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	listID := model.ID(1)
+	filter := model.TodoFilter{ListID: &listID, SortBy: model.SortByDueAt, Order: model.SortDesc, Limit: streamPageSize}
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	gomock.InOrder(
+		svc.EXPECT().ListTodos(gomock.Any(), filter).
+			Return(&model.TodoPage{Todos: []model.Todo{{ID: 1}, {ID: 2}}, NextCursor: "next"}, nil),
+		svc.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{ListID: &listID, SortBy: model.SortByDueAt, Order: model.SortDesc, Limit: streamPageSize, Cursor: "next"}).
+			Return(&model.TodoPage{Todos: []model.Todo{{ID: 3}}}, nil),
+	)
+	svc.EXPECT().ListTrash(gomock.Any(), gomock.Any()).Return(nil, model.ErrInvalidArgument)
+
+	_, client := newGrpcTestServer(t, svc, nil)
+	ctx := context.Background()
+
+	stream, err := client.ListTodos(ctx, &todov1.ListTodosRequest{
+		ListId: ptr(uint64(1)),
+		SortBy: todov1.SortField_SORT_FIELD_DUE_AT,
+		Order:  todov1.SortOrder_SORT_ORDER_DESC,
+	})
+	require.NoError(t, err)
+
+	var ids []uint64
+	for {
+		todo, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, todo.Id)
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, ids, "every page is streamed")
+
+	trash, err := client.ListTrash(ctx, &todov1.ListTodosRequest{})
+	require.NoError(t, err)
+	_, err = trash.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGrpc_Batch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+	current := model.Todo{ID: 2, Title: "Title 2", Version: 3}
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().Batch(gomock.Any(), []model.BatchOp{
+		{Type: model.BatchCreate, Todo: model.Todo{ListID: 1, Title: "Title 1"}},
+		{Type: model.BatchToggle, ID: 2, IfVersion: ptr(int64(1))},
+	}, false).Return([]model.BatchResult{
+		{Todo: &todo},
+		{Err: &model.VersionConflictError{Current: current}},
+	}, nil)
+
+	_, client := newGrpcTestServer(t, svc, nil)
+
+	response, err := client.Batch(context.Background(), &todov1.BatchRequest{
+		Mode: todov1.BatchMode_BATCH_MODE_BEST_EFFORT,
+		Operations: []*todov1.BatchOperation{
+			{Op: &todov1.BatchOperation_Create{Create: &todov1.CreateTodoRequest{ListId: 1, Title: "Title 1"}}},
+			{Op: &todov1.BatchOperation_Toggle{Toggle: &todov1.ToggleTodoRequest{Id: 2, Version: ptr(int64(1))}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Results, 2)
+
+	assert.Equal(t, "Title 1", response.Results[0].GetTodo().Title)
+	assert.Equal(t, int32(codes.Aborted), response.Results[1].GetError().Code)
+	assert.Equal(t, int64(3), response.Results[1].GetError().Current.Version)
+
+	_, err = client.Batch(context.Background(), &todov1.BatchRequest{Operations: []*todov1.BatchOperation{{}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "an operation needs a request")
+}
+
+func TestGrpc_WatchChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changes := make(chan model.Change, 1)
+	changes <- model.Change{ID: 7, Type: model.ChangeCreated, Action: model.AuditRestore, Todo: model.Todo{ID: 1}}
+	close(changes)
+
+	live := make(chan model.Change)
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	gomock.InOrder(
+		svc.EXPECT().WatchChanges(gomock.Any(), model.ChangeFilter{
+			TodoIDs: []model.ID{1},
+			Types:   []model.ChangeType{model.ChangeCreated},
+			AfterID: 6,
+		}).Return((<-chan model.Change)(changes), nil),
+		svc.EXPECT().WatchChanges(gomock.Any(), gomock.Any()).Return((<-chan model.Change)(live), nil),
+	)
+
+	server, client := newGrpcTestServer(t, svc, nil)
+	ctx := context.Background()
+
+	stream, err := client.WatchChanges(ctx, &todov1.WatchChangesRequest{
+		TodoIds: []uint64{1},
+		Types:   []todov1.ChangeType{todov1.ChangeType_CHANGE_TYPE_CREATED},
+		AfterId: 6,
+	})
+	require.NoError(t, err)
+
+	change, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), change.Id)
+	assert.Equal(t, todov1.ChangeType_CHANGE_TYPE_CREATED, change.Type)
+	assert.Equal(t, todov1.AuditAction_AUDIT_ACTION_RESTORE, change.Action)
+
+	// A feed that breaks off ends the stream, to be resumed.
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// So does shutting down.
+	stream, err = client.WatchChanges(ctx, &todov1.WatchChangesRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	go server.Shutdown(shutdownCtx)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestGrpc_Auth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	secret := []byte("secret")
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		require.NoError(t, err)
+		return token
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	todo := model.Todo{ID: 1, Title: "Title 1", UserID: 7}
+
+	var infos []service.RequestInfo
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(1)).DoAndReturn(
+		func(ctx context.Context, id model.ID) (*model.Todo, error) {
+			infos = append(infos, service.RequestInfoFrom(ctx))
+			if user, _ := model.UserFrom(ctx); user != todo.UserID {
+				return nil, model.ErrNotFound
+			}
+			return &todo, nil
+		},
+	).Times(3)
+	svc.EXPECT().WatchChanges(gomock.Any(), gomock.Any()).Times(0)
+
+	_, client := newGrpcTestServer(t, svc, secret)
+
+	call := func(pairs ...string) (*todov1.Todo, metadata.MD, error) {
+		var header metadata.MD
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(pairs...))
+		todo, err := client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 1}, grpc.Header(&header))
+		return todo, header, err
+	}
+
+	_, header, err := call("authorization", "Bearer "+sign(jwt.MapClaims{"user_id": 7, "exp": expires}))
+	require.NoError(t, err)
+	assert.Len(t, header.Get("x-request-id"), 1, "a request id is generated")
+
+	_, header, err = call("authorization", "Bearer "+sign(jwt.MapClaims{"user_id": 7, "exp": expires}), "x-actor", "alice", "x-request-id", "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"req-1"}, header.Get("x-request-id"))
+
+	// Todos of other users look like they don't exist.
+	_, _, err = call("authorization", "Bearer "+sign(jwt.MapClaims{"user_id": 8, "exp": expires}))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The actor named by the call can't stand in for the user.
+	assert.Equal(t, []string{"user:7", "user:7", "user:8"}, []string{infos[0].Actor, infos[1].Actor, infos[2].Actor})
+	assert.Equal(t, "req-1", infos[1].RequestID)
+
+	_, _, err = call()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, _, err = call("authorization", "Bearer "+sign(jwt.MapClaims{"user_id": 7}))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "tokens have to expire")
+
+	// Streams are authenticated too.
+	stream, err := client.WatchChanges(context.Background(), &todov1.WatchChangesRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGrpc_Limits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	todo := model.Todo{ID: 1, ListID: 1, Title: "Title 1"}
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().CreateTodo(gomock.Any(), gomock.Any()).Return(&todo, nil).Times(2)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&todo, nil)
+
+	http := newTestServer(svc, NewHttpConfig())
+	http.writeLimiter = newRateLimiter(0.001, 2)
+
+	server, client := newGrpcTestServer(t, svc, nil)
+	server.ShareRateLimits(http)
+
+	create := func(actor string) (metadata.MD, error) {
+		var header metadata.MD
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-actor", actor))
+		_, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{ListId: 1, Title: "Title 1"}, grpc.Header(&header))
+		return header, err
+	}
+
+	header, err := create("alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, header.Get("ratelimit-limit"))
+	assert.Equal(t, []string{"1"}, header.Get("ratelimit-remaining"))
+	assert.Equal(t, []string{"1000"}, header.Get("ratelimit-reset"))
+
+	_, err = create("alice")
+	require.NoError(t, err)
+
+	// A new actor is still the same client.
+	header, err = create("bob")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"0"}, header.Get("ratelimit-remaining"))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	assert.Equal(t, 1000*time.Second, details[0].(*errdetails.RetryInfo).RetryDelay.AsDuration().Round(time.Second))
+
+	// Reads have a bucket of their own.
+	_, err = client.GetTodo(context.Background(), &todov1.GetTodoRequest{Id: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.rejected.WithLabelValues(todov1.TodoService_CreateTodo_FullMethodName, "rate_limit")))
+}
+
+func TestGrpc_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&model.Todo{ID: 1, Title: "Title 1"}, nil).Times(2)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+
+	server, client := newGrpcTestServer(t, svc, nil)
+	ctx := context.Background()
+
+	for _, id := range []uint64{1, 1, 2, 0} {
+		client.GetTodo(ctx, &todov1.GetTodoRequest{Id: id})
+	}
+
+	method := todov1.TodoService_GetTodo_FullMethodName
+	assert.Equal(t, 2.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues(method, "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues(method, "NotFound")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues(method, "InvalidArgument")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.rejected.WithLabelValues(method, "validate")))
+	assert.Equal(t, 3, testutil.CollectAndCount(server.metrics.duration), "calls are timed by status code")
+}
+
+// ptr points to v, for optional fields.
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,338 @@
+package transport
+
+import (
+	"context"
+	todov1 "crud/api/todo/v1"
+	"crud/internal/model"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamPageSize is how many todos ListTodos and ListTrash read at
+// once.
+const streamPageSize = 100
+
+var _ todov1.TodoServiceServer = &GrpcServer{}
+
+// GetTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) GetTodo(ctx context.Context, message *todov1.GetTodoRequest) (*todov1.Todo, error) {
+	req := &GetTodoRequest{Id: model.ID(message.Id)}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("GetTodoRequest", "id", req.Id)
+	todo, err := s.service.GetTodo(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// GetTodos implements todov1.TodoServiceServer.
+func (s *GrpcServer) GetTodos(ctx context.Context, message *todov1.GetTodosRequest) (*todov1.GetTodosResponse, error) {
+	req := &GetTodosRequest{Ids: make([]model.ID, len(message.Ids))}
+	for i, id := range message.Ids {
+		req.Ids[i] = model.ID(id)
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("GetTodosRequest", "ids", req.Ids)
+	todos, err := s.service.GetTodos(ctx, req.Ids)
+	if err != nil {
+		return nil, err
+	}
+	return &todov1.GetTodosResponse{Todos: todoMessages(todos)}, nil
+}
+
+// GetTodoTree implements todov1.TodoServiceServer.
+func (s *GrpcServer) GetTodoTree(ctx context.Context, message *todov1.GetTodoTreeRequest) (*todov1.TodoTree, error) {
+	req := &GetTodoTreeRequest{Id: model.ID(message.Id)}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("GetTodoTreeRequest", "id", req.Id)
+	tree, err := s.service.GetTodoTree(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return todoTreeMessage(*tree), nil
+}
+
+// ListTodos implements todov1.TodoServiceServer.
+func (s *GrpcServer) ListTodos(message *todov1.ListTodosRequest, stream grpc.ServerStreamingServer[todov1.Todo]) error {
+	return s.streamTodos(message, stream, s.service.ListTodos)
+}
+
+// ListTrash implements todov1.TodoServiceServer.
+func (s *GrpcServer) ListTrash(message *todov1.ListTodosRequest, stream grpc.ServerStreamingServer[todov1.Todo]) error {
+	return s.streamTodos(message, stream, s.service.ListTrash)
+}
+
+// streamTodos sends every todo list finds, going through its pages
+// until the last one.
+func (s *GrpcServer) streamTodos(message *todov1.ListTodosRequest, stream grpc.ServerStreamingServer[todov1.Todo], list func(context.Context, model.TodoFilter) (*model.TodoPage, error)) error {
+	req, err := listTodosRequest(message)
+	if err != nil {
+		return malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return validationStatus(err)
+	}
+
+	s.logger.Debug("ListTodosRequest", "list_id", req.ListID)
+
+	ctx := stream.Context()
+	filter := req.filter()
+	filter.Limit = streamPageSize
+
+	for {
+		page, err := list(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range page.Todos {
+			if err := stream.Send(todoMessage(todo)); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// SearchTodos implements todov1.TodoServiceServer.
+func (s *GrpcServer) SearchTodos(ctx context.Context, message *todov1.SearchTodosRequest) (*todov1.SearchTodosResponse, error) {
+	req := &SearchTodosRequest{Query: message.Query, Cursor: message.Cursor, Limit: int(message.Limit)}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("SearchTodosRequest", "query", req.Query, "cursor", req.Cursor, "limit", req.Limit)
+	page, err := s.service.SearchTodos(ctx, req.filter())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &todov1.SearchTodosResponse{
+		Results:    make([]*todov1.SearchResult, len(page.Results)),
+		NextCursor: page.NextCursor,
+	}
+	for i, result := range page.Results {
+		response.Results[i] = &todov1.SearchResult{
+			Todo:    todoMessage(result.Todo),
+			Rank:    result.Rank,
+			Snippet: result.Snippet,
+		}
+	}
+	return response, nil
+}
+
+// CreateTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) CreateTodo(ctx context.Context, message *todov1.CreateTodoRequest) (*todov1.Todo, error) {
+	req, err := createTodoRequest(message)
+	if err != nil {
+		return nil, malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("CreateTodoRequest", "title", req.Title)
+	todo, err := s.service.CreateTodo(ctx, req.todo())
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// UpdateTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) UpdateTodo(ctx context.Context, message *todov1.UpdateTodoRequest) (*todov1.Todo, error) {
+	req, err := updateTodoRequest(message)
+	if err != nil {
+		return nil, malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("UpdateTodoRequest", "id", req.Id)
+	todo, err := s.service.UpdateTodo(ctx, req.Id, req.patch())
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// ToggleTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) ToggleTodo(ctx context.Context, message *todov1.ToggleTodoRequest) (*todov1.Todo, error) {
+	req := toggleTodoRequest(message)
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("ToggleTodoRequest", "id", req.Id, "cascade", req.Cascade)
+	todo, err := s.service.ToggleTodo(ctx, req.Id, req.options())
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// DeleteTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) DeleteTodo(ctx context.Context, message *todov1.DeleteTodoRequest) (*todov1.Todo, error) {
+	req := deleteTodoRequest(message)
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("DeleteTodoRequest", "id", req.Id, "cascade", req.Cascade)
+	todo, err := s.service.DeleteTodo(ctx, req.Id, req.options())
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// RestoreTodo implements todov1.TodoServiceServer.
+func (s *GrpcServer) RestoreTodo(ctx context.Context, message *todov1.RestoreTodoRequest) (*todov1.Todo, error) {
+	req := &RestoreTodoRequest{Id: model.ID(message.Id)}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("RestoreTodoRequest", "id", req.Id)
+	todo, err := s.service.RestoreTodo(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return todoMessage(*todo), nil
+}
+
+// Batch implements todov1.TodoServiceServer.
+func (s *GrpcServer) Batch(ctx context.Context, message *todov1.BatchRequest) (*todov1.BatchResponse, error) {
+	req, err := batchRequest(message)
+	if err != nil {
+		return nil, malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("BatchRequest", "mode", req.Mode, "operations", len(req.Operations))
+
+	ops := make([]model.BatchOp, len(req.Operations))
+	for i := range req.Operations {
+		ops[i] = req.Operations[i].op()
+	}
+
+	results, err := s.service.Batch(ctx, ops, req.Mode != BatchModeBestEffort)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &todov1.BatchResponse{Results: make([]*todov1.BatchResult, len(results))}
+	for i, result := range results {
+		if result.Err != nil {
+			st := errorStatus(result.Err)
+			if st.Code() == codes.Internal {
+				s.logger.Error("Batch operation error", "index", i, "error", result.Err.Error())
+			}
+
+			batchErr := &todov1.BatchError{Code: int32(st.Code()), Message: st.Message()}
+			var conflict *model.VersionConflictError
+			if errors.As(result.Err, &conflict) {
+				batchErr.Current = todoMessage(conflict.Current)
+			}
+			response.Results[i] = &todov1.BatchResult{Result: &todov1.BatchResult_Error{Error: batchErr}}
+			continue
+		}
+
+		response.Results[i] = &todov1.BatchResult{Result: &todov1.BatchResult_Todo{Todo: todoMessage(*result.Todo)}}
+	}
+
+	return response, nil
+}
+
+// ListAudit implements todov1.TodoServiceServer.
+func (s *GrpcServer) ListAudit(ctx context.Context, message *todov1.ListAuditRequest) (*todov1.ListAuditResponse, error) {
+	req, err := listAuditRequest(message)
+	if err != nil {
+		return nil, malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, validationStatus(err)
+	}
+
+	s.logger.Debug("ListAuditRequest", "todo_id", req.TodoID, "cursor", req.Cursor, "limit", req.Limit)
+	page, err := s.service.ListAudit(ctx, req.filter())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &todov1.ListAuditResponse{
+		Entries:    make([]*todov1.AuditEntry, len(page.Entries)),
+		NextCursor: page.NextCursor,
+	}
+	for i, entry := range page.Entries {
+		response.Entries[i] = auditEntryMessage(entry)
+	}
+	return response, nil
+}
+
+// WatchChanges implements todov1.TodoServiceServer. A stream the server
+// ends, because the feed broke off or the server is shutting down,
+// fails with UNAVAILABLE, so clients retry from the last change.
+func (s *GrpcServer) WatchChanges(message *todov1.WatchChangesRequest, stream grpc.ServerStreamingServer[todov1.Change]) error {
+	req, err := watchChangesRequest(message)
+	if err != nil {
+		return malformedStatus(err)
+	}
+	if err := validateRequest(req); err != nil {
+		return validationStatus(err)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	changes, err := s.service.WatchChanges(ctx, req.filter())
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("Streaming changes", "after_id", req.LastEventID)
+
+	// Like the event stream, the headers go out at once so the client
+	// knows it is subscribed before the first change.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				if err := stream.Context().Err(); err != nil {
+					return err
+				}
+				return status.Error(codes.Unavailable, "feed interrupted, resume with after_id")
+			}
+
+			if err := stream.Send(changeMessage(change)); err != nil {
+				return err
+			}
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server is shutting down, resume with after_id")
+		}
+	}
+}
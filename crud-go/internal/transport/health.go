@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// HealthCheck checks a dependency of the server, failing with an error
+// that says what is wrong.
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// AddLivenessCheck adds a check to /healthz. A failing liveness check
+// gets the process restarted, so it is only for what a restart fixes.
+// Checks are added before Start.
+func (s *HttpServer) AddLivenessCheck(name string, check HealthCheck) {
+	s.livenessChecks = append(s.livenessChecks, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck adds a check to /readyz, which takes the server out
+// of load balancing while any of them fails. Checks are added before
+// Start.
+func (s *HttpServer) AddReadinessCheck(name string, check HealthCheck) {
+	s.readinessChecks = append(s.readinessChecks, namedCheck{name: name, check: check})
+}
+
+// checkShutdown fails once the server is draining.
+func (s *HttpServer) checkShutdown(ctx context.Context) error {
+	if !s.ready.Load() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func (s *HttpServer) healthz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, s.livenessChecks, "alive", "not alive")
+}
+
+func (s *HttpServer) readyz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, s.readinessChecks, "ready", "not ready")
+}
+
+// writeHealth runs checks at once, each bounded by HealthCheckTimeout,
+// and answers 503 with the failing ones if there are any.
+func (s *HttpServer) writeHealth(w http.ResponseWriter, r *http.Request, checks []namedCheck, pass, fail string) {
+	response := HealthResponse{Status: pass, Checks: make(map[string]CheckResult, len(checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.runCheck(r.Context(), c.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[c.name] = result
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, result := range response.Checks {
+		if result.Error != "" {
+			response.Status, code = fail, http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *HttpServer) runCheck(ctx context.Context, check HealthCheck) CheckResult {
+	if s.config.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.HealthCheckTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    "pass",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status, result.Error = "fail", err.Error()
+	}
+
+	return result
+}
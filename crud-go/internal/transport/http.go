@@ -1,91 +1,384 @@
 package transport
 
 import (
+	"context"
+	"crud/internal/model"
 	"crud/internal/service"
 	"crud/internal/store"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type HttpServer struct {
 	config  *HttpConfig
 	logger  *slog.Logger
 	router  *mux.Router
+	handler http.Handler
+	server  *http.Server
 	service service.ITodoService
+	metrics *httpMetrics
+	// readLimiter and writeLimiter limit the requests of each client to
+	// read and write routes. Either is nil when off.
+	readLimiter  *rateLimiter
+	writeLimiter *rateLimiter
+	// trustedProxies are the parsed HttpConfig.TrustedProxies.
+	trustedProxies []netip.Prefix
+	// authSecret signs the bearer tokens of requests. Requests are not
+	// authenticated when it is nil.
+	authSecret []byte
+	// webhooks manages the webhooks, whose deliveries the
+	// service.Dispatcher sends.
+	webhooks service.IWebhookService
+	// lists manages the lists todos are kept in.
+	lists service.IListService
+
+	// ready is checked by /readyz. It is cleared before draining, so
+	// load balancers stop sending traffic first.
+	ready atomic.Bool
+	// livenessChecks and readinessChecks are run by /healthz and
+	// /readyz.
+	livenessChecks  []namedCheck
+	readinessChecks []namedCheck
+	// shutdown is closed when draining starts, which ends the change
+	// streams.
+	shutdown chan struct{}
 }
 
-func NewHttpServer(logger *slog.Logger, store store.Store, config *HttpConfig, serviceConfig *service.Config) *HttpServer {
-	return &HttpServer{
-		config:  config,
-		logger:  logger,
-		router:  mux.NewRouter(),
-		service: service.NewTodoService(logger, store.Todos(), serviceConfig),
+// NewHttpServer creates the API server of todos, which it may share
+// with the GrpcServer. Its request metrics are registered with
+// registerer. /readyz checks that the store can be reached and is fully
+// migrated, and that the server isn't shutting down.
+func NewHttpServer(logger *slog.Logger, store store.Store, todos service.ITodoService, config *HttpConfig, registerer prometheus.Registerer) *HttpServer {
+	s := &HttpServer{
+		config:       config,
+		logger:       logger,
+		router:       mux.NewRouter(),
+		service:      todos,
+		metrics:      newHttpMetrics(registerer),
+		readLimiter:  newRateLimiter(config.ReadRateLimit, config.ReadBurst),
+		writeLimiter: newRateLimiter(config.WriteRateLimit, config.WriteBurst),
+		webhooks:     service.NewWebhookService(logger, store),
+		lists:        service.NewListService(logger, store),
+		shutdown:     make(chan struct{}),
+	}
+	s.trustedProxies = parseTrustedProxies(logger, config.TrustedProxies)
+
+	s.server = &http.Server{
+		Addr:         config.BindAddress,
+		Handler:      s,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
 	}
+	s.server.RegisterOnShutdown(func() { close(s.shutdown) })
+
+	s.AddReadinessCheck("shutdown", s.checkShutdown)
+	s.AddReadinessCheck("database", store.Ping)
+	s.AddReadinessCheck("migrations", store.Migrator().Check)
+
+	return s
+}
+
+// ServeHTTP serves a request through the middleware and the router.
+func (s *HttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
 }
 
+// Start serves until Shutdown is called, then returns nil.
 func (s *HttpServer) Start() error {
 	s.logger.Info(`Starting API server`, "bind_address", s.config.BindAddress)
 
 	s.createEndpoints()
 
-	return http.ListenAndServe(s.config.BindAddress, s.router)
+	s.ready.Store(true)
+
+	if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown reports the server as not ready, waits ShutdownDelay for
+// load balancers to notice and then drains in-flight requests. Whatever
+// is still running when ctx is done gets its connection closed.
+func (s *HttpServer) Shutdown(ctx context.Context) error {
+	s.ready.Store(false)
+
+	s.logger.Info("Server is not ready, draining", "delay", s.config.ShutdownDelay)
+
+	select {
+	case <-time.After(s.config.ShutdownDelay):
+	case <-ctx.Done():
+	}
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+
+	return nil
 }
 
 func (s *HttpServer) createEndpoints() {
-	s.router.HandleFunc("/get", pipe[GetTodosRequest, GetTodosResponse](
+	s.router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	getTodos := pipe[GetTodosRequest, GetTodosResponse](
 		decodeRequest,
-		func(req *GetTodosRequest) (GetTodosResponse, error) { // Это контроллер и его стоит поместить в отдельный файл
+		func(ctx context.Context, req *GetTodosRequest) (GetTodosResponse, error) { // Это контроллер и его стоит поместить в отдельный файл
 			s.logger.Debug("GetTodosRequest", "ids", req.Ids)
-			todos, err := s.service.GetTodos(req.Ids)
+			todos, err := s.service.GetTodos(ctx, req.Ids)
 			return GetTodosResponse{Todos: todos}, err
 		},
 		encodeResponse,
 		s.logger,
-	)).Methods("GET")
+	)
+
+	getTodo := pipe[GetTodoRequest, GetTodoResponse](
+		decodeIdRequest[GetTodoRequest],
+		func(ctx context.Context, req *GetTodoRequest) (GetTodoResponse, error) {
+			s.logger.Debug("GetTodoRequest", "id", req.Id)
+			todo, err := s.service.GetTodo(ctx, req.Id)
+			if err != nil {
+				return GetTodoResponse{}, err
+			}
+			return GetTodoResponse{Todo: *todo}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	getTodoTree := pipe[GetTodoTreeRequest, GetTodoTreeResponse](
+		decodeIdRequest[GetTodoTreeRequest],
+		func(ctx context.Context, req *GetTodoTreeRequest) (GetTodoTreeResponse, error) {
+			s.logger.Debug("GetTodoTreeRequest", "id", req.Id)
+			tree, err := s.service.GetTodoTree(ctx, req.Id)
+			if err != nil {
+				return GetTodoTreeResponse{}, err
+			}
+			return GetTodoTreeResponse{Todo: *tree}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
 
-	s.router.HandleFunc("/create", pipe[CreateTodoRequest, CreateTodoResponse](
+	listTodos := func(list func(context.Context, model.TodoFilter) (*model.TodoPage, error)) http.HandlerFunc {
+		return pipe[ListTodosRequest, ListTodosResponse](
+			decodeListTodosRequest,
+			func(ctx context.Context, req *ListTodosRequest) (ListTodosResponse, error) {
+				s.logger.Debug("ListTodosRequest", "cursor", req.Cursor, "limit", req.Limit)
+				page, err := list(ctx, req.filter())
+				if err != nil {
+					return ListTodosResponse{}, err
+				}
+				return ListTodosResponse{Todos: page.Todos, NextCursor: page.NextCursor}, nil
+			},
+			encodeResponse,
+			s.logger,
+		)
+	}
+
+	searchTodos := pipe[SearchTodosRequest, SearchTodosResponse](
+		decodeSearchTodosRequest,
+		func(ctx context.Context, req *SearchTodosRequest) (SearchTodosResponse, error) {
+			s.logger.Debug("SearchTodosRequest", "query", req.Query, "cursor", req.Cursor, "limit", req.Limit)
+			page, err := s.service.SearchTodos(ctx, req.filter())
+			if err != nil {
+				return SearchTodosResponse{}, err
+			}
+			return SearchTodosResponse{Results: page.Results, NextCursor: page.NextCursor}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	importTodos := pipe[ImportTodosRequest, ImportTodosResponse](
+		decodeImportTodosRequest,
+		func(ctx context.Context, req *ImportTodosRequest) (ImportTodosResponse, error) {
+			s.logger.Debug("ImportTodosRequest", "todos", len(req.Todos), "dry_run", req.DryRun)
+			todos, err := s.service.ImportTodos(ctx, req.todos(), req.DryRun)
+			if err != nil {
+				return ImportTodosResponse{}, err
+			}
+			return ImportTodosResponse{DryRun: req.DryRun, Todos: todos}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	createTodo := pipe[CreateTodoRequest, CreateTodoResponse](
 		decodeRequest,
-		func(req *CreateTodoRequest) (CreateTodoResponse, error) {
+		func(ctx context.Context, req *CreateTodoRequest) (CreateTodoResponse, error) {
 			s.logger.Debug("CreateTodoRequest", "title", req.Title)
-			todo, err := s.service.CreateTodo(req.Title)
-			return CreateTodoResponse{Todo: *todo}, err
+			todo, err := s.service.CreateTodo(ctx, req.todo())
+			if err != nil {
+				return CreateTodoResponse{}, err
+			}
+			return CreateTodoResponse{Todo: *todo}, nil
 		},
 		encodeResponse,
 		s.logger,
-	)).Methods("POST")
+	)
 
-	s.router.HandleFunc("/toggle", pipe[ToggleTodoRequest, ToggleTodoResponse](
-		decodeRequest,
-		func(req *ToggleTodoRequest) (ToggleTodoResponse, error) {
-			s.logger.Debug("ToggleTodoRequest", "id", req.Id)
-			todo, err := s.service.ToggleTodo(req.Id)
-			return ToggleTodoResponse{Todo: *todo}, err
+	toggleTodo := func(decoder func(*http.Request) (*ToggleTodoRequest, error)) http.HandlerFunc {
+		return pipe[ToggleTodoRequest, ToggleTodoResponse](
+			decoder,
+			func(ctx context.Context, req *ToggleTodoRequest) (ToggleTodoResponse, error) {
+				s.logger.Debug("ToggleTodoRequest", "id", req.Id, "cascade", req.Cascade)
+				todo, err := s.service.ToggleTodo(ctx, req.Id, req.options())
+				if err != nil {
+					return ToggleTodoResponse{}, err
+				}
+				return ToggleTodoResponse{Todo: *todo}, nil
+			},
+			encodeResponse,
+			s.logger,
+		)
+	}
+
+	updateTodo := func(decoder func(*http.Request) (*UpdateTodoRequest, error)) http.HandlerFunc {
+		return pipe[UpdateTodoRequest, UpdateTodoResponse](
+			decoder,
+			func(ctx context.Context, req *UpdateTodoRequest) (UpdateTodoResponse, error) {
+				s.logger.Debug("UpdateTodoRequest", "id", req.Id)
+				todo, err := s.service.UpdateTodo(ctx, req.Id, req.patch())
+				if err != nil {
+					return UpdateTodoResponse{}, err
+				}
+				return UpdateTodoResponse{Todo: *todo}, nil
+			},
+			encodeResponse,
+			s.logger,
+		)
+	}
+
+	deleteTodo := func(decoder func(*http.Request) (*DeleteTodoRequest, error)) http.HandlerFunc {
+		return pipe[DeleteTodoRequest, DeleteTodoResponse](
+			decoder,
+			func(ctx context.Context, req *DeleteTodoRequest) (DeleteTodoResponse, error) {
+				s.logger.Debug("DeleteTodoRequest", "id", req.Id, "cascade", req.Cascade)
+				todo, err := s.service.DeleteTodo(ctx, req.Id, req.options())
+				if err != nil {
+					return DeleteTodoResponse{}, err
+				}
+				return DeleteTodoResponse{Todo: *todo}, nil
+			},
+			encodeResponse,
+			s.logger,
+		)
+	}
+
+	restoreTodo := pipe[RestoreTodoRequest, RestoreTodoResponse](
+		decodeIdRequest[RestoreTodoRequest],
+		func(ctx context.Context, req *RestoreTodoRequest) (RestoreTodoResponse, error) {
+			s.logger.Debug("RestoreTodoRequest", "id", req.Id)
+			todo, err := s.service.RestoreTodo(ctx, req.Id)
+			if err != nil {
+				return RestoreTodoResponse{}, err
+			}
+			return RestoreTodoResponse{Todo: *todo}, nil
 		},
 		encodeResponse,
 		s.logger,
-	)).Methods("POST")
+	)
 
-	s.router.HandleFunc("/update", pipe[UpdateTodoRequest, UpdateTodoResponse](
-		decodeRequest,
-		func(req *UpdateTodoRequest) (UpdateTodoResponse, error) {
-			s.logger.Debug("UpdateTodoRequest", "id", req.Id)
-			todo, err := s.service.UpdateTodo(req.Id, req.Title, req.Complete)
-			return UpdateTodoResponse{Todo: *todo}, err
+	listAudit := pipe[ListAuditRequest, ListAuditResponse](
+		decodeListAuditRequest,
+		func(ctx context.Context, req *ListAuditRequest) (ListAuditResponse, error) {
+			s.logger.Debug("ListAuditRequest", "todo_id", req.TodoID, "cursor", req.Cursor, "limit", req.Limit)
+			page, err := s.service.ListAudit(ctx, req.filter())
+			if err != nil {
+				return ListAuditResponse{}, err
+			}
+			return ListAuditResponse{Entries: page.Entries, NextCursor: page.NextCursor}, nil
 		},
 		encodeResponse,
 		s.logger,
-	)).Methods("POST")
+	)
 
-	s.router.HandleFunc("/delete", pipe[DeleteTodoRequest, DeleteTodoResponse](
+	batch := pipe[BatchRequest, BatchResponse](
 		decodeRequest,
-		func(req *DeleteTodoRequest) (DeleteTodoResponse, error) {
-			s.logger.Debug("DeleteTodoRequest", "id", req.Id)
-			todo, err := s.service.DeleteTodo(req.Id)
-			return DeleteTodoResponse{Todo: *todo}, err
+		func(ctx context.Context, req *BatchRequest) (BatchResponse, error) {
+			s.logger.Debug("BatchRequest", "mode", req.Mode, "operations", len(req.Operations))
+
+			ops := make([]model.BatchOp, len(req.Operations))
+			for i := range req.Operations {
+				ops[i] = req.Operations[i].op()
+			}
+
+			results, err := s.service.Batch(ctx, ops, req.Mode != BatchModeBestEffort)
+			if err != nil {
+				return BatchResponse{}, err
+			}
+
+			response := BatchResponse{Results: make([]BatchResult, len(results))}
+			for i, result := range results {
+				if result.Err != nil {
+					problem := errorProblem(result.Err)
+					if problem.Status >= http.StatusInternalServerError {
+						s.logger.Error("Batch operation error", "index", i, "error", result.Err.Error())
+					}
+					response.Results[i] = BatchResult{Status: problem.Status, Problem: &problem}
+					continue
+				}
+
+				status := http.StatusOK
+				if ops[i].Type == model.BatchCreate {
+					status = http.StatusCreated
+				}
+				response.Results[i] = BatchResult{Status: status, Todo: result.Todo}
+			}
+
+			return response, nil
 		},
 		encodeResponse,
 		s.logger,
-	)).Methods("POST")
+	)
+
+	s.handler = s.withRoute(
+		s.withMetrics(
+			withRequestInfo(
+				s.withAuth(
+					s.withRateLimit(
+						s.withBodyLimit(
+							s.withTimeout(s.router)))))))
+
+	s.router.HandleFunc("/healthz", s.healthz).Methods("GET").Name("healthz")
+	s.router.HandleFunc("/readyz", s.readyz).Methods("GET").Name("readyz")
+
+	s.router.HandleFunc("/todos", listTodos(s.service.ListTodos)).Methods("GET").Name("listTodos")
+	s.router.HandleFunc("/todos", createTodo).Methods("POST").Name("createTodo")
+	s.router.HandleFunc("/todos/search", searchTodos).Methods("GET").Name("searchTodos")
+	s.router.HandleFunc("/todos/export", s.exportTodos).Methods("GET").Name("exportTodos")
+	s.router.HandleFunc("/todos/import", importTodos).Methods("POST").Name("importTodos")
+	s.router.HandleFunc("/todos/{id:[0-9]+}", getTodo).Methods("GET").Name("getTodo")
+	s.router.HandleFunc("/todos/{id:[0-9]+}", updateTodo(decodeIdRequest[UpdateTodoRequest])).Methods("PATCH").Name("updateTodo")
+	s.router.HandleFunc("/todos/{id:[0-9]+}", deleteTodo(decodeIdRequest[DeleteTodoRequest])).Methods("DELETE").Name("deleteTodo")
+	s.router.HandleFunc("/batch", batch).Methods("POST").Name("batch")
+	s.router.HandleFunc("/todos/{id:[0-9]+}/tree", getTodoTree).Methods("GET").Name("getTodoTree")
+	s.router.HandleFunc("/todos/{id:[0-9]+}/toggle", toggleTodo(decodeIdRequest[ToggleTodoRequest])).Methods("POST").Name("toggleTodo")
+	s.router.HandleFunc("/todos/{id:[0-9]+}/restore", restoreTodo).Methods("POST").Name("restoreTodo")
+	s.router.HandleFunc("/trash", listTodos(s.service.ListTrash)).Methods("GET").Name("listTrash")
+	s.router.HandleFunc("/todos/{id:[0-9]+}/history", listAudit).Methods("GET").Name("getTodoHistory")
+	s.router.HandleFunc("/audit", listAudit).Methods("GET").Name("listAudit")
+	s.router.HandleFunc("/changes", s.watchChanges).Methods("GET").Name("watchChanges")
+	s.createWebhookEndpoints()
+	s.createListEndpoints()
+
+	// RPC-style routes kept as aliases for older clients. They share the
+	// route names, and so the timeouts, of the routes above.
+	s.router.HandleFunc("/get", getTodos).Methods("GET").Name("getTodos")
+	s.router.HandleFunc("/list", listTodos(s.service.ListTodos)).Methods("GET").Name("listTodos")
+	s.router.HandleFunc("/create", createTodo).Methods("POST").Name("createTodo")
+	s.router.HandleFunc("/toggle", toggleTodo(decodeRequest)).Methods("POST").Name("toggleTodo")
+	s.router.HandleFunc("/update", updateTodo(decodeRequest)).Methods("POST").Name("updateTodo")
+	s.router.HandleFunc("/delete", deleteTodo(decodeRequest)).Methods("POST").Name("deleteTodo")
 }
@@ -1,11 +1,81 @@
 package transport
 
+import "time"
+
 type HttpConfig struct {
 	BindAddress string
+	// AdminBindAddress is where /metrics is served, apart from the API.
+	// Empty turns the admin server off.
+	AdminBindAddress string
+	// RequestTimeout bounds every request, including its database work.
+	RequestTimeout time.Duration
+	// RouteTimeouts overrides RequestTimeout by route name, e.g.
+	// "listTodos". Names are matched case-insensitively.
+	RouteTimeouts map[string]time.Duration
+
+	// ReadRateLimit and WriteRateLimit are the requests per second a
+	// client may make to read and write routes, in bursts of up to
+	// ReadBurst and WriteBurst. Clients are told apart by user, or by IP
+	// address when anonymous. Zero turns a limit off.
+	ReadRateLimit  float64
+	ReadBurst      int
+	WriteRateLimit float64
+	WriteBurst     int
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in
+	// front of the server. The IP address of a request from one of them
+	// is taken from X-Forwarded-For; without any, every client behind a
+	// proxy shares its rate limits.
+	TrustedProxies []string
+
+	// MaxBodySize is the largest request body accepted, in bytes.
+	// RouteMaxBodySizes overrides it by route name. Zero is no limit.
+	MaxBodySize       int64
+	RouteMaxBodySizes map[string]int64
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// StreamHeartbeat is how often an idle change stream is kept alive.
+	// Zero sends no heartbeats.
+	StreamHeartbeat time.Duration
+
+	// HealthCheckTimeout bounds each check of /healthz and /readyz.
+	HealthCheckTimeout time.Duration
+
+	// ShutdownDelay is how long the server keeps serving after it has
+	// reported itself not ready, before it starts draining.
+	ShutdownDelay time.Duration
+	// ShutdownGracePeriod is how long in-flight requests get to finish.
+	ShutdownGracePeriod time.Duration
 }
 
 func NewHttpConfig() *HttpConfig {
 	return &HttpConfig{
-		BindAddress: "",
+		BindAddress:      "",
+		AdminBindAddress: ":9090",
+		RequestTimeout:   10 * time.Second,
+		RouteTimeouts:    map[string]time.Duration{},
+
+		ReadRateLimit:  50,
+		ReadBurst:      100,
+		WriteRateLimit: 10,
+		WriteBurst:     20,
+		TrustedProxies: []string{},
+
+		MaxBodySize: 1 << 20,
+		// Imports carry whole exports.
+		RouteMaxBodySizes: map[string]int64{"importTodos": 64 << 20},
+
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+
+		StreamHeartbeat: 15 * time.Second,
+
+		HealthCheckTimeout: 2 * time.Second,
+
+		ShutdownDelay:       0,
+		ShutdownGracePeriod: 30 * time.Second,
 	}
 }
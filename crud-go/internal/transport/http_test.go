@@ -0,0 +1,1680 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"crud/internal/service"
+	mock_service "crud/internal/service/mocks"
+	"crud/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(service *mock_service.MockITodoService, config *HttpConfig) *HttpServer {
+	s := &HttpServer{
+		config:  config,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		router:  mux.NewRouter(),
+		service: service,
+		metrics: newHttpMetrics(prometheus.NewRegistry()),
+	}
+	s.createEndpoints()
+	return s
+}
+
+func TestHttp_Routes(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockITodoService)
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+
+	testTable := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		mockBehavior    mockBehavior
+		expectedStatus  int
+		expectedProblem string
+	}{
+		{
+			name:   "Get todo",
+			method: "GET",
+			path:   "/todos/1",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Get missing todo",
+			method: "GET",
+			path:   "/todos/2",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().GetTodo(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+			},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+		{
+			name:   "Create todo",
+			method: "POST",
+			path:   "/todos",
+			body:   `{"listId":1,"title":"Title 1"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{ListID: 1, Title: "Title 1"}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:   "Create todo with fields",
+			method: "POST",
+			path:   "/todos",
+			body:   `{"listId":1,"title":"Title 1","priority":"high","dueAt":"2030-03-10T12:00:00Z","dueTimezone":"Europe/Berlin","tags":["work"]}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				dueAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{
+					ListID:      1,
+					Title:       "Title 1",
+					Priority:    model.PriorityHigh,
+					DueAt:       &dueAt,
+					DueTimezone: "Europe/Berlin",
+					Tags:        []string{"work"},
+				}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:            "Create todo with unknown priority",
+			method:          "POST",
+			path:            "/todos",
+			body:            `{"listId":1,"title":"Title 1","priority":"whenever"}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:            "Create todo with unknown timezone",
+			method:          "POST",
+			path:            "/todos",
+			body:            `{"listId":1,"title":"Title 1","dueTimezone":"Mars/Olympus"}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:            "Create invalid todo",
+			method:          "POST",
+			path:            "/todos",
+			body:            `{"listId":1,"title":"T"}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:            "Create malformed todo",
+			method:          "POST",
+			path:            "/todos",
+			body:            `{"title":`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:   "Update todo",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"complete":true}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				complete := true
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{Complete: &complete}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Update todo clears due date",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"dueAt":null}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{DueAt: model.NullableOf[time.Time](nil)}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Move todo to an archived list",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"listId":2}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				listID := model.ID(2)
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{ListID: &listID}).Return(nil, store.ErrListArchived)
+			},
+			expectedStatus:  http.StatusConflict,
+			expectedProblem: ProblemConflict,
+		},
+		{
+			name:   "Create recurring todo",
+			method: "POST",
+			path:   "/todos",
+			body:   `{"listId":1,"title":"Title 1","dueAt":"2030-03-10T12:00:00Z","recurrence":"FREQ=WEEKLY"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				dueAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{ListID: 1, Title: "Title 1", DueAt: &dueAt, Recurrence: "FREQ=WEEKLY"}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:   "Create recurring todo without due date",
+			method: "POST",
+			path:   "/todos",
+			body:   `{"listId":1,"title":"Title 1","recurrence":"FREQ=WEEKLY"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().CreateTodo(gomock.Any(), model.Todo{ListID: 1, Title: "Title 1", Recurrence: "FREQ=WEEKLY"}).
+					Return(nil, fmt.Errorf("%w: a recurring todo needs a due date", model.ErrInvalidArgument))
+			},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemInvalidArgument,
+		},
+		{
+			name:   "Update series",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"title":"Title 2","scope":"series"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				title := "Title 2"
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{Title: &title, Series: true}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Update occurrence",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"recurrence":"","scope":"occurrence"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				recurrence := ""
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{Recurrence: &recurrence}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Update todo with unknown scope",
+			method:          "PATCH",
+			path:            "/todos/1",
+			body:            `{"title":"Title 2","scope":"future"}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:   "List occurrences of a series",
+			method: "GET",
+			path:   "/todos?seriesId=3",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				seriesID := model.ID(3)
+				s.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{SeriesID: &seriesID}).Return(&model.TodoPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "List todos of a list",
+			method: "GET",
+			path:   "/todos?listId=2",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				listID := model.ID(2)
+				s.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{ListID: &listID}).Return(&model.TodoPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "List todos by tags",
+			method: "GET",
+			path:   "/todos?tags=work,home&tags=urgent&minPriority=high&sortBy=due_at",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				priority := model.PriorityHigh
+				s.EXPECT().ListTodos(gomock.Any(), model.TodoFilter{
+					Tags:        []string{"work", "home", "urgent"},
+					MinPriority: &priority,
+					SortBy:      model.SortByDueAt,
+				}).Return(&model.TodoPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Delete missing todo alias",
+			method: "POST",
+			path:   "/delete",
+			body:   `{"id":3}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().DeleteTodo(gomock.Any(), model.ID(3), model.DeleteOptions{}).Return(&model.Todo{}, model.ErrNotFound)
+			},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+		{
+			name:   "Toggle internal error",
+			method: "POST",
+			path:   "/todos/1/toggle",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), model.ToggleOptions{}).Return(&model.Todo{}, errors.New("connection refused"))
+			},
+			expectedStatus:  http.StatusInternalServerError,
+			expectedProblem: ProblemInternal,
+		},
+		{
+			name:   "Get todo tree",
+			method: "GET",
+			path:   "/todos/1/tree",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().GetTodoTree(gomock.Any(), model.ID(1)).Return(&model.TodoTree{Todo: todo}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Delete todo cascade",
+			method: "DELETE",
+			path:   "/todos/1?cascade=true",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().DeleteTodo(gomock.Any(), model.ID(1), model.DeleteOptions{Cascade: true}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Toggle todo cascade alias",
+			method: "POST",
+			path:   "/toggle",
+			body:   `{"id":1,"cascade":true}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), model.ToggleOptions{Cascade: true}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Move todo under itself",
+			method: "PATCH",
+			path:   "/todos/1",
+			body:   `{"parentId":1}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				parent := model.ID(1)
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{ParentID: model.NullableOf(&parent)}).Return(nil, store.ErrCycle)
+			},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemInvalidArgument,
+		},
+		{
+			name:   "List trash",
+			method: "GET",
+			path:   "/trash?sortBy=updated_at",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().ListTrash(gomock.Any(), model.TodoFilter{SortBy: model.SortByUpdatedAt}).Return(&model.TodoPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Restore todo",
+			method: "POST",
+			path:   "/todos/1/restore",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().RestoreTodo(gomock.Any(), model.ID(1)).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Restore todo not in trash",
+			method: "POST",
+			path:   "/todos/2/restore",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().RestoreTodo(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+			},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+		{
+			name:   "Todo history",
+			method: "GET",
+			path:   "/todos/1/history?limit=5",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				id := model.ID(1)
+				s.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{TodoID: &id, Limit: 5}).Return(&model.AuditPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Audit by time range",
+			method: "GET",
+			path:   "/audit?createdAfter=2024-01-01T00:00:00Z&createdBefore=2024-02-01T00:00:00Z",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+				s.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{CreatedAfter: &after, CreatedBefore: &before}).Return(&model.AuditPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Audit with malformed time",
+			method:          "GET",
+			path:            "/audit?createdAfter=yesterday",
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:   "Audit with invalid cursor",
+			method: "GET",
+			path:   "/audit?cursor=nope",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().ListAudit(gomock.Any(), model.AuditFilter{Cursor: "nope"}).Return(nil, store.ErrInvalidCursor)
+			},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemInvalidArgument,
+		},
+		{
+			name:            "Watch changes of malformed todo id",
+			method:          "GET",
+			path:            "/changes?todoId=one",
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:            "Watch changes of unknown type",
+			method:          "GET",
+			path:            "/changes?type=created,renamed",
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:   "Search todos",
+			method: "GET",
+			path:   "/todos/search?q=milk+bu&limit=5&cursor=abc",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().SearchTodos(gomock.Any(), model.SearchFilter{Query: "milk bu", Cursor: "abc", Limit: 5}).
+					Return(&model.SearchPage{Results: []model.SearchResult{{Todo: todo, Rank: 0.5}}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Search without query",
+			method:          "GET",
+			path:            "/todos/search",
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:            "Unknown route",
+			method:          "GET",
+			path:            "/nope",
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			service := mock_service.NewMockITodoService(ctrl)
+			tt.mockBehavior(service)
+
+			rec := httptest.NewRecorder()
+			newTestServer(service, NewHttpConfig()).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+
+			if tt.expectedProblem != "" {
+				var problem Problem
+				assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				assert.Equal(t, tt.expectedProblem, problem.Type)
+				assert.Equal(t, tt.expectedStatus, problem.Status)
+			}
+		})
+	}
+}
+
+func TestHttp_RouteTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock_service.NewMockITodoService(ctrl)
+	service.EXPECT().ListTodos(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, filter model.TodoFilter) (*model.TodoPage, error) {
+			<-ctx.Done()
+			return nil, errors.New("pq: canceling statement due to user request")
+		},
+	)
+
+	config := NewHttpConfig()
+	config.RouteTimeouts = map[string]time.Duration{"listtodos": 10 * time.Millisecond}
+
+	rec := httptest.NewRecorder()
+	newTestServer(service, config).ServeHTTP(rec, httptest.NewRequest("GET", "/todos", nil))
+
+	var problem Problem
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+	assert.Equal(t, ProblemTimeout, problem.Type)
+}
+
+func TestHttp_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+
+	service := mock_service.NewMockITodoService(ctrl)
+	service.EXPECT().CreateTodo(gomock.Any(), gomock.Any()).Return(&todo, nil).Times(2)
+
+	registry := prometheus.NewRegistry()
+	server := newTestServer(service, NewHttpConfig())
+	server.metrics = newHttpMetrics(registry)
+
+	for _, body := range []string{`{"listId":1,"title":"Title 1"}`, `{"listId":1,"title":"Title 1"}`, `{"listId":1,"title":"T"}`, `{"title":`} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/todos", strings.NewReader(body)))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues("createTodo", "201")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues("createTodo", "422")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues("createTodo", "400")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.rejected.WithLabelValues("createTodo", "validate")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.rejected.WithLabelValues("createTodo", "decode")))
+
+	// Requests no route matches are counted too, and get a request id.
+	for _, req := range []*http.Request{httptest.NewRequest("GET", "/nope", nil), httptest.NewRequest("PUT", "/todos", nil)} {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues("unmatched", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.metrics.requests.WithLabelValues("unmatched", "405")))
+
+	// The admin server exposes them in the text format.
+	rec := httptest.NewRecorder()
+	NewAdminServer(server.logger, NewHttpConfig(), registry).router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `crud_http_request_duration_seconds_count{route="createTodo",status="201"} 2`)
+	assert.Contains(t, rec.Body.String(), `crud_http_rejected_requests_total{route="createTodo",stage="decode"} 1`)
+}
+
+func TestHttp_Health(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := NewHttpConfig()
+	config.HealthCheckTimeout = 10 * time.Millisecond
+
+	server := newTestServer(mock_service.NewMockITodoService(ctrl), config)
+	server.AddReadinessCheck("shutdown", server.checkShutdown)
+	server.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+	server.AddReadinessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	server.ready.Store(true)
+
+	get := func(path string) (int, HealthResponse) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		var response HealthResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		return rec.Code, response
+	}
+
+	code, response := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "alive", response.Status)
+
+	code, response = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", response.Status)
+	assert.Equal(t, "pass", response.Checks["shutdown"].Status)
+	assert.Equal(t, "pass", response.Checks["database"].Status)
+	assert.Equal(t, "fail", response.Checks["slow"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), response.Checks["slow"].Error)
+	assert.GreaterOrEqual(t, response.Checks["slow"].LatencyMs, 10.0)
+
+	server.readinessChecks = server.readinessChecks[:2]
+
+	code, response = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", response.Status)
+
+	server.ready.Store(false)
+
+	code, response = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, errShuttingDown.Error(), response.Checks["shutdown"].Error)
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		decision := limiter.take("a")
+		assert.True(t, decision.allowed)
+		assert.Equal(t, i, decision.remaining)
+	}
+
+	decision := limiter.take("a")
+	assert.False(t, decision.allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.retryAfter)
+	assert.Equal(t, 1500*time.Millisecond, decision.reset)
+
+	// Other clients have buckets of their own.
+	assert.True(t, limiter.take("b").allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.take("a").allowed)
+	assert.False(t, limiter.take("a").allowed)
+
+	// Buckets that are full again are dropped.
+	now = now.Add(rateLimitSweep)
+	limiter.take("c")
+	assert.Len(t, limiter.buckets, 1)
+
+	assert.Nil(t, newRateLimiter(0, 10))
+}
+
+func TestClientKey(t *testing.T) {
+	ctx := service.WithRequestInfo(context.Background(), service.RequestInfo{Actor: "alice"})
+
+	assert.Equal(t, "ip:192.0.2.1", clientKey(ctx, "192.0.2.1:1234"))
+	assert.Equal(t, "ip:192.0.2.1", clientKey(ctx, "192.0.2.1"))
+	assert.Equal(t, "user:7", clientKey(model.WithUser(ctx, 7), "192.0.2.1:1234"))
+}
+
+func TestHttp_ClientAddr(t *testing.T) {
+	server := &HttpServer{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	server.trustedProxies = parseTrustedProxies(server.logger, []string{"10.0.0.0/8", "192.0.2.9", "nope"})
+	assert.Len(t, server.trustedProxies, 2, "invalid proxies are left out")
+
+	testTable := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "198.51.100.1:1234",
+			forwarded:  []string{"203.0.113.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "Through a proxy",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"203.0.113.1"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "Through proxies",
+			remoteAddr: "192.0.2.9:1234",
+			forwarded:  []string{"198.51.100.7, 203.0.113.1", "10.1.2.3"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "Only proxies",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"10.0.0.2, 10.0.0.3"},
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "Proxy without header",
+			remoteAddr: "10.0.0.1:1234",
+			expected:   "10.0.0.1",
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/todos", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+
+			assert.Equal(t, tt.expected, server.clientAddr(req))
+		})
+	}
+}
+
+func TestHttp_Limits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+
+	service := mock_service.NewMockITodoService(ctrl)
+	service.EXPECT().CreateTodo(gomock.Any(), gomock.Any()).Return(&todo, nil).Times(3)
+	service.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&todo, nil)
+
+	config := NewHttpConfig()
+	config.MaxBodySize = 32
+
+	server := newTestServer(service, config)
+	server.writeLimiter = newRateLimiter(0.001, 2)
+
+	serve := func(method, path, body, actor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Actor", actor)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	problemType := func(rec *httptest.ResponseRecorder) string {
+		var problem Problem
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		return problem.Type
+	}
+
+	rec := serve("POST", "/todos", `{"listId":1,"title":"Title 1"}`, "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1000", rec.Header().Get("RateLimit-Reset"))
+
+	serve("POST", "/todos", `{"listId":1,"title":"Title 1"}`, "")
+
+	rec = serve("POST", "/todos", `{"listId":1,"title":"Title 1"}`, "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1000", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, ProblemRateLimited, problemType(rec))
+
+	// A new actor doesn't get around the limit of its address.
+	assert.Equal(t, http.StatusTooManyRequests, serve("POST", "/todos", `{"listId":1,"title":"Title 1"}`, "alice").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("POST", "/todos", `{"listId":1,"title":"Title 1"}`, "bob").Code)
+
+	// Other addresses are limited apart, reads apart from writes, and
+	// probes not at all.
+	req := httptest.NewRequest("POST", "/todos", strings.NewReader(`{"listId":1,"title":"Title 1"}`))
+	req.RemoteAddr = "192.0.2.2:1234"
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/todos/1", "", "").Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/healthz", "", "").Code)
+
+	server.writeLimiter = nil
+
+	// A body sent with its length is turned away before decoding, one
+	// streamed is when decoding passes the limit.
+	rec = serve("POST", "/todos", `{"title":"`+strings.Repeat("a", 32)+`"}`, "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, ProblemRequestTooLarge, problemType(rec))
+
+	req = httptest.NewRequest("POST", "/todos", io.MultiReader(strings.NewReader(`{"title":"`+strings.Repeat("a", 32)+`"}`)))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, ProblemRequestTooLarge, problemType(rec))
+}
+
+func TestHttp_Auth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	secret := []byte("secret")
+	sign := func(key []byte, method jwt.SigningMethod, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		assert.NoError(t, err)
+		return token
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	todo := model.Todo{ID: 1, Title: "Title 1", UserID: 7}
+
+	var infos []service.RequestInfo
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().GetTodo(gomock.Any(), model.ID(1)).DoAndReturn(
+		func(ctx context.Context, id model.ID) (*model.Todo, error) {
+			infos = append(infos, service.RequestInfoFrom(ctx))
+			if user, _ := model.UserFrom(ctx); user != todo.UserID {
+				return nil, model.ErrNotFound
+			}
+			return &todo, nil
+		},
+	).Times(3)
+
+	server := newTestServer(svc, NewHttpConfig())
+	server.RequireAuth(secret)
+
+	serve := func(authorization, actor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/todos/1", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		req.Header.Set("X-Actor", actor)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("Bearer "+sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": expires}), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve("bearer "+sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": expires}), "alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Todos of other users look like they don't exist.
+	rec = serve("Bearer "+sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 8, "exp": expires}), "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The actor named by the request can't stand in for the user.
+	assert.Equal(t, []string{"user:7", "user:7", "user:8"}, []string{infos[0].Actor, infos[1].Actor, infos[2].Actor})
+
+	testTable := []struct {
+		name          string
+		authorization string
+		challenge     string
+	}{
+		{name: "Missing", challenge: "Bearer"},
+		{name: "Other scheme", authorization: "Basic YWxpY2U6c2VjcmV0", challenge: "Bearer"},
+		{name: "Other secret", authorization: "Bearer " + sign([]byte("other"), jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": expires}), challenge: `Bearer error="invalid_token"`},
+		{name: "Other method", authorization: "Bearer " + sign(secret, jwt.SigningMethodHS512, jwt.MapClaims{"user_id": 7, "exp": expires}), challenge: `Bearer error="invalid_token"`},
+		{name: "Expired", authorization: "Bearer " + sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": time.Now().Add(-time.Hour).Unix()}), challenge: `Bearer error="invalid_token"`},
+		{name: "Not expiring", authorization: "Bearer " + sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7}), challenge: `Bearer error="invalid_token"`},
+		{name: "No user", authorization: "Bearer " + sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"exp": expires}), challenge: `Bearer error="invalid_token"`},
+		{name: "Zero user", authorization: "Bearer " + sign(secret, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 0, "exp": expires}), challenge: `Bearer error="invalid_token"`},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.authorization, "")
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, tt.challenge, rec.Header().Get("WWW-Authenticate"))
+
+			var problem Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+			assert.Equal(t, ProblemUnauthorized, problem.Type)
+		})
+	}
+
+	// Probes don't need a token.
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHttp_RequestInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+
+	var infos []service.RequestInfo
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id model.ID, opts model.ToggleOptions) (*model.Todo, error) {
+			infos = append(infos, service.RequestInfoFrom(ctx))
+			return &todo, nil
+		},
+	).Times(2)
+
+	server := newTestServer(svc, NewHttpConfig())
+
+	req := httptest.NewRequest("POST", "/todos/1/toggle", nil)
+	req.Header.Set("X-Actor", "alice")
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-ID"))
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", "/todos/1/toggle", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+
+	assert.Equal(t, []service.RequestInfo{
+		{Actor: "alice", RequestID: "req-1"},
+		{Actor: "anonymous", RequestID: rec.Header().Get("X-Request-ID")},
+	}, infos)
+}
+
+func TestHttp_ChangesEventStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changes := make(chan model.Change, 2)
+	changes <- model.Change{ID: 8, Type: model.ChangeCreated, Todo: model.Todo{ID: 1, Title: "Title 1"}}
+	changes <- model.Change{ID: 9, Type: model.ChangeDeleted, Todo: model.Todo{ID: 1, Title: "Title 1"}}
+	close(changes)
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().WatchChanges(gomock.Any(), model.ChangeFilter{
+		TodoIDs: []model.ID{1, 2},
+		Types:   []model.ChangeType{model.ChangeCreated, model.ChangeDeleted},
+		AfterID: 7,
+	}).Return(changes, nil)
+
+	server := httptest.NewServer(newTestServer(svc, NewHttpConfig()).router)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/changes?todoId=1,2&type=created&type=deleted&lastEventId=3", nil)
+	req.Header.Set("Last-Event-ID", "7")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	assert.Len(t, events, 2)
+	assert.True(t, strings.HasPrefix(events[0], "id: 8\nevent: created\ndata: {"), events[0])
+	assert.True(t, strings.HasPrefix(events[1], "id: 9\nevent: deleted\ndata: {"), events[1])
+
+	var change model.Change
+	_, data, _ := strings.Cut(events[1], "data: ")
+	assert.NoError(t, json.Unmarshal([]byte(data), &change))
+	assert.Equal(t, "Title 1", change.Todo.Title)
+}
+
+func TestHttp_ChangesWebSocket(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changes := make(chan model.Change)
+
+	svc := mock_service.NewMockITodoService(ctrl)
+	svc.EXPECT().WatchChanges(gomock.Any(), model.ChangeFilter{Tags: []string{"work"}, AfterID: 3}).Return(changes, nil)
+
+	server := httptest.NewServer(newTestServer(svc, NewHttpConfig()).router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/changes?tags=work&lastEventId=3", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	changes <- model.Change{ID: 4, Type: model.ChangeUpdated, Todo: model.Todo{ID: 1, Tags: []string{"work"}}}
+
+	var change model.Change
+	assert.NoError(t, conn.ReadJSON(&change))
+	assert.Equal(t, int64(4), change.ID)
+	assert.Equal(t, model.ChangeUpdated, change.Type)
+
+	// The feed breaking off asks the client to come back later.
+	close(changes)
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), err)
+}
+
+func TestHttp_Batch(t *testing.T) {
+	todo := model.Todo{ID: 1, Title: "Title 1"}
+
+	testTable := []struct {
+		name            string
+		body            string
+		mockBehavior    func(s *mock_service.MockITodoService)
+		expectedStatus  int
+		expectedProblem string
+		expectedField   string
+		expectedResults []int
+	}{
+		{
+			name: "Atomic",
+			body: `{"operations":[{"op":"create","create":{"listId":1,"title":"Title 1"}},{"op":"toggle","toggle":{"id":1,"cascade":true}}]}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().Batch(gomock.Any(), []model.BatchOp{
+					{Type: model.BatchCreate, Todo: model.Todo{ListID: 1, Title: "Title 1"}},
+					{Type: model.BatchToggle, ID: 1, Cascade: true},
+				}, true).Return([]model.BatchResult{{Todo: &todo}, {Todo: &todo}}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedResults: []int{http.StatusCreated, http.StatusOK},
+		},
+		{
+			name: "Atomic failure",
+			body: `{"operations":[{"op":"delete","delete":{"id":2}}]}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().Batch(gomock.Any(), gomock.Any(), true).Return(nil, fmt.Errorf("operation 0: %w", model.ErrNotFound))
+			},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+		{
+			name: "Best effort",
+			body: `{"mode":"best_effort","operations":[{"op":"update","update":{"id":1,"title":"New title"}},{"op":"delete","delete":{"id":2}},{"op":"toggle","toggle":{"id":3}}]}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().Batch(gomock.Any(), gomock.Len(3), false).Return([]model.BatchResult{
+					{Todo: &todo},
+					{Err: model.ErrNotFound},
+					{Err: errors.New("connection reset")},
+				}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedResults: []int{http.StatusOK, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			name:            "Missing operation body",
+			body:            `{"operations":[{"op":"create","create":{"listId":1,"title":"Title 1"}},{"op":"update"}]}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "operations[1].update",
+		},
+		{
+			name:            "Invalid operation",
+			body:            `{"operations":[{"op":"create","create":{"listId":1,"title":"T"}}]}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "operations[0].create.title",
+		},
+		{
+			name:            "Empty",
+			body:            `{"operations":[]}`,
+			mockBehavior:    func(s *mock_service.MockITodoService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "operations",
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			service := mock_service.NewMockITodoService(ctrl)
+			tt.mockBehavior(service)
+
+			rec := httptest.NewRecorder()
+			newTestServer(service, NewHttpConfig()).ServeHTTP(rec, httptest.NewRequest("POST", "/batch", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+
+			if tt.expectedProblem != "" {
+				var problem Problem
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				assert.Equal(t, tt.expectedProblem, problem.Type)
+				if tt.expectedField != "" && assert.NotEmpty(t, problem.Errors) {
+					assert.Equal(t, tt.expectedField, problem.Errors[0].Field)
+				}
+				return
+			}
+
+			var response BatchResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+
+			statuses := make([]int, len(response.Results))
+			for i, result := range response.Results {
+				statuses[i] = result.Status
+				assert.Equal(t, result.Status >= 400, result.Problem != nil)
+			}
+			assert.Equal(t, tt.expectedResults, statuses)
+		})
+	}
+}
+
+func TestHttp_Versions(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockITodoService)
+
+	version := int64(3)
+	todo := model.Todo{ID: 1, Title: "Title 1", Version: version}
+	conflict := &model.VersionConflictError{Current: todo}
+
+	testTable := []struct {
+		name           string
+		method         string
+		path           string
+		ifMatch        string
+		body           string
+		mockBehavior   mockBehavior
+		expectedStatus int
+		expectedETag   string
+		expectedTodo   bool
+	}{
+		{
+			name:   "Get sends ETag",
+			method: "GET",
+			path:   "/todos/1",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().GetTodo(gomock.Any(), model.ID(1)).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:    "Update with If-Match",
+			method:  "PATCH",
+			path:    "/todos/1",
+			ifMatch: `"3"`,
+			body:    `{"title":"Title 1"}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				title := "Title 1"
+				s.EXPECT().UpdateTodo(gomock.Any(), model.ID(1), model.TodoPatch{IfVersion: &version, Title: &title}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:    "If-Match wins over version",
+			method:  "DELETE",
+			path:    "/todos/1",
+			ifMatch: `"3"`,
+			body:    `{"version":2}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().DeleteTodo(gomock.Any(), model.ID(1), model.DeleteOptions{IfVersion: &version}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Any version",
+			method:  "POST",
+			path:    "/todos/1/toggle",
+			ifMatch: "*",
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				s.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), model.ToggleOptions{}).Return(&todo, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:   "Stale version alias",
+			method: "POST",
+			path:   "/toggle",
+			body:   `{"id":1,"version":2}`,
+			mockBehavior: func(s *mock_service.MockITodoService) {
+				stale := int64(2)
+				s.EXPECT().ToggleTodo(gomock.Any(), model.ID(1), model.ToggleOptions{IfVersion: &stale}).Return(nil, conflict)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedETag:   `"3"`,
+			expectedTodo:   true,
+		},
+		{
+			name:           "Weak ETag",
+			method:         "PATCH",
+			path:           "/todos/1",
+			ifMatch:        `W/"3"`,
+			body:           `{"title":"Title 1"}`,
+			mockBehavior:   func(s *mock_service.MockITodoService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			service := mock_service.NewMockITodoService(ctrl)
+			tt.mockBehavior(service)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			rec := httptest.NewRecorder()
+			newTestServer(service, NewHttpConfig()).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedETag, rec.Header().Get("ETag"))
+
+			if tt.expectedTodo {
+				var problem Problem
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				assert.Equal(t, ProblemStaleVersion, problem.Type)
+				if assert.NotNil(t, problem.Todo) {
+					assert.Equal(t, todo, *problem.Todo)
+				}
+			}
+		})
+	}
+}
+
+func TestHttp_Webhooks(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockIWebhookService)
+
+	webhook := model.Webhook{ID: 1, URL: "https://example.com/hook", Events: []model.ChangeType{}, Secret: "0123456789abcdef", Active: true}
+	delivery := model.WebhookDelivery{ID: 8, WebhookID: 1, Event: model.ChangeCreated, Status: model.DeliveryPending}
+	failed := model.DeliveryFailed
+
+	testTable := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		mockBehavior   mockBehavior
+		expectedStatus int
+	}{
+		{
+			name:   "Create webhook",
+			method: "POST",
+			path:   "/webhooks",
+			body:   `{"url":"https://example.com/hook","events":["created","deleted"],"secret":"0123456789abcdef"}`,
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().CreateWebhook(gomock.Any(), model.Webhook{
+					URL:    "https://example.com/hook",
+					Events: []model.ChangeType{model.ChangeCreated, model.ChangeDeleted},
+					Secret: "0123456789abcdef",
+					Active: true,
+				}).Return(&webhook, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Create webhook with unknown event",
+			method:         "POST",
+			path:           "/webhooks",
+			body:           `{"url":"https://example.com/hook","events":["renamed"],"secret":"0123456789abcdef"}`,
+			mockBehavior:   func(s *mock_service.MockIWebhookService) {},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Create webhook with short secret",
+			method:         "POST",
+			path:           "/webhooks",
+			body:           `{"url":"https://example.com/hook","secret":"short"}`,
+			mockBehavior:   func(s *mock_service.MockIWebhookService) {},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Create webhook with invalid url",
+			method:         "POST",
+			path:           "/webhooks",
+			body:           `{"url":"ftp://example.com","secret":"0123456789abcdef"}`,
+			mockBehavior:   func(s *mock_service.MockIWebhookService) {},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "List webhooks",
+			method: "GET",
+			path:   "/webhooks",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().ListWebhooks(gomock.Any()).Return([]model.Webhook{webhook}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Get missing webhook",
+			method: "GET",
+			path:   "/webhooks/2",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().GetWebhook(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Deactivate webhook",
+			method: "PATCH",
+			path:   "/webhooks/1",
+			body:   `{"active":false}`,
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				active := false
+				s.EXPECT().UpdateWebhook(gomock.Any(), model.ID(1), model.WebhookPatch{Active: &active}).Return(&webhook, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Delete webhook",
+			method: "DELETE",
+			path:   "/webhooks/1",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().DeleteWebhook(gomock.Any(), model.ID(1)).Return(&webhook, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "List failed deliveries",
+			method: "GET",
+			path:   "/webhooks/1/deliveries?status=failed&limit=5&cursor=abc",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().ListDeliveries(gomock.Any(), model.DeliveryFilter{WebhookID: 1, Status: &failed, Cursor: "abc", Limit: 5}).
+					Return(&model.DeliveryPage{Deliveries: []model.WebhookDelivery{delivery}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "List deliveries of unknown status",
+			method:         "GET",
+			path:           "/webhooks/1/deliveries?status=lost",
+			mockBehavior:   func(s *mock_service.MockIWebhookService) {},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "Redeliver",
+			method: "POST",
+			path:   "/webhooks/1/deliveries/7/redeliver",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().Redeliver(gomock.Any(), model.ID(1), int64(7)).Return(&delivery, nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:   "Redeliver missing delivery",
+			method: "POST",
+			path:   "/webhooks/1/deliveries/9/redeliver",
+			mockBehavior: func(s *mock_service.MockIWebhookService) {
+				s.EXPECT().Redeliver(gomock.Any(), model.ID(1), int64(9)).Return(nil, model.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			webhooks := mock_service.NewMockIWebhookService(ctrl)
+			tt.mockBehavior(webhooks)
+
+			server := newTestServer(mock_service.NewMockITodoService(ctrl), NewHttpConfig())
+			server.webhooks = webhooks
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.NotContains(t, rec.Body.String(), webhook.Secret, "secrets are never sent back")
+		})
+	}
+}
+
+func TestHttp_Lists(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockIListService)
+
+	list := model.List{ID: 1, Name: "Work", Counts: model.ListCounts{Total: 3, Completed: 1}}
+
+	testTable := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		mockBehavior    mockBehavior
+		expectedStatus  int
+		expectedProblem string
+	}{
+		{
+			name:   "Create list",
+			method: "POST",
+			path:   "/lists",
+			body:   `{"name":"Work"}`,
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().CreateList(gomock.Any(), model.List{Name: "Work"}).Return(&list, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:            "Create list without name",
+			method:          "POST",
+			path:            "/lists",
+			body:            `{}`,
+			mockBehavior:    func(s *mock_service.MockIListService) {},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+		},
+		{
+			name:   "List lists",
+			method: "GET",
+			path:   "/lists",
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().ListLists(gomock.Any(), model.ListFilter{}).Return([]model.List{list}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "List archived lists",
+			method: "GET",
+			path:   "/lists?archived=true",
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().ListLists(gomock.Any(), model.ListFilter{Archived: true}).Return([]model.List{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Get missing list",
+			method: "GET",
+			path:   "/lists/2",
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().GetList(gomock.Any(), model.ID(2)).Return(nil, model.ErrNotFound)
+			},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: ProblemNotFound,
+		},
+		{
+			name:   "Rename and archive list",
+			method: "PATCH",
+			path:   "/lists/1",
+			body:   `{"name":"Office","archived":true}`,
+			mockBehavior: func(s *mock_service.MockIListService) {
+				name, archived := "Office", true
+				s.EXPECT().UpdateList(gomock.Any(), model.ID(1), model.ListPatch{Name: &name, Archived: &archived}).Return(&list, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Delete list with todos",
+			method: "DELETE",
+			path:   "/lists/1",
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().DeleteList(gomock.Any(), model.ID(1), model.DeleteListOptions{}).Return(nil, store.ErrListNotEmpty)
+			},
+			expectedStatus:  http.StatusConflict,
+			expectedProblem: ProblemConflict,
+		},
+		{
+			name:   "Delete list cascade",
+			method: "DELETE",
+			path:   "/lists/1?cascade=true",
+			mockBehavior: func(s *mock_service.MockIListService) {
+				s.EXPECT().DeleteList(gomock.Any(), model.ID(1), model.DeleteListOptions{Cascade: true}).Return(&list, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			lists := mock_service.NewMockIListService(ctrl)
+			tt.mockBehavior(lists)
+
+			server := newTestServer(mock_service.NewMockITodoService(ctrl), NewHttpConfig())
+			server.lists = lists
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedProblem != "" {
+				var problem Problem
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				assert.Equal(t, tt.expectedProblem, problem.Type)
+			}
+		})
+	}
+}
+
+func TestHttp_Export(t *testing.T) {
+	parentID := model.ID(1)
+	createdAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+	todos := []model.Todo{
+		{ID: 1, ListID: 2, Title: "Buy milk", Tags: []string{"home", "shop"}, CreatedAt: createdAt, UpdatedAt: createdAt},
+		{ID: 2, ListID: 2, ParentID: &parentID, Title: "Oat, \"barista\"", Complete: true, Priority: model.PriorityHigh, Tags: []string{}, CreatedAt: createdAt, UpdatedAt: createdAt, CompletedAt: &createdAt},
+	}
+
+	testTable := []struct {
+		name                string
+		path                string
+		exportErr           error
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "NDJSON",
+			path:                "/todos/export?tags=home",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"id":1,"listId":2,"title":"Buy milk","description":"","complete":false,"priority":"none","tags":["home","shop"],"createdAt":"2030-03-10T12:00:00Z","updatedAt":"2030-03-10T12:00:00Z","version":0}` + "\n" +
+				`{"id":2,"listId":2,"parentId":1,"title":"Oat, \"barista\"","description":"","complete":true,"priority":"high","tags":[],"createdAt":"2030-03-10T12:00:00Z","updatedAt":"2030-03-10T12:00:00Z","completedAt":"2030-03-10T12:00:00Z","version":0}` + "\n",
+		},
+		{
+			name:                "CSV",
+			path:                "/todos/export?format=csv&tags=home",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,listId,parentId,title,description,complete,priority,dueAt,dueTimezone,recurrence,tags,createdAt,updatedAt,completedAt\n" +
+				"1,2,,Buy milk,,false,none,,,,\"home,shop\",2030-03-10T12:00:00Z,2030-03-10T12:00:00Z,\n" +
+				"2,2,1,\"Oat, \"\"barista\"\"\",,true,high,,,,,2030-03-10T12:00:00Z,2030-03-10T12:00:00Z,2030-03-10T12:00:00Z\n",
+		},
+		{
+			name:                "Markdown",
+			path:                "/todos/export?format=markdown&tags=home",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/markdown; charset=utf-8",
+			expectedBody:        "- [ ] Buy milk\n  - [x] Oat, \"barista\"\n",
+		},
+		{
+			name:                "Failure before the first todo",
+			path:                "/todos/export?tags=home",
+			exportErr:           errors.New("connection reset"),
+			expectedStatus:      http.StatusInternalServerError,
+			expectedContentType: "application/problem+json",
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			service := mock_service.NewMockITodoService(ctrl)
+			service.EXPECT().ExportTodos(gomock.Any(), model.TodoFilter{Tags: []string{"home"}}, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, filter model.TodoFilter, each func(model.Todo) error) error {
+					if tt.exportErr != nil {
+						return tt.exportErr
+					}
+					for _, todo := range todos {
+						if err := each(todo); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			)
+
+			rec := httptest.NewRecorder()
+			newTestServer(service, NewHttpConfig()).ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	id := func(id model.ID) *model.ID { return &id }
+
+	// Sorted by title, subtasks come before their parents.
+	todos := []model.Todo{
+		{ID: 3, ParentID: id(2), Title: "Apples", Complete: true},
+		{ID: 2, ParentID: id(1), Title: "Fruit"},
+		{ID: 4, ParentID: id(9), Title: "Orphan"},
+		{ID: 1, Title: "Shopping"},
+		{ID: 5, ParentID: id(1), Title: "Soap"},
+	}
+
+	var buf strings.Builder
+	w := todoFormats[FormatMarkdown].writer(&buf)
+	for _, todo := range todos {
+		require.NoError(t, w.Write(todo))
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "- [ ] Orphan\n- [ ] Shopping\n  - [ ] Fruit\n    - [x] Apples\n  - [ ] Soap\n", buf.String())
+
+	imported, err := readMarkdown(strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	require.Len(t, imported, 5)
+
+	parents := make(map[string]string)
+	titles := make(map[model.ID]string)
+	for _, todo := range imported {
+		titles[todo.Id] = todo.Title
+		if todo.ParentID != nil {
+			parents[todo.Title] = titles[*todo.ParentID]
+		}
+	}
+	assert.Equal(t, map[string]string{"Fruit": "Shopping", "Apples": "Fruit", "Soap": "Shopping"}, parents)
+	assert.True(t, imported[3].Complete)
+}
+
+func TestHttp_Import(t *testing.T) {
+	parentID := model.ID(1)
+	listParentID := model.ID(3)
+	dueAt := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name            string
+		path            string
+		body            string
+		expectedTodos   []model.Todo
+		expectedDryRun  bool
+		expectedStatus  int
+		expectedProblem string
+		expectedField   string
+	}{
+		{
+			name: "NDJSON",
+			path: "/todos/import?listId=2",
+			body: `{"id":1,"title":"Buy milk","priority":"low","createdAt":"2030-03-10T12:00:00Z"}` + "\n\n" +
+				`{"id":2,"parentId":1,"title":"Oat milk","complete":true,"tags":["shop"]}` + "\n",
+			expectedTodos: []model.Todo{
+				{ID: 1, ListID: 2, Title: "Buy milk", Priority: model.PriorityLow},
+				{ID: 2, ListID: 2, ParentID: &parentID, Title: "Oat milk", Complete: true, Tags: []string{"shop"}},
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "CSV",
+			path: "/todos/import?format=csv&listId=2",
+			body: "title,dueAt,tags,priority,parentId,id,updatedAt\n" +
+				"Buy milk,2030-03-10T12:00:00Z,\"home, shop\",,,1,2030-03-10T12:00:00Z\n" +
+				"\"Oat, \"\"barista\"\"\",,,urgent,1,2,\n",
+			expectedTodos: []model.Todo{
+				{ID: 1, ListID: 2, Title: "Buy milk", DueAt: &dueAt, Tags: []string{"home", "shop"}},
+				{ID: 2, ListID: 2, ParentID: &parentID, Title: "Oat, \"barista\"", Priority: model.PriorityUrgent},
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "Markdown dry run",
+			path: "/todos/import?format=markdown&dryRun=true&listId=2",
+			body: "# Groceries\n\n- [ ] Buy milk\n\t- [x] Oat milk\n* [X] Bread\nSome notes\n",
+			expectedTodos: []model.Todo{
+				{ID: 3, ListID: 2, Title: "Buy milk"},
+				{ID: 4, ListID: 2, ParentID: &listParentID, Title: "Oat milk", Complete: true},
+				{ID: 5, ListID: 2, Title: "Bread", Complete: true},
+			},
+			expectedDryRun: true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Unknown format",
+			path:            "/todos/import?format=xml",
+			body:            "<todos/>",
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:            "Malformed line",
+			path:            "/todos/import",
+			body:            `{"title":"Buy milk"}` + "\n" + `{"title":`,
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:            "CSV without title",
+			path:            "/todos/import?format=csv",
+			body:            "id,description\n1,Milk\n",
+			expectedStatus:  http.StatusBadRequest,
+			expectedProblem: ProblemMalformedRequest,
+		},
+		{
+			name:            "Invalid todo",
+			path:            "/todos/import?format=markdown&listId=2",
+			body:            "- [ ] Buy milk\n- [ ] Go\n",
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "todos[1].title",
+		},
+		{
+			name:            "Nothing to import",
+			path:            "/todos/import?format=markdown&listId=2",
+			body:            "Nothing to do\n",
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "todos",
+		},
+		{
+			name:            "No list",
+			path:            "/todos/import",
+			body:            `{"title":"Buy milk"}` + "\n",
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedProblem: ProblemValidation,
+			expectedField:   "listId",
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			service := mock_service.NewMockITodoService(ctrl)
+			if tt.expectedTodos != nil {
+				service.EXPECT().ImportTodos(gomock.Any(), tt.expectedTodos, tt.expectedDryRun).Return(tt.expectedTodos, nil)
+			}
+
+			rec := httptest.NewRecorder()
+			newTestServer(service, NewHttpConfig()).ServeHTTP(rec, httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+
+			if tt.expectedProblem != "" {
+				var problem Problem
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+				assert.Equal(t, tt.expectedProblem, problem.Type)
+				if tt.expectedField != "" && assert.NotEmpty(t, problem.Errors) {
+					assert.Equal(t, tt.expectedField, problem.Errors[0].Field)
+				}
+				return
+			}
+
+			var response ImportTodosResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			assert.Equal(t, tt.expectedDryRun, response.DryRun)
+			assert.Len(t, response.Todos, len(tt.expectedTodos))
+		})
+	}
+}
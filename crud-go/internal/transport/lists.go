@@ -0,0 +1,161 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ListListsRequest with Archived lists the archived lists instead of
+// the others.
+type ListListsRequest struct {
+	Archived bool `json:"archived,omitempty"`
+}
+
+type ListListsResponse struct {
+	Lists []model.List `json:"lists"`
+}
+
+type CreateListRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CreateListResponse struct {
+	List model.List `json:"list"`
+}
+
+func (CreateListResponse) StatusCode() int { return http.StatusCreated }
+
+type GetListRequest struct {
+	Id model.ID `json:"id" validate:"required,min=1"`
+}
+
+func (r *GetListRequest) setId(id model.ID) { r.Id = id }
+
+type GetListResponse struct {
+	List model.List `json:"list"`
+}
+
+// UpdateListRequest renames a list or archives it. Archived lists keep
+// their todos but take no new ones, neither created nor moved in.
+type UpdateListRequest struct {
+	Id       model.ID `json:"id" validate:"required,min=1"`
+	Name     *string  `json:"name,omitempty" validate:"omitempty,max=100"`
+	Archived *bool    `json:"archived,omitempty"`
+}
+
+func (r *UpdateListRequest) setId(id model.ID) { r.Id = id }
+
+func (r *UpdateListRequest) patch() model.ListPatch {
+	return model.ListPatch{Name: r.Name, Archived: r.Archived}
+}
+
+type UpdateListResponse struct {
+	List model.List `json:"list"`
+}
+
+// DeleteListRequest with Cascade deletes the todos of the list along
+// with it. Otherwise a list with todos is refused with 409.
+type DeleteListRequest struct {
+	Id      model.ID `json:"id" validate:"required,min=1"`
+	Cascade bool     `json:"cascade,omitempty"`
+}
+
+func (r *DeleteListRequest) setId(id model.ID) { r.Id = id }
+
+func (r *DeleteListRequest) setCascade(cascade bool) { r.Cascade = cascade }
+
+type DeleteListResponse struct {
+	List model.List `json:"list"`
+}
+
+func decodeListListsRequest(r *http.Request) (*ListListsRequest, error) {
+	req := &ListListsRequest{}
+
+	if v := r.URL.Query().Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("archived: %w", err)
+		}
+		req.Archived = archived
+	}
+
+	return req, nil
+}
+
+func (s *HttpServer) createListEndpoints() {
+	listLists := pipe[ListListsRequest, ListListsResponse](
+		decodeListListsRequest,
+		func(ctx context.Context, req *ListListsRequest) (ListListsResponse, error) {
+			s.logger.Debug("ListListsRequest", "archived", req.Archived)
+			lists, err := s.lists.ListLists(ctx, model.ListFilter{Archived: req.Archived})
+			return ListListsResponse{Lists: lists}, err
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	createList := pipe[CreateListRequest, CreateListResponse](
+		decodeRequest,
+		func(ctx context.Context, req *CreateListRequest) (CreateListResponse, error) {
+			s.logger.Debug("CreateListRequest", "name", req.Name)
+			list, err := s.lists.CreateList(ctx, model.List{Name: req.Name})
+			if err != nil {
+				return CreateListResponse{}, err
+			}
+			return CreateListResponse{List: *list}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	getList := pipe[GetListRequest, GetListResponse](
+		decodeIdRequest[GetListRequest],
+		func(ctx context.Context, req *GetListRequest) (GetListResponse, error) {
+			s.logger.Debug("GetListRequest", "id", req.Id)
+			list, err := s.lists.GetList(ctx, req.Id)
+			if err != nil {
+				return GetListResponse{}, err
+			}
+			return GetListResponse{List: *list}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	updateList := pipe[UpdateListRequest, UpdateListResponse](
+		decodeIdRequest[UpdateListRequest],
+		func(ctx context.Context, req *UpdateListRequest) (UpdateListResponse, error) {
+			s.logger.Debug("UpdateListRequest", "id", req.Id)
+			list, err := s.lists.UpdateList(ctx, req.Id, req.patch())
+			if err != nil {
+				return UpdateListResponse{}, err
+			}
+			return UpdateListResponse{List: *list}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	deleteList := pipe[DeleteListRequest, DeleteListResponse](
+		decodeIdRequest[DeleteListRequest],
+		func(ctx context.Context, req *DeleteListRequest) (DeleteListResponse, error) {
+			s.logger.Debug("DeleteListRequest", "id", req.Id, "cascade", req.Cascade)
+			list, err := s.lists.DeleteList(ctx, req.Id, model.DeleteListOptions{Cascade: req.Cascade})
+			if err != nil {
+				return DeleteListResponse{}, err
+			}
+			return DeleteListResponse{List: *list}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	s.router.HandleFunc("/lists", listLists).Methods("GET").Name("listLists")
+	s.router.HandleFunc("/lists", createList).Methods("POST").Name("createList")
+	s.router.HandleFunc("/lists/{id:[0-9]+}", getList).Methods("GET").Name("getList")
+	s.router.HandleFunc("/lists/{id:[0-9]+}", updateList).Methods("PATCH").Name("updateList")
+	s.router.HandleFunc("/lists/{id:[0-9]+}", deleteList).Methods("DELETE").Name("deleteList")
+}
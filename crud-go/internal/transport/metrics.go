@@ -0,0 +1,164 @@
+package transport
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	// rejected counts the requests turned away before reaching the
+	// service.
+	rejected *prometheus.CounterVec
+}
+
+func newHttpMetrics(registerer prometheus.Registerer) *httpMetrics {
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "crud",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Requests handled, by route and status.",
+		}, []string{"route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "crud",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests, by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "crud",
+			Subsystem: "http",
+			Name:      "rejected_requests_total",
+			Help:      "Requests turned away before reaching the service, by route and stage.",
+		}, []string{"route", "stage"}),
+	}
+	registerer.MustRegister(m.requests, m.duration, m.rejected)
+
+	return m
+}
+
+type grpcMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	// rejected counts the calls turned away before reaching the service.
+	rejected *prometheus.CounterVec
+}
+
+func newGrpcMetrics(registerer prometheus.Registerer) *grpcMetrics {
+	m := &grpcMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "crud",
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "Calls handled, by method and status code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "crud",
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of calls, by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "crud",
+			Subsystem: "grpc",
+			Name:      "rejected_requests_total",
+			Help:      "Calls turned away before reaching the service, by method and stage.",
+		}, []string{"method", "stage"}),
+	}
+	registerer.MustRegister(m.requests, m.duration, m.rejected)
+
+	return m
+}
+
+// rejectionStages are the stages of a request the problems of these
+// types come from.
+var rejectionStages = map[string]string{
+	ProblemUnauthorized:     "auth",
+	ProblemRateLimited:      "rate_limit",
+	ProblemRequestTooLarge:  "body_size",
+	ProblemMalformedRequest: "decode",
+	ProblemValidation:       "validate",
+}
+
+// withMetrics counts and times requests by the name of their route. A
+// problem written for a request that is unauthorized, rate limited, too
+// large, can't be decoded or is invalid also counts it as rejected.
+func (s *HttpServer) withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeName(r)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.statusCode())
+		s.metrics.requests.WithLabelValues(route, status).Inc()
+		s.metrics.duration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+
+		if stage, ok := rejectionStages[rec.problem]; ok {
+			s.metrics.rejected.WithLabelValues(route, stage).Inc()
+		}
+	})
+}
+
+// problemRecorder is implemented by response writers that want to know
+// the type of the problem written to them.
+type problemRecorder interface {
+	recordProblem(problemType string)
+}
+
+// statusRecorder remembers the status and problem type of a response.
+// Flushing and hijacking go through to the underlying writer, so that
+// streams and WebSockets keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	problem string
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) recordProblem(problemType string) { r.problem = problemType }
+
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,161 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/service"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	headerRequestID = "X-Request-ID"
+	// headerActor names who is making the request. It is set by the
+	// proxy in front of the server and trusted as is, unless the request
+	// is authenticated.
+	headerActor = "X-Actor"
+
+	anonymousActor = "anonymous"
+	maxRequestID   = 128
+
+	// unmatchedRoute names the requests no route matches, which are
+	// answered 404 or 405.
+	unmatchedRoute = "unmatched"
+)
+
+type routeKey struct{}
+
+// withRoute puts the name of the route matching a request into its
+// context. The middleware wraps the router rather than being added to
+// it, which only runs it for requests some route matches, so it learns
+// the route from here rather than from mux.CurrentRoute.
+func (s *HttpServer) withRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		var match mux.RouteMatch
+		if s.router.Match(r, &match) && match.MatchErr == nil && match.Route != nil {
+			route = match.Route.GetName()
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+	})
+}
+
+// routeName returns the name of the route of r set by withRoute.
+func routeName(r *http.Request) string {
+	if route, ok := r.Context().Value(routeKey{}).(string); ok {
+		return route
+	}
+	return unmatchedRoute
+}
+
+// withRequestInfo puts the actor and the request id into the request
+// context, so changes can be traced back to them. A request id sent by
+// the client is kept, otherwise one is generated; either way it is
+// echoed in the response.
+func withRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := newRequestInfo(r.Header.Get(headerActor), r.Header.Get(headerRequestID))
+
+		w.Header().Set(headerRequestID, info.RequestID)
+
+		next.ServeHTTP(w, r.WithContext(service.WithRequestInfo(r.Context(), info)))
+	})
+}
+
+// newRequestInfo is the info of a request sent by actor with
+// requestID, either of which may be empty.
+func newRequestInfo(actor, requestID string) service.RequestInfo {
+	info := service.RequestInfo{
+		Actor:     strings.TrimSpace(actor),
+		RequestID: strings.TrimSpace(requestID),
+	}
+	if info.Actor == "" {
+		info.Actor = anonymousActor
+	}
+	if info.RequestID == "" || len(info.RequestID) > maxRequestID {
+		info.RequestID = newRequestID()
+	}
+	return info
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// streamingRoutes run for as long as the client stays, so they have no
+// timeout.
+var streamingRoutes = map[string]bool{
+	"watchChanges": true,
+}
+
+// withTimeout bounds the request context with the timeout of the
+// matched route, so slow queries are cancelled instead of hanging.
+func (s *HttpServer) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeName(r)
+		if streamingRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeout := s.routeTimeout(route)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (s *HttpServer) routeTimeout(route string) time.Duration {
+	for name, timeout := range s.config.RouteTimeouts {
+		if strings.EqualFold(name, route) {
+			return timeout
+		}
+	}
+
+	return s.config.RequestTimeout
+}
+
+// withBodyLimit caps the request body at the size limit of the matched
+// route. A body declared larger is rejected at once; any other is cut
+// off where it passes the limit, which fails its decoding with 413.
+func (s *HttpServer) withBodyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.routeMaxBodySize(routeName(r))
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			writeProblem(w, r, requestTooLargeProblem(limit))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *HttpServer) routeMaxBodySize(route string) int64 {
+	for name, limit := range s.config.RouteMaxBodySizes {
+		if strings.EqualFold(name, route) {
+			return limit
+		}
+	}
+
+	return s.config.MaxBodySize
+}
@@ -0,0 +1,201 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Problem types clients can switch on. They are relative URIs as
+// allowed by RFC 9457.
+const (
+	ProblemMalformedRequest = "/problems/malformed-request"
+	ProblemUnauthorized     = "/problems/unauthorized"
+	ProblemRequestTooLarge  = "/problems/request-too-large"
+	ProblemValidation       = "/problems/validation-failed"
+	ProblemInvalidArgument  = "/problems/invalid-argument"
+	ProblemNotFound         = "/problems/not-found"
+	ProblemStaleVersion     = "/problems/stale-version"
+	ProblemConflict         = "/problems/conflict"
+	ProblemMethodNotAllowed = "/problems/method-not-allowed"
+	ProblemRateLimited      = "/problems/rate-limited"
+	ProblemTimeout          = "/problems/timeout"
+	ProblemClientClosed     = "/problems/client-closed-request"
+	ProblemInternal         = "/problems/internal"
+)
+
+const statusClientClosedRequest = 499
+
+// Problem is an application/problem+json response body.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// Todo is the current state of a todo written at a stale version.
+	Todo *model.Todo `json:"todo,omitempty"`
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// malformedRequestProblem describes a request that could not be
+// decoded. A body cut off at its size limit is too large rather than
+// malformed.
+func malformedRequestProblem(err error) Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return requestTooLargeProblem(tooLarge.Limit)
+	}
+
+	return Problem{
+		Type:   ProblemMalformedRequest,
+		Title:  "Malformed request",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	}
+}
+
+func requestTooLargeProblem(limit int64) Problem {
+	return Problem{
+		Type:   ProblemRequestTooLarge,
+		Title:  "Request body too large",
+		Status: http.StatusRequestEntityTooLarge,
+		Detail: fmt.Sprintf("the body may be at most %d bytes", limit),
+	}
+}
+
+func validationProblem(err error) Problem {
+	problem := Problem{
+		Type:   ProblemValidation,
+		Title:  "Request validation failed",
+		Status: http.StatusUnprocessableEntity,
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		problem.Detail = err.Error()
+		return problem
+	}
+
+	for _, e := range validationErrors {
+		// The namespace starts with the request type; the rest is the
+		// path of the field, e.g. operations[0].create.title.
+		_, field, _ := strings.Cut(e.Namespace(), ".")
+
+		problem.Errors = append(problem.Errors, FieldError{
+			Field: field,
+			Rule:  e.Tag(),
+			Param: e.Param(),
+		})
+	}
+
+	return problem
+}
+
+// errorProblem maps an error returned by the service onto a problem.
+// A request that ran out of time is a 504; one whose client went away
+// gets the non-standard 499, only ever seen in logs and metrics.
+// Anything outside the model error taxonomy is an internal error and
+// its text is not exposed to the client.
+func errorProblem(err error) Problem {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Problem{
+			Type:   ProblemTimeout,
+			Title:  "Request timed out",
+			Status: http.StatusGatewayTimeout,
+		}
+	case errors.Is(err, context.Canceled):
+		return Problem{
+			Type:   ProblemClientClosed,
+			Title:  "Client closed request",
+			Status: statusClientClosedRequest,
+		}
+	case errors.Is(err, model.ErrNotFound):
+		problem := Problem{
+			Type:   ProblemNotFound,
+			Title:  "Resource not found",
+			Status: http.StatusNotFound,
+		}
+		// Say which resource when the error was given some context.
+		if err != model.ErrNotFound {
+			problem.Detail = err.Error()
+		}
+		return problem
+	case errors.Is(err, model.ErrPreconditionFailed):
+		problem := Problem{
+			Type:   ProblemStaleVersion,
+			Title:  "Todo was changed in the meantime",
+			Status: http.StatusPreconditionFailed,
+			Detail: err.Error(),
+		}
+		var conflict *model.VersionConflictError
+		if errors.As(err, &conflict) {
+			problem.Todo = &conflict.Current
+		}
+		return problem
+	case errors.Is(err, model.ErrConflict):
+		return Problem{
+			Type:   ProblemConflict,
+			Title:  "Conflict",
+			Status: http.StatusConflict,
+			Detail: err.Error(),
+		}
+	case errors.Is(err, model.ErrInvalidArgument):
+		return Problem{
+			Type:   ProblemInvalidArgument,
+			Title:  "Invalid argument",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		}
+	default:
+		return Problem{
+			Type:   ProblemInternal,
+			Title:  "Internal server error",
+			Status: http.StatusInternalServerError,
+		}
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Instance = r.URL.Path
+
+	if p, ok := w.(problemRecorder); ok {
+		p.recordProblem(problem.Type)
+	}
+
+	if problem.Todo != nil {
+		w.Header().Set("ETag", etag(*problem.Todo))
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, Problem{
+		Type:   ProblemNotFound,
+		Title:  "Resource not found",
+		Status: http.StatusNotFound,
+	})
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, Problem{
+		Type:   ProblemMethodNotAllowed,
+		Title:  "Method not allowed",
+		Status: http.StatusMethodNotAllowed,
+	})
+}
@@ -0,0 +1,253 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerForwardedFor       = "X-Forwarded-For"
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+
+	// rateLimitSweep is how often the buckets that have filled up again
+	// are dropped, so idle clients don't pile up.
+	rateLimitSweep = time.Minute
+)
+
+// unlimitedRoutes are the probes, which must answer however busy the
+// server is.
+var unlimitedRoutes = map[string]bool{
+	"healthz": true,
+	"readyz":  true,
+}
+
+// rateLimiter keeps a token bucket per client. A bucket holds up to
+// burst tokens and gains rate of them every second; every request takes
+// one.
+type rateLimiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil, which allows everything, if rate is not
+// positive.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		rate:    rate,
+		burst:   max(burst, 1),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+type rateDecision struct {
+	allowed   bool
+	remaining int
+	// reset is how long until the bucket is full again.
+	reset time.Duration
+	// retryAfter is how long until the next request is allowed.
+	retryAfter time.Duration
+}
+
+// take takes a token from the bucket of key, if there is one.
+func (l *rateLimiter) take(key string) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweep {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = l.refill(bucket, now)
+	bucket.last = now
+
+	decision := rateDecision{allowed: bucket.tokens >= 1}
+	if decision.allowed {
+		bucket.tokens--
+	} else {
+		decision.retryAfter = l.after(1 - bucket.tokens)
+	}
+	decision.remaining = int(bucket.tokens)
+	decision.reset = l.after(float64(l.burst) - bucket.tokens)
+
+	return decision
+}
+
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	return min(float64(l.burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+}
+
+// after returns how long it takes to gain tokens.
+func (l *rateLimiter) after(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops full buckets, which are no different from new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if l.refill(bucket, now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// withRateLimit limits the requests of each client, read and write
+// routes separately. Clients are told apart by user, or by IP address
+// when anonymous. Every limited response says how many requests are
+// left; a rejected one also says when to retry.
+func (s *HttpServer) withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedRoutes[routeName(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limiter := s.writeLimiter
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			limiter = s.readLimiter
+		}
+		if limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decision := limiter.take(clientKey(r.Context(), s.clientAddr(r)))
+
+		w.Header().Set(headerRateLimitLimit, strconv.Itoa(limiter.burst))
+		w.Header().Set(headerRateLimitRemaining, strconv.Itoa(decision.remaining))
+		w.Header().Set(headerRateLimitReset, seconds(decision.reset))
+
+		if !decision.allowed {
+			w.Header().Set(headerRetryAfter, seconds(decision.retryAfter))
+			writeProblem(w, r, Problem{
+				Type:   ProblemRateLimited,
+				Title:  "Too many requests",
+				Status: http.StatusTooManyRequests,
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey names the bucket of the client of a request: its user, or
+// else the IP address of remoteAddr. The actor is left out, as a client
+// could send a new one with every request.
+func clientKey(ctx context.Context, remoteAddr string) string {
+	if user, ok := model.UserFrom(ctx); ok {
+		return "user:" + strconv.FormatUint(uint64(user), 10)
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// clientAddr returns the IP address of the client of r. A request from
+// a trusted proxy comes from the last address of X-Forwarded-For that is
+// not one of them, as the ones before it could be made up by the client.
+func (s *HttpServer) clientAddr(r *http.Request) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if !s.trustedProxy(addr) {
+		return addr
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values(headerForwardedFor) {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		addr = hop
+		if !s.trustedProxy(hop) {
+			break
+		}
+	}
+	return addr
+}
+
+func (s *HttpServer) trustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses addresses and CIDR ranges. Invalid ones are
+// logged and left out.
+func parseTrustedProxies(logger *slog.Logger, proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				logger.Warn("Invalid trusted proxy", "proxy", proxy, "error", err.Error())
+				continue
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// seconds rounds d up to whole seconds, as rate limit headers have
+// them.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
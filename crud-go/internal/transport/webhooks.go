@@ -0,0 +1,284 @@
+package transport
+
+import (
+	"context"
+	"crud/internal/model"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type ListWebhooksRequest struct{}
+
+type ListWebhooksResponse struct {
+	Webhooks []model.Webhook `json:"webhooks"`
+}
+
+// CreateWebhookRequest subscribes URL to the changes of todos. Without
+// events it gets all of them. Deliveries are signed with Secret, which
+// is never sent back.
+type CreateWebhookRequest struct {
+	URL    string             `json:"url" validate:"required,http_url,max=2048"`
+	Events []model.ChangeType `json:"events,omitempty" validate:"omitempty,dive,oneof=created updated deleted"`
+	Secret string             `json:"secret" validate:"required,min=16,max=256"`
+	// Active defaults to true.
+	Active *bool `json:"active,omitempty"`
+}
+
+func (r *CreateWebhookRequest) webhook() model.Webhook {
+	webhook := model.Webhook{
+		URL:    r.URL,
+		Events: r.Events,
+		Secret: r.Secret,
+		Active: true,
+	}
+	if r.Active != nil {
+		webhook.Active = *r.Active
+	}
+	return webhook
+}
+
+type CreateWebhookResponse struct {
+	Webhook model.Webhook `json:"webhook"`
+}
+
+func (CreateWebhookResponse) StatusCode() int { return http.StatusCreated }
+
+type GetWebhookRequest struct {
+	Id model.ID `json:"id" validate:"required,min=1"`
+}
+
+func (r *GetWebhookRequest) setId(id model.ID) { r.Id = id }
+
+type GetWebhookResponse struct {
+	Webhook model.Webhook `json:"webhook"`
+}
+
+// UpdateWebhookRequest changes only the fields present in the body.
+// Inactive webhooks keep their pending deliveries until they are
+// activated again.
+type UpdateWebhookRequest struct {
+	Id     model.ID            `json:"id" validate:"required,min=1"`
+	URL    *string             `json:"url,omitempty" validate:"omitempty,http_url,max=2048"`
+	Events *[]model.ChangeType `json:"events,omitempty" validate:"omitempty,dive,oneof=created updated deleted"`
+	Secret *string             `json:"secret,omitempty" validate:"omitempty,min=16,max=256"`
+	Active *bool               `json:"active,omitempty"`
+}
+
+func (r *UpdateWebhookRequest) setId(id model.ID) { r.Id = id }
+
+func (r *UpdateWebhookRequest) patch() model.WebhookPatch {
+	return model.WebhookPatch{
+		URL:    r.URL,
+		Events: r.Events,
+		Secret: r.Secret,
+		Active: r.Active,
+	}
+}
+
+type UpdateWebhookResponse struct {
+	Webhook model.Webhook `json:"webhook"`
+}
+
+// DeleteWebhookRequest removes a webhook along with its deliveries.
+type DeleteWebhookRequest struct {
+	Id model.ID `json:"id" validate:"required,min=1"`
+}
+
+func (r *DeleteWebhookRequest) setId(id model.ID) { r.Id = id }
+
+type DeleteWebhookResponse struct {
+	Webhook model.Webhook `json:"webhook"`
+}
+
+type ListDeliveriesRequest struct {
+	WebhookID model.ID `json:"webhookId" validate:"required,min=1"`
+	Status    string   `json:"status,omitempty" validate:"omitempty,oneof=pending succeeded failed"`
+	Cursor    string   `json:"cursor,omitempty" validate:"omitempty"`
+	Limit     int      `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+func (r *ListDeliveriesRequest) filter() model.DeliveryFilter {
+	filter := model.DeliveryFilter{
+		WebhookID: r.WebhookID,
+		Cursor:    r.Cursor,
+		Limit:     r.Limit,
+	}
+	if r.Status != "" {
+		status := model.DeliveryStatus(r.Status)
+		filter.Status = &status
+	}
+	return filter
+}
+
+type ListDeliveriesResponse struct {
+	Deliveries []model.WebhookDelivery `json:"deliveries"`
+	NextCursor string                  `json:"nextCursor,omitempty"`
+}
+
+// RedeliverRequest sends the payload of a delivery again, whatever came
+// of it.
+type RedeliverRequest struct {
+	WebhookID  model.ID `json:"webhookId" validate:"required,min=1"`
+	DeliveryID int64    `json:"deliveryId" validate:"required,min=1"`
+}
+
+// RedeliverResponse is the new delivery, which is sent shortly.
+type RedeliverResponse struct {
+	Delivery model.WebhookDelivery `json:"delivery"`
+}
+
+func (RedeliverResponse) StatusCode() int { return http.StatusAccepted }
+
+func decodeListWebhooksRequest(r *http.Request) (*ListWebhooksRequest, error) {
+	return &ListWebhooksRequest{}, nil
+}
+
+// decodeListDeliveriesRequest reads ListDeliveriesRequest from the URL
+// query and the webhook from the path.
+func decodeListDeliveriesRequest(r *http.Request) (*ListDeliveriesRequest, error) {
+	query := r.URL.Query()
+	req := &ListDeliveriesRequest{
+		Status: query.Get("status"),
+		Cursor: query.Get("cursor"),
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+	req.WebhookID = model.ID(id)
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		req.Limit = limit
+	}
+
+	return req, nil
+}
+
+func decodeRedeliverRequest(r *http.Request) (*RedeliverRequest, error) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+
+	deliveryID, err := strconv.ParseInt(vars["deliveryId"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("deliveryId: %w", err)
+	}
+
+	return &RedeliverRequest{WebhookID: model.ID(id), DeliveryID: deliveryID}, nil
+}
+
+func (s *HttpServer) createWebhookEndpoints() {
+	listWebhooks := pipe[ListWebhooksRequest, ListWebhooksResponse](
+		decodeListWebhooksRequest,
+		func(ctx context.Context, req *ListWebhooksRequest) (ListWebhooksResponse, error) {
+			s.logger.Debug("ListWebhooksRequest")
+			webhooks, err := s.webhooks.ListWebhooks(ctx)
+			return ListWebhooksResponse{Webhooks: webhooks}, err
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	createWebhook := pipe[CreateWebhookRequest, CreateWebhookResponse](
+		decodeRequest,
+		func(ctx context.Context, req *CreateWebhookRequest) (CreateWebhookResponse, error) {
+			s.logger.Debug("CreateWebhookRequest", "url", req.URL)
+			webhook, err := s.webhooks.CreateWebhook(ctx, req.webhook())
+			if err != nil {
+				return CreateWebhookResponse{}, err
+			}
+			return CreateWebhookResponse{Webhook: *webhook}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	getWebhook := pipe[GetWebhookRequest, GetWebhookResponse](
+		decodeIdRequest[GetWebhookRequest],
+		func(ctx context.Context, req *GetWebhookRequest) (GetWebhookResponse, error) {
+			s.logger.Debug("GetWebhookRequest", "id", req.Id)
+			webhook, err := s.webhooks.GetWebhook(ctx, req.Id)
+			if err != nil {
+				return GetWebhookResponse{}, err
+			}
+			return GetWebhookResponse{Webhook: *webhook}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	updateWebhook := pipe[UpdateWebhookRequest, UpdateWebhookResponse](
+		decodeIdRequest[UpdateWebhookRequest],
+		func(ctx context.Context, req *UpdateWebhookRequest) (UpdateWebhookResponse, error) {
+			s.logger.Debug("UpdateWebhookRequest", "id", req.Id)
+			webhook, err := s.webhooks.UpdateWebhook(ctx, req.Id, req.patch())
+			if err != nil {
+				return UpdateWebhookResponse{}, err
+			}
+			return UpdateWebhookResponse{Webhook: *webhook}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	deleteWebhook := pipe[DeleteWebhookRequest, DeleteWebhookResponse](
+		decodeIdRequest[DeleteWebhookRequest],
+		func(ctx context.Context, req *DeleteWebhookRequest) (DeleteWebhookResponse, error) {
+			s.logger.Debug("DeleteWebhookRequest", "id", req.Id)
+			webhook, err := s.webhooks.DeleteWebhook(ctx, req.Id)
+			if err != nil {
+				return DeleteWebhookResponse{}, err
+			}
+			return DeleteWebhookResponse{Webhook: *webhook}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	listDeliveries := pipe[ListDeliveriesRequest, ListDeliveriesResponse](
+		decodeListDeliveriesRequest,
+		func(ctx context.Context, req *ListDeliveriesRequest) (ListDeliveriesResponse, error) {
+			s.logger.Debug("ListDeliveriesRequest", "webhook_id", req.WebhookID, "cursor", req.Cursor, "limit", req.Limit)
+			page, err := s.webhooks.ListDeliveries(ctx, req.filter())
+			if err != nil {
+				return ListDeliveriesResponse{}, err
+			}
+			return ListDeliveriesResponse{Deliveries: page.Deliveries, NextCursor: page.NextCursor}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	redeliver := pipe[RedeliverRequest, RedeliverResponse](
+		decodeRedeliverRequest,
+		func(ctx context.Context, req *RedeliverRequest) (RedeliverResponse, error) {
+			s.logger.Debug("RedeliverRequest", "webhook_id", req.WebhookID, "delivery_id", req.DeliveryID)
+			delivery, err := s.webhooks.Redeliver(ctx, req.WebhookID, req.DeliveryID)
+			if err != nil {
+				return RedeliverResponse{}, err
+			}
+			return RedeliverResponse{Delivery: *delivery}, nil
+		},
+		encodeResponse,
+		s.logger,
+	)
+
+	s.router.HandleFunc("/webhooks", listWebhooks).Methods("GET").Name("listWebhooks")
+	s.router.HandleFunc("/webhooks", createWebhook).Methods("POST").Name("createWebhook")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}", getWebhook).Methods("GET").Name("getWebhook")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}", updateWebhook).Methods("PATCH").Name("updateWebhook")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}", deleteWebhook).Methods("DELETE").Name("deleteWebhook")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", listDeliveries).Methods("GET").Name("listDeliveries")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries/{deliveryId:[0-9]+}/redeliver", redeliver).Methods("POST").Name("redeliver")
+}
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations of every store driver,
+// one directory per driver.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todo_audit_user_id_id_idx;
+DROP INDEX IF EXISTS todos_user_id_id_idx;
+
+ALTER TABLE todo_audit DROP COLUMN IF EXISTS user_id;
+ALTER TABLE todos DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,8 @@
+-- Todos from before there were users belong to none, user 0, and are
+-- only seen while authentication is off. The audit trail keeps the
+-- owner of the todo of each entry, so histories stay with their owner.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS user_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE todo_audit ADD COLUMN IF NOT EXISTS user_id BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS todos_user_id_id_idx ON todos (user_id, id);
+CREATE INDEX IF NOT EXISTS todo_audit_user_id_id_idx ON todo_audit (user_id, id);
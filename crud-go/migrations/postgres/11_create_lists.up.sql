@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS lists (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    archived_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_id_idx ON lists (user_id, id);
+
+-- Every owner of todos gets an Inbox holding all of them.
+INSERT INTO lists (user_id, name) SELECT DISTINCT user_id, 'Inbox' FROM todos;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS list_id INTEGER REFERENCES lists (id);
+UPDATE todos SET list_id = (SELECT id FROM lists WHERE lists.user_id = todos.user_id);
+ALTER TABLE todos ALTER COLUMN list_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id);
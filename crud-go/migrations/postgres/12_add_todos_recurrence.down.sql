@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_series_id_idx;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS series_id;
+ALTER TABLE todos DROP COLUMN IF EXISTS recurrence;
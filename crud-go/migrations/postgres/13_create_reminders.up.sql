@@ -0,0 +1,19 @@
+-- A reminder is claimed by inserting it, so the unique key keeps it from
+-- being sent twice.
+CREATE TABLE IF NOT EXISTS reminders (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    offset_seconds BIGINT NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (todo_id, offset_seconds, due_at)
+);
+
+-- A lease names the instance that runs a job until expires_at.
+CREATE TABLE IF NOT EXISTS leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+-- Webhooks from before they had owners belong to none, user 0, like the
+-- todos of that time, whose changes are the only ones they get.
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS user_id BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id_id_idx ON webhooks (user_id, id);
@@ -0,0 +1 @@
+ALTER TABLE reminders ALTER COLUMN due_at TYPE TIMESTAMP USING due_at AT TIME ZONE 'UTC';
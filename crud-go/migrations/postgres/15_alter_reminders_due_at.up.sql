@@ -0,0 +1,5 @@
+-- due_at is a TIMESTAMPTZ like todos.due_at: a TIMESTAMP takes the copy
+-- in the session's timezone, so instances in different ones didn't see
+-- each other's claims and sent a reminder again. The times stored so far
+-- are taken as UTC, the timezone the sessions run in.
+ALTER TABLE reminders ALTER COLUMN due_at TYPE TIMESTAMPTZ USING due_at AT TIME ZONE 'UTC';
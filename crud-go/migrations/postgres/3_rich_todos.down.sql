@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS todos_tags_idx;
+DROP INDEX IF EXISTS todos_priority_id_idx;
+DROP INDEX IF EXISTS todos_due_at_id_idx;
+DROP INDEX IF EXISTS todos_updated_at_id_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS due_timezone,
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS description;
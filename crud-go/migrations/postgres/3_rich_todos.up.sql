@@ -0,0 +1,16 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS due_timezone TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+
+UPDATE todos SET updated_at = created_at;
+UPDATE todos SET completed_at = created_at WHERE complete;
+
+CREATE INDEX IF NOT EXISTS todos_updated_at_id_idx ON todos (updated_at, id);
+CREATE INDEX IF NOT EXISTS todos_due_at_id_idx ON todos ((COALESCE(due_at, 'infinity'::timestamptz)), id);
+CREATE INDEX IF NOT EXISTS todos_priority_id_idx ON todos (priority, id);
+CREATE INDEX IF NOT EXISTS todos_tags_idx ON todos USING GIN (tags);
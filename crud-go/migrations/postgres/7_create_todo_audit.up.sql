@@ -0,0 +1,14 @@
+-- No foreign key to todos: the history outlives purged todos.
+CREATE TABLE IF NOT EXISTS todo_audit (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    actor TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS todo_audit_todo_id_id_idx ON todo_audit (todo_id, id);
+CREATE INDEX IF NOT EXISTS todo_audit_created_at_idx ON todo_audit (created_at);
@@ -0,0 +1,8 @@
+-- The simple configuration doesn't stem, so that prefixes match the
+-- words as written, whatever their language.
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', description), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS todos_search_idx ON todos USING GIN (search);
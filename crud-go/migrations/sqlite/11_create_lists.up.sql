@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    archived_at TEXT,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_id_idx ON lists (user_id, id);
+
+-- Every owner of todos gets an Inbox holding all of them.
+INSERT INTO lists (user_id, name) SELECT DISTINCT user_id, 'Inbox' FROM todos;
+
+-- No foreign key, as with parent_id, so the column can be dropped again;
+-- the service checks list_id itself.
+ALTER TABLE todos ADD COLUMN list_id INTEGER NOT NULL DEFAULT 0;
+UPDATE todos SET list_id = (SELECT id FROM lists WHERE lists.user_id = todos.user_id);
+
+CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_series_id_idx;
+
+ALTER TABLE todos DROP COLUMN series_id;
+ALTER TABLE todos DROP COLUMN recurrence;
@@ -0,0 +1,6 @@
+-- A recurring todo carries an RRULE. Completing it creates the next
+-- occurrence, which links back to the first todo of the series.
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN series_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS todos_series_id_idx ON todos (series_id);
@@ -0,0 +1,19 @@
+-- A reminder is claimed by inserting it, so the unique key keeps it from
+-- being sent twice.
+CREATE TABLE IF NOT EXISTS reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    offset_seconds INTEGER NOT NULL,
+    due_at TEXT NOT NULL,
+    sent_at TEXT,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    UNIQUE (todo_id, offset_seconds, due_at)
+);
+
+-- A lease names the instance that runs a job until expires_at.
+CREATE TABLE IF NOT EXISTS leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS webhooks_user_id_id_idx;
+
+ALTER TABLE webhooks DROP COLUMN user_id;
@@ -0,0 +1,3 @@
+-- SQLite keeps times as UTC text already; this keeps the versions of the
+-- drivers in step with Postgres.
+SELECT 1;
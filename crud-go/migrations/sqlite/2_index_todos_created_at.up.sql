@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS todos_created_at_id_idx ON todos (created_at, id);
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS todos_priority_id_idx;
+DROP INDEX IF EXISTS todos_due_at_id_idx;
+DROP INDEX IF EXISTS todos_updated_at_id_idx;
+
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN updated_at;
+ALTER TABLE todos DROP COLUMN tags;
+ALTER TABLE todos DROP COLUMN due_timezone;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN priority;
+ALTER TABLE todos DROP COLUMN description;
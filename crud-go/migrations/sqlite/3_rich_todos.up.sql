@@ -0,0 +1,17 @@
+-- SQLite cannot add a column with a non-constant default, so updated_at
+-- is backfilled here and always written by the store. Tags are a JSON
+-- array of strings.
+ALTER TABLE todos ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN due_at TEXT;
+ALTER TABLE todos ADD COLUMN due_timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE todos ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN completed_at TEXT;
+
+UPDATE todos SET updated_at = created_at;
+UPDATE todos SET completed_at = created_at WHERE complete;
+
+CREATE INDEX IF NOT EXISTS todos_updated_at_id_idx ON todos (updated_at, id);
+CREATE INDEX IF NOT EXISTS todos_due_at_id_idx ON todos (COALESCE(due_at, '9999-12-31T23:59:59.999Z'), id);
+CREATE INDEX IF NOT EXISTS todos_priority_id_idx ON todos (priority, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_parent_id_idx;
+
+ALTER TABLE todos DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+-- No foreign key: SQLite can't drop a column that has one, so the store
+-- checks parent_id itself.
+ALTER TABLE todos ADD COLUMN parent_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id);
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN deleted_at TEXT;
+
+-- Only the trash listing and the purge look at trashed todos.
+CREATE INDEX IF NOT EXISTS todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- No foreign key to todos: the history outlives purged todos. before and
+-- after are the JSON of the todo.
+CREATE TABLE IF NOT EXISTS todo_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    actor TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS todo_audit_todo_id_id_idx ON todo_audit (todo_id, id);
+CREATE INDEX IF NOT EXISTS todo_audit_created_at_idx ON todo_audit (created_at);
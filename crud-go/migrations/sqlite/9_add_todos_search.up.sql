@@ -0,0 +1,3 @@
+-- SQLite searches todos without an index, see SqliteTodoRepository.SearchTodos.
+-- The migration keeps the versions of both drivers in step.
+SELECT 1;